@@ -0,0 +1,536 @@
+// Package app assembles the demo server: telemetry, store, services,
+// middleware, and routes. main builds it from the environment; options let
+// tests and alternate demo setups swap pieces without editing Build.
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"telemetry-demo/cache"
+	"telemetry-demo/config"
+	"telemetry-demo/events"
+	"telemetry-demo/handlers"
+	"telemetry-demo/identity"
+	"telemetry-demo/jobs"
+	"telemetry-demo/logging"
+	"telemetry-demo/middleware"
+	"telemetry-demo/notify"
+	"telemetry-demo/pool"
+	"telemetry-demo/probe"
+	"telemetry-demo/service"
+	"telemetry-demo/storage"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Option changes how Build assembles the application.
+type Option func(*options)
+
+type options struct {
+	store       *store.MemoryStore
+	service     service.SubscriberService
+	cache       *cache.InMemoryCache
+	middleware  []gin.HandlerFunc
+	routes      []RouteRegistrar
+	clock       func() time.Time
+	exporters   []telemetry.ExporterKind
+	sampling    telemetry.SamplingConfig
+	propagators []telemetry.PropagatorKind
+	metrics     []telemetry.MetricExporterKind
+	started     time.Time
+}
+
+// WithStore uses an existing store instead of a new empty one.
+func WithStore(s *store.MemoryStore) Option {
+	return func(o *options) { o.store = s }
+}
+
+// WithService replaces the core subscriber service. Each tier still wraps
+// it in its usual decorators.
+func WithService(svc service.SubscriberService) Option {
+	return func(o *options) { o.service = svc }
+}
+
+// WithCache backs the V2 response cache with c. The response cache is
+// enabled even if CACHE_TTL is zero.
+func WithCache(c *cache.InMemoryCache) Option {
+	return func(o *options) { o.cache = c }
+}
+
+// WithMiddleware adds router middleware after the built-in chain, so it
+// runs for every route but only once load shedding has let a request in.
+func WithMiddleware(handlers ...gin.HandlerFunc) Option {
+	return func(o *options) { o.middleware = append(o.middleware, handlers...) }
+}
+
+// WithRoutes registers extra resources under the base path, after the
+// built-in ones. Plain functions can be passed as RouteRegistrarFunc.
+func WithRoutes(registrars ...RouteRegistrar) Option {
+	return func(o *options) { o.routes = append(o.routes, registrars...) }
+}
+
+// WithClock replaces time.Now for subscriber timestamps. It has no effect
+// when WithStore supplies the store.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.clock = now }
+}
+
+// WithExporters sends spans to the given backends instead of Zipkin and
+// Jaeger.
+func WithExporters(kinds ...telemetry.ExporterKind) Option {
+	return func(o *options) { o.exporters = kinds }
+}
+
+// WithSampling chooses which traces are exported instead of
+// OTEL_TRACES_SAMPLER or the adaptive sampler.
+func WithSampling(sampling telemetry.SamplingConfig) Option {
+	return func(o *options) { o.sampling = sampling }
+}
+
+// WithPropagators reads and writes trace context in the given formats
+// instead of the ones named by OTEL_PROPAGATORS or W3C trace context and
+// baggage.
+func WithPropagators(kinds ...telemetry.PropagatorKind) Option {
+	return func(o *options) { o.propagators = kinds }
+}
+
+// WithMetricExporters sends metrics to the given backends instead of the
+// ones named by OTEL_METRICS_EXPORTER.
+func WithMetricExporters(kinds ...telemetry.MetricExporterKind) Option {
+	return func(o *options) { o.metrics = kinds }
+}
+
+// WithStartTime starts the service.startup trace at t instead of when Build
+// is called, so it also covers what ran before, such as loading config.
+func WithStartTime(t time.Time) Option {
+	return func(o *options) { o.started = t }
+}
+
+// App is a built, ready-to-run server.
+type App struct {
+	Router         *gin.Engine
+	LatencyProfile service.LatencyProfile
+	// Handler is Router behind middleware.MethodOverride. Serve Handler;
+	// Router alone ignores X-HTTP-Method-Override.
+	Handler http.Handler
+
+	closers []func()
+}
+
+// Close stops background work and flushes telemetry.
+func (a *App) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+}
+
+// Build wires the application from cfg. Callers must Close the result.
+// The whole sequence is recorded as a service.startup trace.
+func Build(cfg *config.Config, opts ...Option) (*App, error) {
+	buildStart := time.Now()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	began := buildStart
+	if !o.started.IsZero() {
+		began = o.started
+	}
+	boot := newStartup(began)
+	if began.Before(buildStart) {
+		boot.timed("startup.config_load", began, buildStart)
+	}
+
+	latencyProfile, err := service.LookupLatencyProfile(cfg.LatencyProfile)
+	if err != nil {
+		return nil, err
+	}
+	logLevel, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	a := &App{LatencyProfile: latencyProfile}
+
+	// Initialize tracing with adaptive sampling, in-process cost estimation,
+	// and a span status audit. The sampler is also registered as a processor
+	// so it can see errors, and so is the anomaly detector, which wraps
+	// whichever sampler the strategy chose to force-sample failing requests
+	// while an incident is open.
+	// Operator annotations are stamped on root spans as they start.
+	sampler := telemetry.NewAdaptiveSampler(telemetry.DefaultAdaptiveSamplerConfig())
+	costProcessor := telemetry.NewCostProcessor()
+	statusAudit := telemetry.NewStatusAuditProcessor()
+	anomalies := telemetry.NewAnomalyDetector(telemetry.DefaultAnomalyDetectorConfig())
+	annotations := telemetry.NewAnnotations()
+	// The resource records the environment and the mode the server started
+	// in; toggles show up on rejected writes and in /health. The config hash
+	// ties every span to the configuration /admin/config reports
+	resourceAttrs := []attribute.KeyValue{
+		attrs.DeploymentEnvironment(cfg.Environment),
+		attribute.Bool("app.read_only", cfg.ReadOnly),
+		attribute.String("app.config.hash", cfg.Hash()),
+	}
+	if cfg.TenantID != "" {
+		resourceAttrs = append(resourceAttrs, attrs.TenantID.String(cfg.TenantID))
+	}
+	// Probe traffic is always marked synthetic and every request with its
+	// tenant, whatever BAGGAGE_FIELDS says
+	baggageFields := slices.Clip(cfg.BaggageFields)
+	for _, key := range []attribute.Key{attrs.Synthetic, attrs.TenantID} {
+		if !slices.Contains(baggageFields, string(key)) {
+			baggageFields = append(baggageFields, string(key))
+		}
+	}
+	processors := []sdktrace.SpanProcessor{sampler, anomalies, annotations, costProcessor, statusAudit, telemetry.RequestAttributesProcessor{}, telemetry.NewBaggageProcessor(baggageFields)}
+	// Every sampled span also feeds call count and duration metrics
+	if cfg.SpanMetrics {
+		processors = append(processors, telemetry.NewSpanMetricsProcessor())
+	}
+	tracing := []telemetry.Option{
+		telemetry.WithExporter(o.exporters...),
+		telemetry.WithSampler(o.sampling),
+		telemetry.WithAdaptiveSampler(sampler),
+		telemetry.WithSamplerWrapper(anomalies.Sampler),
+		telemetry.WithPropagators(o.propagators...),
+		telemetry.WithRedaction(telemetry.RedactionConfig{
+			Attributes: cfg.PIIAttributes,
+			Mode:       telemetry.RedactionMode(cfg.PIIRedaction),
+		}),
+		telemetry.WithResource(resourceAttrs...),
+		telemetry.WithBatching(telemetry.BatchConfig{
+			MaxQueueSize:       cfg.ExportQueueSize,
+			MaxExportBatchSize: cfg.ExportBatchSize,
+			BatchTimeout:       cfg.ExportBatchTimeout,
+			ExportTimeout:      cfg.ExportTimeout,
+			BlockOnQueueFull:   cfg.ExportBlockOnFull,
+			Retry:              telemetry.RetryConfig{MaxElapsedTime: cfg.ExportRetryMaxElapsed},
+		}),
+		telemetry.WithSpanProcessors(processors...),
+	}
+	for exporter, filter := range cfg.ExporterFilters {
+		tracing = append(tracing, telemetry.WithExporterFilter(telemetry.ExporterKind(exporter), telemetry.SpanFilter(filter)))
+	}
+	// Optionally export only the traces worth looking at
+	var tailSampler *telemetry.TailSampler
+	if cfg.TailSamplingLatency > 0 {
+		tailConfig := telemetry.DefaultTailSamplerConfig()
+		tailConfig.LatencyThreshold = cfg.TailSamplingLatency
+		tailSampler = telemetry.NewTailSampler(tailConfig)
+		tracing = append(tracing, telemetry.WithTailSampler(tailSampler))
+	}
+	provider, err := telemetry.Init(context.Background(), tracing...)
+	if err != nil {
+		log.Printf("Tracing disabled: %v", err)
+	} else {
+		// Registered first so it runs last, after draining work has ended
+		// its spans
+		a.closers = append(a.closers, func() {
+			if err := provider.FlushAndShutdown(cfg.ExportFlushTimeout); err != nil {
+				log.Printf("Error shutting down tracer: %v", err)
+			}
+		})
+	}
+
+	// Metrics are exported alongside traces and describe the same resource
+	a.closers = append(a.closers, telemetry.InitMeter(telemetry.MetricsConfig{
+		Exporters:      o.metrics,
+		Resource:       resourceAttrs,
+		LatencyBuckets: cfg.LatencyBuckets,
+	}))
+	boot.timed("startup.telemetry_init", buildStart, time.Now())
+	boot.traced()
+
+	// Failures from here on end the startup trace before telemetry is
+	// shut down, so it still gets exported
+	fail := func(err error) (*App, error) {
+		boot.finish(err)
+		a.Close()
+		return nil, err
+	}
+
+	// Secrets come from Dapr or Vault when one is configured
+	vault, err := loadSecrets(boot.ctx, cfg)
+	if err != nil {
+		return fail(fmt.Errorf("load secrets: %w", err))
+	}
+	if vault != nil {
+		a.closers = append(a.closers, vault.Close)
+	}
+
+	// Optional local GeoIP table for client location enrichment
+	var geo middleware.GeoLookup
+	if cfg.GeoIPDatabase != "" {
+		_, span := boot.step("startup.geoip_load")
+		table, err := middleware.LoadGeoIPTable(cfg.GeoIPDatabase)
+		if err != nil {
+			telemetry.FailSpan(span, err, "")
+			span.End()
+			return fail(fmt.Errorf("load GeoIP table: %w", err))
+		}
+		span.End()
+		geo = table
+		log.Printf("🌍 GeoIP enrichment enabled from %s", cfg.GeoIPDatabase)
+	}
+
+	// Create in-memory store
+	_, storeSpan := boot.step("startup.store_connect")
+	memStore := o.store
+	if memStore == nil {
+		var storeOpts []store.Option
+		if o.clock != nil {
+			storeOpts = append(storeOpts, store.WithClock(o.clock))
+		}
+		memStore = store.NewMemoryStore(storeOpts...)
+	}
+	a.closers = append(a.closers, memStore.ObserveSubscribers())
+	storeSpan.End()
+
+	// Every tier shares the same business logic and simulated latency.
+	// Cross-cutting concerns are decorators: all tiers are metered and warn
+	// on slow calls, and V2 also gets its business spans from the service
+	// and refuses calls once a request's latency budget has run out
+	subscriberService := o.service
+	if subscriberService == nil {
+		subscriberService = service.NewSubscriberService(memStore, latencyProfile)
+	}
+	serviceMetrics := service.NewServiceMetrics()
+	// Log lines with a trace ID are kept for /admin/logs and debug bundles,
+	// with the same PII redacted as on exported spans
+	logging.TraceLogs.SetCapacity(cfg.LogIndexSize)
+	logging.TraceLogs.SetRedaction(telemetry.RedactionConfig{
+		Attributes: cfg.PIIAttributes,
+		Mode:       telemetry.RedactionMode(cfg.PIIRedaction),
+	}.FieldRedactor())
+	a.closers = append(a.closers, logging.TraceLogs.Observe())
+	logger := logging.ContextLogger{Logger: newLogger(cfg.LogBackend, logLevel), Baggage: baggageFields}
+	tierService := func(tier string, extra ...service.Decorator) service.SubscriberService {
+		decorators := append(extra, service.Metered(serviceMetrics, tier), service.Logged(tier, service.DefaultSlowCallThreshold, logger))
+		return service.Chain(subscriberService, decorators...)
+	}
+
+	// Create handlers
+	v0Handler := handlers.NewV0Handler(tierService("v0"))
+	v1Handler := handlers.NewV1Handler(tierService("v1"))
+
+	// Activity events are queued and persisted in batches off the request
+	// path, then periodically rolled up into engagement scores
+	ingester := events.NewIngester(memStore, events.DefaultQueueSize, events.DefaultBatchSize, events.DefaultFlushInterval)
+	aggregator := events.NewAggregator(memStore, events.DefaultAggregationInterval)
+	eventsHandler := handlers.NewEventsHandler(memStore, ingester, aggregator)
+	a.closers = append(a.closers, func() {
+		aggregator.Stop()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ingester.Close(ctx)
+	})
+
+	// Setup Gin router
+	gin.SetMode(cfg.GinMode)
+	router := gin.New()
+	router.Use(middleware.AccessLog(), gin.Recovery())
+	a.Router = router
+	a.Handler = middleware.MethodOverride(router, middleware.DefaultOverridableMethods...)
+
+	// Client enrichment runs for every request, including unmatched routes,
+	// and resolves the locale error messages are translated into
+	router.Use(middleware.ClientInfo(geo), middleware.UserAgent(), middleware.Locale())
+
+	// Per-API-key usage is recorded for every request, including shed ones
+	usageTracker := middleware.NewUsageTracker()
+	router.Use(usageTracker.Middleware())
+
+	// Every caller is bucketed into an experiment variant that tags its
+	// spans, access log line, and per-variant stats
+	experiment := middleware.NewExperimentAssigner(cfg.ExperimentName, cfg.ExperimentVariants)
+	router.Use(experiment.Middleware())
+
+	// Every request is attributed to a tenant, through its baggage, before
+	// anything that traces, logs, or caches it
+	router.Use(middleware.NewTenantResolver(cfg.TenantHeader, cfg.TenantJWTClaim, cfg.TenantID, cfg.TenantTiers).Middleware())
+
+	// OPTIONS is answered from the route table, filled once routes exist
+	routeTable := middleware.NewRouteTable()
+	router.Use(middleware.MetadataMethods(routeTable))
+
+	// Shed API traffic under overload; health, readiness, admin, and debug
+	// routes stay exempt so operators can still observe the server. Change
+	// watches are exempt too: a long poll spends its time parked waiting,
+	// so counting it would let idle watchers shed the whole API
+	shedder := middleware.NewLoadShedder(cfg.MaxInFlight, cfg.ShedRetryAfter,
+		cfg.BasePath+"/health", cfg.BasePath+"/ready", cfg.BasePath+"/admin", cfg.BasePath+"/debug",
+		cfg.BasePath+"/v1/subscribers/watch")
+	router.Use(shedder.Middleware())
+
+	// Read-only mode rejects writes; admin and auth stay writable so an
+	// operator can switch it back off
+	readOnly := middleware.NewReadOnlyGuard(cfg.ReadOnly, cfg.BasePath+"/admin", cfg.BasePath+"/auth")
+	router.Use(readOnly.Middleware())
+
+	// Injected middleware sees every admitted request
+	router.Use(o.middleware...)
+
+	// Unknown routes and methods get structured, traced responses
+	fallbackHandler := handlers.NewFallbackHandler(routeTable)
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(fallbackHandler.NotFound)
+	router.NoMethod(fallbackHandler.MethodNotAllowed)
+
+	// Only trust X-Forwarded-For from known proxies so client IPs are accurate
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fail(fmt.Errorf("trusted proxies: %w", err))
+	}
+
+	// V2 Routes - Middleware Magic
+	serialization := telemetry.NewSerializationRecorder()
+	// V2 writes are delivered to the notification service, handing on the
+	// caller. Its closer is registered first so queued writes drain into it
+	v2Decorators := []service.Decorator{service.Traced(), service.Budgeted()}
+	if cfg.NotifyURL != "" {
+		var propagator identity.Propagator
+		switch cfg.NotifyIdentity {
+		case "signed":
+			propagator = identity.NewSignedToken([]byte(cfg.IdentityTokenSecret), "telemetry-demo", identity.DefaultTokenTTL)
+		case "dapr":
+			propagator = identity.DaprAPIToken(cfg.DaprAPIToken)
+		}
+		notifier := notify.New(
+			notify.Config{URL: cfg.NotifyURL, Secret: []byte(cfg.NotifyWebhookSecret), Identity: propagator},
+			pool.New("notify", notify.DefaultWorkers, notify.DefaultQueueSize),
+		)
+		a.closers = append(a.closers, notifier.Close)
+		v2Decorators = append(v2Decorators, service.Notified(notifier))
+	}
+	// In async write mode creates are accepted with 202 and stored by a
+	// worker pool, each in a trace linked to the request that accepted it
+	var writes *jobs.Tracker
+	if cfg.AsyncWrites {
+		writes = jobs.NewTracker(pool.New("writes", jobs.DefaultWorkers, jobs.DefaultQueueSize))
+		a.closers = append(a.closers, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := writes.Drain(ctx); err != nil {
+				log.Printf("Error draining write queue: %v", err)
+			}
+		})
+	}
+	v2Handler := handlers.NewV2Handler(tierService("v2", v2Decorators...), serialization, logger, cfg.LatencyBudget, writes)
+
+	// V2 GETs are served from cache until any write invalidates them
+	_, cacheSpan := boot.step("startup.cache_init")
+	var responseCache *middleware.ResponseCache
+	var invalidations *cache.InvalidationBus
+	responseStore := o.cache
+	if responseStore == nil && cfg.CacheTTL > 0 {
+		responseStore = cache.NewInMemoryCache("http_response",
+			cache.WithDefaultTTL(cfg.CacheTTL),
+			cache.WithTTLJitter(cfg.CacheTTLJitter),
+			cache.WithCleanupInterval(cfg.CacheSweepInterval),
+		)
+		a.closers = append(a.closers, responseStore.Close)
+	}
+	if responseStore != nil {
+		// Stale-while-revalidate routes are configured without the base path
+		staleFor := make(map[string]time.Duration, len(cfg.StaleWhileRevalidate))
+		for route, window := range cfg.StaleWhileRevalidate {
+			staleFor[cfg.BasePath+route] = window
+		}
+		refreshers := pool.New("http_cache", middleware.RevalidationWorkers, middleware.RevalidationQueueSize)
+		a.closers = append(a.closers, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := refreshers.Drain(ctx); err != nil {
+				log.Printf("Error draining cache revalidations: %v", err)
+			}
+		})
+		responseCache = middleware.NewResponseCache(responseStore,
+			middleware.WithStaleWhileRevalidate(router, staleFor, refreshers),
+			middleware.WithBreaker(cache.BreakerConfig{FailureThreshold: cfg.CacheBreakerThreshold, Cooldown: cfg.CacheBreakerCooldown}),
+		)
+		invalidations = cache.NewInvalidationBus("")
+		invalidations.Subscribe(responseStore)
+		memStore.OnChange(func() {
+			invalidations.Publish(context.Background(), responseStore.Name(), "")
+		})
+	}
+
+	cacheSpan.SetAttributes(attribute.Bool("cache.enabled", responseStore != nil))
+	cacheSpan.End()
+
+	// Identical concurrent V2 GETs that miss the cache share one execution
+	var coalescer *middleware.RequestCoalescer
+	if cfg.CoalesceGets {
+		coalescer = middleware.NewRequestCoalescer()
+	}
+
+	// Admin Routes - Telemetry introspection, optionally behind OIDC login
+	// Storage usage is measured on a schedule, never on the request path
+	storageReport := storage.NewReporter(memStore, []*cache.InMemoryCache{responseStore}, cfg.StorageReportInterval)
+	a.closers = append(a.closers, storageReport.Stop)
+	// Synthetic canary traffic against this server's own API
+	var prober *probe.Prober
+	if cfg.ProbeInterval > 0 {
+		prober = probe.New(cfg.ProbeURL, cfg.BasePath, cfg.ProbeInterval)
+		a.closers = append(a.closers, prober.Stop)
+		log.Printf("🩺 Probing %s%s/v2 every %s", cfg.ProbeURL, cfg.BasePath, cfg.ProbeInterval)
+	}
+	adminHandler := handlers.NewAdminHandler(costProcessor, sampler, usageTracker, experiment, serialization, serviceMetrics, responseCache, invalidations, coalescer, readOnly, statusAudit, anomalies, annotations, tailSampler, storageReport, prober, cfg)
+	var oidcAuth *middleware.OIDCAuth
+	if cfg.OIDCIssuer != "" {
+		ctx, span := boot.step("startup.oidc_discovery")
+		oidcAuth, err = middleware.NewOIDCAuth(ctx, middleware.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			LoginPath:    cfg.BasePath + "/auth/login",
+		})
+		if err != nil {
+			telemetry.FailSpan(span, err, "")
+			span.End()
+			return fail(fmt.Errorf("OIDC setup: %w", err))
+		}
+		span.End()
+	}
+
+	// Every resource registers its routes under the configured base path
+	// (empty by default), built-in resources first, then injected ones
+	_, routesSpan := boot.step("startup.route_registration")
+	registrars := []RouteRegistrar{
+		healthRoutes{shedder: shedder, readOnly: readOnly, vault: vault, cache: responseCache},
+		problemRoutes{},
+		v0Routes{handler: v0Handler},
+		v1Routes{handler: v1Handler, events: eventsHandler, watch: handlers.NewWatchHandler(memStore, cfg.WatchMaxWait)},
+		v2Routes{handler: v2Handler, cache: responseCache, coalescer: coalescer},
+		daprRoutes{handler: handlers.NewDaprHandler(eventsHandler, cfg.BasePath), v2: v2Handler},
+		uiRoutes{handler: handlers.NewUIHandler(tierService("ui", service.Traced()), logger)},
+	}
+	if oidcAuth != nil {
+		registrars = append(registrars, authRoutes{oidc: oidcAuth})
+	}
+	registrars = append(registrars,
+		adminRoutes{handler: adminHandler, synthetic: handlers.NewSyntheticHandler(), oidc: oidcAuth},
+		debugRoutes{handler: adminHandler},
+	)
+	registrars = append(registrars, o.routes...)
+
+	api := router.Group(cfg.BasePath)
+	for _, registrar := range registrars {
+		registrar.Register(api)
+	}
+
+	routeTable.Load(router.Routes())
+	routesSpan.SetAttributes(attribute.Int("routes.count", len(router.Routes())))
+	routesSpan.End()
+
+	boot.finish(nil)
+	return a, nil
+}
@@ -0,0 +1,21 @@
+package app
+
+import (
+	"log/slog"
+	"os"
+
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
+)
+
+// newLogger returns the logger V2 handlers and slow call warnings write
+// through, built on the configured backend and dropping lines below level.
+// Both write text to stderr and keep lines with a trace ID in
+// logging.TraceLogs.
+func newLogger(backend string, level logging.Level) logging.Logger {
+	if backend == "slog" {
+		handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logging.SlogLevel(level)})
+		return logging.NewSlog(logging.IndexHandler(handler, logging.TraceLogs))
+	}
+	return logruslog.New(level)
+}
@@ -0,0 +1,252 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/propagation"
+	"telemetry-demo/handlers"
+	"telemetry-demo/middleware"
+	"telemetry-demo/problem"
+	"telemetry-demo/secrets"
+)
+
+// RouteRegistrar adds one resource's routes to the router. Build hands
+// every registrar the base path group, so a new resource plugs in through
+// WithRoutes instead of another block in Build. Registrars bring their own
+// middleware and take any gin.IRouter, so a demo can also mount one on its
+// own gin.Engine without copying the route list.
+type RouteRegistrar interface {
+	Register(r gin.IRouter)
+}
+
+// RouteRegistrarFunc lets an ordinary function act as a RouteRegistrar.
+type RouteRegistrarFunc func(r gin.IRouter)
+
+func (f RouteRegistrarFunc) Register(r gin.IRouter) {
+	f(r)
+}
+
+// Read routes also answer HEAD with the same handler chain.
+var readMethods = []string{http.MethodGet, http.MethodHead}
+
+// healthRoutes serves liveness and readiness checks.
+type healthRoutes struct {
+	shedder  *middleware.LoadShedder
+	readOnly *middleware.ReadOnlyGuard
+	// vault is nil unless secrets come from Vault
+	vault *secrets.Vault
+	// cache is nil when response caching is off
+	cache *middleware.ResponseCache
+}
+
+func (h healthRoutes) Register(r gin.IRouter) {
+	r.Match(readMethods, "/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "healthy", "read_only": h.readOnly.Enabled()})
+	})
+
+	// Readiness check - reports not ready while requests are being shed.
+	// An expired Vault token is reported but doesn't fail readiness, since
+	// secrets were already loaded at startup, and neither does a degraded
+	// response cache, since requests skip it
+	r.Match(readMethods, "/ready", func(c *gin.Context) {
+		stats := h.shedder.Stats()
+		body := gin.H{"status": "ready", "load": stats, "read_only": h.readOnly.Enabled()}
+		if h.vault != nil {
+			body["vault"] = h.vault.Status()
+		}
+		if h.cache != nil {
+			body["cache"] = h.cache.Degradation()
+		}
+		if stats.Overloaded {
+			body["status"] = "overloaded"
+			c.JSON(http.StatusServiceUnavailable, body)
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+}
+
+// problemRoutes documents the error codes in problem+json bodies.
+type problemRoutes struct{}
+
+func (problemRoutes) Register(r gin.IRouter) {
+	r.Match(readMethods, "/problems", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"problems": problem.Catalog()})
+	})
+}
+
+// v0Routes - Basic Logging
+type v0Routes struct {
+	handler *handlers.V0Handler
+}
+
+func (v v0Routes) Register(r gin.IRouter) {
+	v0 := r.Group("/v0")
+	v0.POST("/subscribers", v.handler.CreateSubscriber)
+	v0.Match(readMethods, "/subscribers", v.handler.GetSubscribers)
+	v0.Match(readMethods, "/subscribers/:id", v.handler.GetSubscriber)
+	v0.PUT("/subscribers/:id", v.handler.UpdateSubscriber)
+	v0.DELETE("/subscribers/:id", v.handler.DeleteSubscriber)
+}
+
+// v1Routes - Manual Tracing, plus activity event ingestion and the change
+// watch
+type v1Routes struct {
+	handler *handlers.V1Handler
+	events  *handlers.EventsHandler
+	watch   *handlers.WatchHandler
+}
+
+func (v v1Routes) Register(r gin.IRouter) {
+	v1 := r.Group("/v1")
+	v1.POST("/subscribers", v.handler.CreateSubscriber)
+	v1.Match(readMethods, "/subscribers", v.handler.GetSubscribers)
+	v1.GET("/subscribers/watch", v.watch.WatchSubscribers)
+	v1.Match(readMethods, "/subscribers/:id", v.handler.GetSubscriber)
+	v1.PUT("/subscribers/:id", v.handler.UpdateSubscriber)
+	v1.DELETE("/subscribers/:id", v.handler.DeleteSubscriber)
+	v1.POST("/events", v.events.IngestEvents)
+	v1.Match(readMethods, "/events/stats", v.events.GetEventStats)
+}
+
+// v2Routes - Middleware Magic. The group gets automatic HTTP tracing and,
+// when configured, the response cache and request coalescing.
+type v2Routes struct {
+	handler   *handlers.V2Handler
+	cache     *middleware.ResponseCache
+	coalescer *middleware.RequestCoalescer
+}
+
+func (v v2Routes) Register(r gin.IRouter) {
+	v2 := r.Group("/v2")
+	v2.Use(otelgin.Middleware("telemetry-demo"), middleware.HTTPMetrics()) // Automatic HTTP tracing and metrics for V2 only
+	if v.cache != nil {
+		v2.Use(v.cache.Middleware())
+	}
+	if v.coalescer != nil {
+		v2.Use(v.coalescer.Middleware())
+	}
+
+	v2.POST("/subscribers", v.handler.CreateSubscriber)
+	v2.Match(readMethods, "/subscribers", v.handler.GetSubscribers)
+	v2.Match(readMethods, "/subscribers/batch", v.handler.GetSubscribersBatch)
+	v2.Match(readMethods, "/subscribers/count", v.handler.CountSubscribers)
+	v2.Match(readMethods, "/subscribers/export", v.handler.ExportSubscribers)
+	v2.Match(readMethods, "/subscribers/:id", v.handler.GetSubscriber)
+	v2.Match(readMethods, "/subscribers/:id/full", v.handler.GetSubscriberFull)
+	v2.PUT("/subscribers/:id", v.handler.UpdateSubscriber)
+	v2.DELETE("/subscribers/:id", v.handler.DeleteSubscriber)
+	v2.Match(readMethods, "/jobs/:id", v.handler.GetJob)
+}
+
+// uiRoutes - HTML demo page, traced like V2 so browser spans can join
+// the server's traces
+type uiRoutes struct {
+	handler *handlers.UIHandler
+}
+
+func (u uiRoutes) Register(r gin.IRouter) {
+	ui := r.Group("/ui")
+	ui.Use(otelgin.Middleware("telemetry-demo"), middleware.HTTPMetrics())
+	ui.Match(readMethods, "/subscribers", u.handler.ListSubscribers)
+	ui.POST("/subscribers", u.handler.CreateSubscriber)
+}
+
+// daprRoutes serves the app to its Dapr sidecar: pub/sub subscriptions and
+// service invocation of the subscriber API. The sidecar always sends W3C
+// trace context, so it is extracted here whatever the global propagator.
+type daprRoutes struct {
+	handler *handlers.DaprHandler
+	v2      *handlers.V2Handler
+}
+
+func (d daprRoutes) Register(r gin.IRouter) {
+	dapr := r.Group("/dapr")
+	dapr.Use(
+		otelgin.Middleware("telemetry-demo", otelgin.WithPropagators(propagation.TraceContext{})),
+		middleware.DaprCaller(),
+		middleware.HTTPMetrics(),
+	)
+
+	dapr.GET("/subscribe", d.handler.Subscribe)
+	dapr.POST("/events/activity", d.handler.ReceiveActivity)
+
+	// Invoked as method "dapr/subscribers" and so on
+	dapr.POST("/subscribers", d.v2.CreateSubscriber)
+	dapr.Match(readMethods, "/subscribers", d.v2.GetSubscribers)
+	dapr.Match(readMethods, "/subscribers/:id", d.v2.GetSubscriber)
+	dapr.PUT("/subscribers/:id", d.v2.UpdateSubscriber)
+	dapr.DELETE("/subscribers/:id", d.v2.DeleteSubscriber)
+	dapr.Match(readMethods, "/jobs/:id", d.v2.GetJob)
+}
+
+// authRoutes serves the OIDC login flow.
+type authRoutes struct {
+	oidc *middleware.OIDCAuth
+}
+
+func (a authRoutes) Register(r gin.IRouter) {
+	auth := r.Group("/auth")
+	auth.GET("/login", a.oidc.Login)
+	auth.GET("/callback", a.oidc.Callback)
+	auth.POST("/logout", a.oidc.Logout)
+}
+
+// adminRoutes - Telemetry introspection and synthetic failures, behind
+// OIDC login when it is configured.
+type adminRoutes struct {
+	handler   *handlers.AdminHandler
+	synthetic *handlers.SyntheticHandler
+	oidc      *middleware.OIDCAuth
+}
+
+func (a adminRoutes) Register(r gin.IRouter) {
+	admin := r.Group("/admin")
+	if a.oidc != nil {
+		admin.Use(a.oidc.Middleware())
+	}
+
+	admin.Match(readMethods, "/config", a.handler.GetConfig)
+	admin.Match(readMethods, "/telemetry/cost", a.handler.GetTelemetryCost)
+	admin.Match(readMethods, "/usage", a.handler.GetUsage)
+	admin.Match(readMethods, "/experiments", a.handler.GetExperiment)
+	admin.Match(readMethods, "/serialization", a.handler.GetSerialization)
+	admin.Match(readMethods, "/service", a.handler.GetServiceCalls)
+	admin.Match(readMethods, "/cache", a.handler.GetCacheStats)
+	admin.PUT("/cache/outage", a.handler.SetCacheOutage)
+	admin.Match(readMethods, "/coalescing", a.handler.GetCoalescingStats)
+	admin.Match(readMethods, "/storage", a.handler.GetStorage)
+	admin.Match(readMethods, "/probe", a.handler.GetProbe)
+	admin.Match(readMethods, "/read-only", a.handler.GetReadOnly)
+	admin.PUT("/read-only", a.handler.SetReadOnly)
+	admin.Match(readMethods, "/incidents", a.handler.GetIncidents)
+	admin.Match(readMethods, "/incidents/:id", a.handler.GetIncident)
+	admin.Match(readMethods, "/traces/:traceID/bundle", a.handler.GetTraceBundle)
+	admin.Match(readMethods, "/logs", a.handler.GetLogs)
+	admin.POST("/annotations", a.handler.CreateAnnotation)
+	admin.Match(readMethods, "/annotations", a.handler.GetAnnotations)
+	admin.POST("/annotations/:id/end", a.handler.EndAnnotation)
+
+	// Synthetic failures - traced like V2 so each signature is realistic
+	admin.Match(readMethods, "/synthetic", a.synthetic.GetCatalog)
+	synthetic := admin.Group("/synthetic")
+	synthetic.Use(otelgin.Middleware("telemetry-demo"), middleware.HTTPMetrics())
+	synthetic.GET("/:failure", a.synthetic.Trigger)
+}
+
+// debugRoutes - Live sampling decisions
+type debugRoutes struct {
+	handler *handlers.AdminHandler
+}
+
+func (d debugRoutes) Register(r gin.IRouter) {
+	debug := r.Group("/debug")
+	debug.Match(readMethods, "/sampling", d.handler.GetSamplingReport)
+	debug.Match(readMethods, "/tail-sampling", d.handler.GetTailSampling)
+	debug.Match(readMethods, "/span-status", d.handler.GetSpanStatusAudit)
+	debug.Match(readMethods, "/cache-sweeps", d.handler.GetCacheSweeps)
+	debug.Match(readMethods, "/goroutines", d.handler.GetGoroutines)
+	debug.Match(readMethods, "/exemplars", d.handler.GetExemplars)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"telemetry-demo/config"
+	"telemetry-demo/secrets"
+	"telemetry-demo/telemetry"
+)
+
+// loadSecrets replaces the secret settings cfg needs with values from the
+// configured secrets provider, falling back to what the environment
+// already provided. It runs once tracing is up, so every fetch lands in a
+// startup trace. With the vault provider it also returns the Vault source,
+// which keeps renewing its token until closed.
+func loadSecrets(ctx context.Context, cfg *config.Config) (*secrets.Vault, error) {
+	ctx, span := otel.Tracer("telemetry-demo/app").Start(ctx, "load_secrets")
+	defer span.End()
+
+	var vault *secrets.Vault
+	var resolver *secrets.Resolver
+	switch cfg.SecretsProvider {
+	case "vault":
+		var err error
+		vault, err = secrets.NewVault(ctx, secrets.VaultConfig{
+			Address: cfg.VaultAddr,
+			Token:   cfg.VaultToken,
+			Mount:   cfg.VaultMount,
+			Path:    cfg.VaultPath,
+		})
+		if err != nil {
+			telemetry.FailSpan(span, err, "")
+			return nil, err
+		}
+		resolver = secrets.NewResolver(vault, secrets.Env{})
+	case "dapr":
+		resolver = secrets.NewResolver(secrets.NewDapr(cfg.SecretStore, cfg.DaprHTTPPort), secrets.Env{})
+	default:
+		resolver = secrets.NewResolver(secrets.Env{})
+	}
+
+	if cfg.OIDCIssuer != "" {
+		secret, err := resolver.Get(ctx, "OIDC_CLIENT_SECRET")
+		if err != nil {
+			err = fmt.Errorf("OIDC_ISSUER is set but no client secret was found in %s: %w", resolver.Names(), err)
+			telemetry.FailSpan(span, err, "")
+			if vault != nil {
+				vault.Close()
+			}
+			return nil, err
+		}
+		cfg.OIDCClientSecret = secret
+		log.Printf("🔑 Secrets loaded from %s", resolver.Names())
+	}
+
+	// Webhook deliveries are signed when a secret exists; the identity
+	// they hand on needs its own
+	if cfg.NotifyURL != "" {
+		if secret, err := resolver.Get(ctx, "NOTIFY_WEBHOOK_SECRET"); err == nil {
+			cfg.NotifyWebhookSecret = secret
+		}
+		var required string
+		var target *string
+		switch cfg.NotifyIdentity {
+		case "signed":
+			required, target = "IDENTITY_TOKEN_SECRET", &cfg.IdentityTokenSecret
+		case "dapr":
+			required, target = "DAPR_API_TOKEN", &cfg.DaprAPIToken
+		}
+		if target != nil {
+			secret, err := resolver.Get(ctx, required)
+			if err != nil {
+				err = fmt.Errorf("NOTIFY_IDENTITY is %s but no %s was found in %s: %w", cfg.NotifyIdentity, required, resolver.Names(), err)
+				telemetry.FailSpan(span, err, "")
+				if vault != nil {
+					vault.Close()
+				}
+				return nil, err
+			}
+			if cfg.NotifyIdentity == "signed" {
+				err = config.CheckIdentityTokenSecret(secret)
+			}
+			if err != nil {
+				telemetry.FailSpan(span, err, "")
+				if vault != nil {
+					vault.Close()
+				}
+				return nil, err
+			}
+			*target = secret
+		}
+	}
+	return vault, nil
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+)
+
+var (
+	appMeter        = telemetry.Meter("telemetry-demo/app")
+	startupDuration = telemetry.Float64Histogram(appMeter, "service.startup.duration", "ms", "Time from process start until the server was ready to serve, by outcome")
+)
+
+// startupStep is an initialization step timed before tracing was up.
+type startupStep struct {
+	name       string
+	start, end time.Time
+}
+
+// startup records Build as one service.startup trace with a child span per
+// initialization step, so every run starts with a trace and a slow
+// dependency shows up as a long step. Steps that run before telemetry.Init
+// are timed and turned into backdated spans once tracing is up.
+type startup struct {
+	began   time.Time
+	pending []startupStep
+	ctx     context.Context
+	span    trace.Span
+}
+
+func newStartup(began time.Time) *startup {
+	return &startup{began: began, ctx: context.Background()}
+}
+
+// timed records a step that ran before tracing was installed.
+func (s *startup) timed(name string, start, end time.Time) {
+	s.pending = append(s.pending, startupStep{name: name, start: start, end: end})
+}
+
+// traced starts the service.startup span, backdated to when startup began,
+// with the steps timed so far as its first children.
+func (s *startup) traced() {
+	tracer := otel.Tracer("telemetry-demo/app")
+	s.ctx, s.span = tracer.Start(context.Background(), "service.startup", trace.WithTimestamp(s.began))
+	for _, step := range s.pending {
+		_, span := tracer.Start(s.ctx, step.name, trace.WithTimestamp(step.start))
+		span.End(trace.WithTimestamp(step.end))
+	}
+	s.pending = nil
+}
+
+// step starts the span of the next initialization step. The caller ends it.
+func (s *startup) step(name string) (context.Context, trace.Span) {
+	return otel.Tracer("telemetry-demo/app").Start(s.ctx, name)
+}
+
+// finish ends the startup trace, failed when err isn't nil, and records
+// the total boot time. It must run before the tracer is shut down.
+func (s *startup) finish(err error) {
+	elapsed := time.Since(s.began)
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		telemetry.FailSpan(s.span, err, "")
+	}
+	s.span.SetAttributes(attribute.Float64("startup.duration_ms", telemetry.Milliseconds(elapsed)))
+	startupDuration.Record(s.ctx, telemetry.Milliseconds(elapsed), metric.WithAttributes(attribute.String("startup.outcome", outcome)))
+	s.span.End()
+
+	if err == nil {
+		log.Printf("⏱️  Started in %s", elapsed.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,116 @@
+// Package budget carries a request's latency budget from the handler down
+// through the service and store layers. The handler allocates the total;
+// every layer checks in before starting work, recording what is left on
+// its span, and refuses once nothing is. A single trace then shows where
+// the time went and which layer gave up.
+//
+// Budgets are cooperative: they don't cancel the request's context, so
+// work that has already started runs to completion.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+)
+
+// Layers that check in with a budget, the budget.layer attribute.
+const (
+	LayerHandler = "handler"
+	LayerService = "service"
+	LayerStore   = "store"
+)
+
+// ErrExhausted is returned by Check and Err once the budget has run out,
+// and by every service and store call refused for it. FailSpan classifies
+// it as telemetry.ErrorBudgetExhausted.
+var ErrExhausted = telemetry.WithErrorType(errors.New("latency budget exhausted"), telemetry.ErrorBudgetExhausted)
+
+var (
+	budgetMeter     = telemetry.Meter("telemetry-demo/budget")
+	budgetExhausted = telemetry.Int64Counter(budgetMeter, "budget.exhausted", "{request}", "Requests refused for running out of latency budget, by the layer that refused them")
+)
+
+// Budget is the time a request may still spend.
+type Budget struct {
+	total    time.Duration
+	deadline time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+type contextKey struct{}
+
+// Start allocates total to the request in ctx and records it on ctx's span
+// as budget.total_ms.
+func Start(ctx context.Context, total time.Duration) (context.Context, *Budget) {
+	b := &Budget{total: total, deadline: time.Now().Add(total)}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("budget.total_ms", telemetry.Milliseconds(total)))
+	return context.WithValue(ctx, contextKey{}, b), b
+}
+
+// From returns the budget Start put in ctx, or nil.
+func From(ctx context.Context) *Budget {
+	b, _ := ctx.Value(contextKey{}).(*Budget)
+	return b
+}
+
+func (b *Budget) Total() time.Duration { return b.total }
+
+// Remaining is how much of the budget is left, negative once overrun.
+func (b *Budget) Remaining() time.Duration { return time.Until(b.deadline) }
+
+// Check is called by layer before it starts work. It records the budget
+// left as budget.<layer>.remaining_ms on ctx's span and returns an error
+// wrapping ErrExhausted when nothing is left, or when an earlier layer
+// already refused. Without a budget in ctx it always returns nil.
+func Check(ctx context.Context, layer string) error {
+	b := From(ctx)
+	if b == nil {
+		return nil
+	}
+
+	remaining := b.Remaining()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Float64("budget."+layer+".remaining_ms", telemetry.Milliseconds(max(remaining, 0))))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	if remaining > 0 {
+		return nil
+	}
+
+	b.err = fmt.Errorf("%w: %s refused work %s over a %s budget", ErrExhausted, layer, (-remaining).Round(time.Millisecond), b.total)
+	span.AddEvent("budget.exhausted", trace.WithAttributes(
+		attribute.String("budget.layer", layer),
+		attribute.Float64("budget.overrun_ms", telemetry.Milliseconds(-remaining)),
+	))
+	budgetExhausted.Add(ctx, 1, metric.WithAttributes(attribute.String("budget.layer", layer)))
+	return b.err
+}
+
+// Err returns the error of the first layer that refused work on ctx's
+// budget, or nil.
+func Err(ctx context.Context) error {
+	b := From(ctx)
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.err
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// BreakerState is where a Breaker is in its degraded-mode cycle.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every cache call through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen skips the cache until the cooldown has passed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets one probe call through to test the backend.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerConfig tunes when a Breaker trips and how long it stays open.
+type BreakerConfig struct {
+	// FailureThreshold is how many consecutive failed calls trip it.
+	FailureThreshold int
+	// Cooldown is how long the cache is skipped before a probe.
+	Cooldown time.Duration
+}
+
+// DefaultBreakerConfig trips after 3 consecutive failures and probes again
+// after 30 seconds.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{FailureThreshold: 3, Cooldown: 30 * time.Second}
+}
+
+// BreakerStatus reports a Breaker's state and history.
+type BreakerStatus struct {
+	State               BreakerState `json:"state"`
+	Degraded            bool         `json:"degraded"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Trips               int64        `json:"trips"`
+	Recoveries          int64        `json:"recoveries"`
+	Skipped             int64        `json:"skipped_calls"`
+	DegradedSince       *time.Time   `json:"degraded_since,omitempty"`
+	NextProbe           *time.Time   `json:"next_probe,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	FailureThreshold    int          `json:"failure_threshold"`
+	CooldownMs          int64        `json:"cooldown_ms"`
+}
+
+var cacheBreakerTransitions = telemetry.Int64Counter(cacheMeter, "cache.breaker.transitions", "{transition}", "Cache circuit breaker state changes, by cache.name and the state entered")
+
+// Breaker puts a cache in degraded mode when its backend keeps failing:
+// after FailureThreshold consecutive failures callers skip the cache for
+// Cooldown, then one probe call decides whether it has recovered. State
+// changes are logged, counted, and added as events to the caller's span.
+type Breaker struct {
+	name   string
+	config BreakerConfig
+
+	mu            sync.Mutex
+	state         BreakerState
+	failures      int
+	probing       bool
+	degradedSince time.Time
+	openedAt      time.Time
+	trips         int64
+	recoveries    int64
+	skipped       int64
+	lastError     string
+}
+
+func NewBreaker(name string, config BreakerConfig) *Breaker {
+	defaults := DefaultBreakerConfig()
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = defaults.Cooldown
+	}
+	return &Breaker{name: name, config: config, state: BreakerClosed}
+}
+
+// Allow reports whether the next cache call should be made. Once an open
+// breaker's cooldown has passed it lets a single probe through.
+func (b *Breaker) Allow(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) >= b.config.Cooldown {
+			b.transition(ctx, BreakerHalfOpen)
+			b.probing = true
+			return true
+		}
+	case BreakerHalfOpen:
+		if !b.probing {
+			b.probing = true
+			return true
+		}
+	}
+	b.skipped++
+	return false
+}
+
+// Record reports the outcome of an allowed cache call and returns true
+// when it ended degraded mode.
+func (b *Breaker) Record(ctx context.Context, err error) (recovered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		if b.state == BreakerHalfOpen {
+			b.probing = false
+			b.recoveries++
+			log.Printf("✅ Cache %s recovered after %s degraded", b.name, time.Since(b.degradedSince).Round(time.Millisecond))
+			b.transition(ctx, BreakerClosed)
+			b.degradedSince = time.Time{}
+			return true
+		}
+		return false
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	switch {
+	case b.state == BreakerHalfOpen:
+		// The probe failed: stay degraded for another cooldown
+		b.probing = false
+		b.openedAt = time.Now()
+		b.transition(ctx, BreakerOpen)
+	case b.state == BreakerClosed && b.failures >= b.config.FailureThreshold:
+		b.trips++
+		b.openedAt = time.Now()
+		b.degradedSince = b.openedAt
+		log.Printf("⚠️  Cache %s degraded after %d consecutive failures (%v); skipping it for %s", b.name, b.failures, err, b.config.Cooldown)
+		b.transition(ctx, BreakerOpen)
+	}
+	return false
+}
+
+// Degraded reports whether callers are currently skipping the cache.
+func (b *Breaker) Degraded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state != BreakerClosed
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		Degraded:            b.state != BreakerClosed,
+		ConsecutiveFailures: b.failures,
+		Trips:               b.trips,
+		Recoveries:          b.recoveries,
+		Skipped:             b.skipped,
+		LastError:           b.lastError,
+		FailureThreshold:    b.config.FailureThreshold,
+		CooldownMs:          b.config.Cooldown.Milliseconds(),
+	}
+	if status.Degraded {
+		since := b.degradedSince
+		next := b.openedAt.Add(b.config.Cooldown)
+		status.DegradedSince = &since
+		status.NextProbe = &next
+	}
+	return status
+}
+
+// transition moves to state, recording the change on the caller's span.
+// b.mu must be held.
+func (b *Breaker) transition(ctx context.Context, state BreakerState) {
+	from := b.state
+	b.state = state
+
+	trace.SpanFromContext(ctx).AddEvent("cache.breaker.transition", trace.WithAttributes(
+		attrs.CacheName(b.name),
+		attribute.String("cache.breaker.from", string(from)),
+		attribute.String("cache.breaker.to", string(state)),
+	))
+	cacheBreakerTransitions.Add(ctx, 1, metric.WithAttributes(
+		attrs.CacheName(b.name),
+		attribute.String("cache.breaker.state", string(state)),
+	))
+}
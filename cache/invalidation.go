@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Invalidation asks every instance to drop the entries under Prefix in the
+// named cache. An empty Prefix drops the whole cache.
+type Invalidation struct {
+	Origin    string    `json:"origin"`
+	Cache     string    `json:"cache"`
+	Prefix    string    `json:"prefix"`
+	Published time.Time `json:"published"`
+}
+
+// InvalidationStats counts invalidation fan-out.
+type InvalidationStats struct {
+	Origin         string    `json:"origin"`
+	Subscribers    int       `json:"subscribers"`
+	Published      int64     `json:"published"`
+	Delivered      int64     `json:"delivered"`
+	EntriesDropped int64     `json:"entries_dropped"`
+	LastPublished  time.Time `json:"last_published"`
+}
+
+// InvalidationBus fans write-through invalidations out to subscribed caches
+// so no cache keeps serving data a write has replaced.
+//
+// Delivery is in-process: every subscribed cache in this instance receives
+// each invalidation. A cross-instance transport (Redis pub/sub, a Dapr topic)
+// would publish the same Invalidation message and call Deliver on receipt;
+// the spans already link publisher and receiver for that case.
+type InvalidationBus struct {
+	origin string
+	tracer trace.Tracer
+
+	mu          sync.RWMutex
+	subscribers map[string][]*InMemoryCache
+
+	published     atomic.Int64
+	delivered     atomic.Int64
+	dropped       atomic.Int64
+	lastPublished atomic.Int64
+}
+
+// NewInvalidationBus creates a bus identified by origin. An empty origin
+// defaults to hostname/pid, which is unique per instance.
+func NewInvalidationBus(origin string) *InvalidationBus {
+	if origin == "" {
+		host, _ := os.Hostname()
+		origin = fmt.Sprintf("%s/%d", host, os.Getpid())
+	}
+
+	return &InvalidationBus{
+		origin:      origin,
+		tracer:      otel.Tracer("telemetry-demo/cache"),
+		subscribers: make(map[string][]*InMemoryCache),
+	}
+}
+
+// Subscribe delivers invalidations for c's name to c.
+func (b *InvalidationBus) Subscribe(c *InMemoryCache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[c.name] = append(b.subscribers[c.name], c)
+}
+
+// Publish broadcasts an invalidation of prefix in the named cache.
+func (b *InvalidationBus) Publish(ctx context.Context, cacheName, prefix string) {
+	msg := Invalidation{
+		Origin:    b.origin,
+		Cache:     cacheName,
+		Prefix:    prefix,
+		Published: time.Now(),
+	}
+
+	ctx, span := b.tracer.Start(ctx, "cache.invalidation.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attrs.CacheName(cacheName),
+			attribute.String("cache.prefix", prefix),
+			attribute.String("cache.invalidation.origin", b.origin),
+		),
+	)
+	defer span.End()
+
+	b.published.Add(1)
+	b.lastPublished.Store(msg.Published.UnixNano())
+
+	delivered := b.Deliver(ctx, msg)
+	span.SetAttributes(attribute.Int("cache.invalidation.receivers", delivered))
+}
+
+// Deliver applies msg to every local subscriber of its cache and returns
+// how many received it. The receive span links back to the publisher's span
+// found in ctx.
+func (b *InvalidationBus) Deliver(ctx context.Context, msg Invalidation) int {
+	b.mu.RLock()
+	targets := b.subscribers[msg.Cache]
+	b.mu.RUnlock()
+
+	link := trace.LinkFromContext(ctx)
+	for _, target := range targets {
+		rctx, span := b.tracer.Start(ctx, "cache.invalidation.receive",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithLinks(link),
+			trace.WithAttributes(
+				attrs.CacheName(msg.Cache),
+				attribute.String("cache.prefix", msg.Prefix),
+				attribute.String("cache.invalidation.origin", msg.Origin),
+				attribute.Bool("cache.invalidation.local", msg.Origin == b.origin),
+				attribute.Int64("cache.invalidation.lag_ms", time.Since(msg.Published).Milliseconds()),
+			),
+		)
+
+		removed, err := target.DeletePrefix(rctx, msg.Prefix)
+		if err != nil {
+			telemetry.FailSpan(span, err, "")
+		}
+		span.SetAttributes(attribute.Int("cache.removed", removed))
+		span.End()
+
+		b.delivered.Add(1)
+		b.dropped.Add(int64(removed))
+	}
+
+	return len(targets)
+}
+
+func (b *InvalidationBus) Stats() InvalidationStats {
+	b.mu.RLock()
+	subscribers := 0
+	for _, caches := range b.subscribers {
+		subscribers += len(caches)
+	}
+	b.mu.RUnlock()
+
+	stats := InvalidationStats{
+		Origin:         b.origin,
+		Subscribers:    subscribers,
+		Published:      b.published.Load(),
+		Delivered:      b.delivered.Load(),
+		EntriesDropped: b.dropped.Load(),
+	}
+	if last := b.lastPublished.Load(); last > 0 {
+		stats.LastPublished = time.Unix(0, last)
+	}
+	return stats
+}
@@ -0,0 +1,444 @@
+// Package cache provides a small traced in-memory cache shared by the
+// response cache and anything else that wants to skip repeated work.
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// DefaultTTL is used unless the cache is created WithDefaultTTL.
+const DefaultTTL = 5 * time.Minute
+
+// DefaultCleanupInterval is how often expired entries are swept out of
+// memory. Get already ignores expired entries; the sweep only reclaims space.
+const DefaultCleanupInterval = time.Minute
+
+// ErrUnavailable is returned by every traced operation while the cache
+// simulates an outage. Spans it fails are classified as unavailable.
+var ErrUnavailable = telemetry.WithErrorType(errors.New("cache backend unavailable"), telemetry.ErrorUnavailable)
+
+// Option configures an InMemoryCache.
+type Option func(*InMemoryCache)
+
+// WithDefaultTTL sets the TTL used by Set calls without WithTTL.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *InMemoryCache) {
+		if ttl > 0 {
+			c.defaultTTL = ttl
+		}
+	}
+}
+
+// WithTTLJitter spreads each entry's TTL by up to ±fraction so entries
+// written together don't all expire in the same instant.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *InMemoryCache) {
+		if fraction >= 0 && fraction < 1 {
+			c.jitter = fraction
+		}
+	}
+}
+
+// SetOption adjusts a single Set call.
+type SetOption func(*setConfig)
+
+type setConfig struct {
+	ttl time.Duration
+}
+
+// WithTTL overrides the cache's default TTL for one entry. Jitter still
+// applies.
+func WithTTL(ttl time.Duration) SetOption {
+	return func(cfg *setConfig) {
+		if ttl > 0 {
+			cfg.ttl = ttl
+		}
+	}
+}
+
+// WithCleanupInterval changes how often expired entries are swept.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *InMemoryCache) {
+		if interval > 0 {
+			c.cleanupInterval = interval
+		}
+	}
+}
+
+// SweepStats describes the cleanup loop so it isn't an invisible background
+// cost.
+type SweepStats struct {
+	Cache          string    `json:"cache"`
+	IntervalMs     int64     `json:"interval_ms"`
+	Sweeps         int64     `json:"sweeps"`
+	TotalExpired   int64     `json:"total_expired"`
+	LastSweep      time.Time `json:"last_sweep"`
+	LastScanned    int       `json:"last_scanned"`
+	LastExpired    int       `json:"last_expired"`
+	LastDurationMs float64   `json:"last_duration_ms"`
+	NextSweep      time.Time `json:"next_sweep"`
+}
+
+// Cache instruments, exported through the global MeterProvider. Every
+// measurement carries cache.name.
+var (
+	cacheMeter       = telemetry.Meter("telemetry-demo/cache")
+	cacheHits        = telemetry.Int64Counter(cacheMeter, "cache.hits", "{lookup}", "Cache lookups that found a live entry")
+	cacheMisses      = telemetry.Int64Counter(cacheMeter, "cache.misses", "{lookup}", "Cache lookups that found no entry or an expired one, by cache.miss_reason")
+	cacheExpirations = telemetry.Int64Counter(cacheMeter, "cache.expirations", "{entry}", "Expired entries removed by the cleanup sweep")
+	cacheEvictions   = telemetry.Int64Counter(cacheMeter, "cache.evictions", "{entry}", "Live or expired entries removed by invalidation")
+	cacheItems       = telemetry.Int64ObservableGauge(cacheMeter, "cache.items", "{entry}", "Entries currently stored, including expired ones not yet swept")
+	sweepScanned     = telemetry.Int64Counter(cacheMeter, "cache.sweep.scanned", "{entry}", "Entries the cleanup sweep checked for expiry")
+	sweepDuration    = telemetry.Float64Histogram(cacheMeter, "cache.sweep.duration", "ms", "Time each cleanup sweep held the cache")
+	sweepNextIn      = telemetry.Int64ObservableGauge(cacheMeter, "cache.sweep.next_in", "ms", "Time until the next cleanup sweep")
+)
+
+type entry struct {
+	value   any
+	expires time.Time
+	// stored and origin are when and by which cache.set span the entry was
+	// written, so hits can link back to the trace that populated it
+	stored time.Time
+	origin trace.SpanContext
+}
+
+// InMemoryCache is a TTL cache whose operations are recorded as child spans
+// of the caller's span.
+type InMemoryCache struct {
+	name    string
+	tracer  trace.Tracer
+	metrics metric.MeasurementOption
+	gauge   metric.Registration
+
+	mu    sync.RWMutex
+	items map[string]entry
+
+	defaultTTL      time.Duration
+	jitter          float64
+	cleanupInterval time.Duration
+	sweepMu         sync.Mutex
+	sweeps          SweepStats
+
+	// outage makes traced operations fail the way a remote cache would
+	// when it can't be reached
+	outage atomic.Bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewInMemoryCache creates a cache and starts its cleanup loop; call Close
+// to stop it.
+func NewInMemoryCache(name string, opts ...Option) *InMemoryCache {
+	c := &InMemoryCache{
+		name:            name,
+		tracer:          otel.Tracer("telemetry-demo/cache"),
+		items:           make(map[string]entry),
+		defaultTTL:      DefaultTTL,
+		cleanupInterval: DefaultCleanupInterval,
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.sweeps = SweepStats{
+		Cache:      name,
+		IntervalMs: c.cleanupInterval.Milliseconds(),
+		NextSweep:  time.Now().Add(c.cleanupInterval),
+	}
+
+	c.metrics = metric.WithAttributes(attrs.CacheName(name))
+	gauge, err := cacheMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(cacheItems, int64(c.Len()), c.metrics)
+		o.ObserveInt64(sweepNextIn, time.Until(c.SweepStats().NextSweep).Milliseconds(), c.metrics)
+		return nil
+	}, cacheItems, sweepNextIn)
+	if err != nil {
+		log.Printf("Failed to observe cache %s size: %v", name, err)
+	}
+	c.gauge = gauge
+	telemetry.Go(context.Background(), "cache.cleanup", func(context.Context) { c.cleanup() })
+	return c
+}
+
+// Get returns the live entry under key. It fails with ErrUnavailable
+// during a simulated outage. A hit's span links to the cache.set span that
+// stored the entry, so a stale response can be traced to the write behind
+// it.
+func (c *InMemoryCache) Get(ctx context.Context, key string) (any, bool, error) {
+	// Look the entry up before the span starts: links can only be added
+	// then
+	start := time.Now()
+	var item entry
+	var ok bool
+	if !c.outage.Load() {
+		c.mu.RLock()
+		item, ok = c.items[key]
+		c.mu.RUnlock()
+	}
+	hit := ok && start.Before(item.expires)
+
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attrs.CacheName(c.name),
+			attribute.String("cache.key", key),
+		),
+	}
+	if hit && item.origin.IsValid() {
+		// Zipkin drops links, so the origin is also an attribute
+		opts = append(opts,
+			trace.WithLinks(trace.Link{SpanContext: item.origin}),
+			trace.WithAttributes(
+				attribute.String("cache.origin.trace_id", item.origin.TraceID().String()),
+				attribute.Int64("cache.entry.age_ms", start.Sub(item.stored).Milliseconds()),
+			),
+		)
+	}
+	_, span := c.tracer.Start(ctx, "cache.get", opts...)
+	defer span.End()
+
+	if err := c.unavailable(span); err != nil {
+		return nil, false, err
+	}
+
+	span.SetAttributes(attrs.CacheResult(hit))
+	if !hit {
+		reason := "absent"
+		if ok {
+			reason = "expired"
+		}
+		telemetry.CacheMiss(span, c.name, key, reason)
+		cacheMisses.Add(ctx, 1, c.metrics, metric.WithAttributes(attribute.String("cache.miss_reason", reason)))
+		return nil, false, nil
+	}
+	telemetry.CacheHit(span, c.name, key)
+	cacheHits.Add(ctx, 1, c.metrics)
+	return item.value, true, nil
+}
+
+// Set stores value under key. The entry lives for the cache's default TTL
+// (or WithTTL's) adjusted by jitter; the span records the effective TTL.
+func (c *InMemoryCache) Set(ctx context.Context, key string, value any, opts ...SetOption) error {
+	cfg := setConfig{ttl: c.defaultTTL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ttl := cfg.ttl
+	if c.jitter > 0 {
+		ttl += time.Duration(float64(ttl) * c.jitter * (2*rand.Float64() - 1))
+	}
+
+	_, span := c.tracer.Start(ctx, "cache.set", trace.WithAttributes(
+		attrs.CacheName(c.name),
+		attribute.String("cache.key", key),
+		attribute.Int64("cache.ttl_ms", ttl.Milliseconds()),
+		attribute.Int64("cache.ttl_base_ms", cfg.ttl.Milliseconds()),
+	))
+	defer span.End()
+
+	if err := c.unavailable(span); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.items[key] = entry{value: value, expires: now.Add(ttl), stored: now, origin: span.SpanContext()}
+	c.mu.Unlock()
+	telemetry.CacheStored(span, c.name, key, ttl)
+	return nil
+}
+
+// DeletePrefix removes every key starting with prefix and returns how many
+// were removed.
+func (c *InMemoryCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	_, span := c.tracer.Start(ctx, "cache.delete_prefix", trace.WithAttributes(
+		attrs.CacheName(c.name),
+		attribute.String("cache.prefix", prefix),
+	))
+	defer span.End()
+
+	if err := c.unavailable(span); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	removed := 0
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.items, key)
+			removed++
+		}
+	}
+	c.mu.Unlock()
+
+	span.SetAttributes(attribute.Int("cache.removed", removed))
+	cacheEvictions.Add(ctx, int64(removed), c.metrics)
+	return removed, nil
+}
+
+// SetOutage starts or ends a simulated outage and reports whether it
+// changed. While it lasts Get, Set, and DeletePrefix fail with
+// ErrUnavailable, so degraded mode can be demoed without a remote cache.
+func (c *InMemoryCache) SetOutage(down bool) bool {
+	return c.outage.Swap(down) != down
+}
+
+// Outage reports whether a simulated outage is in progress.
+func (c *InMemoryCache) Outage() bool {
+	return c.outage.Load()
+}
+
+func (c *InMemoryCache) unavailable(span trace.Span) error {
+	if !c.outage.Load() {
+		return nil
+	}
+	telemetry.FailSpan(span, ErrUnavailable, "")
+	return ErrUnavailable
+}
+
+// Clear drops every entry. It is untraced so it can be called from hooks
+// that have no request context.
+func (c *InMemoryCache) Clear() int {
+	c.mu.Lock()
+	removed := len(c.items)
+	c.items = make(map[string]entry)
+	c.mu.Unlock()
+
+	cacheEvictions.Add(context.Background(), int64(removed), c.metrics)
+	return removed
+}
+
+// Name identifies the cache in spans and invalidations.
+func (c *InMemoryCache) Name() string {
+	return c.name
+}
+
+// DefaultTTL returns the TTL entries get unless overridden per Set.
+func (c *InMemoryCache) DefaultTTL() time.Duration {
+	return c.defaultTTL
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// swept.
+func (c *InMemoryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.items)
+}
+
+// Sizer is implemented by cached values that know roughly how much memory
+// they hold, so Stats can estimate the cache's size.
+type Sizer interface {
+	SizeBytes() int
+}
+
+// Stats is a point-in-time measure of what a cache holds.
+type Stats struct {
+	Cache   string `json:"cache"`
+	Entries int    `json:"entries"`
+	// Expired entries are still stored until the next sweep.
+	Expired int `json:"expired"`
+	// EstimatedBytes counts keys, []byte and string values, and values
+	// that implement Sizer. Other values count as nothing.
+	EstimatedBytes int64 `json:"estimated_bytes"`
+}
+
+// Stats counts and sizes the stored entries. It scans the whole cache
+// without recording spans or metrics.
+func (c *InMemoryCache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	stats := Stats{Cache: c.name, Entries: len(c.items)}
+	for key, e := range c.items {
+		if !now.Before(e.expires) {
+			stats.Expired++
+		}
+		stats.EstimatedBytes += int64(len(key))
+		switch value := e.value.(type) {
+		case []byte:
+			stats.EstimatedBytes += int64(len(value))
+		case string:
+			stats.EstimatedBytes += int64(len(value))
+		case Sizer:
+			stats.EstimatedBytes += int64(value.SizeBytes())
+		}
+	}
+	return stats
+}
+
+func (c *InMemoryCache) Close() {
+	c.once.Do(func() {
+		close(c.stop)
+		if c.gauge != nil {
+			c.gauge.Unregister()
+		}
+	})
+}
+
+// SweepStats reports what the cleanup loop has done and when it runs next.
+func (c *InMemoryCache) SweepStats() SweepStats {
+	c.sweepMu.Lock()
+	defer c.sweepMu.Unlock()
+
+	return c.sweeps
+}
+
+func (c *InMemoryCache) cleanup() {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.sweep(now)
+		}
+	}
+}
+
+func (c *InMemoryCache) sweep(now time.Time) {
+	c.mu.Lock()
+	scanned, expired := len(c.items), 0
+	for key, item := range c.items {
+		if !now.Before(item.expires) {
+			delete(c.items, key)
+			expired++
+		}
+	}
+	c.mu.Unlock()
+	duration := time.Since(now)
+
+	cacheExpirations.Add(context.Background(), int64(expired), c.metrics)
+	sweepScanned.Add(context.Background(), int64(scanned), c.metrics)
+	sweepDuration.Record(context.Background(), telemetry.Milliseconds(duration), c.metrics)
+
+	c.sweepMu.Lock()
+	defer c.sweepMu.Unlock()
+
+	c.sweeps.Sweeps++
+	c.sweeps.TotalExpired += int64(expired)
+	c.sweeps.LastSweep = now
+	c.sweeps.LastScanned = scanned
+	c.sweeps.LastExpired = expired
+	c.sweeps.LastDurationMs = telemetry.Milliseconds(duration)
+	c.sweeps.NextSweep = now.Add(c.cleanupInterval)
+}
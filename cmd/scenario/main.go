@@ -0,0 +1,225 @@
+// Command scenario replays named traffic scenarios against a running demo
+// server so presenters can reproduce specific trace patterns on demand.
+//
+//	go run ./cmd/scenario -list
+//	go run ./cmd/scenario warmup error-burst
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Step struct {
+	Name         string        `yaml:"name"`
+	Method       string        `yaml:"method"`
+	Path         string        `yaml:"path"`
+	Body         string        `yaml:"body"`
+	Count        int           `yaml:"count"`
+	Concurrency  int           `yaml:"concurrency"`
+	Delay        time.Duration `yaml:"delay"`
+	ExpectStatus int           `yaml:"expect_status"`
+}
+
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Steps       []Step `yaml:"steps"`
+}
+
+type scenarioFile struct {
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+type stepResult struct {
+	statuses   map[int]int
+	failures   int
+	mismatches int
+	total      time.Duration
+	slowest    time.Duration
+}
+
+func main() {
+	file := flag.String("file", "cmd/scenario/scenarios.yaml", "scenario definitions")
+	baseURL := flag.String("base-url", "http://localhost:8080", "demo server URL")
+	list := flag.Bool("list", false, "list available scenarios and exit")
+	flag.Parse()
+
+	scenarios, err := loadScenarios(*file)
+	if err != nil {
+		log.Fatalf("Failed to load scenarios: %v", err)
+	}
+
+	if *list || flag.NArg() == 0 {
+		printScenarios(scenarios)
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, name := range flag.Args() {
+		scenario, ok := scenarios[name]
+		if !ok {
+			log.Fatalf("Unknown scenario %q (use -list to see available scenarios)", name)
+		}
+		runScenario(client, strings.TrimRight(*baseURL, "/"), scenario)
+	}
+}
+
+func loadScenarios(path string) (map[string]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed scenarioFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	scenarios := make(map[string]Scenario, len(parsed.Scenarios))
+	for _, scenario := range parsed.Scenarios {
+		for _, step := range scenario.Steps {
+			if step.Method == "" || step.Path == "" {
+				return nil, fmt.Errorf("scenario %q step %q needs a method and path", scenario.Name, step.Name)
+			}
+		}
+		scenarios[scenario.Name] = scenario
+	}
+
+	return scenarios, nil
+}
+
+func printScenarios(scenarios map[string]Scenario) {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Available scenarios:")
+	for _, name := range names {
+		fmt.Printf("  %-20s %s\n", name, scenarios[name].Description)
+	}
+}
+
+func runScenario(client *http.Client, baseURL string, scenario Scenario) {
+	log.Printf("🎬 Running scenario %q: %s", scenario.Name, scenario.Description)
+	start := time.Now()
+
+	for _, step := range scenario.Steps {
+		result := runStep(client, baseURL, step)
+		report(step, result)
+	}
+
+	log.Printf("✅ Scenario %q finished in %s", scenario.Name, time.Since(start).Round(time.Millisecond))
+}
+
+// runStep sends step.Count requests spread across step.Concurrency workers.
+func runStep(client *http.Client, baseURL string, step Step) *stepResult {
+	count := step.Count
+	if count < 1 {
+		count = 1
+	}
+	workers := step.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	result := &stepResult{statuses: make(map[int]int)}
+	var mu sync.Mutex
+
+	requests := make(chan struct{}, count)
+	for i := 0; i < count; i++ {
+		requests <- struct{}{}
+	}
+	close(requests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requests {
+				status, elapsed, err := send(client, baseURL, step)
+
+				mu.Lock()
+				if err != nil {
+					result.failures++
+				} else {
+					result.statuses[status]++
+					if step.ExpectStatus != 0 && status != step.ExpectStatus {
+						result.mismatches++
+					}
+				}
+				result.total += elapsed
+				if elapsed > result.slowest {
+					result.slowest = elapsed
+				}
+				mu.Unlock()
+
+				if step.Delay > 0 {
+					time.Sleep(step.Delay)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+func send(client *http.Client, baseURL string, step Step) (int, time.Duration, error) {
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(step.Body)
+	}
+
+	req, err := http.NewRequest(step.Method, baseURL+step.Path, body)
+	if err != nil {
+		return 0, 0, err
+	}
+	if step.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so streaming endpoints are timed end to end
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, time.Since(start), nil
+}
+
+func report(step Step, result *stepResult) {
+	sent := result.failures
+	statuses := make([]string, 0, len(result.statuses))
+	for status, n := range result.statuses {
+		sent += n
+		statuses = append(statuses, fmt.Sprintf("%d×%d", status, n))
+	}
+	sort.Strings(statuses)
+
+	avg := time.Duration(0)
+	if sent > 0 {
+		avg = result.total / time.Duration(sent)
+	}
+
+	log.Printf("   %-22s %s %s → statuses=[%s] failures=%d unexpected=%d avg=%s max=%s",
+		step.Name, step.Method, step.Path, strings.Join(statuses, " "),
+		result.failures, result.mismatches,
+		avg.Round(time.Microsecond), result.slowest.Round(time.Microsecond))
+}
@@ -0,0 +1,879 @@
+// Package config loads the demo server's runtime settings from the
+// environment and validates them at startup.
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"telemetry-demo/tenant"
+)
+
+type Config struct {
+	// Environment is the profile the server runs under: dev, staging, or
+	// prod. It is stamped on telemetry as deployment.environment.
+	Environment string
+	// GinMode is one of gin's debug, release, or test modes.
+	GinMode string
+	// TrustedProxies lists the proxy IPs/CIDRs whose X-Forwarded-For headers
+	// are believed when resolving client IPs. Empty trusts no proxies.
+	TrustedProxies []string
+	// BasePath prefixes every route, e.g. "/telemetry" behind a path-based
+	// ingress. Empty serves routes from the root.
+	BasePath string
+	// GeoIPDatabase is an optional CSV of "cidr,country,city" rows used to
+	// geolocate client IPs. Empty disables geo enrichment.
+	GeoIPDatabase string
+	// MaxInFlight is the number of concurrent API requests above which new
+	// ones are shed with 503. Zero disables load shedding.
+	MaxInFlight int
+	// ShedRetryAfter is the Retry-After hint sent with shed responses.
+	ShedRetryAfter time.Duration
+	// CacheTTL is how long V2 GET responses are cached. Zero disables the
+	// response cache.
+	CacheTTL time.Duration
+	// CacheTTLJitter spreads each cache entry's TTL by up to ±this
+	// fraction so entries cached together don't expire together.
+	CacheTTLJitter float64
+	// CacheSweepInterval is how often expired cache entries are removed.
+	CacheSweepInterval time.Duration
+	// StaleWhileRevalidate maps V2 route templates, such as
+	// /v2/subscribers/:id, to how long past CacheTTL their cached responses
+	// are still served while being refreshed in the background.
+	StaleWhileRevalidate map[string]time.Duration
+	// CacheBreakerThreshold and CacheBreakerCooldown control the response
+	// cache's degraded mode: how many consecutive cache failures trip it
+	// and how long the cache is skipped before it is tried again.
+	CacheBreakerThreshold int
+	CacheBreakerCooldown  time.Duration
+	// TailSamplingLatency enables tail sampling: only traces with an error or
+	// a span at least this slow are exported. Zero disables it.
+	TailSamplingLatency time.Duration
+	// ExportQueueSize, ExportBatchSize, ExportBatchTimeout, and
+	// ExportTimeout tune the batch processor in front of every span
+	// exporter. Zero keeps the SDK default. ExportBlockOnFull makes a full
+	// queue slow requests down instead of dropping spans, and
+	// ExportRetryMaxElapsed is how long a failed export is retried, zero
+	// for not at all.
+	ExportQueueSize       int
+	ExportBatchSize       int
+	ExportBatchTimeout    time.Duration
+	ExportTimeout         time.Duration
+	ExportBlockOnFull     bool
+	ExportRetryMaxElapsed time.Duration
+	// ExportFlushTimeout is how long shutdown waits for queued spans to be
+	// exported before the exporters are stopped.
+	ExportFlushTimeout time.Duration
+	// SpanMetrics derives call count and duration metrics from every
+	// sampled span.
+	SpanMetrics bool
+	// LatencyBuckets are the bucket upper bounds, in ms, of every latency
+	// histogram. Empty keeps the SDK's defaults.
+	LatencyBuckets []float64
+	// ReadOnly starts the API in read-only mode, rejecting writes with 503.
+	// It can be switched at runtime from /admin/read-only.
+	ReadOnly bool
+	// AsyncWrites accepts V2 creates with 202 and stores them from a
+	// worker pool, instead of storing them before responding.
+	AsyncWrites bool
+	// CoalesceGets runs identical concurrent V2 GETs once and shares the
+	// response with every caller.
+	CoalesceGets bool
+	// LatencyProfile names the simulated backend latency profile: fast,
+	// realistic, slow, or chaotic.
+	LatencyProfile string
+	// LatencyBudget is the time each V2 request may spend across handler,
+	// service, and store before a layer refuses work. Zero disables it.
+	LatencyBudget time.Duration
+	// WatchMaxWait is the longest a change watch is held open waiting for a
+	// subscriber change.
+	WatchMaxWait time.Duration
+	// StorageReportInterval is how often /admin/storage and the storage
+	// gauges are refreshed.
+	StorageReportInterval time.Duration
+	// ProbeInterval is how often the self-probe runs canary CRUD against
+	// the API at ProbeURL. Zero disables it.
+	ProbeInterval time.Duration
+	ProbeURL      string
+	// ShutdownTimeout is how long shutdown waits for in-flight requests
+	// before closing their connections.
+	ShutdownTimeout time.Duration
+	// NotifyURL is the notification service that V2 writes are delivered
+	// to as webhooks. Empty disables delivery.
+	NotifyURL string
+	// NotifyWebhookSecret signs deliveries. Empty sends them unsigned.
+	NotifyWebhookSecret string
+	// NotifyIdentity is how the caller is handed on with each delivery:
+	// signed (a token signed with IdentityTokenSecret), dapr (alongside
+	// DaprAPIToken), or none.
+	NotifyIdentity string
+	// IdentityTokenSecret must be at least MinIdentityTokenSecret bytes,
+	// since anyone who guesses it can sign as any caller.
+	IdentityTokenSecret string
+	DaprAPIToken        string
+	// OIDCIssuer enables OpenID Connect login for the admin endpoints. Empty
+	// leaves them open.
+	OIDCIssuer string
+	// OIDCClientID, OIDCClientSecret, and OIDCRedirectURL identify this
+	// server to the OIDC provider.
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	// SecretsProvider is where secrets are read from before falling back
+	// to environment variables: env, dapr, or vault.
+	SecretsProvider string
+	// SecretStore names the Dapr secret store component the dapr provider
+	// reads from.
+	SecretStore string
+	// DaprHTTPPort is the local Dapr sidecar's HTTP port.
+	DaprHTTPPort int
+	// VaultAddr, VaultToken, VaultMount, and VaultPath locate the KV v2
+	// secret the vault provider reads from.
+	VaultAddr  string
+	VaultToken string
+	VaultMount string
+	VaultPath  string
+	// PIIAttributes lists the span attributes redacted before export, and
+	// PIIRedaction how: hash, mask, or none.
+	PIIAttributes []string
+	PIIRedaction  string
+	// ExporterFilters maps a span exporter (zipkin, jaeger, otlphttp, or
+	// otlpgrpc) to the spans it receives: all, errors, or roots. Unlisted
+	// exporters get every span.
+	ExporterFilters map[string]string
+	// BaggageFields lists the baggage members copied onto every span and
+	// log line, e.g. user.id. tenant.id is always copied.
+	BaggageFields []string
+	// TenantHeader and TenantJWTClaim are where a request's tenant is read
+	// from: a request header, or a claim of a bearer JWT. Empty skips
+	// either.
+	TenantHeader   string
+	TenantJWTClaim string
+	// TenantID is the tenant of a deployment dedicated to one. It is
+	// stamped on the resource, and requests naming no tenant belong to it.
+	// Empty leaves them unattributed.
+	TenantID string
+	// TenantTiers maps a tenant ID to its tier, e.g. enterprise, which HTTP
+	// metrics are sliced by. Unlisted tenants are DefaultTenantTier.
+	TenantTiers map[string]string
+	// LogBackend is the logger V2 handlers and slow service call warnings
+	// write through: logrus or slog.
+	LogBackend string
+	// LogLevel is the least severe line that logger writes: debug, info,
+	// warn, or error.
+	LogLevel string
+	// LogIndexSize is how many log lines with a trace ID are kept for
+	// /admin/logs and trace debug bundles. Zero keeps none.
+	LogIndexSize int
+	// ExperimentName and ExperimentVariants define the A/B experiment every
+	// request is assigned to.
+	ExperimentName     string
+	ExperimentVariants []string
+}
+
+// Load reads the configuration from environment variables, after
+// ApplyProfile has filled in the defaults of the chosen environment:
+//
+//	APP_ENV              environment profile: dev (default), staging, or prod
+//	SERVER_MODE          gin mode: debug (default), release, or test
+//	TRUSTED_PROXIES      comma-separated IPs or CIDRs
+//	BASE_PATH            route prefix such as /telemetry
+//	GEOIP_DB             path to a local GeoIP CSV table
+//	MAX_IN_FLIGHT        concurrent request limit before shedding (default 100, 0 disables)
+//	SHED_RETRY_AFTER     Retry-After for shed requests (default 1s)
+//	CACHE_TTL            V2 response cache TTL (default 5m, 0 disables)
+//	CACHE_TTL_JITTER     fraction of the TTL to randomize by (default 0.1)
+//	CACHE_SWEEP_INTERVAL how often expired cache entries are removed (default 1m)
+//	CACHE_STALE_WHILE_REVALIDATE comma-separated route=window pairs, e.g. /v2/subscribers=30s
+//	CACHE_BREAKER_THRESHOLD consecutive cache failures before it is skipped (default 3)
+//	CACHE_BREAKER_COOLDOWN  how long a failing cache is skipped (default 30s)
+//	COALESCE_GETS        share one execution among identical concurrent V2 GETs (default false)
+//	READ_ONLY            start with writes rejected (default false)
+//	ASYNC_WRITES         accept V2 creates with 202 and store them in the background (default false)
+//	LATENCY_BUCKETS      comma-separated latency histogram bucket bounds in ms (default SDK buckets)
+//	TAIL_SAMPLING_LATENCY export only failed traces or ones with a span this slow (default 0, disabled)
+//	EXPORT_QUEUE_SIZE    spans waiting for export before new ones are dropped (default 0, SDK default 2048)
+//	EXPORT_BATCH_SIZE    most spans per export (default 0, SDK default 512)
+//	EXPORT_BATCH_TIMEOUT longest a span waits for a batch (default 0, SDK default 5s)
+//	EXPORT_TIMEOUT       time limit of one export including retries (default 0, SDK default 30s)
+//	EXPORT_BLOCK_ON_FULL wait for room in a full export queue instead of dropping spans (default false)
+//	EXPORT_RETRY_MAX_ELAPSED how long a failed export is retried (default 0, no retries)
+//	EXPORT_FLUSH_TIMEOUT how long shutdown waits for queued spans to export (default 5s)
+//	SPAN_METRICS         derive span.calls and span.duration metrics from spans (default true)
+//	LATENCY_PROFILE      simulated backend latency (default realistic)
+//	LATENCY_BUDGET       time each V2 request may spend (default 2s, 0 disables)
+//	WATCH_MAX_WAIT       longest a change watch waits for a change (default 30s)
+//	STORAGE_REPORT_INTERVAL how often storage usage is measured (default 30s)
+//	PROBE_INTERVAL       how often the self-probe runs canary CRUD (default 0, disabled)
+//	PROBE_URL            where the self-probe sends requests (default http://localhost:8080)
+//	SHUTDOWN_TIMEOUT     how long shutdown waits for in-flight requests (default 10s)
+//	NOTIFY_URL           notification service webhook URL for V2 writes (default none, disabled)
+//	NOTIFY_WEBHOOK_SECRET secret deliveries are signed with (default none, unsigned)
+//	NOTIFY_IDENTITY      how the caller is handed on: signed (default), dapr, or none
+//	IDENTITY_TOKEN_SECRET secret signed identity tokens are signed with
+//	DAPR_API_TOKEN       Dapr API token sent with dapr identity
+//	OIDC_ISSUER          OIDC provider URL; enables login for /admin
+//	OIDC_CLIENT_ID       client ID registered with the provider
+//	OIDC_CLIENT_SECRET   client secret registered with the provider
+//	OIDC_REDIRECT_URL    callback URL registered with the provider
+//	SECRETS_PROVIDER     env, dapr, or vault (default dapr if DAPR_SECRET_STORE is set, else env)
+//	DAPR_SECRET_STORE    Dapr secret store component to read secrets from
+//	DAPR_HTTP_PORT       Dapr sidecar HTTP port (default 3500)
+//	VAULT_ADDR           Vault base URL for the vault provider
+//	VAULT_TOKEN          Vault token, renewed while the server runs
+//	VAULT_KV_MOUNT       KV v2 mount holding the secret (default secret)
+//	VAULT_SECRET_PATH    secret whose keys are the secret names (default telemetry-demo)
+//	PII_ATTRIBUTES       comma-separated span attributes redacted before export (default user.email,subscriber.email,validation.email,request.body)
+//	PII_REDACTION        hash (default), mask, or none
+//	EXPORTER_FILTERS     comma-separated exporter=filter pairs such as jaeger=errors (default every span to every exporter)
+//	BAGGAGE_FIELDS       comma-separated baggage members added to spans and logs (default tenant.id,user.id)
+//	TENANT_HEADER        request header naming the tenant (default X-Tenant-ID)
+//	TENANT_JWT_CLAIM     bearer JWT claim naming the tenant (default tenant_id)
+//	TENANT_ID            tenant of a dedicated deployment (default none)
+//	TENANT_TIERS         comma-separated tenant=tier pairs such as acme=enterprise (default every tenant standard)
+//	LOG_BACKEND          V2 handler and slow call logger: logrus (default) or slog
+//	LOG_LEVEL            least severe line that logger writes: debug, info (default), warn, or error
+//	LOG_INDEX_SIZE       log lines with a trace ID kept for /admin/logs (default 5000)
+//	EXPERIMENT_NAME      A/B experiment name (default subscriber-flow)
+//	EXPERIMENT_VARIANTS  comma-separated variants (default control,treatment)
+//
+// SERVER_MODE is used instead of GIN_MODE because gin panics on an invalid
+// GIN_MODE during package init, before it can be reported cleanly.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Environment:         envOrDefault("APP_ENV", EnvDev),
+		GinMode:             envOrDefault("SERVER_MODE", gin.DebugMode),
+		TrustedProxies:      splitList(os.Getenv("TRUSTED_PROXIES")),
+		BasePath:            normalizeBasePath(os.Getenv("BASE_PATH")),
+		GeoIPDatabase:       strings.TrimSpace(os.Getenv("GEOIP_DB")),
+		LatencyProfile:      envOrDefault("LATENCY_PROFILE", "realistic"),
+		OIDCIssuer:          strings.TrimSpace(os.Getenv("OIDC_ISSUER")),
+		OIDCClientID:        strings.TrimSpace(os.Getenv("OIDC_CLIENT_ID")),
+		OIDCClientSecret:    os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:     strings.TrimSpace(os.Getenv("OIDC_REDIRECT_URL")),
+		NotifyURL:           strings.TrimSpace(os.Getenv("NOTIFY_URL")),
+		NotifyWebhookSecret: os.Getenv("NOTIFY_WEBHOOK_SECRET"),
+		NotifyIdentity:      envOrDefault("NOTIFY_IDENTITY", "signed"),
+		IdentityTokenSecret: os.Getenv("IDENTITY_TOKEN_SECRET"),
+		DaprAPIToken:        os.Getenv("DAPR_API_TOKEN"),
+		SecretStore:         strings.TrimSpace(os.Getenv("DAPR_SECRET_STORE")),
+		VaultAddr:           strings.TrimSpace(os.Getenv("VAULT_ADDR")),
+		VaultToken:          os.Getenv("VAULT_TOKEN"),
+		VaultMount:          envOrDefault("VAULT_KV_MOUNT", "secret"),
+		VaultPath:           envOrDefault("VAULT_SECRET_PATH", "telemetry-demo"),
+		PIIAttributes:       splitList(envOrDefault("PII_ATTRIBUTES", "user.email,subscriber.email,validation.email,request.body")),
+		PIIRedaction:        envOrDefault("PII_REDACTION", "hash"),
+		BaggageFields:       splitList(envOrDefault("BAGGAGE_FIELDS", "tenant.id,user.id")),
+		TenantHeader:        envOrDefault("TENANT_HEADER", "X-Tenant-ID"),
+		TenantJWTClaim:      envOrDefault("TENANT_JWT_CLAIM", "tenant_id"),
+		TenantID:            strings.TrimSpace(os.Getenv("TENANT_ID")),
+		LogBackend:          envOrDefault("LOG_BACKEND", "logrus"),
+		LogLevel:            strings.ToLower(envOrDefault("LOG_LEVEL", "info")),
+		ExperimentName:      envOrDefault("EXPERIMENT_NAME", "subscriber-flow"),
+		ExperimentVariants:  splitList(envOrDefault("EXPERIMENT_VARIANTS", "control,treatment")),
+	}
+	// Naming a Dapr secret store is enough to read secrets from it
+	if cfg.SecretsProvider = strings.TrimSpace(os.Getenv("SECRETS_PROVIDER")); cfg.SecretsProvider == "" {
+		cfg.SecretsProvider = "env"
+		if cfg.SecretStore != "" {
+			cfg.SecretsProvider = "dapr"
+		}
+	}
+
+	var err error
+	if cfg.MaxInFlight, err = envInt("MAX_IN_FLIGHT", 100); err != nil {
+		return nil, err
+	}
+	if cfg.ShedRetryAfter, err = envDuration("SHED_RETRY_AFTER", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTL, err = envDuration("CACHE_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTLJitter, err = envFloat("CACHE_TTL_JITTER", 0.1); err != nil {
+		return nil, err
+	}
+	if cfg.CacheSweepInterval, err = envDuration("CACHE_SWEEP_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.StaleWhileRevalidate, err = envDurationMap("CACHE_STALE_WHILE_REVALIDATE"); err != nil {
+		return nil, err
+	}
+	if cfg.CacheBreakerThreshold, err = envInt("CACHE_BREAKER_THRESHOLD", 3); err != nil {
+		return nil, err
+	}
+	if cfg.CacheBreakerCooldown, err = envDuration("CACHE_BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.CoalesceGets, err = envBool("COALESCE_GETS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ReadOnly, err = envBool("READ_ONLY", false); err != nil {
+		return nil, err
+	}
+	if cfg.AsyncWrites, err = envBool("ASYNC_WRITES", false); err != nil {
+		return nil, err
+	}
+	if cfg.TailSamplingLatency, err = envDuration("TAIL_SAMPLING_LATENCY", 0); err != nil {
+		return nil, err
+	}
+	if cfg.SpanMetrics, err = envBool("SPAN_METRICS", true); err != nil {
+		return nil, err
+	}
+	if cfg.ExportQueueSize, err = envInt("EXPORT_QUEUE_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExportBatchSize, err = envInt("EXPORT_BATCH_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExportBatchTimeout, err = envDuration("EXPORT_BATCH_TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExportTimeout, err = envDuration("EXPORT_TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExportBlockOnFull, err = envBool("EXPORT_BLOCK_ON_FULL", false); err != nil {
+		return nil, err
+	}
+	if cfg.ExportRetryMaxElapsed, err = envDuration("EXPORT_RETRY_MAX_ELAPSED", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ExportFlushTimeout, err = envDuration("EXPORT_FLUSH_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LatencyBuckets, err = envFloatList("LATENCY_BUCKETS"); err != nil {
+		return nil, err
+	}
+	if cfg.LatencyBudget, err = envDuration("LATENCY_BUDGET", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ExporterFilters, err = envStringMap("EXPORTER_FILTERS"); err != nil {
+		return nil, err
+	}
+	if cfg.TenantTiers, err = envStringMap("TENANT_TIERS"); err != nil {
+		return nil, err
+	}
+	if cfg.WatchMaxWait, err = envDuration("WATCH_MAX_WAIT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LogIndexSize, err = envInt("LOG_INDEX_SIZE", 5000); err != nil {
+		return nil, err
+	}
+	if cfg.StorageReportInterval, err = envDuration("STORAGE_REPORT_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ProbeInterval, err = envDuration("PROBE_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	cfg.ProbeURL = strings.TrimSuffix(envOrDefault("PROBE_URL", "http://localhost:8080"), "/")
+	if cfg.ShutdownTimeout, err = envDuration("SHUTDOWN_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DaprHTTPPort, err = envInt("DAPR_HTTP_PORT", 3500); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	if _, ok := Profiles[c.Environment]; !ok {
+		return fmt.Errorf("invalid APP_ENV %q: must be %s, %s, or %s", c.Environment, EnvDev, EnvStaging, EnvProd)
+	}
+
+	switch c.GinMode {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+	default:
+		return fmt.Errorf("invalid SERVER_MODE %q: must be %s, %s, or %s",
+			c.GinMode, gin.DebugMode, gin.ReleaseMode, gin.TestMode)
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err == nil {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES entry %q: must be an IP or CIDR", proxy)
+		}
+	}
+
+	if strings.ContainsAny(c.BasePath, ":*") {
+		return fmt.Errorf("invalid BASE_PATH %q: must not contain route parameters", c.BasePath)
+	}
+
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("invalid MAX_IN_FLIGHT %d: must not be negative", c.MaxInFlight)
+	}
+	if c.ShedRetryAfter < time.Second {
+		return fmt.Errorf("invalid SHED_RETRY_AFTER %s: must be at least 1s", c.ShedRetryAfter)
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("invalid CACHE_TTL %s: must not be negative", c.CacheTTL)
+	}
+	if c.CacheTTLJitter < 0 || c.CacheTTLJitter >= 1 {
+		return fmt.Errorf("invalid CACHE_TTL_JITTER %g: must be at least 0 and below 1", c.CacheTTLJitter)
+	}
+	if c.CacheSweepInterval < time.Second {
+		return fmt.Errorf("invalid CACHE_SWEEP_INTERVAL %s: must be at least 1s", c.CacheSweepInterval)
+	}
+	for route, window := range c.StaleWhileRevalidate {
+		if !strings.HasPrefix(route, "/") || window <= 0 {
+			return fmt.Errorf("invalid CACHE_STALE_WHILE_REVALIDATE entry %s=%s: needs a route starting with / and a positive window", route, window)
+		}
+	}
+	if c.CacheBreakerThreshold < 1 {
+		return fmt.Errorf("invalid CACHE_BREAKER_THRESHOLD %d: must be at least 1", c.CacheBreakerThreshold)
+	}
+	if c.CacheBreakerCooldown < time.Second {
+		return fmt.Errorf("invalid CACHE_BREAKER_COOLDOWN %s: must be at least 1s", c.CacheBreakerCooldown)
+	}
+	if c.LatencyBudget < 0 {
+		return fmt.Errorf("invalid LATENCY_BUDGET %s: must not be negative", c.LatencyBudget)
+	}
+	if c.WatchMaxWait < time.Second {
+		return fmt.Errorf("invalid WATCH_MAX_WAIT %s: must be at least 1s", c.WatchMaxWait)
+	}
+	if c.StorageReportInterval < time.Second {
+		return fmt.Errorf("invalid STORAGE_REPORT_INTERVAL %s: must be at least 1s", c.StorageReportInterval)
+	}
+	if c.ProbeInterval != 0 && c.ProbeInterval < time.Second {
+		return fmt.Errorf("invalid PROBE_INTERVAL %s: must be 0 or at least 1s", c.ProbeInterval)
+	}
+	if c.ProbeInterval > 0 {
+		if u, err := url.Parse(c.ProbeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid PROBE_URL %q: must be an http or https URL", c.ProbeURL)
+		}
+	}
+	if c.NotifyURL != "" {
+		if u, err := url.Parse(c.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid NOTIFY_URL %q: must be an http or https URL", c.NotifyURL)
+		}
+	}
+	switch c.NotifyIdentity {
+	case "signed", "dapr", "none":
+	default:
+		return fmt.Errorf("invalid NOTIFY_IDENTITY %q: must be signed, dapr, or none", c.NotifyIdentity)
+	}
+	// Other providers resolve the secret at startup, and check it then
+	if c.NotifyURL != "" && c.NotifyIdentity == "signed" && c.SecretsProvider == "env" {
+		if err := CheckIdentityTokenSecret(c.IdentityTokenSecret); err != nil {
+			return err
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("invalid SHUTDOWN_TIMEOUT %s: must be positive", c.ShutdownTimeout)
+	}
+	if c.TailSamplingLatency < 0 {
+		return fmt.Errorf("invalid TAIL_SAMPLING_LATENCY %s: must not be negative", c.TailSamplingLatency)
+	}
+	if c.ExportQueueSize < 0 {
+		return fmt.Errorf("invalid EXPORT_QUEUE_SIZE %d: must not be negative", c.ExportQueueSize)
+	}
+	if c.ExportBatchSize < 0 {
+		return fmt.Errorf("invalid EXPORT_BATCH_SIZE %d: must not be negative", c.ExportBatchSize)
+	}
+	if c.ExportQueueSize > 0 && c.ExportBatchSize > c.ExportQueueSize {
+		return fmt.Errorf("invalid EXPORT_BATCH_SIZE %d: must not exceed EXPORT_QUEUE_SIZE %d", c.ExportBatchSize, c.ExportQueueSize)
+	}
+	if c.ExportBatchTimeout < 0 {
+		return fmt.Errorf("invalid EXPORT_BATCH_TIMEOUT %s: must not be negative", c.ExportBatchTimeout)
+	}
+	if c.ExportTimeout < 0 {
+		return fmt.Errorf("invalid EXPORT_TIMEOUT %s: must not be negative", c.ExportTimeout)
+	}
+	if c.ExportRetryMaxElapsed < 0 {
+		return fmt.Errorf("invalid EXPORT_RETRY_MAX_ELAPSED %s: must not be negative", c.ExportRetryMaxElapsed)
+	}
+	if c.ExportFlushTimeout <= 0 {
+		return fmt.Errorf("invalid EXPORT_FLUSH_TIMEOUT %s: must be positive", c.ExportFlushTimeout)
+	}
+	for i, bound := range c.LatencyBuckets {
+		if bound < 0 || (i > 0 && bound <= c.LatencyBuckets[i-1]) {
+			return fmt.Errorf("invalid LATENCY_BUCKETS %s: bounds must be non-negative and increasing", formatFloats(c.LatencyBuckets))
+		}
+	}
+	if c.DaprHTTPPort < 1 || c.DaprHTTPPort > 65535 {
+		return fmt.Errorf("invalid DAPR_HTTP_PORT %d: must be a port number", c.DaprHTTPPort)
+	}
+	switch c.SecretsProvider {
+	case "env":
+	case "dapr":
+		if c.SecretStore == "" {
+			return fmt.Errorf("SECRETS_PROVIDER is dapr but DAPR_SECRET_STORE is missing")
+		}
+	case "vault":
+		if c.VaultAddr == "" || c.VaultToken == "" {
+			return fmt.Errorf("SECRETS_PROVIDER is vault but VAULT_ADDR or VAULT_TOKEN is missing")
+		}
+		if _, err := url.ParseRequestURI(c.VaultAddr); err != nil {
+			return fmt.Errorf("invalid VAULT_ADDR %q: must be an absolute URL", c.VaultAddr)
+		}
+	default:
+		return fmt.Errorf("invalid SECRETS_PROVIDER %q: must be env, dapr, or vault", c.SecretsProvider)
+	}
+
+	switch c.PIIRedaction {
+	case "hash", "mask", "none":
+	default:
+		return fmt.Errorf("invalid PII_REDACTION %q: must be hash, mask, or none", c.PIIRedaction)
+	}
+
+	for exporter, filter := range c.ExporterFilters {
+		switch exporter {
+		case "zipkin", "jaeger", "otlphttp", "otlpgrpc":
+		default:
+			return fmt.Errorf("invalid EXPORTER_FILTERS exporter %q: must be zipkin, jaeger, otlphttp, or otlpgrpc", exporter)
+		}
+		switch filter {
+		case "all", "errors", "roots":
+		default:
+			return fmt.Errorf("invalid EXPORTER_FILTERS filter %q for %s: must be all, errors, or roots", filter, exporter)
+		}
+	}
+
+	switch c.LogBackend {
+	case "logrus", "slog":
+	default:
+		return fmt.Errorf("invalid LOG_BACKEND %q: must be logrus or slog", c.LogBackend)
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn, or error", c.LogLevel)
+	}
+	if c.LogIndexSize < 0 {
+		return fmt.Errorf("invalid LOG_INDEX_SIZE %d: must not be negative", c.LogIndexSize)
+	}
+
+	if c.TenantID != "" && !tenant.ValidID(c.TenantID) {
+		return fmt.Errorf("invalid TENANT_ID %q: must be 1 to 64 letters, digits, '.', '_', or '-'", c.TenantID)
+	}
+	for id, tier := range c.TenantTiers {
+		if !tenant.ValidID(id) {
+			return fmt.Errorf("invalid TENANT_TIERS tenant %q: must be 1 to 64 letters, digits, '.', '_', or '-'", id)
+		}
+		if tier == "" {
+			return fmt.Errorf("invalid TENANT_TIERS tier for %s: must not be empty", id)
+		}
+	}
+
+	if len(c.ExperimentVariants) < 2 {
+		return fmt.Errorf("invalid EXPERIMENT_VARIANTS %q: must list at least two variants", strings.Join(c.ExperimentVariants, ","))
+	}
+	seen := make(map[string]bool, len(c.ExperimentVariants))
+	for _, variant := range c.ExperimentVariants {
+		if seen[variant] {
+			return fmt.Errorf("invalid EXPERIMENT_VARIANTS: %q is listed twice", variant)
+		}
+		seen[variant] = true
+	}
+
+	if c.OIDCIssuer != "" {
+		if c.OIDCClientID == "" || c.OIDCRedirectURL == "" {
+			return fmt.Errorf("OIDC_ISSUER is set but OIDC_CLIENT_ID or OIDC_REDIRECT_URL is missing")
+		}
+		if _, err := url.ParseRequestURI(c.OIDCRedirectURL); err != nil {
+			return fmt.Errorf("invalid OIDC_REDIRECT_URL %q: must be an absolute URL", c.OIDCRedirectURL)
+		}
+	}
+
+	return nil
+}
+
+// MinIdentityTokenSecret is the shortest IDENTITY_TOKEN_SECRET accepted,
+// the size of the HMAC-SHA256 key it becomes.
+const MinIdentityTokenSecret = 32
+
+// CheckIdentityTokenSecret reports whether secret can sign identity tokens.
+func CheckIdentityTokenSecret(secret string) error {
+	if secret == "" {
+		return fmt.Errorf("NOTIFY_IDENTITY is signed but IDENTITY_TOKEN_SECRET is missing")
+	}
+	if len(secret) < MinIdentityTokenSecret {
+		return fmt.Errorf("invalid IDENTITY_TOKEN_SECRET: must be at least %d bytes, got %d", MinIdentityTokenSecret, len(secret))
+	}
+	return nil
+}
+
+// redacted replaces secret values in Snapshot.
+const redacted = "[redacted]"
+
+// redact hides a secret's value but shows whether it is set.
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL hides the credentials and query a URL may carry, but keeps
+// where it points.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redact(raw)
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// Snapshot returns the effective settings keyed by the environment variable
+// each one is read from, with secrets redacted, so it can be shown to
+// anyone who can reach the admin endpoints.
+func (c *Config) Snapshot() map[string]string {
+	return map[string]string{
+		"APP_ENV":                      c.Environment,
+		"SERVER_MODE":                  c.GinMode,
+		"TRUSTED_PROXIES":              strings.Join(c.TrustedProxies, ","),
+		"BASE_PATH":                    c.BasePath,
+		"GEOIP_DB":                     c.GeoIPDatabase,
+		"MAX_IN_FLIGHT":                strconv.Itoa(c.MaxInFlight),
+		"SHED_RETRY_AFTER":             c.ShedRetryAfter.String(),
+		"CACHE_TTL":                    c.CacheTTL.String(),
+		"CACHE_TTL_JITTER":             strconv.FormatFloat(c.CacheTTLJitter, 'g', -1, 64),
+		"CACHE_SWEEP_INTERVAL":         c.CacheSweepInterval.String(),
+		"CACHE_BREAKER_THRESHOLD":      strconv.Itoa(c.CacheBreakerThreshold),
+		"CACHE_BREAKER_COOLDOWN":       c.CacheBreakerCooldown.String(),
+		"COALESCE_GETS":                strconv.FormatBool(c.CoalesceGets),
+		"CACHE_STALE_WHILE_REVALIDATE": formatDurationMap(c.StaleWhileRevalidate),
+		"READ_ONLY":                    strconv.FormatBool(c.ReadOnly),
+		"ASYNC_WRITES":                 strconv.FormatBool(c.AsyncWrites),
+		"TAIL_SAMPLING_LATENCY":        c.TailSamplingLatency.String(),
+		"SPAN_METRICS":                 strconv.FormatBool(c.SpanMetrics),
+		"EXPORT_QUEUE_SIZE":            strconv.Itoa(c.ExportQueueSize),
+		"EXPORT_BATCH_SIZE":            strconv.Itoa(c.ExportBatchSize),
+		"EXPORT_BATCH_TIMEOUT":         c.ExportBatchTimeout.String(),
+		"EXPORT_TIMEOUT":               c.ExportTimeout.String(),
+		"EXPORT_BLOCK_ON_FULL":         strconv.FormatBool(c.ExportBlockOnFull),
+		"EXPORT_RETRY_MAX_ELAPSED":     c.ExportRetryMaxElapsed.String(),
+		"EXPORT_FLUSH_TIMEOUT":         c.ExportFlushTimeout.String(),
+		"LATENCY_BUCKETS":              formatFloats(c.LatencyBuckets),
+		"LATENCY_PROFILE":              c.LatencyProfile,
+		"LATENCY_BUDGET":               c.LatencyBudget.String(),
+		"WATCH_MAX_WAIT":               c.WatchMaxWait.String(),
+		"STORAGE_REPORT_INTERVAL":      c.StorageReportInterval.String(),
+		"PROBE_INTERVAL":               c.ProbeInterval.String(),
+		"PROBE_URL":                    c.ProbeURL,
+		"SHUTDOWN_TIMEOUT":             c.ShutdownTimeout.String(),
+		"NOTIFY_URL":                   redactURL(c.NotifyURL),
+		"NOTIFY_WEBHOOK_SECRET":        redact(c.NotifyWebhookSecret),
+		"NOTIFY_IDENTITY":              c.NotifyIdentity,
+		"IDENTITY_TOKEN_SECRET":        redact(c.IdentityTokenSecret),
+		"DAPR_API_TOKEN":               redact(c.DaprAPIToken),
+		"OIDC_ISSUER":                  c.OIDCIssuer,
+		"OIDC_CLIENT_ID":               c.OIDCClientID,
+		"OIDC_CLIENT_SECRET":           redact(c.OIDCClientSecret),
+		"OIDC_REDIRECT_URL":            c.OIDCRedirectURL,
+		"SECRETS_PROVIDER":             c.SecretsProvider,
+		"DAPR_SECRET_STORE":            c.SecretStore,
+		"DAPR_HTTP_PORT":               strconv.Itoa(c.DaprHTTPPort),
+		"VAULT_ADDR":                   c.VaultAddr,
+		"VAULT_TOKEN":                  redact(c.VaultToken),
+		"VAULT_KV_MOUNT":               c.VaultMount,
+		"VAULT_SECRET_PATH":            c.VaultPath,
+		"PII_ATTRIBUTES":               strings.Join(c.PIIAttributes, ","),
+		"PII_REDACTION":                c.PIIRedaction,
+		"EXPORTER_FILTERS":             formatStringMap(c.ExporterFilters),
+		"BAGGAGE_FIELDS":               strings.Join(c.BaggageFields, ","),
+		"TENANT_HEADER":                c.TenantHeader,
+		"TENANT_JWT_CLAIM":             c.TenantJWTClaim,
+		"TENANT_ID":                    c.TenantID,
+		"TENANT_TIERS":                 formatStringMap(c.TenantTiers),
+		"LOG_BACKEND":                  c.LogBackend,
+		"LOG_LEVEL":                    c.LogLevel,
+		"LOG_INDEX_SIZE":               strconv.Itoa(c.LogIndexSize),
+		"EXPERIMENT_NAME":              c.ExperimentName,
+		"EXPERIMENT_VARIANTS":          strings.Join(c.ExperimentVariants, ","),
+	}
+}
+
+// Hash is a short fingerprint of Snapshot. It is stamped on the telemetry
+// resource, so a trace can be matched to the configuration that produced it.
+func (c *Config) Hash() string {
+	snapshot := c.Snapshot()
+	keys := make([]string, 0, len(snapshot))
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%s\n", key, snapshot[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) (int, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be an integer", key, value)
+	}
+	return parsed, nil
+}
+
+func envFloat(key string, fallback float64) (float64, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a number", key, value)
+	}
+	return parsed, nil
+}
+
+// envFloatList parses a comma-separated list of numbers. Unset is nil.
+func envFloatList(key string) ([]float64, error) {
+	var values []float64
+	for _, item := range splitList(os.Getenv(key)) {
+		value, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: must be a number", key, item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// envDurationMap parses comma-separated key=duration pairs. Unset is nil.
+func envDurationMap(key string) (map[string]time.Duration, error) {
+	items := splitList(os.Getenv(key))
+	if len(items) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]time.Duration, len(items))
+	for _, item := range items {
+		name, raw, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry %q: must be name=duration", key, item)
+		}
+		value, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %v", key, item, err)
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	return values, nil
+}
+
+// envStringMap parses comma-separated key=value pairs. Unset is nil.
+func envStringMap(key string) (map[string]string, error) {
+	items := splitList(os.Getenv(key))
+	if len(items) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]string, len(items))
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry %q: must be name=value", key, item)
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+func formatStringMap(values map[string]string) string {
+	items := make([]string, 0, len(values))
+	for name, value := range values {
+		items = append(items, name+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func formatDurationMap(values map[string]time.Duration) string {
+	items := make([]string, 0, len(values))
+	for name, value := range values {
+		items = append(items, name+"="+value.String())
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func formatFloats(values []float64) string {
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	return strings.Join(items, ",")
+}
+
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a duration such as 500ms or 2s", key, value)
+	}
+	return parsed, nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", key, value)
+	}
+	return parsed, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeBasePath turns "telemetry/" or "/telemetry/" into "/telemetry"
+// and "/" into "".
+func normalizeBasePath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment profiles, selected with --env or APP_ENV.
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
+)
+
+// collectorEndpoint is where staging and prod expect an OpenTelemetry
+// collector, e.g. a sidecar or a cluster service of that name.
+const collectorEndpoint = "http://otel-collector:4318"
+
+// Profiles are the defaults of each environment, keyed by the environment
+// variable they stand in for. They only fill in what the environment leaves
+// unset, so a variable set explicitly always wins. OTEL_* entries reach the
+// telemetry package the same way as if they had been exported.
+var Profiles = map[string]map[string]string{
+	// Local runs log everything and keep the built-in tracing: Zipkin and
+	// Jaeger with the adaptive sampler, which keeps every trace at low volume
+	EnvDev: {
+		"SERVER_MODE": "debug",
+		"LOG_LEVEL":   "debug",
+	},
+	// Staging keeps half of new traces and reports to a collector
+	EnvStaging: {
+		"SERVER_MODE":                 "release",
+		"LOG_LEVEL":                   "info",
+		"OTEL_TRACES_SAMPLER":         "parentbased_traceidratio",
+		"OTEL_TRACES_SAMPLER_ARG":     "0.5",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": collectorEndpoint,
+	},
+	// Production leaves sampling to the adaptive sampler, retries failed
+	// exports, and only logs warnings and errors
+	EnvProd: {
+		"SERVER_MODE":                 "release",
+		"LOG_LEVEL":                   "warn",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": collectorEndpoint,
+		"EXPORT_RETRY_MAX_ELAPSED":    "30s",
+	},
+}
+
+// ApplyProfile sets the defaults of the named environment profile for every
+// variable that isn't already set, and records the choice in APP_ENV for
+// Load. An empty name uses APP_ENV, or dev when that is unset too. Call it
+// before Load and before telemetry is initialized.
+func ApplyProfile(name string) error {
+	if name = strings.TrimSpace(name); name == "" {
+		name = envOrDefault("APP_ENV", EnvDev)
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		return fmt.Errorf("invalid environment %q: must be %s, %s, or %s", name, EnvDev, EnvStaging, EnvProd)
+	}
+
+	if err := os.Setenv("APP_ENV", name); err != nil {
+		return err
+	}
+	for key, value := range profile {
+		if strings.TrimSpace(os.Getenv(key)) != "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
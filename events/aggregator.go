@@ -0,0 +1,172 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/models"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// DefaultAggregationInterval is how often new events are rolled up.
+const DefaultAggregationInterval = 10 * time.Second
+
+// Engagement score weights: a click says more about interest than an open.
+const (
+	openWeight  = 1.0
+	clickWeight = 3.0
+)
+
+var (
+	aggregatorMeter = telemetry.Meter("telemetry-demo/events")
+	aggregationLag  = telemetry.Float64Histogram(aggregatorMeter, "events.aggregation.lag", "ms",
+		"Age of the oldest event each aggregation run picked up; runs with no new events aren't recorded")
+)
+
+// AggregationStats describes the aggregator's progress. LagMs is how old
+// the oldest not-yet-aggregated event was when the last run started.
+type AggregationStats struct {
+	Runs              int64     `json:"runs"`
+	EventsAggregated  int64     `json:"events_aggregated"`
+	LastRun           time.Time `json:"last_run"`
+	LastRunDurationMs float64   `json:"last_run_duration_ms"`
+	LastBatchEvents   int       `json:"last_batch_events"`
+	LagMs             int64     `json:"lag_ms"`
+	PendingEvents     int       `json:"pending_events"`
+}
+
+// Aggregator periodically folds new activity events into per-subscriber
+// engagement stored on the subscriber model.
+type Aggregator struct {
+	store    *store.MemoryStore
+	interval time.Duration
+	tracer   trace.Tracer
+
+	mu     sync.Mutex
+	cursor int
+	totals map[int]models.Engagement
+	stats  AggregationStats
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewAggregator starts a background loop running Run every interval; call
+// Stop to end it.
+func NewAggregator(store *store.MemoryStore, interval time.Duration) *Aggregator {
+	a := &Aggregator{
+		store:    store,
+		interval: interval,
+		tracer:   otel.Tracer("telemetry-demo/events"),
+		totals:   make(map[int]models.Engagement),
+		stop:     make(chan struct{}),
+	}
+	telemetry.Go(context.Background(), "events.aggregator", func(context.Context) { a.loop() })
+	return a
+}
+
+func (a *Aggregator) Stop() {
+	a.once.Do(func() { close(a.stop) })
+}
+
+func (a *Aggregator) Stats() AggregationStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := a.stats
+	stats.PendingEvents = a.store.EventCount() - a.cursor
+	return stats
+}
+
+func (a *Aggregator) loop() {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.Run(context.Background())
+		}
+	}
+}
+
+// Run aggregates every event persisted since the previous run. Each run is
+// one trace: reading new events, scoring them, and writing scores back.
+func (a *Aggregator) Run(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	start := time.Now()
+	ctx, span := a.tracer.Start(ctx, "events.aggregate", trace.WithNewRoot())
+	defer span.End()
+
+	_, readSpan := a.tracer.Start(ctx, "events.aggregate.read")
+	batch, next := a.store.EventsSince(a.cursor)
+	readSpan.SetAttributes(
+		attribute.Int("events.cursor", a.cursor),
+		attribute.Int("events.count", len(batch)),
+	)
+	readSpan.End()
+
+	var lag time.Duration
+	for _, event := range batch {
+		if age := start.Sub(event.Timestamp); age > lag {
+			lag = age
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("events.count", len(batch)),
+		attribute.Int64("events.aggregation.lag_ms", lag.Milliseconds()),
+	)
+	if len(batch) > 0 {
+		aggregationLag.Record(ctx, float64(lag.Microseconds())/1000)
+	}
+
+	if len(batch) > 0 {
+		_, scoreSpan := a.tracer.Start(ctx, "events.aggregate.score")
+		changed := make(map[int]models.Engagement)
+		for _, event := range batch {
+			engagement := a.totals[event.SubscriberID]
+			switch event.Type {
+			case models.EventOpen:
+				engagement.Opens++
+			case models.EventClick:
+				engagement.Clicks++
+			}
+			engagement.Score = float64(engagement.Opens)*openWeight + float64(engagement.Clicks)*clickWeight
+			engagement.UpdatedAt = start
+			a.totals[event.SubscriberID] = engagement
+			changed[event.SubscriberID] = engagement
+		}
+		scoreSpan.SetAttributes(attribute.Int("subscribers.scored", len(changed)))
+		scoreSpan.End()
+
+		_, applySpan := a.tracer.Start(ctx, "events.aggregate.apply", trace.WithAttributes(
+			attrs.DBSystemMemory,
+		))
+		updated := a.store.UpdateEngagement(changed)
+		applySpan.SetAttributes(attribute.Int("subscribers.updated", updated))
+		if missing := len(changed) - updated; missing > 0 {
+			// Events for subscribers that no longer exist are dropped, not retried
+			applySpan.SetAttributes(attribute.Int("subscribers.missing", missing))
+		}
+		applySpan.End()
+	}
+
+	a.cursor = next
+	a.store.TrimEvents(next)
+	a.stats.Runs++
+	a.stats.EventsAggregated += int64(len(batch))
+	a.stats.LastRun = start
+	a.stats.LastRunDurationMs = float64(time.Since(start).Microseconds()) / 1000
+	a.stats.LastBatchEvents = len(batch)
+	a.stats.LagMs = lag.Milliseconds()
+}
@@ -0,0 +1,172 @@
+// Package events buffers high-volume activity events and persists them in
+// batches, keeping the request path down to a channel send.
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/models"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+var ErrClosed = errors.New("event ingester is closed")
+
+const (
+	DefaultQueueSize     = 10000
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 250 * time.Millisecond
+)
+
+// Stats are aggregate counters for the ingestion path. They stand in for
+// per-event spans, which would cost more than the events themselves.
+type Stats struct {
+	Accepted      int64 `json:"accepted"`
+	Rejected      int64 `json:"rejected"`
+	Persisted     int64 `json:"persisted"`
+	Batches       int64 `json:"batches"`
+	QueueDepth    int   `json:"queue_depth"`
+	QueueCapacity int   `json:"queue_capacity"`
+	Stored        int   `json:"stored"`
+}
+
+type Ingester struct {
+	store         *store.MemoryStore
+	queue         chan models.ActivityEvent
+	batchSize     int
+	flushInterval time.Duration
+	tracer        trace.Tracer
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	accepted  atomic.Int64
+	rejected  atomic.Int64
+	persisted atomic.Int64
+	batches   atomic.Int64
+}
+
+// NewIngester starts the background flusher. Events are written to the
+// store when batchSize accumulate or every flushInterval, whichever is first.
+func NewIngester(store *store.MemoryStore, queueSize, batchSize int, flushInterval time.Duration) *Ingester {
+	i := &Ingester{
+		store:         store,
+		queue:         make(chan models.ActivityEvent, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		tracer:        otel.Tracer("telemetry-demo/events"),
+		done:          make(chan struct{}),
+	}
+	telemetry.Go(context.Background(), "events.ingester", func(context.Context) { i.run() })
+	return i
+}
+
+// Enqueue queues as many events as fit without blocking and returns how
+// many were accepted; the rest are counted as rejected.
+func (i *Ingester) Enqueue(events []models.ActivityEvent) (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.closed {
+		return 0, ErrClosed
+	}
+
+	accepted := 0
+	for _, event := range events {
+		select {
+		case i.queue <- event:
+			accepted++
+		default:
+		}
+	}
+
+	i.accepted.Add(int64(accepted))
+	i.rejected.Add(int64(len(events) - accepted))
+	return accepted, nil
+}
+
+// Close stops accepting events and waits for the queue to be flushed.
+func (i *Ingester) Close(ctx context.Context) error {
+	i.mu.Lock()
+	if !i.closed {
+		i.closed = true
+		close(i.queue)
+	}
+	i.mu.Unlock()
+
+	select {
+	case <-i.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Ingester) Stats() Stats {
+	return Stats{
+		Accepted:      i.accepted.Load(),
+		Rejected:      i.rejected.Load(),
+		Persisted:     i.persisted.Load(),
+		Batches:       i.batches.Load(),
+		QueueDepth:    len(i.queue),
+		QueueCapacity: cap(i.queue),
+		Stored:        i.store.EventCount(),
+	}
+}
+
+func (i *Ingester) run() {
+	defer close(i.done)
+
+	ticker := time.NewTicker(i.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ActivityEvent, 0, i.batchSize)
+	for {
+		select {
+		case event, ok := <-i.queue:
+			if !ok {
+				i.flush(batch, "shutdown")
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= i.batchSize {
+				i.flush(batch, "size")
+				batch = make([]models.ActivityEvent, 0, i.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				i.flush(batch, "interval")
+				batch = make([]models.ActivityEvent, 0, i.batchSize)
+			}
+		}
+	}
+}
+
+// flush persists a batch under a single span, so tracing cost scales with
+// batches rather than events.
+func (i *Ingester) flush(batch []models.ActivityEvent, trigger string) {
+	if len(batch) == 0 {
+		return
+	}
+
+	_, span := i.tracer.Start(context.Background(), "events.persist_batch", trace.WithAttributes(
+		attribute.Int("events.batch_size", len(batch)),
+		attribute.String("events.flush_trigger", trigger),
+		attribute.Int("events.queue_depth", len(i.queue)),
+		attrs.DBSystemMemory,
+	))
+	defer span.End()
+
+	i.store.AppendEvents(batch)
+	i.persisted.Add(int64(len(batch)))
+	i.batches.Add(1)
+}
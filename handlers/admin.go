@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/cache"
+	"telemetry-demo/config"
+	"telemetry-demo/logging"
+	"telemetry-demo/middleware"
+	"telemetry-demo/probe"
+	"telemetry-demo/problem"
+	"telemetry-demo/service"
+	"telemetry-demo/storage"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+type AdminHandler struct {
+	costs   *telemetry.CostProcessor
+	sampler *telemetry.AdaptiveSampler
+	usage   *middleware.UsageTracker
+	exp     *middleware.ExperimentAssigner
+	encode  *telemetry.SerializationRecorder
+	calls   *service.ServiceMetrics
+	cache   *middleware.ResponseCache
+	bus     *cache.InvalidationBus
+	merge   *middleware.RequestCoalescer
+	guard   *middleware.ReadOnlyGuard
+	audit   *telemetry.StatusAuditProcessor
+	anomaly *telemetry.AnomalyDetector
+	notes   *telemetry.Annotations
+	tail    *telemetry.TailSampler
+	storage *storage.Reporter
+	probe   *probe.Prober
+	config  *config.Config
+}
+
+// NewAdminHandler wires the admin endpoints to the components they report
+// on. responses and bus may be nil when response caching is disabled, merge
+// when request coalescing is, tail when tail sampling is, and prober when
+// the self-probe is. cfg is the configuration the server was built from.
+func NewAdminHandler(costs *telemetry.CostProcessor, sampler *telemetry.AdaptiveSampler, usage *middleware.UsageTracker, exp *middleware.ExperimentAssigner, encode *telemetry.SerializationRecorder, calls *service.ServiceMetrics, responses *middleware.ResponseCache, bus *cache.InvalidationBus, merge *middleware.RequestCoalescer, guard *middleware.ReadOnlyGuard, audit *telemetry.StatusAuditProcessor, anomaly *telemetry.AnomalyDetector, notes *telemetry.Annotations, tail *telemetry.TailSampler, usageReport *storage.Reporter, prober *probe.Prober, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		costs:   costs,
+		sampler: sampler,
+		usage:   usage,
+		exp:     exp,
+		encode:  encode,
+		calls:   calls,
+		cache:   responses,
+		bus:     bus,
+		merge:   merge,
+		guard:   guard,
+		audit:   audit,
+		anomaly: anomaly,
+		notes:   notes,
+		tail:    tail,
+		storage: usageReport,
+		probe:   prober,
+		config:  cfg,
+	}
+}
+
+// GetConfig reports what the process is actually running with: the
+// effective configuration with secrets redacted, the telemetry pipeline,
+// the sampler, and which optional features and backends are in use.
+// config_hash matches the app.config.hash resource attribute on every span.
+func (h *AdminHandler) GetConfig(c *gin.Context) {
+	settings := telemetry.CurrentSettings()
+
+	responseCache := "disabled"
+	if h.cache != nil {
+		responseCache = "memory"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"config_hash": h.config.Hash(),
+		"config":      h.config.Snapshot(),
+		"telemetry":   settings,
+		"sampler": gin.H{
+			"strategy":        settings.Traces.SamplingStrategy,
+			"adaptive_routes": len(h.sampler.Report()),
+			"tail_sampling":   h.tail != nil,
+		},
+		"features": gin.H{
+			"response_cache":     h.cache != nil,
+			"request_coalescing": h.merge != nil,
+			"read_only":          h.guard.Enabled(),
+			"tail_sampling":      h.tail != nil,
+			"load_shedding":      h.config.MaxInFlight > 0,
+			"oidc_login":         h.config.OIDCIssuer != "",
+			"geoip":              h.config.GeoIPDatabase != "",
+			"slow_span_stacks":   settings.Traces.SlowSpanStackMs > 0,
+		},
+		"backends": gin.H{
+			"store":            "memory",
+			"response_cache":   responseCache,
+			"latency_profile":  h.config.LatencyProfile,
+			"trace_exporters":  settings.Traces.Exporters,
+			"metric_exporters": settings.Metrics.Exporters,
+		},
+	})
+}
+
+// GetTelemetryCost reports the endpoints producing the most telemetry.
+// Use ?top=N to limit the report (default 10, 0 for all).
+func (h *AdminHandler) GetTelemetryCost(c *gin.Context) {
+	top, err := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if err != nil || top < 0 {
+		h.reject(c, problem.New(problem.ValidationFailed, "Invalid top parameter"))
+		return
+	}
+
+	endpoints := h.costs.TopEndpoints(top)
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints":      endpoints,
+		"count":          len(endpoints),
+		"dropped_traces": h.costs.DroppedTraces(),
+	})
+}
+
+// GetSamplingReport shows the adaptive sampler's current probability per route
+// and why it was chosen.
+func (h *AdminHandler) GetSamplingReport(c *gin.Context) {
+	routes := h.sampler.Report()
+
+	c.JSON(http.StatusOK, gin.H{
+		"sampler": h.sampler.Description(),
+		"routes":  routes,
+		"count":   len(routes),
+	})
+}
+
+// GetTailSampling reports how many traces tail sampling kept for errors or
+// latency and how many it dropped.
+func (h *AdminHandler) GetTailSampling(c *gin.Context) {
+	if h.tail == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"stats":   h.tail.Stats(),
+	})
+}
+
+// GetGoroutines reports the background goroutines started with
+// telemetry.Go next to the process-wide goroutine count.
+func (h *AdminHandler) GetGoroutines(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"goroutines": telemetry.GoroutineReport(),
+		"total":      runtime.NumGoroutine(),
+	})
+}
+
+// GetExemplars lists the latest traced measurement in each bucket of every
+// latency histogram. Use ?instrument= to show one histogram, e.g.
+// http.server.duration.
+func (h *AdminHandler) GetExemplars(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"series": telemetry.ExemplarReport(c.Query("instrument"))})
+}
+
+// GetLogs returns the indexed log lines of ?trace_id=, oldest first, next
+// to what the log index holds. Without trace_id only the index is
+// reported.
+func (h *AdminHandler) GetLogs(c *gin.Context) {
+	stats := logging.TraceLogs.Stats()
+	raw, ok := c.GetQuery("trace_id")
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"index": stats})
+		return
+	}
+	traceID, err := trace.TraceIDFromHex(raw)
+	if err != nil {
+		h.reject(c, problem.Newf(problem.ValidationFailed, "%q is not a valid trace ID", raw))
+		return
+	}
+
+	entries := logging.TraceLogs.Trace(traceID.String())
+	c.JSON(http.StatusOK, gin.H{
+		"trace_id": traceID.String(),
+		"entries":  entries,
+		"count":    len(entries),
+		"index":    stats,
+	})
+}
+
+// traceBundle is everything the process still knows about one trace.
+type traceBundle struct {
+	TraceID     string                     `json:"trace_id"`
+	GeneratedAt time.Time                  `json:"generated_at"`
+	ConfigHash  string                     `json:"config_hash"`
+	Spans       []telemetry.RecordedSpan   `json:"spans"`
+	Logs        []logging.Entry            `json:"logs"`
+	Exemplars   []telemetry.ExemplarSeries `json:"exemplars"`
+}
+
+// GetTraceBundle downloads one trace as a single JSON file: its spans from
+// the recent span buffer, the log lines that carried its trace ID, and the
+// histogram exemplars pointing at it. Both buffers are bounded, so an old
+// or very large trace comes back incomplete.
+func (h *AdminHandler) GetTraceBundle(c *gin.Context) {
+	raw := c.Param("traceID")
+	traceID, err := trace.TraceIDFromHex(raw)
+	if err != nil {
+		h.reject(c, problem.Newf(problem.ValidationFailed, "%q is not a valid trace ID", raw))
+		return
+	}
+
+	bundle := traceBundle{
+		TraceID:     traceID.String(),
+		GeneratedAt: time.Now(),
+		ConfigHash:  h.config.Hash(),
+		Spans:       telemetry.TraceSpans(traceID),
+		Logs:        logging.TraceLogs.Trace(traceID.String()),
+		Exemplars:   telemetry.TraceExemplars(traceID),
+	}
+	if len(bundle.Spans) == 0 && len(bundle.Logs) == 0 && len(bundle.Exemplars) == 0 {
+		h.reject(c, problem.Newf(problem.TraceNotFound, "No spans, logs or exemplars are left for trace %s", bundle.TraceID))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "trace-"+bundle.TraceID+".json"))
+	c.IndentedJSON(http.StatusOK, bundle)
+}
+
+// GetUsage reports request counts, error rates, and data volume per hashed
+// API key.
+func (h *AdminHandler) GetUsage(c *gin.Context) {
+	keys := h.usage.Report()
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  keys,
+		"count": len(keys),
+	})
+}
+
+// GetExperiment reports traffic, error rate, and latency per variant of the
+// running experiment.
+func (h *AdminHandler) GetExperiment(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": h.exp.Name(),
+		"variants":   h.exp.Report(),
+	})
+}
+
+// GetSerialization reports response body sizes and JSON encoding time per
+// route, with a histogram of encoding times.
+func (h *AdminHandler) GetSerialization(c *gin.Context) {
+	routes := h.encode.Report()
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes": routes,
+		"count":  len(routes),
+	})
+}
+
+// GetServiceCalls reports call counts and latency per service operation,
+// split by the API tier that made them.
+func (h *AdminHandler) GetServiceCalls(c *gin.Context) {
+	operations := h.calls.Report()
+
+	c.JSON(http.StatusOK, gin.H{
+		"operations": operations,
+		"count":      len(operations),
+	})
+}
+
+// GetCacheStats reports response cache hits, misses, the handler time hits
+// saved, and how many entries write invalidations dropped.
+func (h *AdminHandler) GetCacheStats(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":       true,
+		"stats":         h.cache.Stats(),
+		"invalidations": h.bus.Stats(),
+		"degradation":   h.cache.Degradation(),
+	})
+}
+
+// SetCacheOutage starts or ends a simulated cache backend outage, to demo
+// the response cache's degraded mode.
+func (h *AdminHandler) SetCacheOutage(c *gin.Context) {
+	if h.cache == nil {
+		h.reject(c, problem.New(problem.CacheDisabled, ""))
+		return
+	}
+	var req toggleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.reject(c, problem.Newf(problem.ValidationFailed, "Invalid request body: %s", err.Error()))
+		return
+	}
+
+	if h.cache.SetOutage(*req.Enabled) {
+		log.Printf("🧪 Simulated cache outage set to %t", *req.Enabled)
+	}
+	c.JSON(http.StatusOK, h.cache.Degradation())
+}
+
+// GetCoalescingStats reports how many V2 GETs led, followed, or fell back
+// to running the handler themselves.
+func (h *AdminHandler) GetCoalescingStats(c *gin.Context) {
+	if h.merge == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"stats":   h.merge.Stats(),
+	})
+}
+
+// GetStorage reports how many objects and roughly how many bytes each
+// storage backend held at the last scheduled refresh. ?refresh=true
+// measures again first.
+func (h *AdminHandler) GetStorage(c *gin.Context) {
+	if refresh, _ := strconv.ParseBool(c.Query("refresh")); refresh {
+		c.JSON(http.StatusOK, h.storage.Refresh(c.Request.Context()))
+		return
+	}
+	c.JSON(http.StatusOK, h.storage.Report())
+}
+
+// GetProbe reports the self-probe's recent runs, newest first, and the
+// share of them that succeeded.
+func (h *AdminHandler) GetProbe(c *gin.Context) {
+	if h.probe == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"report":  h.probe.Report(),
+	})
+}
+
+// GetReadOnly reports whether writes are being rejected.
+func (h *AdminHandler) GetReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, h.guard.Status())
+}
+
+// toggleRequest switches a mode on or off.
+type toggleRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// SetReadOnly switches read-only mode on or off, e.g. around a migration.
+func (h *AdminHandler) SetReadOnly(c *gin.Context) {
+	var req toggleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.reject(c, problem.Newf(problem.ValidationFailed, "Invalid request body: %s", err.Error()))
+		return
+	}
+
+	if h.guard.Set(*req.Enabled) {
+		log.Printf("🔒 Read-only mode set to %t", *req.Enabled)
+	}
+	c.JSON(http.StatusOK, h.guard.Status())
+}
+
+// GetSpanStatusAudit lists spans whose status contradicts what they
+// recorded, such as an error event on a span that isn't marked Error.
+func (h *AdminHandler) GetSpanStatusAudit(c *gin.Context) {
+	violations := h.audit.Report()
+
+	c.JSON(http.StatusOK, gin.H{
+		"violations": violations,
+		"count":      len(violations),
+	})
+}
+
+// GetCacheSweeps reports the response cache's background cleanup loop:
+// items scanned and expired by the last sweep, its duration, and when the
+// next sweep is due.
+func (h *AdminHandler) GetCacheSweeps(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"sweeper": h.cache.SweepStats(),
+	})
+}
+
+// GetIncidents lists error rate incidents, newest first, with the trace IDs
+// each one captured.
+func (h *AdminHandler) GetIncidents(c *gin.Context) {
+	incidents := h.anomaly.Incidents()
+
+	c.JSON(http.StatusOK, gin.H{
+		"incidents": incidents,
+		"count":     len(incidents),
+	})
+}
+
+// GetIncident returns one incident record.
+func (h *AdminHandler) GetIncident(c *gin.Context) {
+	incident, ok := h.anomaly.Incident(c.Param("id"))
+	if !ok {
+		h.reject(c, problem.Newf(problem.IncidentNotFound, "No incident with ID %s", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+type annotationRequest struct {
+	Kind    string `json:"kind" binding:"required"`
+	Message string `json:"message" binding:"required"`
+	// Duration such as "15m"; empty keeps the annotation active until it
+	// is ended.
+	Duration string `json:"duration"`
+}
+
+// CreateAnnotation records an operator annotation such as a deploy or a
+// chaos experiment. Root spans started while it is active carry its ID.
+func (h *AdminHandler) CreateAnnotation(c *gin.Context) {
+	var req annotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.reject(c, problem.Newf(problem.ValidationFailed, "Invalid request body: %s", err.Error()))
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil || parsed <= 0 {
+			h.reject(c, problem.Newf(problem.ValidationFailed, "Invalid duration %q", req.Duration))
+			return
+		}
+		duration = parsed
+	}
+
+	annotation := h.notes.Annotate(c.Request.Context(), req.Kind, req.Message, duration)
+	c.JSON(http.StatusCreated, annotation)
+}
+
+// GetAnnotations lists operator annotations, newest first.
+func (h *AdminHandler) GetAnnotations(c *gin.Context) {
+	annotations := h.notes.List()
+
+	c.JSON(http.StatusOK, gin.H{
+		"annotations": annotations,
+		"count":       len(annotations),
+	})
+}
+
+// EndAnnotation ends an annotation before its duration runs out.
+func (h *AdminHandler) EndAnnotation(c *gin.Context) {
+	annotation, ok := h.notes.End(c.Request.Context(), c.Param("id"))
+	if !ok {
+		h.reject(c, problem.Newf(problem.AnnotationNotFound, "No annotation with ID %s", c.Param("id")))
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
+
+// reject answers an admin request with p, tagging the request's span with
+// its code the way the V2 handlers do.
+func (h *AdminHandler) reject(c *gin.Context, p *problem.Details) {
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attrs.ErrorCode.String(string(p.Code)))
+	problem.Abort(c, span, p)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/models"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Pub/sub component and topic the activity subscription listens on.
+const (
+	DaprPubSubName    = "pubsub"
+	DaprActivityTopic = "subscriber-activity"
+)
+
+// Delivery results Dapr understands in a subscription response: SUCCESS
+// acknowledges the message, RETRY asks for redelivery, DROP discards it.
+const (
+	daprSuccess = "SUCCESS"
+	daprRetry   = "RETRY"
+	daprDrop    = "DROP"
+)
+
+type daprSubscription struct {
+	PubSubName string `json:"pubsubname"`
+	Topic      string `json:"topic"`
+	Route      string `json:"route"`
+}
+
+// cloudEvent is the envelope Dapr delivers pub/sub messages in.
+type cloudEvent struct {
+	ID         string          `json:"id"`
+	Source     string          `json:"source"`
+	Type       string          `json:"type"`
+	Topic      string          `json:"topic"`
+	PubSubName string          `json:"pubsubname"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// DaprHandler serves the endpoints a Dapr sidecar calls on its app: the
+// subscription list it reads at startup and the topic deliveries that
+// follow. Service invocation reuses the V2 handlers under /dapr.
+type DaprHandler struct {
+	events   *EventsHandler
+	basePath string
+}
+
+// NewDaprHandler delivers activity messages to events. basePath is the
+// router's base path, which subscription routes must include.
+func NewDaprHandler(events *EventsHandler, basePath string) *DaprHandler {
+	return &DaprHandler{events: events, basePath: basePath}
+}
+
+// Subscribe lists the topics this app consumes, answering the sidecar's
+// GET /dapr/subscribe.
+func (h *DaprHandler) Subscribe(c *gin.Context) {
+	c.JSON(http.StatusOK, []daprSubscription{{
+		PubSubName: DaprPubSubName,
+		Topic:      DaprActivityTopic,
+		Route:      h.basePath + "/dapr/events/activity",
+	}})
+}
+
+// ReceiveActivity queues the activity events in a pub/sub delivery, like
+// POST /v1/events. The sidecar forwards the publisher's traceparent, so the
+// delivery joins the publisher's trace. Malformed messages are dropped,
+// since redelivering them can't help; a full queue or a store that can't
+// answer asks for a retry.
+func (h *DaprHandler) ReceiveActivity(c *gin.Context) {
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(
+		attrs.MessagingSystem.String("dapr"),
+		attrs.MessagingOperation.String("receive"),
+	)
+
+	var event cloudEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		h.respond(c, span, daprDrop, "Invalid CloudEvent", err)
+		return
+	}
+	span.SetAttributes(
+		attrs.MessagingDestination.String(event.Topic),
+		attrs.MessagingMessageID.String(event.ID),
+		attribute.String("dapr.pubsub_name", event.PubSubName),
+		attribute.String("cloudevents.event_source", event.Source),
+		attribute.String("cloudevents.event_type", event.Type),
+	)
+
+	var batch []models.ActivityEvent
+	if err := json.Unmarshal(event.Data, &batch); err != nil {
+		h.respond(c, span, daprDrop, "Invalid activity data", err)
+		return
+	}
+	if len(batch) == 0 || len(batch) > maxEventsPerRequest {
+		h.respond(c, span, daprDrop, "Invalid batch size",
+			fmt.Errorf("expected 1 to %d events, got %d", maxEventsPerRequest, len(batch)))
+		return
+	}
+	span.SetAttributes(attrs.MessagingBatchCount.Int(len(batch)))
+
+	now := time.Now()
+	for i := range batch {
+		if err := h.events.validate(c.Request.Context(), &batch[i], now); err != nil {
+			var invalid invalidEvent
+			if !errors.As(err, &invalid) {
+				h.respond(c, span, daprRetry, "Event validation failed", err)
+				return
+			}
+			h.respond(c, span, daprDrop, "Invalid event", fmt.Errorf("event %d: %w", i, err))
+			return
+		}
+	}
+
+	accepted, err := h.events.ingester.Enqueue(batch)
+	span.SetAttributes(attribute.Int("events.accepted", accepted))
+	if err != nil || accepted == 0 {
+		if err == nil {
+			err = errors.New("ingestion queue is full")
+		}
+		h.respond(c, span, daprRetry, "Ingestion unavailable", err)
+		return
+	}
+	h.respond(c, span, daprSuccess, "", nil)
+}
+
+// respond answers 200 with the delivery result; Dapr reads the status
+// field, not the HTTP status, to decide what happens to the message.
+func (h *DaprHandler) respond(c *gin.Context, span trace.Span, status, message string, err error) {
+	span.SetAttributes(attribute.String("dapr.delivery_status", status))
+	if err != nil {
+		h.events.logger.WithFields(logrus.Fields{
+			"endpoint":        c.FullPath(),
+			"delivery_status": status,
+			"error":           err.Error(),
+			"trace_id":        span.SpanContext().TraceID().String(),
+		}).Warn(message)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
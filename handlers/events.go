@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/budget"
+	"telemetry-demo/events"
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
+	"telemetry-demo/models"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// maxEventsPerRequest bounds how much one request can push into the queue.
+const maxEventsPerRequest = 1000
+
+// EventsHandler is the write-heavy ingestion path. Unlike the CRUD handlers
+// it creates a single span per request with no children and logs only
+// failures; volume is tracked through the ingester's counters instead.
+type EventsHandler struct {
+	store      *store.MemoryStore
+	ingester   *events.Ingester
+	aggregator *events.Aggregator
+	logger     *logrus.Logger
+	tracer     trace.Tracer
+}
+
+func NewEventsHandler(store *store.MemoryStore, ingester *events.Ingester, aggregator *events.Aggregator) *EventsHandler {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		TimestampFormat: "15:04:05",
+		FullTimestamp:   true,
+		ForceColors:     true,
+	})
+	logger.AddHook(logruslog.IndexHook(logging.TraceLogs))
+
+	return &EventsHandler{
+		store:      store,
+		ingester:   ingester,
+		aggregator: aggregator,
+		logger:     logger,
+		tracer:     otel.Tracer("telemetry-demo/events"),
+	}
+}
+
+// IngestEvents accepts a JSON array of activity events and queues them for
+// batched persistence, answering 202 before they are stored.
+func (h *EventsHandler) IngestEvents(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ingest_events_request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(c.FullPath()),
+		),
+	)
+	defer span.End()
+
+	var batch []models.ActivityEvent
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		h.reject(c, span, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if len(batch) == 0 || len(batch) > maxEventsPerRequest {
+		h.reject(c, span, http.StatusBadRequest, "Invalid batch size",
+			fmt.Errorf("expected 1 to %d events, got %d", maxEventsPerRequest, len(batch)))
+		return
+	}
+
+	now := time.Now()
+	for i := range batch {
+		if err := h.validate(ctx, &batch[i], now); err != nil {
+			var invalid invalidEvent
+			switch {
+			case errors.As(err, &invalid):
+				h.reject(c, span, http.StatusBadRequest, "Invalid event", fmt.Errorf("event %d: %w", i, err))
+			case errors.Is(err, budget.ErrExhausted):
+				h.reject(c, span, http.StatusGatewayTimeout, "Latency budget exhausted", err)
+			default:
+				h.reject(c, span, http.StatusInternalServerError, "Event validation failed", err)
+			}
+			return
+		}
+	}
+
+	accepted, err := h.ingester.Enqueue(batch)
+	span.SetAttributes(
+		attribute.Int("events.count", len(batch)),
+		attribute.Int("events.accepted", accepted),
+	)
+	if err != nil || accepted == 0 {
+		if err == nil {
+			err = fmt.Errorf("ingestion queue is full")
+		}
+		c.Header("Retry-After", "1")
+		h.reject(c, span, http.StatusServiceUnavailable, "Ingestion unavailable", err)
+		return
+	}
+
+	span.SetAttributes(attrs.HTTPStatusCode.Int(http.StatusAccepted))
+	c.JSON(http.StatusAccepted, gin.H{
+		"accepted": accepted,
+		"rejected": len(batch) - accepted,
+	})
+}
+
+// GetEventStats reports ingestion throughput, aggregation progress and lag,
+// and the most engaged subscribers. Use ?top=N to size the leaderboard
+// (default 10, 0 for all).
+func (h *EventsHandler) GetEventStats(c *gin.Context) {
+	top, err := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if err != nil || top < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid top parameter"})
+		return
+	}
+
+	subscribers, err := h.store.GetAllSubscribers(c.Request.Context(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	engaged := make([]*models.Subscriber, 0)
+	for _, subscriber := range subscribers {
+		if subscriber.Engagement != nil {
+			engaged = append(engaged, subscriber)
+		}
+	}
+	sort.Slice(engaged, func(i, j int) bool {
+		if engaged[i].Engagement.Score != engaged[j].Engagement.Score {
+			return engaged[i].Engagement.Score > engaged[j].Engagement.Score
+		}
+		return engaged[i].ID < engaged[j].ID
+	})
+	if top > 0 && top < len(engaged) {
+		engaged = engaged[:top]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ingestion":   h.ingester.Stats(),
+		"aggregation": h.aggregator.Stats(),
+		"top_engaged": engaged,
+	})
+}
+
+// invalidEvent is a validation failure the client can fix, as opposed to
+// the store failing to answer.
+type invalidEvent struct{ error }
+
+func (h *EventsHandler) validate(ctx context.Context, event *models.ActivityEvent, now time.Time) error {
+	switch event.Type {
+	case models.EventOpen, models.EventClick:
+	default:
+		return invalidEvent{fmt.Errorf("type must be %q or %q", models.EventOpen, models.EventClick)}
+	}
+	exists, err := h.store.SubscriberExists(ctx, event.SubscriberID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return invalidEvent{fmt.Errorf("unknown subscriber %d", event.SubscriberID)}
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = now
+	}
+	return nil
+}
+
+func (h *EventsHandler) reject(c *gin.Context, span trace.Span, status int, message string, err error) {
+	span.SetAttributes(attrs.HTTPStatusCode.Int(status))
+	if status >= http.StatusInternalServerError {
+		telemetry.FailSpan(span, err, message)
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"method":   c.Request.Method,
+		"endpoint": c.FullPath(),
+		"status":   status,
+		"error":    err.Error(),
+		"trace_id": span.SpanContext().TraceID().String(),
+	}).Warn(message)
+
+	c.JSON(status, gin.H{"error": message, "details": err.Error()})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
+	"telemetry-demo/middleware"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Route placeholders recorded on spans for requests that matched nothing, so
+// unknown paths don't each become their own http.route value.
+const (
+	notFoundRoute         = "<not_found>"
+	methodNotAllowedRoute = "<method_not_allowed>"
+)
+
+var (
+	fallbackMeter     = telemetry.Meter("telemetry-demo/router")
+	unmatchedRequests = telemetry.Int64Counter(fallbackMeter, "http.server.unmatched_requests", "{request}",
+		"Requests no route matched, by http.route placeholder")
+)
+
+// FallbackHandler answers requests gin couldn't route, making sure they
+// still produce a span and a log line instead of an untraced default 404.
+type FallbackHandler struct {
+	logger *logrus.Logger
+	tracer trace.Tracer
+	routes *middleware.RouteTable
+}
+
+func NewFallbackHandler(routes *middleware.RouteTable) *FallbackHandler {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		TimestampFormat: "15:04:05",
+		FullTimestamp:   true,
+		ForceColors:     true,
+	})
+	logger.AddHook(logruslog.IndexHook(logging.TraceLogs))
+
+	return &FallbackHandler{
+		logger: logger,
+		tracer: otel.Tracer("telemetry-demo/router"),
+		routes: routes,
+	}
+}
+
+func (h *FallbackHandler) NotFound(c *gin.Context) {
+	h.respond(c, http.StatusNotFound, notFoundRoute, "Route not found")
+}
+
+func (h *FallbackHandler) MethodNotAllowed(c *gin.Context) {
+	c.Header("Allow", h.routes.AllowHeader(c.Request.URL.Path))
+	h.respond(c, http.StatusMethodNotAllowed, methodNotAllowedRoute, "Method not allowed")
+}
+
+// respond leaves the span status Unset: a client asking for a route that
+// doesn't exist isn't a server error.
+func (h *FallbackHandler) respond(c *gin.Context, status int, route, message string) {
+	_, span := h.tracer.Start(c.Request.Context(), route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(route),
+			attrs.HTTPTarget.String(c.Request.URL.Path),
+			attrs.HTTPStatusCode.Int(status),
+		),
+	)
+	defer span.End()
+	// Only the placeholder: the method and path are the client's to choose
+	unmatchedRequests.Add(c.Request.Context(), 1, metric.WithAttributes(attrs.HTTPRoute.String(route)))
+
+	h.logger.WithFields(logrus.Fields{
+		"method":   c.Request.Method,
+		"path":     c.Request.URL.Path,
+		"route":    route,
+		"status":   status,
+		"trace_id": span.SpanContext().TraceID().String(),
+	}).Warn(message)
+
+	c.JSON(status, gin.H{
+		"error":  message,
+		"method": c.Request.Method,
+		"path":   c.Request.URL.Path,
+	})
+}
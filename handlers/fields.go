@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/logging"
+	"telemetry-demo/models"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+)
+
+// subscriberFieldNames are the members of a subscriber's JSON that
+// ?fields= may select, taken from the model's json tags.
+var subscriberFieldNames = jsonFieldNames(reflect.TypeOf(models.Subscriber{}))
+
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// fieldSet is a ?fields=id,email sparse fieldset. Subscribers are projected
+// onto it after the service returns them, and it tallies how many bytes the
+// projection saved.
+type fieldSet struct {
+	names     []string
+	keep      map[string]bool
+	full      int
+	projected int
+}
+
+// parseFields reads the fields query parameter. It returns nil when the
+// request doesn't ask for a sparse fieldset.
+func parseFields(c *gin.Context) (*fieldSet, *apiError) {
+	param := c.Query("fields")
+	if param == "" {
+		return nil, nil
+	}
+
+	fields := &fieldSet{keep: make(map[string]bool)}
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || fields.keep[name] {
+			continue
+		}
+		if !isSubscriberField(name) {
+			return nil, &apiError{
+				status:    http.StatusBadRequest,
+				code:      problem.UnknownField,
+				message:   "Unknown field %q, must be one of %s",
+				args:      []any{name, strings.Join(subscriberFieldNames, ", ")},
+				errorType: telemetry.ErrorValidation,
+				logMsg:    "Unknown field requested",
+				level:     logging.ErrorLevel,
+				cause:     &unknownFieldError{field: name},
+				fields:    logging.Fields{"fields": param},
+			}
+		}
+		fields.names = append(fields.names, name)
+		fields.keep[name] = true
+	}
+	if len(fields.names) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func isSubscriberField(name string) bool {
+	for _, known := range subscriberFieldNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownFieldError is the cause logged for a ?fields= member that isn't a
+// subscriber field.
+type unknownFieldError struct {
+	field string
+}
+
+func (e *unknownFieldError) Error() string {
+	return "unknown field " + e.field
+}
+
+// subscriber returns s with only the selected members.
+func (f *fieldSet) subscriber(s *models.Subscriber) map[string]json.RawMessage {
+	full, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(full, &members); err != nil {
+		return nil
+	}
+
+	out := make(map[string]json.RawMessage, len(f.names))
+	for _, name := range f.names {
+		if value, ok := members[name]; ok {
+			out[name] = value
+		}
+	}
+	projected, _ := json.Marshal(out)
+	f.full += len(full)
+	f.projected += len(projected)
+	return out
+}
+
+// subscribers projects every subscriber in list.
+func (f *fieldSet) subscribers(list []*models.Subscriber) []map[string]json.RawMessage {
+	out := make([]map[string]json.RawMessage, len(list))
+	for i, s := range list {
+		out[i] = f.subscriber(s)
+	}
+	return out
+}
+
+// record puts the requested fields and the bytes projection saved on span.
+// The sizes cover the subscribers only, not the envelope around them.
+func (f *fieldSet) record(span trace.Span) {
+	span.SetAttributes(
+		attribute.StringSlice("fields.requested", f.names),
+		attribute.Int("fields.full_bytes", f.full),
+		attribute.Int("fields.projected_bytes", f.projected),
+		attribute.Int("fields.saved_bytes", f.full-f.projected),
+	)
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/budget"
+	"telemetry-demo/i18n"
+	"telemetry-demo/logging"
+	"telemetry-demo/models"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// apiError stops a request with a mapped response. code and message (the
+// problem detail, formatted with args in the caller's language) go to the
+// client; fields and cause go to the log.
+type apiError struct {
+	status    int
+	code      problem.Code
+	message   string
+	args      []any
+	errorType telemetry.ErrorType
+	logMsg    string
+	level     logging.Level
+	cause     error
+	fields    logging.Fields
+}
+
+func invalidID(idStr string) *apiError {
+	return &apiError{
+		status:    http.StatusBadRequest,
+		code:      problem.InvalidID,
+		message:   "%q is not a valid subscriber ID",
+		args:      []any{idStr},
+		errorType: telemetry.ErrorParsing,
+		logMsg:    "Invalid subscriber ID",
+		level:     logging.ErrorLevel,
+		cause:     errors.New("Invalid ID format"),
+		fields:    logging.Fields{"id": idStr},
+	}
+}
+
+// budgetExhausted reports that a layer refused work once the request's
+// latency budget ran out.
+func budgetExhausted(ctx context.Context, err error) *apiError {
+	total := budget.From(ctx).Total()
+	return &apiError{
+		status:    http.StatusGatewayTimeout,
+		code:      problem.BudgetExhausted,
+		message:   "The request ran out of its %s latency budget",
+		args:      []any{total.String()},
+		errorType: telemetry.ErrorBudgetExhausted,
+		logMsg:    "Latency budget exhausted",
+		level:     logging.WarnLevel,
+		cause:     err,
+		fields:    logging.Fields{"budget": total},
+	}
+}
+
+// serviceFailed maps an error from the subscriber service: a layer that
+// refused work for lack of latency budget is a 504, anything else a 500.
+func serviceFailed(ctx context.Context, err error) *apiError {
+	if errors.Is(err, budget.ErrExhausted) {
+		return budgetExhausted(ctx, err)
+	}
+	return &apiError{
+		status:    http.StatusInternalServerError,
+		code:      problem.InternalError,
+		message:   "Internal server error",
+		errorType: telemetry.ErrorTypeOf(err),
+		logMsg:    "Service call failed",
+		level:     logging.ErrorLevel,
+		cause:     err,
+	}
+}
+
+func subscriberNotFound(id int) *apiError {
+	return &apiError{
+		status:    http.StatusNotFound,
+		code:      problem.SubscriberNotFound,
+		message:   "No subscriber with ID %d",
+		args:      []any{id},
+		errorType: telemetry.ErrorNotFound,
+		logMsg:    "Subscriber not found",
+		level:     logging.WarnLevel,
+		fields:    logging.Fields{"subscriber_id": id},
+	}
+}
+
+// op is one endpoint expressed as bind (parse and validate the request) and
+// call (run the business logic). call returns the fields to log on success.
+// Endpoints that support ?fields= sparse fieldsets set project, which
+// narrows call's result to the requested fields before it is rendered.
+type op[TReq, TResp any] struct {
+	message string
+	status  int
+	bind    func(c *gin.Context) (TReq, *apiError)
+	call    func(c *gin.Context, req TReq) (TResp, logging.Fields, *apiError)
+	project func(resp TResp, fields *fieldSet) any
+}
+
+// handle runs o under the span otelgin already started, then logs and
+// renders the result the same way for every endpoint: trace and span IDs
+// and duration on each log line, error.type, error.category, and error.code
+// on the span and the log line for mapped errors, and a problem+json body for failures. o runs within the
+// request's latency budget: once any layer refuses work for lack of it, the
+// response is a 504 whatever call returned.
+func handle[TReq, TResp any](h *V2Handler, c *gin.Context, o op[TReq, TResp]) {
+	start := time.Now()
+	span := trace.SpanFromContext(c.Request.Context())
+	log := h.logger.WithTracing(c.Request.Context()).WithFields(logging.Fields{
+		"method":   c.Request.Method,
+		"endpoint": c.FullPath(),
+	})
+
+	if apiErr := h.startBudget(c); apiErr != nil {
+		h.fail(c, span, log, apiErr, start)
+		return
+	}
+
+	var sparse *fieldSet
+	if o.project != nil {
+		var apiErr *apiError
+		if sparse, apiErr = parseFields(c); apiErr != nil {
+			h.fail(c, span, log, apiErr, start)
+			return
+		}
+	}
+
+	req, apiErr := o.bind(c)
+	if apiErr != nil {
+		h.fail(c, span, log, apiErr, start)
+		return
+	}
+
+	if err := budget.Check(c.Request.Context(), budget.LayerHandler); err != nil {
+		h.fail(c, span, log, budgetExhausted(c.Request.Context(), err), start)
+		return
+	}
+
+	resp, fields, apiErr := o.call(c, req)
+	if err := budget.Err(c.Request.Context()); err != nil {
+		apiErr = budgetExhausted(c.Request.Context(), err)
+	}
+	if apiErr != nil {
+		h.fail(c, span, log, apiErr, start)
+		return
+	}
+
+	log.WithFields(fields).WithFields(logging.Fields{"duration": time.Since(start)}).Info(o.message)
+
+	if o.status == http.StatusNoContent {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if sparse != nil {
+		projected := o.project(resp, sparse)
+		sparse.record(span)
+		renderJSON(c, h.serialization, o.status, projected)
+		return
+	}
+	renderJSON(c, h.serialization, o.status, resp)
+}
+
+func (h *V2Handler) fail(c *gin.Context, span trace.Span, log logging.Logger, apiErr *apiError, start time.Time) {
+	if apiErr.status >= http.StatusInternalServerError && apiErr.cause != nil {
+		// Spans stay in English whatever the caller's language
+		telemetry.FailSpan(span, apiErr.cause, i18n.Sprintf(i18n.DefaultLocale, apiErr.message, apiErr.args...))
+	}
+	// The mapped type wins over whatever FailSpan derived from the cause
+	errorType := apiErr.errorType
+	if errorType == "" {
+		errorType = telemetry.ErrorInternal
+	}
+	telemetry.ClassifyError(span, errorType)
+	span.SetAttributes(attrs.ErrorCode.String(string(apiErr.code)))
+
+	entry := log.WithFields(apiErr.fields).WithFields(telemetry.ErrorFields(errorType)).WithFields(logging.Fields{"duration": time.Since(start)})
+	if apiErr.cause != nil {
+		entry = entry.WithFields(logging.Fields{"error": apiErr.cause.Error()})
+	}
+	entry.Log(apiErr.level, apiErr.logMsg)
+
+	renderProblem(c, h.serialization, problem.Newf(apiErr.code, apiErr.message, apiErr.args...))
+}
+
+// latencyBudgetHeader lets a client set its own latency budget, e.g.
+// "150ms", instead of the server's default.
+const latencyBudgetHeader = "X-Latency-Budget"
+
+// startBudget allocates the request's latency budget: X-Latency-Budget when
+// the client sends it, the handler's default otherwise. A zero default and
+// no header means no budget.
+func (h *V2Handler) startBudget(c *gin.Context) *apiError {
+	total := h.latencyBudget
+	if header := c.GetHeader(latencyBudgetHeader); header != "" {
+		d, err := time.ParseDuration(header)
+		if err == nil && d <= 0 {
+			err = errors.New("must be positive")
+		}
+		if err != nil {
+			return &apiError{
+				status:    http.StatusBadRequest,
+				code:      problem.ValidationFailed,
+				message:   "Invalid X-Latency-Budget header: %s",
+				args:      []any{header},
+				errorType: telemetry.ErrorValidation,
+				logMsg:    "Invalid latency budget",
+				level:     logging.ErrorLevel,
+				cause:     err,
+				fields:    logging.Fields{"latency_budget": header},
+			}
+		}
+		total = d
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	ctx, _ := budget.Start(c.Request.Context(), total)
+	c.Request = c.Request.WithContext(ctx)
+	return nil
+}
+
+// noBody binds nothing, for endpoints without input.
+func noBody(*gin.Context) (struct{}, *apiError) {
+	return struct{}{}, nil
+}
+
+// bindID parses the :id path parameter.
+func bindID(c *gin.Context) (int, *apiError) {
+	idStr := c.Param("id")
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("subscriber.id_param", idStr))
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, invalidID(idStr)
+	}
+	return id, nil
+}
+
+// bindSubscriber decodes a subscriber body, keeping the raw body for the
+// log and span when it doesn't parse.
+func bindSubscriber(c *gin.Context) (models.Subscriber, *apiError) {
+	body, _ := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	var req models.Subscriber
+	if err := c.ShouldBindJSON(&req); err != nil {
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.body", string(body)))
+		return req, &apiError{
+			status:    http.StatusBadRequest,
+			code:      problem.ValidationFailed,
+			message:   "Invalid request body: %s",
+			args:      []any{err.Error()},
+			errorType: telemetry.ErrorValidation,
+			logMsg:    "Invalid request body",
+			level:     logging.ErrorLevel,
+			cause:     err,
+			fields:    logging.Fields{"raw_body": string(body)},
+		}
+	}
+	return req, nil
+}
+
+type subscriberUpdate struct {
+	id   int
+	body models.Subscriber
+}
+
+// bindUpdate parses the :id path parameter and the subscriber body.
+func bindUpdate(c *gin.Context) (subscriberUpdate, *apiError) {
+	id, apiErr := bindID(c)
+	if apiErr != nil {
+		return subscriberUpdate{}, apiErr
+	}
+
+	body, apiErr := bindSubscriber(c)
+	if apiErr != nil {
+		apiErr.fields["subscriber_id"] = id
+		return subscriberUpdate{}, apiErr
+	}
+	return subscriberUpdate{id: id, body: body}, nil
+}
+
+// bindSort parses the ?sort= query parameter: comma-separated subscriber
+// fields, each prefixed with - to sort descending, e.g. created_at,-name.
+// created is accepted for created_at. A field named twice keeps its first
+// direction.
+func bindSort(c *gin.Context) ([]models.SortKey, *apiError) {
+	param := c.Query("sort")
+	if param == "" {
+		return nil, nil
+	}
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("sort.param", param))
+
+	var keys []models.SortKey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(param, ",") {
+		part = strings.TrimSpace(part)
+		key := models.SortKey{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if key.Field == "" {
+			continue
+		}
+		field, ok := models.SortField(key.Field)
+		if !ok {
+			return nil, &apiError{
+				status:    http.StatusBadRequest,
+				code:      problem.UnknownField,
+				message:   "Unknown sort field %q, must be one of %s",
+				args:      []any{key.Field, strings.Join(models.SortFields, ", ")},
+				errorType: telemetry.ErrorValidation,
+				logMsg:    "Unknown sort field",
+				level:     logging.ErrorLevel,
+				cause:     &unknownFieldError{field: key.Field},
+				fields:    logging.Fields{"sort": param},
+			}
+		}
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		key.Field = field
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// bindFilter parses the optional ?email_domain= query parameter, with or
+// without a leading @. No parameter means no filter.
+func bindFilter(c *gin.Context) (*models.SubscriberFilter, *apiError) {
+	domain := strings.TrimPrefix(strings.TrimSpace(c.Query("email_domain")), "@")
+	if domain == "" {
+		return nil, nil
+	}
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("filter.email_domain", domain))
+	return &models.SubscriberFilter{EmailDomain: domain}, nil
+}
+
+// bindIDList parses the ?ids= query parameter.
+func bindIDList(c *gin.Context) ([]int, *apiError) {
+	idsParam := c.Query("ids")
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("subscriber.ids_param", idsParam))
+
+	ids, err := parseIDList(idsParam)
+	if err != nil {
+		return nil, &apiError{
+			status:    http.StatusBadRequest,
+			code:      problem.InvalidID,
+			message:   "Invalid ids parameter: %s",
+			args:      []any{err.Error()},
+			errorType: telemetry.ErrorParsing,
+			logMsg:    "Invalid subscriber IDs",
+			level:     logging.ErrorLevel,
+			cause:     err,
+			fields:    logging.Fields{"ids": idsParam},
+		}
+	}
+	return ids, nil
+}
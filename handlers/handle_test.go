@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"telemetry-demo/models"
+	"telemetry-demo/problem"
+)
+
+func TestBindSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		param     string
+		want      string
+		wantError string
+	}{
+		{name: "no parameter", param: "", want: ""},
+		{name: "created_at ascending", param: "created_at", want: "created_at"},
+		{name: "created is an alias", param: "-created", want: "-created_at"},
+		{name: "several keys keep their order", param: "created_at,-name,email", want: "created_at,-name,email"},
+		{name: "spaces and empty keys are skipped", param: " name , ,-id", want: "name,-id"},
+		{name: "first direction of a repeated key wins", param: "-name,name", want: "-name"},
+		{name: "alias and field are the same key", param: "created,-created_at", want: "created_at"},
+		{name: "unknown field", param: "name,shoe_size", wantError: "shoe_size"},
+		{name: "unknown descending field", param: "-createdAt", wantError: "createdAt"},
+		{name: "lone minus", param: "-", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/v2/subscribers?sort="+url.QueryEscape(tt.param), nil)
+
+			keys, apiErr := bindSort(c)
+
+			if tt.wantError != "" {
+				if apiErr == nil {
+					t.Fatalf("got keys %q, want an error for %q", models.FormatSort(keys), tt.wantError)
+				}
+				if apiErr.status != http.StatusBadRequest || apiErr.code != problem.UnknownField {
+					t.Errorf("got %d %s, want 400 %s", apiErr.status, apiErr.code, problem.UnknownField)
+				}
+				var unknown *unknownFieldError
+				if !errors.As(apiErr.cause, &unknown) || unknown.field != tt.wantError {
+					t.Errorf("cause = %v, want unknown field %s", apiErr.cause, tt.wantError)
+				}
+				if !slices.Contains(apiErr.args, any(tt.wantError)) {
+					t.Errorf("problem args %v don't name %s", apiErr.args, tt.wantError)
+				}
+				return
+			}
+			if apiErr != nil {
+				t.Fatalf("unexpected error: %s", apiErr.logMsg)
+			}
+			if got := models.FormatSort(keys); got != tt.want {
+				t.Errorf("keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
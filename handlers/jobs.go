@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/jobs"
+	"telemetry-demo/logging"
+	"telemetry-demo/models"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+)
+
+// acceptSubscriber is CreateSubscriber in async write mode: it answers 202
+// as soon as the write is queued, with a Location to poll, and a pool
+// worker validates and stores the subscriber afterwards. The request's
+// trace ends at the 202; the write is a trace of its own whose root span
+// links back to it.
+func (h *V2Handler) acceptSubscriber(c *gin.Context) {
+	handle(h, c, op[models.Subscriber, jobs.Job]{
+		message: "Subscriber write accepted",
+		status:  http.StatusAccepted,
+		bind:    bindSubscriber,
+		call: func(c *gin.Context, req models.Subscriber) (jobs.Job, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			span.SetAttributes(
+				attribute.String("user.name", req.Name),
+				attribute.String("user.email", req.Email),
+				attribute.String("write.mode", "async"),
+			)
+
+			job, err := h.writes.Submit(c.Request.Context(), "create_subscriber", func(ctx context.Context) (int, error) {
+				if err := h.service.Validate(ctx, req.Name, req.Email); err != nil {
+					return 0, err
+				}
+				subscriber, err := h.service.Create(ctx, req.Name, req.Email)
+				if err != nil {
+					return 0, err
+				}
+				return subscriber.ID, nil
+			})
+			if err != nil {
+				c.Header("Retry-After", "1")
+				return jobs.Job{}, nil, &apiError{
+					status:    http.StatusServiceUnavailable,
+					code:      problem.ServiceOverloaded,
+					message:   "The write queue is full",
+					errorType: telemetry.ErrorQueueFull,
+					logMsg:    "Write rejected",
+					level:     logging.WarnLevel,
+					cause:     err,
+				}
+			}
+
+			span.SetAttributes(attribute.Int64("job.id", job.ID))
+			c.Header("Location", path.Join(path.Dir(c.FullPath()), "jobs", strconv.FormatInt(job.ID, 10)))
+			return job, logging.Fields{"job_id": job.ID}, nil
+		},
+	})
+}
+
+// GetJob reports how an accepted write is going. Once it has succeeded the
+// job carries the new subscriber's ID.
+func (h *V2Handler) GetJob(c *gin.Context) {
+	handle(h, c, op[int64, jobs.Job]{
+		message: "Retrieved job",
+		status:  http.StatusOK,
+		bind:    bindJobID,
+		call: func(c *gin.Context, id int64) (jobs.Job, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			span.SetAttributes(attribute.Int64("job.id", id))
+
+			var job jobs.Job
+			found := false
+			if h.writes != nil {
+				job, found = h.writes.Get(id)
+			}
+			if !found {
+				return jobs.Job{}, nil, &apiError{
+					status:    http.StatusNotFound,
+					code:      problem.JobNotFound,
+					message:   "No job with ID %d",
+					args:      []any{id},
+					errorType: telemetry.ErrorNotFound,
+					logMsg:    "Job not found",
+					level:     logging.WarnLevel,
+					fields:    logging.Fields{"job_id": id},
+				}
+			}
+
+			span.SetAttributes(attribute.String("job.status", string(job.Status)))
+			return job, logging.Fields{"job_id": job.ID, "status": job.Status}, nil
+		},
+	})
+}
+
+// bindJobID parses the :id path parameter of a job.
+func bindJobID(c *gin.Context) (int64, *apiError) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, &apiError{
+			status:    http.StatusBadRequest,
+			code:      problem.ValidationFailed,
+			message:   "%q is not a valid job ID",
+			args:      []any{idStr},
+			errorType: telemetry.ErrorParsing,
+			logMsg:    "Invalid job ID",
+			level:     logging.ErrorLevel,
+			cause:     err,
+			fields:    logging.Fields{"id": idStr},
+		}
+	}
+	return id, nil
+}
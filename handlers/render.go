@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// renderJSON is c.JSON with the encoding step measured. Serialization time
+// and body size go on the request's span and into recorder, so large
+// payloads show up as a cost rather than disappearing into handler latency.
+func renderJSON(c *gin.Context, recorder *telemetry.SerializationRecorder, status int, obj any) {
+	render(c, recorder, status, "application/json; charset=utf-8", obj)
+}
+
+// renderProblem renders p as application/problem+json, measured like
+// renderJSON.
+func renderProblem(c *gin.Context, recorder *telemetry.SerializationRecorder, p *problem.Details) {
+	p.Prepare(c, trace.SpanFromContext(c.Request.Context()))
+	render(c, recorder, p.Status, problem.ContentType, p)
+}
+
+func render(c *gin.Context, recorder *telemetry.SerializationRecorder, status int, contentType string, obj any) {
+	span := trace.SpanFromContext(c.Request.Context())
+
+	start := time.Now()
+	body, err := json.Marshal(obj)
+	elapsed := time.Since(start)
+	if err != nil {
+		telemetry.FailSpan(span, errors.Join(errors.New("response serialization failed"), err), "")
+		span.SetAttributes(attrs.ErrorCode.String(string(problem.InternalError)))
+		problem.Abort(c, span, problem.New(problem.InternalError, "Failed to encode response"))
+		return
+	}
+
+	span.SetAttributes(
+		attrs.HTTPResponseBodySize.Int(len(body)),
+		attribute.Float64("http.response.serialization_ms", float64(elapsed.Microseconds())/1000),
+	)
+	recorder.Record(c.Request.Context(), c.FullPath(), elapsed, len(body))
+
+	c.Data(status, contentType, body)
+}
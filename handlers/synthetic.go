@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// SyntheticFailure documents what one class of failure looks like in each
+// telemetry signal, so the catalog doubles as a reference for presenters.
+type SyntheticFailure struct {
+	Name       string `json:"name"`
+	Status     int    `json:"status"`
+	SpanStatus string `json:"span_status"`
+	LogLevel   string `json:"log_level"`
+	Signature  string `json:"signature"`
+}
+
+var syntheticCatalog = []SyntheticFailure{
+	{
+		Name:       "panic",
+		Status:     http.StatusInternalServerError,
+		SpanStatus: "Error",
+		LogLevel:   "error",
+		Signature:  "exception event with code.stacktrace on the server span, then gin.Recovery answers 500",
+	},
+	{
+		Name:       "client_error",
+		Status:     http.StatusUnprocessableEntity,
+		SpanStatus: "Unset",
+		LogLevel:   "warning",
+		Signature:  "error.type=validation_error, error.category=validation on the server span; 4xx is the client's fault, so status stays Unset",
+	},
+	{
+		Name:       "server_error",
+		Status:     http.StatusInternalServerError,
+		SpanStatus: "Error",
+		LogLevel:   "error",
+		Signature:  "exception event and error.type=internal_error, error.category=internal on the server span",
+	},
+	{
+		Name:       "slow",
+		Status:     http.StatusOK,
+		SpanStatus: "Unset",
+		LogLevel:   "warning",
+		Signature:  "successful but long server span (?delay=, default 1500ms); slow_span event if TRACE_SLOW_SPAN_THRESHOLD is set",
+	},
+	{
+		Name:       "timeout",
+		Status:     http.StatusGatewayTimeout,
+		SpanStatus: "Error",
+		LogLevel:   "error",
+		Signature:  "synthetic.slow_dependency child span cut off at its 100ms deadline with context.DeadlineExceeded; error.type=timeout on both spans",
+	},
+	{
+		Name:       "downstream",
+		Status:     http.StatusBadGateway,
+		SpanStatus: "Error",
+		LogLevel:   "error",
+		Signature:  "client span to peer.service=inventory-service returning 503, propagated as 502 on the server span; error.category=backend_unavailable on both spans",
+	},
+}
+
+const (
+	defaultSyntheticDelay = 1500 * time.Millisecond
+	maxSyntheticDelay     = 10 * time.Second
+	syntheticTimeout      = 100 * time.Millisecond
+)
+
+// SyntheticHandler emits each class of failure on demand. Routes using it
+// should run under otelgin so the server span matches V2's.
+type SyntheticHandler struct {
+	logger *logrus.Logger
+	tracer trace.Tracer
+}
+
+func NewSyntheticHandler() *SyntheticHandler {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		TimestampFormat: "15:04:05",
+		FullTimestamp:   true,
+		ForceColors:     true,
+	})
+	logger.AddHook(logruslog.IndexHook(logging.TraceLogs))
+
+	return &SyntheticHandler{
+		logger: logger,
+		tracer: otel.Tracer("telemetry-demo/synthetic"),
+	}
+}
+
+// GetCatalog lists the failures that can be triggered and their signatures.
+func (h *SyntheticHandler) GetCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"failures": syntheticCatalog,
+		"count":    len(syntheticCatalog),
+	})
+}
+
+// Trigger emits the failure named by the :failure path parameter.
+func (h *SyntheticHandler) Trigger(c *gin.Context) {
+	failure := c.Param("failure")
+	span := trace.SpanFromContext(c.Request.Context())
+	span.SetAttributes(attribute.String("synthetic.failure", failure))
+
+	log := h.logger.WithFields(logrus.Fields{
+		"method":   c.Request.Method,
+		"endpoint": c.FullPath(),
+		"failure":  failure,
+		"trace_id": span.SpanContext().TraceID().String(),
+	})
+
+	switch failure {
+	case "panic":
+		h.triggerPanic(span, log)
+	case "client_error":
+		telemetry.ClassifyError(span, telemetry.ErrorValidation)
+		log.WithFields(telemetry.ErrorFields(telemetry.ErrorValidation)).Warn("Synthetic validation failure")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "synthetic validation failure"})
+	case "server_error":
+		err := errors.New("synthetic internal error")
+		telemetry.FailSpan(span, err, "")
+		log.WithError(err).WithFields(telemetry.ErrorFields(telemetry.ErrorInternal)).Error("Synthetic server error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case "slow":
+		h.slow(c, log)
+	case "timeout":
+		h.timeout(c, span, log)
+	case "downstream":
+		h.downstream(c, span, log)
+	default:
+		names := make([]string, len(syntheticCatalog))
+		for i, entry := range syntheticCatalog {
+			names[i] = entry.Name
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":     fmt.Sprintf("unknown synthetic failure %q", failure),
+			"available": names,
+		})
+	}
+}
+
+// triggerPanic records the panic on the server span before letting it
+// propagate, since the span would otherwise end with no sign of why the
+// request died.
+func (h *SyntheticHandler) triggerPanic(span trace.Span, log *logrus.Entry) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err := fmt.Errorf("panic: %v", recovered)
+			span.RecordError(err, trace.WithAttributes(attrs.CodeStacktrace.String(string(debug.Stack()))))
+			span.SetStatus(codes.Error, err.Error())
+			log.WithError(err).Error("Synthetic panic")
+			panic(recovered)
+		}
+	}()
+
+	var subscribers map[string]string
+	subscribers["synthetic"] = "boom"
+}
+
+func (h *SyntheticHandler) slow(c *gin.Context, log *logrus.Entry) {
+	delay := defaultSyntheticDelay
+	if raw := c.Query("delay"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 || parsed > maxSyntheticDelay {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delay parameter (max 10s)"})
+			return
+		}
+		delay = parsed
+	}
+
+	time.Sleep(delay)
+
+	log.WithField("duration", delay).Warn("Synthetic slow request")
+	c.JSON(http.StatusOK, gin.H{"status": "slow", "delay": delay.String()})
+}
+
+func (h *SyntheticHandler) timeout(c *gin.Context, span trace.Span, log *logrus.Entry) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), syntheticTimeout)
+	defer cancel()
+
+	_, child := h.tracer.Start(ctx, "synthetic.slow_dependency", trace.WithAttributes(
+		attribute.String("operation", "slow_dependency"),
+		attribute.Int64("timeout_ms", syntheticTimeout.Milliseconds()),
+	))
+
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+	}
+	err := ctx.Err()
+	telemetry.FailSpan(child, err, "dependency timed out")
+	child.End()
+
+	span.SetStatus(codes.Error, "dependency timed out")
+	telemetry.ClassifyError(span, telemetry.ErrorTimeout)
+	log.WithError(err).WithFields(telemetry.ErrorFields(telemetry.ErrorTimeout)).Error("Synthetic timeout")
+	c.JSON(http.StatusGatewayTimeout, gin.H{"error": "dependency timed out"})
+}
+
+func (h *SyntheticHandler) downstream(c *gin.Context, span trace.Span, log *logrus.Entry) {
+	_, child := h.tracer.Start(c.Request.Context(), "GET /inventory",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrs.PeerService.String("inventory-service"),
+			attrs.HTTPMethod.String(http.MethodGet),
+			attrs.HTTPStatusCode.Int(http.StatusServiceUnavailable),
+		),
+	)
+	time.Sleep(20 * time.Millisecond)
+	err := telemetry.WithErrorType(errors.New("inventory-service returned 503"), telemetry.ErrorUnavailable)
+	telemetry.FailSpan(child, err, "")
+	child.End()
+
+	span.SetStatus(codes.Error, "downstream failure")
+	telemetry.ClassifyError(span, telemetry.ErrorUnavailable)
+	log.WithError(err).WithFields(telemetry.ErrorFields(telemetry.ErrorUnavailable)).Error("Synthetic downstream failure")
+	c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+}
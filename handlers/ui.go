@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	ginrender "github.com/gin-gonic/gin/render"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/logging"
+	"telemetry-demo/models"
+	"telemetry-demo/service"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// UIHandler serves a minimal HTML page for listing and creating
+// subscribers. Every render is its own span, and every page carries the
+// request span's traceparent in a meta tag and a Server-Timing header, so
+// spans a browser (RUM) agent records join the backend trace.
+type UIHandler struct {
+	service   service.SubscriberService
+	templates *template.Template
+	logger    logging.ContextLogger
+	tracer    trace.Tracer
+}
+
+func NewUIHandler(service service.SubscriberService, logger logging.ContextLogger) *UIHandler {
+	return &UIHandler{
+		service:   service,
+		templates: template.Must(template.ParseFS(templateFS, "templates/*.html")),
+		logger:    logger,
+		tracer:    otel.Tracer("telemetry-demo/ui"),
+	}
+}
+
+// subscriberForm is the page's create form.
+type subscriberForm struct {
+	Name  string `form:"name" binding:"required"`
+	Email string `form:"email" binding:"required,email"`
+}
+
+// subscribersPage is the data the subscribers template renders.
+type subscribersPage struct {
+	Traceparent string
+	Subscribers []*models.Subscriber
+	Form        subscriberForm
+	Error       string
+}
+
+// ListSubscribers renders every subscriber and the create form.
+func (h *UIHandler) ListSubscribers(c *gin.Context) {
+	subscribers, err := h.service.List(c.Request.Context(), nil)
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	h.render(c, http.StatusOK, "subscribers.html", subscribersPage{Subscribers: subscribers})
+}
+
+// CreateSubscriber creates a subscriber from the form, then redirects back
+// to the list so reloading the page doesn't post the form again. An
+// invalid form renders the page again with the error.
+func (h *UIHandler) CreateSubscriber(c *gin.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContext(ctx)
+	log := h.logger.WithTracing(ctx)
+
+	var form subscriberForm
+	if err := c.ShouldBind(&form); err != nil {
+		telemetry.ClassifyError(span, telemetry.ErrorValidation)
+		log.WithFields(telemetry.ErrorFields(telemetry.ErrorValidation)).WithFields(logging.Fields{"error": err.Error()}).Warn("Invalid subscriber form")
+		subscribers, err := h.service.List(ctx, nil)
+		if err != nil {
+			h.failed(c, err)
+			return
+		}
+		h.render(c, http.StatusUnprocessableEntity, "subscribers.html", subscribersPage{
+			Subscribers: subscribers,
+			Form:        form,
+			Error:       "Please enter a name and a valid email address.",
+		})
+		return
+	}
+
+	var subscriber *models.Subscriber
+	err := h.service.Validate(ctx, form.Name, form.Email)
+	if err == nil {
+		subscriber, err = h.service.Create(ctx, form.Name, form.Email)
+	}
+	if err != nil {
+		h.failed(c, err)
+		return
+	}
+	span.SetAttributes(attrs.SubscriberID(subscriber.ID))
+	log.WithFields(logging.Fields{"subscriber_id": subscriber.ID}).Info("Subscriber created from the UI")
+
+	c.Redirect(http.StatusSeeOther, c.Request.URL.Path)
+}
+
+// failed renders the page with an apology when the service failed the
+// request: 504 once the latency budget ran out, 500 otherwise.
+func (h *UIHandler) failed(c *gin.Context, err error) {
+	ctx := c.Request.Context()
+	apiErr := serviceFailed(ctx, err)
+	telemetry.FailSpan(trace.SpanFromContext(ctx), err, apiErr.logMsg)
+	h.logger.WithTracing(ctx).WithFields(telemetry.ErrorFields(apiErr.errorType)).WithFields(logging.Fields{"error": err.Error()}).Log(apiErr.level, apiErr.logMsg)
+	h.render(c, apiErr.status, "subscribers.html", subscribersPage{Error: "Something went wrong, please try again."})
+}
+
+// render executes the named template in a ui.render span. The traceparent
+// handed to the browser is the request span's, not the render span's, so
+// browser spans become siblings of the render rather than its children.
+func (h *UIHandler) render(c *gin.Context, status int, name string, page subscribersPage) {
+	ctx := c.Request.Context()
+	page.Traceparent = telemetry.Traceparent(trace.SpanContextFromContext(ctx))
+	if page.Traceparent != "" {
+		c.Header("Server-Timing", fmt.Sprintf(`traceparent;desc="%s"`, page.Traceparent))
+	}
+
+	_, span := h.tracer.Start(ctx, "ui.render",
+		trace.WithAttributes(attribute.String("ui.template", name)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	c.Status(status)
+	err := ginrender.HTML{Template: h.templates, Name: name, Data: page}.Render(c.Writer)
+	elapsed := time.Since(start)
+	if err != nil {
+		telemetry.FailSpan(span, err, "Template render failed")
+		h.logger.WithTracing(ctx).WithFields(telemetry.ErrorFields(telemetry.ErrorInternal)).WithFields(logging.Fields{"error": err.Error(), "template": name}).Error("Template render failed")
+		return
+	}
+
+	span.SetAttributes(
+		attrs.HTTPResponseBodySize.Int(c.Writer.Size()),
+		attribute.Float64("ui.render_ms", float64(elapsed.Microseconds())/1000),
+	)
+}
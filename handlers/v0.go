@@ -10,15 +10,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"telemetry-demo/models"
-	"telemetry-demo/store"
+	"telemetry-demo/service"
 )
 
 type V0Handler struct {
-	store  *store.MemoryStore
-	logger *logrus.Logger
+	service service.SubscriberService
+	logger  *logrus.Logger
 }
 
-func NewV0Handler(store *store.MemoryStore) *V0Handler {
+func NewV0Handler(service service.SubscriberService) *V0Handler {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
 		TimestampFormat: "15:04:05",
@@ -27,8 +27,8 @@ func NewV0Handler(store *store.MemoryStore) *V0Handler {
 	})
 	
 	return &V0Handler{
-		store:  store,
-		logger: logger,
+		service: service,
+		logger:  logger,
 	}
 }
 
@@ -53,10 +53,23 @@ func (h *V0Handler) CreateSubscriber(c *gin.Context) {
 		return
 	}
 	
-	// Simulate some processing time
-	time.Sleep(50 * time.Millisecond)
-	
-	subscriber := h.store.CreateSubscriber(req.Name, req.Email)
+	ctx := c.Request.Context()
+	var subscriber *models.Subscriber
+	err := h.service.Validate(ctx, req.Name, req.Email)
+	if err == nil {
+		subscriber, err = h.service.Create(ctx, req.Name, req.Email)
+	}
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":   "POST",
+			"endpoint": "/v0/subscribers",
+			"error":    err.Error(),
+			"duration": time.Since(start),
+		}).Error("Failed to create subscriber")
+		
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	
 	h.logger.WithFields(logrus.Fields{
 		"method":         "POST",
@@ -73,10 +86,18 @@ func (h *V0Handler) CreateSubscriber(c *gin.Context) {
 func (h *V0Handler) GetSubscribers(c *gin.Context) {
 	start := time.Now()
 	
-	// Simulate database query time
-	time.Sleep(30 * time.Millisecond)
-	
-	subscribers := h.store.GetAllSubscribers()
+	subscribers, err := h.service.List(c.Request.Context(), nil)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":   "GET",
+			"endpoint": "/v0/subscribers",
+			"error":    err.Error(),
+			"duration": time.Since(start),
+		}).Error("Failed to list subscribers")
+		
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	
 	h.logger.WithFields(logrus.Fields{
 		"method":    "GET",
@@ -109,10 +130,19 @@ func (h *V0Handler) GetSubscriber(c *gin.Context) {
 		return
 	}
 	
-	// Simulate database lookup time
-	time.Sleep(20 * time.Millisecond)
-	
-	subscriber, exists := h.store.GetSubscriber(id)
+	subscriber, exists, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "GET",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"error":         err.Error(),
+			"duration":      time.Since(start),
+		}).Error("Failed to get subscriber")
+		
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	if !exists {
 		h.logger.WithFields(logrus.Fields{
 			"method":        "GET",
@@ -135,4 +165,137 @@ func (h *V0Handler) GetSubscriber(c *gin.Context) {
 	}).Info("Retrieved subscriber")
 	
 	c.JSON(http.StatusOK, subscriber)
+}
+
+func (h *V0Handler) UpdateSubscriber(c *gin.Context) {
+	start := time.Now()
+	
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":    "PUT",
+			"endpoint":  "/v0/subscribers/:id",
+			"id":        idStr,
+			"error":     "Invalid ID format",
+			"duration":  time.Since(start),
+		}).Error("Invalid subscriber ID")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
+		return
+	}
+	
+	// Read and preserve raw body for logging
+	body, _ := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+	
+	var req models.Subscriber
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "PUT",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"error":         err.Error(),
+			"raw_body":      string(body),
+			"duration":      time.Since(start),
+		}).Error("Invalid request body")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	ctx := c.Request.Context()
+	var subscriber *models.Subscriber
+	var exists bool
+	err = h.service.Validate(ctx, req.Name, req.Email)
+	if err == nil {
+		subscriber, exists, err = h.service.Update(ctx, id, req.Name, req.Email)
+	}
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "PUT",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"error":         err.Error(),
+			"duration":      time.Since(start),
+		}).Error("Failed to update subscriber")
+		
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !exists {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "PUT",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"duration":      time.Since(start),
+		}).Warn("Subscriber not found")
+		
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
+		return
+	}
+	
+	h.logger.WithFields(logrus.Fields{
+		"method":        "PUT",
+		"endpoint":      "/v0/subscribers/:id",
+		"subscriber_id": subscriber.ID,
+		"name":          subscriber.Name,
+		"email":         subscriber.Email,
+		"duration":      time.Since(start),
+	}).Info("Subscriber updated successfully")
+	
+	c.JSON(http.StatusOK, subscriber)
+}
+
+func (h *V0Handler) DeleteSubscriber(c *gin.Context) {
+	start := time.Now()
+	
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":    "DELETE",
+			"endpoint":  "/v0/subscribers/:id",
+			"id":        idStr,
+			"error":     "Invalid ID format",
+			"duration":  time.Since(start),
+		}).Error("Invalid subscriber ID")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
+		return
+	}
+	
+	deleted, err := h.service.Delete(c.Request.Context(), id)
+	if err != nil {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "DELETE",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"error":         err.Error(),
+			"duration":      time.Since(start),
+		}).Error("Failed to delete subscriber")
+		
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		h.logger.WithFields(logrus.Fields{
+			"method":        "DELETE",
+			"endpoint":      "/v0/subscribers/:id",
+			"subscriber_id": id,
+			"duration":      time.Since(start),
+		}).Warn("Subscriber not found")
+		
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
+		return
+	}
+	
+	h.logger.WithFields(logrus.Fields{
+		"method":        "DELETE",
+		"endpoint":      "/v0/subscribers/:id",
+		"subscriber_id": id,
+		"duration":      time.Since(start),
+	}).Info("Subscriber deleted successfully")
+	
+	c.Status(http.StatusNoContent)
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,28 +15,34 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/budget"
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
 	"telemetry-demo/models"
-	"telemetry-demo/store"
+	"telemetry-demo/service"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
 )
 
 type V1Handler struct {
-	store  *store.MemoryStore
-	logger *logrus.Logger
-	tracer trace.Tracer
+	service service.SubscriberService
+	logger  *logrus.Logger
+	tracer  trace.Tracer
 }
 
-func NewV1Handler(store *store.MemoryStore) *V1Handler {
+func NewV1Handler(service service.SubscriberService) *V1Handler {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
 		TimestampFormat: "15:04:05",
 		FullTimestamp:   true,
 		ForceColors:     true,
 	})
+	logger.AddHook(logruslog.IndexHook(logging.TraceLogs))
 	
 	return &V1Handler{
-		store:  store,
-		logger: logger,
-		tracer: otel.Tracer("telemetry-demo/v1"),
+		service: service,
+		logger:  logger,
+		tracer:  otel.Tracer("telemetry-demo/v1"),
 	}
 }
 
@@ -62,8 +69,8 @@ func (h *V1Handler) CreateSubscriber(c *gin.Context) {
 		// Mark span as error and add error details
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "Invalid request body")
+		telemetry.ClassifyError(span, telemetry.ErrorValidation)
 		span.SetAttributes(
-			attribute.String("error.type", "validation_error"),
 			attribute.String("request.body", string(body)),
 		)
 		
@@ -74,7 +81,7 @@ func (h *V1Handler) CreateSubscriber(c *gin.Context) {
 			"raw_body":  string(body),
 			"duration":  time.Since(start),
 			"trace_id":  span.SpanContext().TraceID().String(),
-		}).Error("Invalid request body")
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorValidation)).Error("Invalid request body")
 		
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -93,25 +100,29 @@ func (h *V1Handler) CreateSubscriber(c *gin.Context) {
 		attribute.String("validation.email", req.Email),
 	)
 	
-	// Simulate validation work
-	time.Sleep(20 * time.Millisecond)
+	if err := h.service.Validate(ctx, req.Name, req.Email); err != nil {
+		h.serviceFailed(c, span, validationSpan, "POST", "/v1/subscribers", err, start)
+		return
+	}
 	validationSpan.SetStatus(codes.Ok, "Validation successful")
 	validationSpan.End()
 	
 	// Create child span for database operation
 	ctx, dbSpan := h.tracer.Start(ctx, "store_subscriber")
 	dbSpan.SetAttributes(
-		attribute.String("operation", "create"),
-		attribute.String("store.type", "memory"),
+		attrs.DBOperation("create"),
+		attrs.DBSystemMemory,
 	)
 	
-	// Simulate database work
-	time.Sleep(50 * time.Millisecond)
-	subscriber := h.store.CreateSubscriber(req.Name, req.Email)
+	subscriber, err := h.service.Create(ctx, req.Name, req.Email)
+	if err != nil {
+		h.serviceFailed(c, span, dbSpan, "POST", "/v1/subscribers", err, start)
+		return
+	}
 	
 	// Add result to database span
 	dbSpan.SetAttributes(
-		attribute.Int("subscriber.id", subscriber.ID),
+		attrs.SubscriberID(subscriber.ID),
 		attribute.String("subscriber.name", subscriber.Name),
 		attribute.String("subscriber.email", subscriber.Email),
 	)
@@ -120,7 +131,7 @@ func (h *V1Handler) CreateSubscriber(c *gin.Context) {
 	
 	// Add final attributes to root span
 	span.SetAttributes(
-		attribute.Int("subscriber.id", subscriber.ID),
+		attrs.SubscriberID(subscriber.ID),
 		attribute.Int("http.status_code", http.StatusCreated),
 	)
 	span.SetStatus(codes.Ok, "Request completed successfully")
@@ -146,7 +157,7 @@ func (h *V1Handler) GetSubscribers(c *gin.Context) {
 	start := time.Now()
 	
 	span.SetAttributes(
-		attribute.String("http.method", "GET"),
+		attribute.String("http.method", c.Request.Method),
 		attribute.String("http.route", "/v1/subscribers"),
 		attribute.String("component", "http_handler"),
 	)
@@ -154,13 +165,15 @@ func (h *V1Handler) GetSubscribers(c *gin.Context) {
 	// Create child span for database query
 	ctx, dbSpan := h.tracer.Start(ctx, "query_all_subscribers")
 	dbSpan.SetAttributes(
-		attribute.String("operation", "read_all"),
-		attribute.String("store.type", "memory"),
+		attrs.DBOperation("read_all"),
+		attrs.DBSystemMemory,
 	)
 	
-	// Simulate database query time
-	time.Sleep(30 * time.Millisecond)
-	subscribers := h.store.GetAllSubscribers()
+	subscribers, err := h.service.List(ctx, nil)
+	if err != nil {
+		h.serviceFailed(c, span, dbSpan, "GET", "/v1/subscribers", err, start)
+		return
+	}
 	
 	dbSpan.SetAttributes(attribute.Int("result.count", len(subscribers)))
 	dbSpan.SetStatus(codes.Ok, fmt.Sprintf("Retrieved %d subscribers", len(subscribers)))
@@ -195,7 +208,7 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 	idStr := c.Param("id")
 	
 	span.SetAttributes(
-		attribute.String("http.method", "GET"),
+		attribute.String("http.method", c.Request.Method),
 		attribute.String("http.route", "/v1/subscribers/:id"),
 		attribute.String("component", "http_handler"),
 		attribute.String("subscriber.id_param", idStr),
@@ -213,7 +226,7 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 		
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "Invalid subscriber ID")
-		span.SetAttributes(attribute.String("error.type", "parsing_error"))
+		telemetry.ClassifyError(span, telemetry.ErrorParsing)
 		
 		h.logger.WithFields(logrus.Fields{
 			"method":    "GET",
@@ -222,7 +235,7 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 			"error":     "Invalid ID format",
 			"duration":  time.Since(start),
 			"trace_id":  span.SpanContext().TraceID().String(),
-		}).Error("Invalid subscriber ID")
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorParsing)).Error("Invalid subscriber ID")
 		
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
 		return
@@ -235,24 +248,27 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 	// Create child span for database lookup
 	ctx, dbSpan := h.tracer.Start(ctx, "lookup_subscriber")
 	dbSpan.SetAttributes(
-		attribute.String("operation", "read_by_id"),
-		attribute.String("store.type", "memory"),
-		attribute.Int("subscriber.id", id),
+		attrs.DBOperation("read_by_id"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
 	)
 	
-	// Simulate database lookup time
-	time.Sleep(20 * time.Millisecond)
-	subscriber, exists := h.store.GetSubscriber(id)
+	subscriber, exists, err := h.service.Get(ctx, id)
+	if err != nil {
+		h.serviceFailed(c, span, dbSpan, "GET", "/v1/subscribers/:id", err, start)
+		return
+	}
 	
 	if !exists {
 		dbSpan.SetStatus(codes.Error, "Subscriber not found")
 		dbSpan.End()
 		
 		span.SetAttributes(
-			attribute.Int("subscriber.id", id),
+			attrs.SubscriberID(id),
 			attribute.Int("http.status_code", http.StatusNotFound),
 		)
 		span.SetStatus(codes.Error, "Subscriber not found")
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
 		
 		h.logger.WithFields(logrus.Fields{
 			"method":        "GET",
@@ -260,7 +276,7 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 			"subscriber_id": id,
 			"duration":      time.Since(start),
 			"trace_id":      span.SpanContext().TraceID().String(),
-		}).Warn("Subscriber not found")
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorNotFound)).Warn("Subscriber not found")
 		
 		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
 		return
@@ -274,7 +290,7 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 	dbSpan.End()
 	
 	span.SetAttributes(
-		attribute.Int("subscriber.id", subscriber.ID),
+		attrs.SubscriberID(subscriber.ID),
 		attribute.String("subscriber.name", subscriber.Name),
 		attribute.String("subscriber.email", subscriber.Email),
 		attribute.Int("http.status_code", http.StatusOK),
@@ -293,4 +309,291 @@ func (h *V1Handler) GetSubscriber(c *gin.Context) {
 	}).Info("Retrieved subscriber")
 	
 	c.JSON(http.StatusOK, subscriber)
-}
\ No newline at end of file
+}
+
+func (h *V1Handler) UpdateSubscriber(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "update_subscriber_request")
+	defer span.End()
+	
+	start := time.Now()
+	idStr := c.Param("id")
+	
+	span.SetAttributes(
+		attribute.String("http.method", c.Request.Method),
+		attribute.String("http.route", "/v1/subscribers/:id"),
+		attribute.String("component", "http_handler"),
+		attribute.String("subscriber.id_param", idStr),
+	)
+	
+	// Create child span for ID parsing
+	ctx, parseSpan := h.tracer.Start(ctx, "parse_subscriber_id")
+	parseSpan.SetAttributes(attribute.String("id_string", idStr))
+	
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		parseSpan.RecordError(err)
+		parseSpan.SetStatus(codes.Error, "Invalid ID format")
+		parseSpan.End()
+		
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Invalid subscriber ID")
+		telemetry.ClassifyError(span, telemetry.ErrorParsing)
+		span.SetAttributes(
+			attribute.Int("http.status_code", http.StatusBadRequest),
+		)
+		
+		h.logger.WithFields(logrus.Fields{
+			"method":    "PUT",
+			"endpoint":  "/v1/subscribers/:id",
+			"id":        idStr,
+			"error":     "Invalid ID format",
+			"duration":  time.Since(start),
+			"trace_id":  span.SpanContext().TraceID().String(),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorParsing)).Error("Invalid subscriber ID")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
+		return
+	}
+	
+	parseSpan.SetAttributes(attribute.Int("parsed_id", id))
+	parseSpan.SetStatus(codes.Ok, "ID parsed successfully")
+	parseSpan.End()
+	
+	// Read and preserve raw body for logging
+	body, _ := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+	
+	var req models.Subscriber
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Invalid request body")
+		telemetry.ClassifyError(span, telemetry.ErrorValidation)
+		span.SetAttributes(
+			attribute.String("request.body", string(body)),
+			attribute.Int("http.status_code", http.StatusBadRequest),
+		)
+		
+		h.logger.WithFields(logrus.Fields{
+			"method":        "PUT",
+			"endpoint":      "/v1/subscribers/:id",
+			"subscriber_id": id,
+			"error":         err.Error(),
+			"raw_body":      string(body),
+			"duration":      time.Since(start),
+			"trace_id":      span.SpanContext().TraceID().String(),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorValidation)).Error("Invalid request body")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	// Create child span for validation
+	ctx, validationSpan := h.tracer.Start(ctx, "validate_subscriber_data")
+	validationSpan.SetAttributes(
+		attribute.String("validation.name", req.Name),
+		attribute.String("validation.email", req.Email),
+	)
+	
+	if err := h.service.Validate(ctx, req.Name, req.Email); err != nil {
+		h.serviceFailed(c, span, validationSpan, "PUT", "/v1/subscribers/:id", err, start)
+		return
+	}
+	validationSpan.SetStatus(codes.Ok, "Validation successful")
+	validationSpan.End()
+	
+	// Create child span for database operation
+	ctx, dbSpan := h.tracer.Start(ctx, "update_subscriber")
+	dbSpan.SetAttributes(
+		attrs.DBOperation("update"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+	
+	subscriber, exists, err := h.service.Update(ctx, id, req.Name, req.Email)
+	if err != nil {
+		h.serviceFailed(c, span, dbSpan, "PUT", "/v1/subscribers/:id", err, start)
+		return
+	}
+	
+	if !exists {
+		dbSpan.SetStatus(codes.Error, "Subscriber not found")
+		dbSpan.End()
+		
+		span.SetAttributes(
+			attrs.SubscriberID(id),
+			attribute.Int("http.status_code", http.StatusNotFound),
+		)
+		span.SetStatus(codes.Error, "Subscriber not found")
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
+		
+		h.logger.WithFields(logrus.Fields{
+			"method":        "PUT",
+			"endpoint":      "/v1/subscribers/:id",
+			"subscriber_id": id,
+			"duration":      time.Since(start),
+			"trace_id":      span.SpanContext().TraceID().String(),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorNotFound)).Warn("Subscriber not found")
+		
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
+		return
+	}
+	
+	dbSpan.SetAttributes(
+		attribute.String("subscriber.name", subscriber.Name),
+		attribute.String("subscriber.email", subscriber.Email),
+	)
+	dbSpan.SetStatus(codes.Ok, "Subscriber updated successfully")
+	dbSpan.End()
+	
+	span.SetAttributes(
+		attrs.SubscriberID(subscriber.ID),
+		attribute.Int("http.status_code", http.StatusOK),
+	)
+	span.SetStatus(codes.Ok, "Request completed successfully")
+	
+	h.logger.WithFields(logrus.Fields{
+		"method":        "PUT",
+		"endpoint":      "/v1/subscribers/:id",
+		"subscriber_id": subscriber.ID,
+		"name":          subscriber.Name,
+		"email":         subscriber.Email,
+		"duration":      time.Since(start),
+		"trace_id":      span.SpanContext().TraceID().String(),
+		"span_id":       span.SpanContext().SpanID().String(),
+	}).Info("Subscriber updated successfully")
+	
+	c.JSON(http.StatusOK, subscriber)
+}
+
+func (h *V1Handler) DeleteSubscriber(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "delete_subscriber_request")
+	defer span.End()
+	
+	start := time.Now()
+	idStr := c.Param("id")
+	
+	span.SetAttributes(
+		attribute.String("http.method", c.Request.Method),
+		attribute.String("http.route", "/v1/subscribers/:id"),
+		attribute.String("component", "http_handler"),
+		attribute.String("subscriber.id_param", idStr),
+	)
+	
+	// Create child span for ID parsing
+	ctx, parseSpan := h.tracer.Start(ctx, "parse_subscriber_id")
+	parseSpan.SetAttributes(attribute.String("id_string", idStr))
+	
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		parseSpan.RecordError(err)
+		parseSpan.SetStatus(codes.Error, "Invalid ID format")
+		parseSpan.End()
+		
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Invalid subscriber ID")
+		telemetry.ClassifyError(span, telemetry.ErrorParsing)
+		span.SetAttributes(
+			attribute.Int("http.status_code", http.StatusBadRequest),
+		)
+		
+		h.logger.WithFields(logrus.Fields{
+			"method":    "DELETE",
+			"endpoint":  "/v1/subscribers/:id",
+			"id":        idStr,
+			"error":     "Invalid ID format",
+			"duration":  time.Since(start),
+			"trace_id":  span.SpanContext().TraceID().String(),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorParsing)).Error("Invalid subscriber ID")
+		
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
+		return
+	}
+	
+	parseSpan.SetAttributes(attribute.Int("parsed_id", id))
+	parseSpan.SetStatus(codes.Ok, "ID parsed successfully")
+	parseSpan.End()
+	
+	// Create child span for database operation
+	ctx, dbSpan := h.tracer.Start(ctx, "delete_subscriber")
+	dbSpan.SetAttributes(
+		attrs.DBOperation("delete"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+	
+	deleted, err := h.service.Delete(ctx, id)
+	if err != nil {
+		h.serviceFailed(c, span, dbSpan, "DELETE", "/v1/subscribers/:id", err, start)
+		return
+	}
+	
+	if !deleted {
+		dbSpan.SetStatus(codes.Error, "Subscriber not found")
+		dbSpan.End()
+		
+		span.SetAttributes(
+			attrs.SubscriberID(id),
+			attribute.Int("http.status_code", http.StatusNotFound),
+		)
+		span.SetStatus(codes.Error, "Subscriber not found")
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
+		
+		h.logger.WithFields(logrus.Fields{
+			"method":        "DELETE",
+			"endpoint":      "/v1/subscribers/:id",
+			"subscriber_id": id,
+			"duration":      time.Since(start),
+			"trace_id":      span.SpanContext().TraceID().String(),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorNotFound)).Warn("Subscriber not found")
+		
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
+		return
+	}
+	
+	dbSpan.SetStatus(codes.Ok, "Subscriber deleted successfully")
+	dbSpan.End()
+	
+	span.SetAttributes(
+		attrs.SubscriberID(id),
+		attribute.Int("http.status_code", http.StatusNoContent),
+	)
+	span.SetStatus(codes.Ok, "Request completed successfully")
+	
+	h.logger.WithFields(logrus.Fields{
+		"method":        "DELETE",
+		"endpoint":      "/v1/subscribers/:id",
+		"subscriber_id": id,
+		"duration":      time.Since(start),
+		"trace_id":      span.SpanContext().TraceID().String(),
+		"span_id":       span.SpanContext().SpanID().String(),
+	}).Info("Subscriber deleted successfully")
+	
+	c.Status(http.StatusNoContent)
+}
+
+// serviceFailed ends child and span with err from the service and answers
+// 504 when a layer refused work for lack of latency budget, 500 otherwise.
+func (h *V1Handler) serviceFailed(c *gin.Context, span, child trace.Span, method, endpoint string, err error, start time.Time) {
+	status := http.StatusInternalServerError
+	level, message := logrus.ErrorLevel, "Service call failed"
+	if errors.Is(err, budget.ErrExhausted) {
+		status = http.StatusGatewayTimeout
+		level, message = logrus.WarnLevel, "Latency budget exhausted"
+	}
+	
+	telemetry.FailSpan(child, err, "")
+	child.End()
+	
+	telemetry.FailSpan(span, err, message)
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	
+	h.logger.WithFields(logrus.Fields{
+		"method":   method,
+		"endpoint": endpoint,
+		"error":    err.Error(),
+		"duration": time.Since(start),
+		"trace_id": span.SpanContext().TraceID().String(),
+	}).WithFields(telemetry.ErrorFields(telemetry.ErrorTypeOf(err))).Log(level, message)
+	
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -1,269 +1,402 @@
 package handlers
 
 import (
-	"bytes"
-	"io"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 	
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/jobs"
+	"telemetry-demo/loader"
+	"telemetry-demo/logging"
 	"telemetry-demo/models"
-	"telemetry-demo/store"
+	"telemetry-demo/problem"
+	"telemetry-demo/service"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
 )
 
 type V2Handler struct {
-	store  *store.MemoryStore
-	logger *logrus.Logger
+	service       service.SubscriberService
+	serialization *telemetry.SerializationRecorder
+	logger        logging.ContextLogger
+	latencyBudget time.Duration
+	writes        *jobs.Tracker
 }
 
-func NewV2Handler(store *store.MemoryStore) *V2Handler {
-	logger := logrus.New()
-	logger.SetFormatter(&logrus.TextFormatter{
-		TimestampFormat: "15:04:05",
-		FullTimestamp:   true,
-		ForceColors:     true,
-	})
-	
+// NewV2Handler serves the V2 API. latencyBudget is the time each request
+// may spend across handler, service, and store unless the client sends
+// X-Latency-Budget; zero means no budget. With writes, creates are
+// accepted with 202 and stored by writes' pool; nil stores them before
+// responding.
+func NewV2Handler(service service.SubscriberService, serialization *telemetry.SerializationRecorder, logger logging.ContextLogger, latencyBudget time.Duration, writes *jobs.Tracker) *V2Handler {
 	return &V2Handler{
-		store:  store,
-		logger: logger,
+		service:       service,
+		serialization: serialization,
+		logger:        logger,
+		latencyBudget: latencyBudget,
+		writes:        writes,
 	}
 }
 
 func (h *V2Handler) CreateSubscriber(c *gin.Context) {
-	start := time.Now()
-	
-	// Get current span from middleware (automatically created!)
-	span := trace.SpanFromContext(c.Request.Context())
-	
-	// Read and preserve raw body for logging  
-	body, _ := io.ReadAll(c.Request.Body)
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-	
-	var req models.Subscriber
-	if err := c.ShouldBindJSON(&req); err != nil {
-		// Add business context to the automatic span
-		span.SetAttributes(
-			attribute.String("error.type", "validation_error"),
-			attribute.String("request.body", string(body)),
-		)
-		
-		h.logger.WithFields(logrus.Fields{
-			"method":    "POST",
-			"endpoint":  "/v2/subscribers",
-			"error":     err.Error(),
-			"raw_body":  string(body),
-			"duration":  time.Since(start),
-			"trace_id":  span.SpanContext().TraceID().String(),
-		}).Error("Invalid request body")
-		
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if h.writes != nil {
+		h.acceptSubscriber(c)
 		return
 	}
 	
-	// Add business context to span (HTTP context already handled by middleware!)
-	span.SetAttributes(
-		attribute.String("user.name", req.Name),
-		attribute.String("user.email", req.Email),
-	)
-	
-	// Pure business logic - no span management needed!
-	h.validateSubscriberData(c, req.Name, req.Email)
-	subscriber := h.storeSubscriber(c, req.Name, req.Email)
-	
-	// Add result to span
-	span.SetAttributes(attribute.Int("subscriber.id", subscriber.ID))
-	
-	h.logger.WithFields(logrus.Fields{
-		"method":         "POST",
-		"endpoint":      "/v2/subscribers",
-		"subscriber_id": subscriber.ID,
-		"name":          subscriber.Name,
-		"email":         subscriber.Email,
-		"duration":      time.Since(start),
-		"trace_id":      span.SpanContext().TraceID().String(),
-		"span_id":       span.SpanContext().SpanID().String(),
-	}).Info("Subscriber created successfully")
-	
-	c.JSON(http.StatusCreated, subscriber)
+	handle(h, c, op[models.Subscriber, *models.Subscriber]{
+		message: "Subscriber created successfully",
+		status:  http.StatusCreated,
+		bind:    bindSubscriber,
+		call: func(c *gin.Context, req models.Subscriber) (*models.Subscriber, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			
+			// Add business context to span (HTTP context already handled by middleware!)
+			span.SetAttributes(
+				attribute.String("user.name", req.Name),
+				attribute.String("user.email", req.Email),
+			)
+			
+			// Pure business logic - no span management needed!
+			if err := h.service.Validate(c.Request.Context(), req.Name, req.Email); err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			subscriber, err := h.service.Create(c.Request.Context(), req.Name, req.Email)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			
+			span.SetAttributes(attrs.SubscriberID(subscriber.ID))
+			return subscriber, subscriberFields(subscriber), nil
+		},
+	})
 }
 
 func (h *V2Handler) GetSubscribers(c *gin.Context) {
-	start := time.Now()
-	span := trace.SpanFromContext(c.Request.Context())
-	
-	// Pure business logic
-	subscribers := h.queryAllSubscribers(c)
-	
-	// Add business context to automatic span  
-	span.SetAttributes(attribute.Int("subscribers.count", len(subscribers)))
-	
-	h.logger.WithFields(logrus.Fields{
-		"method":    "GET", 
-		"endpoint":  "/v2/subscribers",
-		"count":     len(subscribers),
-		"duration":  time.Since(start),
-		"trace_id":  span.SpanContext().TraceID().String(),
-		"span_id":   span.SpanContext().SpanID().String(),
-	}).Info("Retrieved all subscribers")
-	
-	c.JSON(http.StatusOK, gin.H{
-		"subscribers": subscribers,
-		"count":       len(subscribers),
+	handle(h, c, op[[]models.SortKey, gin.H]{
+		message: "Retrieved all subscribers",
+		status:  http.StatusOK,
+		bind:    bindSort,
+		project: projectSubscribers,
+		call: func(c *gin.Context, sort []models.SortKey) (gin.H, logging.Fields, *apiError) {
+			// Pure business logic
+			subscribers, err := h.service.List(c.Request.Context(), sort)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.Int("subscribers.count", len(subscribers)))
+			return gin.H{
+				"subscribers": subscribers,
+				"count":       len(subscribers),
+			}, logging.Fields{"count": len(subscribers)}, nil
+		},
 	})
 }
 
-func (h *V2Handler) GetSubscriber(c *gin.Context) {
+func (h *V2Handler) CountSubscribers(c *gin.Context) {
+	handle(h, c, op[*models.SubscriberFilter, gin.H]{
+		message: "Counted subscribers",
+		status:  http.StatusOK,
+		bind:    bindFilter,
+		call: func(c *gin.Context, filter *models.SubscriberFilter) (gin.H, logging.Fields, *apiError) {
+			// Pure business logic - no need to load the full list just to count it
+			count, err := h.service.Count(c.Request.Context(), filter)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.Int("subscribers.count", count))
+			return gin.H{"count": count}, logging.Fields{"count": count}, nil
+		},
+	})
+}
+
+// ExportSubscribers streams every subscriber as newline-delimited JSON,
+// reading the store in chunks instead of loading the full list.
+func (h *V2Handler) ExportSubscribers(c *gin.Context) {
 	start := time.Now()
 	span := trace.SpanFromContext(c.Request.Context())
-	idStr := c.Param("id")
+	chunkParam := c.DefaultQuery("chunk_size", "100")
 	
-	// Add parameter to span
-	span.SetAttributes(attribute.String("subscriber.id_param", idStr))
-	
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		span.SetAttributes(attribute.String("error.type", "parsing_error"))
+	chunkSize, err := strconv.Atoi(chunkParam)
+	if err != nil || chunkSize < 1 {
+		telemetry.ClassifyError(span, telemetry.ErrorParsing)
+		span.SetAttributes(attrs.ErrorCode.String(string(problem.ValidationFailed)))
 		
-		h.logger.WithFields(logrus.Fields{
-			"method":    "GET",
-			"endpoint":  "/v2/subscribers/:id", 
-			"id":        idStr,
-			"error":     "Invalid ID format",
-			"duration":  time.Since(start),
-			"trace_id":  span.SpanContext().TraceID().String(),
-		}).Error("Invalid subscriber ID")
+		h.logger.WithTracing(c.Request.Context()).WithFields(logging.Fields{
+			"method":     "GET",
+			"endpoint":   "/v2/subscribers/export",
+			"chunk_size": chunkParam,
+			"error":      "Invalid chunk size",
+			"duration":   time.Since(start),
+		}).WithFields(telemetry.ErrorFields(telemetry.ErrorParsing)).Error("Invalid chunk size")
 		
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscriber ID"})
+		renderProblem(c, h.serialization, problem.New(problem.ValidationFailed, "Invalid chunk_size parameter"))
 		return
 	}
 	
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	
 	// Pure business logic
-	subscriber, exists := h.lookupSubscriber(c, id)
-	if !exists {
-		span.SetAttributes(attribute.Int("subscriber.id", id))
-		
-		h.logger.WithFields(logrus.Fields{
-			"method":        "GET",
-			"endpoint":      "/v2/subscribers/:id",
-			"subscriber_id": id,
-			"duration":      time.Since(start),
-			"trace_id":      span.SpanContext().TraceID().String(),
-		}).Warn("Subscriber not found")
-		
-		c.JSON(http.StatusNotFound, gin.H{"error": "Subscriber not found"})
-		return
-	}
+	exported, chunks, err := h.exportSubscribers(c, chunkSize)
 	
-	// Add business context to span
 	span.SetAttributes(
-		attribute.Int("subscriber.id", subscriber.ID),
-		attribute.String("subscriber.name", subscriber.Name),
-		attribute.String("subscriber.email", subscriber.Email),
+		attribute.Int("export.subscribers", exported),
+		attribute.Int("export.chunks", chunks),
 	)
 	
-	h.logger.WithFields(logrus.Fields{
-		"method":        "GET",
-		"endpoint":      "/v2/subscribers/:id",
-		"subscriber_id": subscriber.ID,
-		"name":          subscriber.Name,
-		"email":         subscriber.Email,
-		"duration":      time.Since(start),
-		"trace_id":      span.SpanContext().TraceID().String(),
-		"span_id":       span.SpanContext().SpanID().String(),
-	}).Info("Retrieved subscriber")
+	if err != nil {
+		// Headers are already sent, so the client just sees a truncated stream
+		h.logger.WithTracing(c.Request.Context()).WithFields(logging.Fields{
+			"method":   "GET",
+			"endpoint": "/v2/subscribers/export",
+			"exported": exported,
+			"error":    err.Error(),
+			"duration": time.Since(start),
+		}).Error("Subscriber export aborted")
+		return
+	}
 	
-	c.JSON(http.StatusOK, subscriber)
+	h.logger.WithTracing(c.Request.Context()).WithFields(logging.Fields{
+		"method":   "GET",
+		"endpoint": "/v2/subscribers/export",
+		"exported": exported,
+		"chunks":   chunks,
+		"duration": time.Since(start),
+	}).Info("Exported subscribers")
 }
 
-// Business logic methods with automatic tracing
-func (h *V2Handler) validateSubscriberData(c *gin.Context, name, email string) {
-	// Get tracer for custom spans (when needed)
-	tracer := otel.Tracer("telemetry-demo/business-logic")
-	
-	_, span := tracer.Start(c.Request.Context(), "validate_subscriber_data")
-	defer span.End()
-	
-	span.SetAttributes(
-		attribute.String("validation.name", name),
-		attribute.String("validation.email", email),
-	)
-	
-	// Simulate validation work
-	time.Sleep(20 * time.Millisecond)
+func (h *V2Handler) GetSubscriber(c *gin.Context) {
+	handle(h, c, op[int, *models.Subscriber]{
+		message: "Retrieved subscriber",
+		status:  http.StatusOK,
+		bind:    bindID,
+		project: projectSubscriber,
+		call: func(c *gin.Context, id int) (*models.Subscriber, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			
+			// Pure business logic
+			subscriber, exists, err := h.service.Get(c.Request.Context(), id)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			if !exists {
+				span.SetAttributes(attrs.SubscriberID(id))
+				return nil, nil, subscriberNotFound(id)
+			}
+			
+			// Add business context to span
+			span.SetAttributes(
+				attrs.SubscriberID(subscriber.ID),
+				attribute.String("subscriber.name", subscriber.Name),
+				attribute.String("subscriber.email", subscriber.Email),
+			)
+			return subscriber, subscriberFields(subscriber), nil
+		},
+	})
 }
 
-func (h *V2Handler) storeSubscriber(c *gin.Context, name, email string) *models.Subscriber {
-	tracer := otel.Tracer("telemetry-demo/business-logic")
-	
-	_, span := tracer.Start(c.Request.Context(), "store_subscriber")
-	defer span.End()
-	
-	span.SetAttributes(
-		attribute.String("operation", "create"),
-		attribute.String("store.type", "memory"),
-	)
-	
-	// Simulate database work
-	time.Sleep(50 * time.Millisecond)
-	subscriber := h.store.CreateSubscriber(name, email)
-	
-	span.SetAttributes(
-		attribute.Int("subscriber.id", subscriber.ID),
-		attribute.String("subscriber.name", subscriber.Name),
-		attribute.String("subscriber.email", subscriber.Email),
-	)
-	
-	return subscriber
+// GetSubscriberFull fetches the subscriber and its change history in
+// parallel. Only the subscriber is required: when the history lookup fails
+// the response is still a 200, marked partial with the branch's error.
+func (h *V2Handler) GetSubscriberFull(c *gin.Context) {
+	handle(h, c, op[int, *service.PartialResult]{
+		message: "Retrieved subscriber profile",
+		status:  http.StatusOK,
+		bind:    bindID,
+		call: func(c *gin.Context, id int) (*service.PartialResult, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			span.SetAttributes(attrs.SubscriberID(id))
+
+			subscribers := h.subscriberLoader()
+			result, err := service.RunParallel(c.Request.Context(), "subscriber_full",
+				service.Branch{
+					Name:     "subscriber",
+					Required: true,
+					Fetch: func(ctx context.Context) (any, error) {
+						// A missing subscriber isn't a branch failure; it
+						// becomes the 404 below
+						subscriber, _, err := subscribers.Load(ctx, id)
+						return subscriber, err
+					},
+				},
+				service.Branch{
+					Name: "history",
+					Fetch: func(ctx context.Context) (any, error) {
+						return h.service.History(ctx, id)
+					},
+				},
+			)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			if subscriber, _ := result.Data["subscriber"].(*models.Subscriber); subscriber == nil {
+				return nil, nil, subscriberNotFound(id)
+			}
+
+			span.SetAttributes(attribute.Bool("response.partial", result.Partial))
+			return result, logging.Fields{"subscriber_id": id, "partial": result.Partial}, nil
+		},
+	})
 }
 
-func (h *V2Handler) queryAllSubscribers(c *gin.Context) []*models.Subscriber {
-	tracer := otel.Tracer("telemetry-demo/business-logic")
-	
-	_, span := tracer.Start(c.Request.Context(), "query_all_subscribers")
-	defer span.End()
-	
-	span.SetAttributes(
-		attribute.String("operation", "read_all"),
-		attribute.String("store.type", "memory"),
-	)
-	
-	// Simulate database query time
-	time.Sleep(30 * time.Millisecond)
-	subscribers := h.store.GetAllSubscribers()
-	
-	span.SetAttributes(attribute.Int("result.count", len(subscribers)))
-	
-	return subscribers
+func (h *V2Handler) UpdateSubscriber(c *gin.Context) {
+	handle(h, c, op[subscriberUpdate, *models.Subscriber]{
+		message: "Subscriber updated successfully",
+		status:  http.StatusOK,
+		bind:    bindUpdate,
+		call: func(c *gin.Context, req subscriberUpdate) (*models.Subscriber, logging.Fields, *apiError) {
+			span := trace.SpanFromContext(c.Request.Context())
+			
+			// Pure business logic
+			if err := h.service.Validate(c.Request.Context(), req.body.Name, req.body.Email); err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			subscriber, exists, err := h.service.Update(c.Request.Context(), req.id, req.body.Name, req.body.Email)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			if !exists {
+				span.SetAttributes(attrs.SubscriberID(req.id))
+				return nil, nil, subscriberNotFound(req.id)
+			}
+			
+			span.SetAttributes(attrs.SubscriberID(subscriber.ID))
+			return subscriber, subscriberFields(subscriber), nil
+		},
+	})
 }
 
-func (h *V2Handler) lookupSubscriber(c *gin.Context, id int) (*models.Subscriber, bool) {
-	tracer := otel.Tracer("telemetry-demo/business-logic")
-	
-	_, span := tracer.Start(c.Request.Context(), "lookup_subscriber")
-	defer span.End()
-	
-	span.SetAttributes(
-		attribute.String("operation", "read_by_id"),
-		attribute.String("store.type", "memory"),
-		attribute.Int("subscriber.id", id),
-	)
-	
-	// Simulate database lookup time
-	time.Sleep(20 * time.Millisecond)
-	subscriber, exists := h.store.GetSubscriber(id)
+func (h *V2Handler) DeleteSubscriber(c *gin.Context) {
+	handle(h, c, op[int, struct{}]{
+		message: "Subscriber deleted successfully",
+		status:  http.StatusNoContent,
+		bind:    bindID,
+		call: func(c *gin.Context, id int) (struct{}, logging.Fields, *apiError) {
+			// Pure business logic
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(attrs.SubscriberID(id))
+			deleted, err := h.service.Delete(c.Request.Context(), id)
+			if err != nil {
+				return struct{}{}, nil, serviceFailed(c.Request.Context(), err)
+			}
+			if !deleted {
+				return struct{}{}, nil, subscriberNotFound(id)
+			}
+			return struct{}{}, logging.Fields{"subscriber_id": id}, nil
+		},
+	})
+}
+
+func (h *V2Handler) GetSubscribersBatch(c *gin.Context) {
+	handle(h, c, op[[]int, gin.H]{
+		message: "Retrieved subscriber batch",
+		status:  http.StatusOK,
+		bind:    bindIDList,
+		project: projectSubscribers,
+		call: func(c *gin.Context, ids []int) (gin.H, logging.Fields, *apiError) {
+			// Pure business logic
+			found, err := h.subscriberLoader().LoadMany(c.Request.Context(), ids)
+			if err != nil {
+				return nil, nil, serviceFailed(c.Request.Context(), err)
+			}
+			
+			subscribers := make([]*models.Subscriber, 0, len(found))
+			missing := make([]int, 0)
+			for _, id := range ids {
+				if subscriber, ok := found[id]; ok {
+					subscribers = append(subscribers, subscriber)
+				} else {
+					missing = append(missing, id)
+				}
+			}
+			
+			trace.SpanFromContext(c.Request.Context()).SetAttributes(
+				attribute.Int("subscribers.requested", len(ids)),
+				attribute.Int("subscribers.found", len(subscribers)),
+			)
+			return gin.H{
+				"subscribers": subscribers,
+				"count":       len(subscribers),
+				"missing":     missing,
+			}, logging.Fields{"requested": len(ids), "found": len(subscribers)}, nil
+		},
+	})
+}
+
+// subscriberLoader batches the subscriber lookups made while serving one
+// request into GetMany calls. Create one per request, so lookups are never
+// served from another request's results.
+func (h *V2Handler) subscriberLoader() *loader.Loader[int, *models.Subscriber] {
+	return loader.New("subscribers", h.service.GetMany, loader.DefaultWait, loader.DefaultMaxBatch)
+}
+
+// projectSubscribers narrows a list response's subscribers to fields.
+func projectSubscribers(resp gin.H, fields *fieldSet) any {
+	resp["subscribers"] = fields.subscribers(resp["subscribers"].([]*models.Subscriber))
+	return resp
+}
+
+func projectSubscriber(subscriber *models.Subscriber, fields *fieldSet) any {
+	return fields.subscriber(subscriber)
+}
+
+func subscriberFields(subscriber *models.Subscriber) logging.Fields {
+	return logging.Fields{
+		"subscriber_id": subscriber.ID,
+		"name":          subscriber.Name,
+		"email":         subscriber.Email,
+	}
+}
+
+func parseIDList(param string) ([]int, error) {
+	if param == "" {
+		return nil, fmt.Errorf("ids parameter is required")
+	}
 	
-	if exists {
-		span.SetAttributes(
-			attribute.String("subscriber.name", subscriber.Name),
-			attribute.String("subscriber.email", subscriber.Email),
-		)
+	seen := make(map[int]bool)
+	ids := make([]int, 0)
+	for _, part := range strings.Split(param, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
 	}
 	
-	return subscriber, exists
-}
\ No newline at end of file
+	return ids, nil
+}
+
+// Business logic methods with automatic tracing
+// exportSubscribers writes each chunk as NDJSON. The traced service wraps
+// the export and every chunk in spans.
+func (h *V2Handler) exportSubscribers(c *gin.Context, chunkSize int) (int, int, error) {
+	encoder := json.NewEncoder(c.Writer)
+	exported, chunks := 0, 0
+	
+	err := h.service.Export(c.Request.Context(), chunkSize, func(chunk []*models.Subscriber) error {
+		for _, subscriber := range chunk {
+			if err := encoder.Encode(subscriber); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		
+		exported += len(chunk)
+		chunks++
+		return nil
+	})
+	
+	return exported, chunks, err
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/logging"
+	"telemetry-demo/logging/logruslog"
+	"telemetry-demo/models"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Why a long poll returned, the watch.woken_by attribute.
+const (
+	wokenByChange     = "change"
+	wokenByTimeout    = "timeout"
+	wokenByDisconnect = "client_gone"
+)
+
+// WatchHandler long-polls the store's change feed. Its trace keeps waiting
+// and working apart: watch.check and watch.collect are the work, and
+// watch.wait is the idle time in between. Without that split, a 30 second
+// request span with only a few milliseconds of children reads like a
+// performance problem when it is the endpoint doing its job.
+type WatchHandler struct {
+	store   *store.MemoryStore
+	maxWait time.Duration
+	logger  *logrus.Logger
+	tracer  trace.Tracer
+}
+
+// NewWatchHandler serves the change feed, holding each request for at most
+// maxWait while nothing has changed.
+func NewWatchHandler(store *store.MemoryStore, maxWait time.Duration) *WatchHandler {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		TimestampFormat: "15:04:05",
+		FullTimestamp:   true,
+		ForceColors:     true,
+	})
+	logger.AddHook(logruslog.IndexHook(logging.TraceLogs))
+
+	return &WatchHandler{
+		store:   store,
+		maxWait: maxWait,
+		logger:  logger,
+		tracer:  otel.Tracer("telemetry-demo/watch"),
+	}
+}
+
+// WatchSubscribers returns the subscriber changes after ?since=, waiting up
+// to ?wait= (capped at the handler's maxWait) for one when there are none
+// yet. Without since it waits for the next change. The response's cursor is
+// the since of the next call.
+func (h *WatchHandler) WatchSubscribers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "watch_subscribers_request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(c.FullPath()),
+		),
+	)
+	defer span.End()
+	start := time.Now()
+
+	since := int64(-1)
+	if param := c.Query("since"); param != "" {
+		parsed, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || parsed < 0 {
+			h.reject(c, span, "Invalid since parameter")
+			return
+		}
+		since = parsed
+	}
+	wait := h.maxWait
+	if param := c.Query("wait"); param != "" {
+		parsed, err := time.ParseDuration(param)
+		if err != nil || parsed < 0 {
+			h.reject(c, span, "Invalid wait parameter")
+			return
+		}
+		wait = min(parsed, h.maxWait)
+	}
+	span.SetAttributes(
+		attribute.Int64("watch.since", since),
+		attribute.Float64("watch.max_wait_ms", telemetry.Milliseconds(wait)),
+	)
+
+	batch, changed := h.check(ctx, "watch.check", since)
+	var waited time.Duration
+	wokenBy := wokenByChange
+	if len(batch.Changes) == 0 && !batch.Truncated {
+		waited, wokenBy = h.wait(ctx, changed, wait)
+		if wokenBy == wokenByChange {
+			batch, _ = h.check(ctx, "watch.collect", batch.Cursor)
+		}
+	}
+
+	// Waiting is the point of the endpoint, so it is reported apart from
+	// the time spent working
+	elapsed := time.Since(start)
+	span.SetAttributes(
+		attribute.Int64("watch.cursor", batch.Cursor),
+		attribute.Int("watch.changes", len(batch.Changes)),
+		attribute.Bool("watch.truncated", batch.Truncated),
+		attribute.String("watch.woken_by", wokenBy),
+		attribute.Float64("watch.wait_ms", telemetry.Milliseconds(waited)),
+		attribute.Float64("watch.processing_ms", telemetry.Milliseconds(elapsed-waited)),
+		attrs.HTTPStatusCode.Int(http.StatusOK),
+	)
+
+	h.logger.WithFields(logrus.Fields{
+		"method":     c.Request.Method,
+		"endpoint":   c.FullPath(),
+		"since":      since,
+		"cursor":     batch.Cursor,
+		"changes":    len(batch.Changes),
+		"woken_by":   wokenBy,
+		"waited":     waited,
+		"processing": elapsed - waited,
+		"trace_id":   span.SpanContext().TraceID().String(),
+	}).Info("Watch returned")
+
+	changes := batch.Changes
+	if changes == nil {
+		changes = []models.SubscriberChange{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"changes":   changes,
+		"cursor":    batch.Cursor,
+		"truncated": batch.Truncated,
+		"timed_out": wokenBy == wokenByTimeout,
+	})
+}
+
+// check reads the feed after since in its own span, one of the request's
+// units of work.
+func (h *WatchHandler) check(ctx context.Context, name string, since int64) (store.ChangeBatch, <-chan struct{}) {
+	_, span := h.tracer.Start(ctx, name)
+	defer span.End()
+
+	batch, changed := h.store.ChangesSince(since)
+	span.SetAttributes(
+		attribute.Int64("watch.cursor", batch.Cursor),
+		attribute.Int("watch.changes", len(batch.Changes)),
+	)
+	return batch, changed
+}
+
+// wait blocks until the feed changes, wait passes, or the client goes
+// away, in a watch.wait span that covers nothing but the idle time.
+func (h *WatchHandler) wait(ctx context.Context, changed <-chan struct{}, wait time.Duration) (time.Duration, string) {
+	_, span := h.tracer.Start(ctx, "watch.wait", trace.WithAttributes(
+		attribute.Bool("watch.idle", true),
+	))
+	defer span.End()
+
+	start := time.Now()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	wokenBy := wokenByChange
+	select {
+	case <-changed:
+	case <-timer.C:
+		wokenBy = wokenByTimeout
+	case <-ctx.Done():
+		wokenBy = wokenByDisconnect
+	}
+	waited := time.Since(start)
+	span.SetAttributes(
+		attribute.String("watch.woken_by", wokenBy),
+		attribute.Float64("watch.wait_ms", telemetry.Milliseconds(waited)),
+	)
+	return waited, wokenBy
+}
+
+func (h *WatchHandler) reject(c *gin.Context, span trace.Span, message string) {
+	span.SetAttributes(attrs.HTTPStatusCode.Int(http.StatusBadRequest))
+
+	h.logger.WithFields(logrus.Fields{
+		"method":   c.Request.Method,
+		"endpoint": c.FullPath(),
+		"query":    c.Request.URL.RawQuery,
+		"trace_id": span.SpanContext().TraceID().String(),
+	}).Warn(message)
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": message})
+}
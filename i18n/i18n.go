@@ -0,0 +1,168 @@
+// Package i18n translates user-facing messages into the caller's language.
+// Messages are looked up by their English text, gettext style, so code
+// keeps writing English and an untranslated message simply stays English.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used when Accept-Language names no supported locale.
+const DefaultLocale = "en"
+
+// bundles maps a locale to translations keyed by the English message.
+// English needs no bundle.
+var bundles = map[string]map[string]string{
+	"es": {
+		"Subscriber not found":             "Suscriptor no encontrado",
+		"Job not found":                    "Trabajo no encontrado",
+		"Trace not found":                  "Traza no encontrada",
+		"Incident not found":               "Incidente no encontrado",
+		"Annotation not found":             "Anotación no encontrada",
+		"Response cache is disabled":       "La caché de respuestas está desactivada",
+		"Identity provider request failed": "Falló la solicitud al proveedor de identidad",
+		"Email is already subscribed":      "El correo electrónico ya está suscrito",
+		"Invalid subscriber ID":            "ID de suscriptor no válido",
+		"Request validation failed":        "La validación de la solicitud falló",
+		"Unknown field requested":          "Se solicitó un campo desconocido",
+		"Login required":                   "Se requiere iniciar sesión",
+		"Too many requests":                "Demasiadas solicitudes",
+		"Server overloaded, retry later":   "Servidor sobrecargado, inténtelo más tarde",
+		"API is in read-only mode":         "La API está en modo de solo lectura",
+		"Latency budget exhausted":         "Presupuesto de latencia agotado",
+		"Internal server error":            "Error interno del servidor",
+
+		"No subscriber with ID %d":                                 "No existe ningún suscriptor con el ID %d",
+		"%q is not a valid subscriber ID":                          "%q no es un ID de suscriptor válido",
+		"Invalid request body: %s":                                 "Cuerpo de la solicitud no válido: %s",
+		"Invalid ids parameter: %s":                                "Parámetro ids no válido: %s",
+		"Invalid chunk_size parameter":                             "Parámetro chunk_size no válido",
+		"Failed to encode response":                                "No se pudo codificar la respuesta",
+		"Writes are disabled until read-only mode is switched off": "Las escrituras están desactivadas hasta que se desactive el modo de solo lectura",
+		"The request ran out of its %s latency budget":             "La solicitud agotó su presupuesto de latencia de %s",
+		"Invalid X-Latency-Budget header: %s":                      "Cabecera X-Latency-Budget no válida: %s",
+		"Unknown field %q, must be one of %s":                      "Campo desconocido %q, debe ser uno de %s",
+		"Unknown sort field %q, must be one of %s":                 "Campo de ordenación desconocido %q, debe ser uno de %s",
+		"No job with ID %d":                                        "No existe ningún trabajo con el ID %d",
+		"%q is not a valid job ID":                                 "%q no es un ID de trabajo válido",
+		"The write queue is full":                                  "La cola de escritura está llena",
+	},
+	"de": {
+		"Subscriber not found":             "Abonnent nicht gefunden",
+		"Job not found":                    "Auftrag nicht gefunden",
+		"Trace not found":                  "Trace nicht gefunden",
+		"Incident not found":               "Vorfall nicht gefunden",
+		"Annotation not found":             "Annotation nicht gefunden",
+		"Response cache is disabled":       "Der Antwort-Cache ist deaktiviert",
+		"Identity provider request failed": "Anfrage an den Identitätsanbieter fehlgeschlagen",
+		"Email is already subscribed":      "E-Mail-Adresse ist bereits abonniert",
+		"Invalid subscriber ID":            "Ungültige Abonnenten-ID",
+		"Request validation failed":        "Validierung der Anfrage fehlgeschlagen",
+		"Unknown field requested":          "Unbekanntes Feld angefordert",
+		"Login required":                   "Anmeldung erforderlich",
+		"Too many requests":                "Zu viele Anfragen",
+		"Server overloaded, retry later":   "Server überlastet, bitte später erneut versuchen",
+		"API is in read-only mode":         "Die API ist im Nur-Lese-Modus",
+		"Latency budget exhausted":         "Latenzbudget aufgebraucht",
+		"Internal server error":            "Interner Serverfehler",
+
+		"No subscriber with ID %d":                                 "Kein Abonnent mit der ID %d",
+		"%q is not a valid subscriber ID":                          "%q ist keine gültige Abonnenten-ID",
+		"Invalid request body: %s":                                 "Ungültiger Anfragetext: %s",
+		"Invalid ids parameter: %s":                                "Ungültiger ids-Parameter: %s",
+		"Invalid chunk_size parameter":                             "Ungültiger chunk_size-Parameter",
+		"Failed to encode response":                                "Antwort konnte nicht kodiert werden",
+		"Writes are disabled until read-only mode is switched off": "Schreibzugriffe sind deaktiviert, bis der Nur-Lese-Modus ausgeschaltet wird",
+		"The request ran out of its %s latency budget":             "Die Anfrage hat ihr Latenzbudget von %s aufgebraucht",
+		"Invalid X-Latency-Budget header: %s":                      "Ungültiger X-Latency-Budget-Header: %s",
+		"Unknown field %q, must be one of %s":                      "Unbekanntes Feld %q, erlaubt sind %s",
+		"Unknown sort field %q, must be one of %s":                 "Unbekanntes Sortierfeld %q, erlaubt sind %s",
+		"No job with ID %d":                                        "Kein Auftrag mit der ID %d",
+		"%q is not a valid job ID":                                 "%q ist keine gültige Auftrags-ID",
+		"The write queue is full":                                  "Die Schreibwarteschlange ist voll",
+	},
+}
+
+// Supported returns the locales messages are available in, default first.
+func Supported() []string {
+	locales := []string{DefaultLocale}
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// Negotiate picks the supported locale the Accept-Language header prefers,
+// matching on the primary language ("de-AT" selects "de"). It returns
+// DefaultLocale when nothing matches.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if lang == "" || q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.lang == "*" || c.lang == DefaultLocale {
+			return DefaultLocale
+		}
+		if _, ok := bundles[c.lang]; ok {
+			return c.lang
+		}
+	}
+	return DefaultLocale
+}
+
+// T translates message into locale, or returns it unchanged.
+func T(locale, message string) string {
+	if translated, ok := bundles[locale][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf translates format into locale, then formats it with args.
+func Sprintf(locale, format string, args ...any) string {
+	if len(args) == 0 {
+		return T(locale, format)
+	}
+	return fmt.Sprintf(T(locale, format), args...)
+}
+
+type localeKey struct{}
+
+// ContextWithLocale stores the request's resolved locale.
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale stored in ctx, or DefaultLocale.
+func LocaleFromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok {
+		return locale
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,108 @@
+// Package identity carries the caller of a request through the server and
+// on to downstream services. Middleware records who called with
+// ContextWithCaller; clients calling other services hand the caller on with
+// a Propagator, the way trace context is handed on with a TextMapPropagator.
+package identity
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+)
+
+// Caller sources, the identity.source attribute.
+const (
+	SourceOIDC   = "oidc"
+	SourceAPIKey = "api_key"
+)
+
+// Propagation outcomes, the identity.outcome attribute.
+const (
+	OutcomePropagated = "propagated"
+	// OutcomeAnonymous means there was no caller to hand on, which is not
+	// an error: the downstream call is made without identity.
+	OutcomeAnonymous = "anonymous"
+	OutcomeFailed    = "failed"
+)
+
+var (
+	identityMeter = telemetry.Meter("telemetry-demo/identity")
+	propagations  = telemetry.Int64Counter(identityMeter, "identity.propagations", "{call}",
+		"Downstream calls by identity.scheme and identity.outcome")
+)
+
+// Caller is who a request was made by.
+type Caller struct {
+	// Subject identifies the caller within Source: an OIDC subject, or
+	// the hashed ID of an API key.
+	Subject string `json:"sub"`
+	Source  string `json:"src"`
+}
+
+type callerKey struct{}
+
+// ContextWithCaller returns ctx carrying caller.
+func ContextWithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller recorded in ctx, if any.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok && caller.Subject != ""
+}
+
+// Propagator attaches a caller to an outbound request in a form the
+// receiving service can trust.
+type Propagator interface {
+	// Scheme names the propagator in spans and metrics, e.g. signed_token.
+	Scheme() string
+	Inject(req *http.Request, caller Caller) error
+}
+
+// Propagate attaches the caller in ctx to req with p and records how it
+// went on the client span in ctx: identity.scheme, identity.outcome, and
+// identity.propagated, plus an identity_propagation_failed event on
+// failure. It only returns p's error: with no caller in ctx, or a nil p,
+// the call goes out anonymous.
+func Propagate(ctx context.Context, req *http.Request, p Propagator) error {
+	span := trace.SpanFromContext(ctx)
+	scheme := "none"
+	if p != nil {
+		scheme = p.Scheme()
+	}
+
+	caller, ok := CallerFromContext(ctx)
+	var err error
+	outcome := OutcomePropagated
+	switch {
+	case p == nil || !ok:
+		outcome = OutcomeAnonymous
+	default:
+		if err = p.Inject(req, caller); err != nil {
+			outcome = OutcomeFailed
+			span.AddEvent("identity_propagation_failed", trace.WithAttributes(
+				attribute.String("identity.scheme", scheme),
+				attribute.String("error.message", err.Error()),
+			))
+		}
+	}
+
+	span.SetAttributes(
+		attribute.String("identity.scheme", scheme),
+		attribute.String("identity.outcome", outcome),
+		attribute.Bool("identity.propagated", outcome == OutcomePropagated),
+	)
+	if ok {
+		span.SetAttributes(attribute.String("identity.source", caller.Source))
+	}
+	propagations.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("identity.scheme", scheme),
+		attribute.String("identity.outcome", outcome),
+	))
+	return err
+}
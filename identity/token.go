@@ -0,0 +1,153 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenHeader carries a signed internal token.
+const TokenHeader = "X-Internal-Identity"
+
+// DefaultTokenTTL is how long a signed token is accepted unless the
+// SignedToken is created with another TTL.
+const DefaultTokenTTL = time.Minute
+
+var (
+	ErrMalformedToken = errors.New("identity: malformed token")
+	ErrBadSignature   = errors.New("identity: token signature mismatch")
+	ErrExpiredToken   = errors.New("identity: token expired")
+)
+
+// claims is the payload of a signed token.
+type claims struct {
+	Caller
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SignedToken hands the caller on as a short-lived token signed with a
+// secret shared with the receiving services:
+//
+//	X-Internal-Identity: base64url(claims) "." base64url(hmac_sha256(secret, base64url(claims)))
+//
+// It is deliberately smaller than a JWT: services inside the deployment
+// only need to know who called and that this server said so.
+type SignedToken struct {
+	secret []byte
+	issuer string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSignedToken signs tokens as issuer with secret. A ttl of zero uses
+// DefaultTokenTTL.
+func NewSignedToken(secret []byte, issuer string, ttl time.Duration) *SignedToken {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	return &SignedToken{secret: secret, issuer: issuer, ttl: ttl, now: time.Now}
+}
+
+func (t *SignedToken) Scheme() string { return "signed_token" }
+
+func (t *SignedToken) Inject(req *http.Request, caller Caller) error {
+	token, err := t.Sign(caller)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(TokenHeader, token)
+	return nil
+}
+
+// Sign returns a token for caller, valid from now for the TTL.
+func (t *SignedToken) Sign(caller Caller) (string, error) {
+	if len(t.secret) == 0 {
+		return "", errors.New("identity: no signing secret")
+	}
+	now := t.now()
+	payload, err := json.Marshal(claims{
+		Caller:    caller,
+		Issuer:    t.issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.mac(encoded)), nil
+}
+
+// Verify checks a token made with the same secret and returns its caller.
+// Receiving services use it on TokenHeader.
+func (t *SignedToken) Verify(token string) (Caller, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Caller{}, ErrMalformedToken
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Caller{}, ErrMalformedToken
+	}
+	if !hmac.Equal(sum, t.mac(encoded)) {
+		return Caller{}, ErrBadSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Caller{}, ErrMalformedToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Caller{}, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if t.now().Unix() >= c.ExpiresAt {
+		return Caller{}, ErrExpiredToken
+	}
+	return c.Caller, nil
+}
+
+func (t *SignedToken) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, t.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// Dapr API token headers. The token authenticates this app to the Dapr
+// sidecar it calls through; the caller rides along beside it.
+const (
+	DaprAPITokenHeader  = "dapr-api-token"
+	CallerSubjectHeader = "X-Caller-Subject"
+	CallerSourceHeader  = "X-Caller-Source"
+)
+
+// DaprAPIToken hands the caller on through a Dapr sidecar. The sidecar
+// checks the API token, so the receiving app trusts the caller headers as
+// far as it trusts its sidecar.
+func DaprAPIToken(token string) Propagator {
+	return daprAPIToken{token: token}
+}
+
+type daprAPIToken struct {
+	token string
+}
+
+func (d daprAPIToken) Scheme() string { return "dapr_api_token" }
+
+func (d daprAPIToken) Inject(req *http.Request, caller Caller) error {
+	if d.token == "" {
+		return errors.New("identity: no Dapr API token")
+	}
+	req.Header.Set(DaprAPITokenHeader, d.token)
+	req.Header.Set(CallerSubjectHeader, caller.Subject)
+	req.Header.Set(CallerSourceHeader, caller.Source)
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package jobs tracks writes that were accepted with 202 and handed to a
+// worker pool, so clients can poll for the outcome.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/pool"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Pool sizing for accepted writes.
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+)
+
+// maxRetainedJobs bounds how many jobs are remembered. The oldest finished
+// jobs are forgotten first, and polling one afterwards reports not found.
+const maxRetainedJobs = 1000
+
+// Status is where a job is in its lifecycle.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Job is the outcome of one accepted write.
+type Job struct {
+	ID     int64  `json:"id"`
+	Kind   string `json:"kind"`
+	Status Status `json:"status"`
+	// SubscriberID is the subscriber the write created, once it succeeded.
+	SubscriberID int        `json:"subscriber_id,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	AcceptedAt   time.Time  `json:"accepted_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	// AcceptTraceID is the trace of the request that accepted the job, and
+	// ProcessTraceID the trace of the pool task that ran it. The two are
+	// joined by a span link, not a parent.
+	AcceptTraceID  string `json:"accept_trace_id,omitempty"`
+	ProcessTraceID string `json:"process_trace_id,omitempty"`
+}
+
+// Work performs an accepted write and returns the ID of the subscriber it
+// wrote.
+type Work func(ctx context.Context) (int, error)
+
+// Tracker submits writes to a pool and records how each one went.
+type Tracker struct {
+	pool *pool.Pool
+	now  func() time.Time
+
+	mu    sync.Mutex
+	next  int64
+	jobs  map[int64]*Job
+	order []int64
+}
+
+func NewTracker(p *pool.Pool) *Tracker {
+	return &Tracker{
+		pool: p,
+		now:  time.Now,
+		jobs: make(map[int64]*Job),
+	}
+}
+
+// Submit queues work without blocking and returns the pending job. When
+// the pool's queue is full or the pool is closed it returns the pool's
+// error and nothing is recorded. The job runs in a new trace whose root
+// span links back to ctx's span.
+func (t *Tracker) Submit(ctx context.Context, kind string, work Work) (Job, error) {
+	t.mu.Lock()
+	t.next++
+	job := &Job{
+		ID:            t.next,
+		Kind:          kind,
+		Status:        StatusPending,
+		AcceptedAt:    t.now(),
+		AcceptTraceID: traceID(trace.SpanContextFromContext(ctx)),
+	}
+	t.remember(job)
+	accepted := *job
+	t.mu.Unlock()
+
+	err := t.pool.TrySubmit(ctx, kind, func(ctx context.Context) error {
+		return t.run(ctx, job.ID, work)
+	})
+	if err != nil {
+		t.forget(job.ID)
+		return Job{}, err
+	}
+	return accepted, nil
+}
+
+// Get returns a copy of the job with id.
+func (t *Tracker) Get(id int64) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, ok := t.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Drain waits for queued jobs to finish, or for ctx to be done.
+func (t *Tracker) Drain(ctx context.Context) error {
+	return t.pool.Drain(ctx)
+}
+
+func (t *Tracker) run(ctx context.Context, id int64, work Work) error {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("job.id", id))
+
+	t.update(id, func(job *Job) {
+		started := t.now()
+		job.Status = StatusRunning
+		job.StartedAt = &started
+		job.ProcessTraceID = traceID(span.SpanContext())
+		span.SetAttributes(attribute.Int64("job.queue_wait_ms", started.Sub(job.AcceptedAt).Milliseconds()))
+	})
+
+	subscriberID, err := work(ctx)
+
+	t.update(id, func(job *Job) {
+		finished := t.now()
+		job.FinishedAt = &finished
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = StatusSucceeded
+		job.SubscriberID = subscriberID
+	})
+	if err == nil {
+		span.SetAttributes(attrs.SubscriberID(subscriberID))
+	}
+	return err
+}
+
+func (t *Tracker) update(id int64, change func(job *Job)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if job, ok := t.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// remember records job, forgetting the oldest finished jobs beyond
+// maxRetainedJobs. Callers hold t.mu.
+func (t *Tracker) remember(job *Job) {
+	t.jobs[job.ID] = job
+	t.order = append(t.order, job.ID)
+
+	excess := len(t.order) - maxRetainedJobs
+	if excess <= 0 {
+		return
+	}
+	kept := t.order[:0]
+	for _, id := range t.order {
+		old := t.jobs[id]
+		if excess > 0 && (old.Status == StatusSucceeded || old.Status == StatusFailed) {
+			delete(t.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	t.order = kept
+}
+
+func (t *Tracker) forget(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.jobs, id)
+	for i, queued := range t.order {
+		if queued == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func traceID(sc trace.SpanContext) string {
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,171 @@
+// Package loader coalesces individual lookups issued during one request into
+// batched calls, dataloader style. Create a Loader per request so results
+// are only memoized for that request.
+package loader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"telemetry-demo/telemetry"
+)
+
+const (
+	DefaultWait     = 2 * time.Millisecond
+	DefaultMaxBatch = 100
+)
+
+// BatchFunc fetches many keys at once. Keys missing from the returned map
+// are treated as not found.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type batch[K comparable, V any] struct {
+	ctx    context.Context
+	keys   []K
+	once   sync.Once
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+type Loader[K comparable, V any] struct {
+	name     string
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+	seen    map[K]*batch[K, V]
+}
+
+// New creates a loader that waits up to wait for more keys before fetching,
+// dispatching early once maxBatch keys are queued.
+func New[K comparable, V any](name string, fetch BatchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	if maxBatch < 1 {
+		maxBatch = DefaultMaxBatch
+	}
+
+	return &Loader[K, V]{
+		name:     name,
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		seen:     make(map[K]*batch[K, V]),
+	}
+}
+
+// Load returns the value for key, batching it with other keys requested
+// around the same time. Keys already loaded by this loader are served from
+// the earlier batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	b := l.enqueue(ctx, key)
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+
+	if b.err != nil {
+		var zero V
+		return zero, false, b.err
+	}
+
+	value, ok := b.values[key]
+	return value, ok, nil
+}
+
+// LoadMany loads every key, returning only the ones that were found.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	batches := make([]*batch[K, V], len(keys))
+	for i, key := range keys {
+		batches[i] = l.enqueue(ctx, key)
+	}
+
+	values := make(map[K]V, len(keys))
+	for i, b := range batches {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if b.err != nil {
+			return nil, b.err
+		}
+		if value, ok := b.values[keys[i]]; ok {
+			values[keys[i]] = value
+		}
+	}
+
+	return values, nil
+}
+
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K) *batch[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.seen[key]; ok {
+		return b
+	}
+
+	b := l.pending
+	if b == nil {
+		// The first caller's context parents the batch span
+		b = &batch[K, V]{ctx: ctx, done: make(chan struct{})}
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	b.keys = append(b.keys, key)
+	l.seen[key] = b
+
+	if len(b.keys) >= l.maxBatch {
+		l.pending = nil
+		go l.dispatch(b)
+	}
+
+	return b
+}
+
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	b.once.Do(func() {
+		defer close(b.done)
+		b.values, b.err = l.run(b.ctx, b.keys)
+	})
+}
+
+func (l *Loader[K, V]) run(ctx context.Context, keys []K) (map[K]V, error) {
+	ctx, span := otel.Tracer("telemetry-demo/loader").Start(ctx, "loader.batch "+l.name)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("loader.name", l.name),
+		attribute.Int("loader.batch_size", len(keys)),
+		attribute.Int("loader.max_batch", l.maxBatch),
+	)
+
+	values, err := l.fetch(ctx, keys)
+	if err != nil {
+		telemetry.FailSpan(span, err, "Batch fetch failed")
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("loader.found", len(values)),
+		attribute.Int("loader.missing", len(keys)-len(values)),
+	)
+
+	return values, nil
+}
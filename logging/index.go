@@ -0,0 +1,203 @@
+package logging
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// DefaultIndexSize is how many log lines TraceLogs remembers unless
+// SetCapacity changes it.
+const DefaultIndexSize = 5000
+
+// TraceLogs is the process-wide index of recent log lines by trace ID.
+// The logrus hook in logging/logruslog and IndexHandler fill it.
+var TraceLogs = NewIndex(DefaultIndexSize)
+
+// The index's instruments come straight from the OpenTelemetry API rather
+// than the telemetry package's helpers, so this package keeps depending on
+// nothing but the standard library and OpenTelemetry.
+var (
+	indexMeter   = otel.Meter("telemetry-demo/logging")
+	indexIndexed = indexCounter("log.index.indexed", "Log lines with a trace ID added to the log index")
+	indexEvicted = indexCounter("log.index.evicted", "Log lines the log index forgot to make room for newer ones")
+	indexLines   = indexGauge("log.index.lines", "{line}", "Log lines currently held by the log index")
+	indexTraces  = indexGauge("log.index.traces", "{trace}", "Traces with at least one log line in the log index")
+)
+
+func indexCounter(name, description string) metric.Int64Counter {
+	counter, err := indexMeter.Int64Counter(name, metric.WithUnit("{line}"), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create counter %s: %v", name, err)
+		return noop.Int64Counter{}
+	}
+	return counter
+}
+
+func indexGauge(name, unit, description string) metric.Int64ObservableGauge {
+	gauge, err := indexMeter.Int64ObservableGauge(name, metric.WithUnit(unit), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create gauge %s: %v", name, err)
+		return noop.Int64ObservableGauge{}
+	}
+	return gauge
+}
+
+// Entry is one captured log line.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Fields  Fields    `json:"fields,omitempty"`
+}
+
+// IndexStats describes what an Index holds and has forgotten.
+type IndexStats struct {
+	Capacity int `json:"capacity"`
+	Lines    int `json:"lines"`
+	Traces   int `json:"traces"`
+	// Indexed and Evicted count lines since startup or the last
+	// SetCapacity.
+	Indexed int64 `json:"indexed"`
+	Evicted int64 `json:"evicted"`
+	// Oldest is when the oldest remembered line was logged, so
+	// lines before it may be missing from a trace.
+	Oldest *time.Time `json:"oldest,omitempty"`
+}
+
+// Index remembers the latest log lines that carry a trace ID, so the lines
+// of one trace can be looked up next to its spans. It holds at most a
+// fixed number of lines; the oldest are forgotten first.
+type Index struct {
+	mu      sync.Mutex
+	byTrace map[string][]Entry
+	// order is a ring of the trace ID of every remembered line, oldest at
+	// next once it is full
+	order   []string
+	next    int
+	size    int
+	indexed int64
+	evicted int64
+	// redact rewrites an entry's fields before it is remembered
+	redact func(map[string]any) map[string]any
+}
+
+// NewIndex returns an Index holding up to capacity lines. A zero capacity
+// remembers nothing.
+func NewIndex(capacity int) *Index {
+	return &Index{
+		byTrace: make(map[string][]Entry),
+		order:   make([]string, capacity),
+	}
+}
+
+// SetCapacity resizes the index to hold up to capacity lines, forgetting
+// every line it held.
+func (x *Index) SetCapacity(capacity int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.byTrace = make(map[string][]Entry)
+	x.order = make([]string, capacity)
+	x.next, x.size = 0, 0
+	x.indexed, x.evicted = 0, 0
+}
+
+// SetRedaction rewrites the fields of every line added from now on with
+// redact, so values such as email addresses are never held or served. nil
+// keeps fields as logged.
+func (x *Index) SetRedaction(redact func(map[string]any) map[string]any) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.redact = redact
+}
+
+// Add remembers entry under traceID, forgetting the oldest line when the
+// index is full.
+func (x *Index) Add(traceID string, entry Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if len(x.order) == 0 {
+		return
+	}
+	if x.size == len(x.order) {
+		oldest := x.order[x.next]
+		if remaining := x.byTrace[oldest][1:]; len(remaining) > 0 {
+			x.byTrace[oldest] = remaining
+		} else {
+			delete(x.byTrace, oldest)
+		}
+		x.evicted++
+		indexEvicted.Add(context.Background(), 1)
+	} else {
+		x.size++
+	}
+	if x.redact != nil && len(entry.Fields) > 0 {
+		entry.Fields = x.redact(entry.Fields)
+	}
+	x.order[x.next] = traceID
+	x.next = (x.next + 1) % len(x.order)
+	x.byTrace[traceID] = append(x.byTrace[traceID], entry)
+	x.indexed++
+	indexIndexed.Add(context.Background(), 1)
+}
+
+// Trace returns the remembered lines of traceID, oldest first.
+func (x *Index) Trace(traceID string) []Entry {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return append([]Entry(nil), x.byTrace[traceID]...)
+}
+
+// Stats reports what the index holds.
+func (x *Index) Stats() IndexStats {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	stats := IndexStats{
+		Capacity: len(x.order),
+		Lines:    x.size,
+		Traces:   len(x.byTrace),
+		Indexed:  x.indexed,
+		Evicted:  x.evicted,
+	}
+	if x.size > 0 {
+		// The oldest line is first among its trace's lines
+		oldestTrace := x.order[0]
+		if x.size == len(x.order) {
+			oldestTrace = x.order[x.next]
+		}
+		oldest := x.byTrace[oldestTrace][0].Time
+		stats.Oldest = &oldest
+	}
+	return stats
+}
+
+// Observe reports the index's size as the log.index.lines and
+// log.index.traces gauges on every metric collection until the returned
+// func is called.
+func (x *Index) Observe() func() {
+	registration, err := indexMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := x.Stats()
+		o.ObserveInt64(indexLines, int64(stats.Lines))
+		o.ObserveInt64(indexTraces, int64(stats.Traces))
+		return nil
+	}, indexLines, indexTraces)
+	if err != nil {
+		log.Printf("Failed to observe the log index: %v", err)
+		return func() {}
+	}
+	return func() {
+		if err := registration.Unregister(); err != nil {
+			log.Printf("Failed to stop observing the log index: %v", err)
+		}
+	}
+}
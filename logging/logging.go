@@ -0,0 +1,83 @@
+// Package logging is the structured logger V2 handlers write through.
+// Logger hides the backend: the log/slog implementation here needs only the
+// standard library, and the logrus one lives in logging/logruslog so only
+// programs that choose it pull logrus in.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Level is a log line's severity.
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// ParseLevel reads debug, info, warn, or error.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level %q", name)
+}
+
+// Fields are key-value pairs attached to a log line.
+type Fields map[string]any
+
+// Logger writes structured log lines. WithFields returns a Logger that adds
+// fields to every line it writes, leaving the receiver unchanged.
+type Logger interface {
+	WithFields(fields Fields) Logger
+	Log(level Level, msg string)
+	Debug(msg string)
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+// ContextLogger adds the trace and span IDs of a request's context to each
+// line, so handlers don't copy them into their fields by hand, along with
+// the Baggage members the caller sent.
+type ContextLogger struct {
+	Logger
+	// Baggage lists the baggage members logged as fields of the same name
+	// when the context carries them, e.g. tenant.id.
+	Baggage []string
+}
+
+// WithTracing returns a Logger for the span and baggage in ctx, or the
+// plain Logger when ctx carries neither.
+func (l ContextLogger) WithTracing(ctx context.Context) Logger {
+	fields := Fields{}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields["trace_id"] = span.TraceID().String()
+		fields["span_id"] = span.SpanID().String()
+	}
+	bag := baggage.FromContext(ctx)
+	for _, key := range l.Baggage {
+		if member := bag.Member(key); member.Key() != "" {
+			fields[key] = member.Value()
+		}
+	}
+	if len(fields) == 0 {
+		return l.Logger
+	}
+	return l.Logger.WithFields(fields)
+}
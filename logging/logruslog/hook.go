@@ -0,0 +1,41 @@
+package logruslog
+
+import (
+	"github.com/sirupsen/logrus"
+	"telemetry-demo/logging"
+)
+
+// IndexHook adds every line with a trace_id field to index, e.g.
+// logging.TraceLogs. Lines without one are left alone.
+func IndexHook(index *logging.Index) logrus.Hook {
+	return indexHook{index: index}
+}
+
+type indexHook struct {
+	index *logging.Index
+}
+
+func (h indexHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h indexHook) Fire(entry *logrus.Entry) error {
+	traceID, _ := entry.Data["trace_id"].(string)
+	if traceID == "" {
+		return nil
+	}
+
+	fields := make(logging.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if key != "trace_id" {
+			fields[key] = value
+		}
+	}
+	h.index.Add(traceID, logging.Entry{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Fields:  fields,
+	})
+	return nil
+}
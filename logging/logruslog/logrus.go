@@ -0,0 +1,57 @@
+// Package logruslog implements logging.Logger with logrus.
+package logruslog
+
+import (
+	"github.com/sirupsen/logrus"
+	"telemetry-demo/logging"
+)
+
+type logger struct {
+	entry *logrus.Entry
+}
+
+// New returns a Logger with the demo's usual colored text output that drops
+// lines below level. Lines with a trace ID are also kept in
+// logging.TraceLogs.
+func New(level logging.Level) logging.Logger {
+	l := logrus.New()
+	l.SetLevel(logrusLevel(level))
+	l.SetFormatter(&logrus.TextFormatter{
+		TimestampFormat: "15:04:05",
+		FullTimestamp:   true,
+		ForceColors:     true,
+	})
+	l.AddHook(IndexHook(logging.TraceLogs))
+	return Wrap(l)
+}
+
+// Wrap returns a Logger writing through l.
+func Wrap(l *logrus.Logger) logging.Logger {
+	return logger{entry: logrus.NewEntry(l)}
+}
+
+func (l logger) WithFields(fields logging.Fields) logging.Logger {
+	return logger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l logger) Log(level logging.Level, msg string) {
+	l.entry.Log(logrusLevel(level), msg)
+}
+
+func (l logger) Debug(msg string) { l.entry.Debug(msg) }
+func (l logger) Info(msg string)  { l.entry.Info(msg) }
+func (l logger) Warn(msg string)  { l.entry.Warn(msg) }
+func (l logger) Error(msg string) { l.entry.Error(msg) }
+
+func logrusLevel(level logging.Level) logrus.Level {
+	switch level {
+	case logging.DebugLevel:
+		return logrus.DebugLevel
+	case logging.WarnLevel:
+		return logrus.WarnLevel
+	case logging.ErrorLevel:
+		return logrus.ErrorLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlog returns a Logger writing to handler.
+func NewSlog(handler slog.Handler) Logger {
+	return slogLogger{logger: slog.New(handler)}
+}
+
+func (l slogLogger) WithFields(fields Fields) Logger {
+	// Sorted, so lines list the same fields in the same order
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]any, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, key, fields[key])
+	}
+	return slogLogger{logger: l.logger.With(args...)}
+}
+
+func (l slogLogger) Log(level Level, msg string) {
+	l.logger.Log(context.Background(), SlogLevel(level), msg)
+}
+
+func (l slogLogger) Debug(msg string) { l.Log(DebugLevel, msg) }
+func (l slogLogger) Info(msg string)  { l.Log(InfoLevel, msg) }
+func (l slogLogger) Warn(msg string)  { l.Log(WarnLevel, msg) }
+func (l slogLogger) Error(msg string) { l.Log(ErrorLevel, msg) }
+
+// SlogLevel is the slog equivalent of level.
+func SlogLevel(level Level) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// IndexHandler wraps handler to also add every record with a trace_id
+// attribute to index, e.g. TraceLogs. It is the log/slog counterpart of the
+// logrus hook in logging/logruslog.
+func IndexHandler(handler slog.Handler, index *Index) slog.Handler {
+	return indexHandler{Handler: handler, index: index}
+}
+
+type indexHandler struct {
+	slog.Handler
+	index *Index
+	// attrs were added with WithAttrs, which is where WithFields puts the
+	// trace ID
+	attrs []slog.Attr
+}
+
+func (h indexHandler) Handle(ctx context.Context, r slog.Record) error {
+	var traceID string
+	fields := Fields{}
+	collect := func(a slog.Attr) bool {
+		if a.Key == "trace_id" {
+			traceID = a.Value.String()
+		} else {
+			fields[a.Key] = a.Value.Any()
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	r.Attrs(collect)
+
+	if traceID != "" {
+		h.index.Add(traceID, Entry{
+			Time:    r.Time,
+			Level:   strings.ToLower(r.Level.String()),
+			Message: r.Message,
+			Fields:  fields,
+		})
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h indexHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return indexHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		index:   h.index,
+		attrs:   append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...),
+	}
+}
+
+func (h indexHandler) WithGroup(name string) slog.Handler {
+	return indexHandler{Handler: h.Handler.WithGroup(name), index: h.index, attrs: h.attrs}
+}
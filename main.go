@@ -1,66 +1,71 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
-	"telemetry-demo/handlers"
-	"telemetry-demo/store"
-	"telemetry-demo/telemetry"
+	"telemetry-demo/app"
+	"telemetry-demo/config"
 )
 
 func main() {
-	// Initialize tracing
-	cleanup := telemetry.InitTracer()
-	defer cleanup()
+	env := flag.String("env", "", "environment profile: dev, staging, or prod (default $APP_ENV, then dev)")
+	flag.Parse()
 
-	// Create in-memory store
-	memStore := store.NewMemoryStore()
+	// Load and validate runtime configuration before anything starts. The
+	// environment profile fills in defaults the environment leaves unset,
+	// including the OTEL_* ones telemetry reads. The startup trace is
+	// backdated to cover it
+	started := time.Now()
+	if err := config.ApplyProfile(*env); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
-	// Create handlers
-	v0Handler := handlers.NewV0Handler(memStore)
-	v1Handler := handlers.NewV1Handler(memStore)
+	// Telemetry, store, services, middleware, and routes are assembled in
+	// app.Build; options there let alternate setups swap pieces
+	application, err := app.Build(cfg, app.WithStartTime(started))
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+	defer application.Close()
 
-	// Setup Gin router
-	router := gin.Default()
+	log.Printf("🚀 Starting Telemetry Demo Server on :8080 (environment: %s, gin mode: %s, latency profile: %s)", cfg.Environment, cfg.GinMode, application.LatencyProfile.Name)
+	log.Printf("📊 V0 endpoints available at %s/v0/subscribers (basic logging)", cfg.BasePath)
+	log.Printf("🔍 V1 endpoints available at %s/v1/subscribers (manual tracing)", cfg.BasePath)
+	log.Printf("✨ V2 endpoints available at %s/v2/subscribers (automatic middleware)", cfg.BasePath)
+	log.Printf("🛠️  Admin endpoints available at %s/admin (telemetry introspection)", cfg.BasePath)
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "healthy"})
-	})
+	// Serve until SIGINT or SIGTERM, then let in-flight requests finish.
+	// Returning runs application.Close, which flushes queued spans
+	stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	// V0 Routes - Basic Logging
-	v0 := router.Group("/v0")
-	{
-		v0.POST("/subscribers", v0Handler.CreateSubscriber)
-		v0.GET("/subscribers", v0Handler.GetSubscribers)
-		v0.GET("/subscribers/:id", v0Handler.GetSubscriber)
-	}
+	server := &http.Server{Addr: ":8080", Handler: application.Handler}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
 
-	// V1 Routes - Manual Tracing
-	v1 := router.Group("/v1")
-	{
-		v1.POST("/subscribers", v1Handler.CreateSubscriber)
-		v1.GET("/subscribers", v1Handler.GetSubscribers)
-		v1.GET("/subscribers/:id", v1Handler.GetSubscriber)
+	select {
+	case err := <-serveErr:
+		// log.Fatal skips deferred calls, so flush first
+		application.Close()
+		log.Fatalf("Server stopped: %v", err)
+	case <-stop.Done():
 	}
 
-	// V2 Routes - Middleware Magic
-	v2Handler := handlers.NewV2Handler(memStore)
-	
-	// Create V2 group with OpenTelemetry middleware
-	v2 := router.Group("/v2")
-	v2.Use(otelgin.Middleware("telemetry-demo"))  // Automatic HTTP tracing for V2 only
-	{
-		v2.POST("/subscribers", v2Handler.CreateSubscriber)
-		v2.GET("/subscribers", v2Handler.GetSubscribers) 
-		v2.GET("/subscribers/:id", v2Handler.GetSubscriber)
+	log.Printf("🛑 Shutting down, waiting up to %s for in-flight requests", cfg.ShutdownTimeout)
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error shutting down server: %v", err)
 	}
-
-	log.Println("🚀 Starting Telemetry Demo Server on :8080")
-	log.Println("📊 V0 endpoints available at /v0/subscribers (basic logging)")
-	log.Println("🔍 V1 endpoints available at /v1/subscribers (manual tracing)")
-	log.Println("✨ V2 endpoints available at /v2/subscribers (automatic middleware)")
-	log.Fatal(router.Run(":8080"))
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog is gin's request logger with the client's geo location,
+// tenant, and experiment variant appended when they are known.
+func AccessLog() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		location := ""
+		if country, ok := param.Keys[ClientCountryKey].(string); ok {
+			location = " | " + country
+			if city, ok := param.Keys[ClientCityKey].(string); ok && city != "" {
+				location += "/" + city
+			}
+		}
+
+		tenantID := ""
+		if id, ok := param.Keys[TenantKey].(string); ok {
+			tenantID = " | tenant=" + id
+		}
+
+		experiment := ""
+		if variant, ok := param.Keys[ExperimentVariantKey].(string); ok {
+			experiment = " | exp=" + variant
+		}
+
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s%s | %-7s %#v%s%s\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency.Round(time.Microsecond),
+			param.ClientIP,
+			location,
+			param.Method,
+			param.Path,
+			tenantID,
+			experiment,
+			param.ErrorMessage,
+		)
+	})
+}
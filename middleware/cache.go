@@ -0,0 +1,424 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/cache"
+	"telemetry-demo/pool"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+	"telemetry-demo/tenant"
+)
+
+// The handler time misses cost and hits skipped, the same comparison as
+// CacheStats, by route.
+var (
+	cacheHandlerTime = telemetry.Float64Counter(httpMeter, "http_cache.handler_time", "ms",
+		"Handler time spent producing responses the cache missed, by route")
+	cacheSavedTime = telemetry.Float64Counter(httpMeter, "http_cache.saved_time", "ms",
+		"Handler time skipped by serving cached responses, by route")
+)
+
+// Revalidation pool sizing. A stale hit that finds the queue full is served
+// without starting a refresh; the next one tries again.
+const (
+	RevalidationWorkers   = 2
+	RevalidationQueueSize = 64
+)
+
+// maxCachedBodyBytes keeps large responses (such as full exports) out of
+// the cache.
+const maxCachedBodyBytes = 1 << 20
+
+type cachedResponse struct {
+	status      int
+	contentType string
+	body        []byte
+	handlerTime time.Duration
+	storedAt    time.Time
+	// freshUntil is when a stale-while-revalidate entry turns stale. It
+	// stays in the cache, served while being refreshed, for its route's
+	// window after that.
+	freshUntil time.Time
+}
+
+// SizeBytes lets the cache's Stats count response bodies.
+func (r *cachedResponse) SizeBytes() int {
+	return len(r.body) + len(r.contentType)
+}
+
+// CacheStats compares what the cache saved against what misses cost.
+type CacheStats struct {
+	Entries        int     `json:"entries"`
+	Hits           int64   `json:"hits"`
+	Misses         int64   `json:"misses"`
+	Bypassed       int64   `json:"bypassed"`
+	Degraded       int64   `json:"degraded"`
+	Stale          int64   `json:"stale"`
+	Revalidations  int64   `json:"revalidations"`
+	RevalidateErrs int64   `json:"revalidation_failures"`
+	HitRatio       float64 `json:"hit_ratio"`
+	HandlerTimeMs  float64 `json:"handler_time_ms"`
+	SavedHandlerMs float64 `json:"saved_handler_ms"`
+	TTLMs          int64   `json:"ttl_ms"`
+	// StaleWhileRevalidate lists the routes served stale while refreshing
+	// and how long past their TTL, in ms.
+	StaleWhileRevalidate map[string]int64 `json:"stale_while_revalidate_ms,omitempty"`
+}
+
+// CacheDegradation describes the response cache's degraded mode.
+type CacheDegradation struct {
+	cache.BreakerStatus
+	SimulatedOutage bool `json:"simulated_outage"`
+}
+
+// ResponseCache serves repeated GETs from memory instead of running their
+// handlers. It must run after otelgin so hits and misses are annotated on
+// the server span. When the cache keeps failing, its breaker puts it in
+// degraded mode and requests go straight to their handlers.
+type ResponseCache struct {
+	cache   *cache.InMemoryCache
+	tracer  trace.Tracer
+	breaker *cache.Breaker
+
+	// staleFor maps route templates to how long past the TTL their
+	// responses are still served while handler refreshes them
+	staleFor     map[string]time.Duration
+	handler      http.Handler
+	refreshers   *pool.Pool
+	revalidating sync.Map
+
+	hits           atomic.Int64
+	misses         atomic.Int64
+	bypassed       atomic.Int64
+	degraded       atomic.Int64
+	stale          atomic.Int64
+	revalidations  atomic.Int64
+	revalidateErrs atomic.Int64
+	handlerTime    atomic.Int64
+	savedTime      atomic.Int64
+}
+
+// ResponseCacheOption configures a ResponseCache.
+type ResponseCacheOption func(*ResponseCache)
+
+// WithStaleWhileRevalidate serves a route's expired responses for up to its
+// window past the TTL while refreshing them on refreshers, by replaying the
+// request through handler. routes maps full route templates, such as
+// /v2/subscribers/:id, to their windows.
+func WithStaleWhileRevalidate(handler http.Handler, routes map[string]time.Duration, refreshers *pool.Pool) ResponseCacheOption {
+	return func(rc *ResponseCache) {
+		rc.handler = handler
+		rc.staleFor = routes
+		rc.refreshers = refreshers
+	}
+}
+
+// WithBreaker changes when the cache is considered down and how long it is
+// skipped, instead of cache.DefaultBreakerConfig.
+func WithBreaker(config cache.BreakerConfig) ResponseCacheOption {
+	return func(rc *ResponseCache) {
+		rc.breaker = cache.NewBreaker(rc.cache.Name(), config)
+	}
+}
+
+// NewResponseCache caches responses in store for its default TTL.
+func NewResponseCache(store *cache.InMemoryCache, opts ...ResponseCacheOption) *ResponseCache {
+	rc := &ResponseCache{
+		cache:   store,
+		tracer:  otel.Tracer("telemetry-demo/http_cache"),
+		breaker: cache.NewBreaker(store.Name(), cache.DefaultBreakerConfig()),
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	return rc
+}
+
+// revalidationKey marks the context of a background refresh, which must
+// replace the stale entry instead of being served it.
+type revalidationKey struct{}
+
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		span := trace.SpanFromContext(c.Request.Context())
+
+		// Let clients force a fresh response, e.g. while demoing misses
+		if strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+			rc.bypassed.Add(1)
+			span.SetAttributes(attribute.Bool("http_cache.bypass", true))
+			c.Header("X-Cache", "BYPASS")
+			c.Next()
+			return
+		}
+
+		// In degraded mode the cache isn't called at all until its
+		// breaker lets a probe through
+		if !rc.breaker.Allow(c.Request.Context()) {
+			rc.degraded.Add(1)
+			span.SetAttributes(attribute.Bool("cache.degraded", true))
+			c.Header("X-Cache", "DEGRADED")
+			c.Next()
+			return
+		}
+
+		key := cacheKey(c)
+		window, swr := rc.staleFor[c.FullPath()]
+		revalidation := c.Request.Context().Value(revalidationKey{}) != nil
+		value, ok, err := rc.cache.Get(c.Request.Context(), key)
+		if rc.breaker.Record(c.Request.Context(), err) {
+			// Invalidations were lost while the cache was down, so nothing
+			// in it, including what the probe just read, can be trusted
+			dropped := rc.cache.Clear()
+			log.Printf("Cache %s dropped %d entries that may have missed invalidations", rc.cache.Name(), dropped)
+			ok = false
+		}
+		if err != nil {
+			// Serve from the handler without caching; the breaker decides
+			// when to stop trying
+			span.SetAttributes(
+				attribute.Bool("cache.degraded", rc.breaker.Degraded()),
+				attribute.String("http_cache.error", err.Error()),
+			)
+			c.Header("X-Cache", "ERROR")
+			c.Next()
+			return
+		}
+		if ok && !revalidation {
+			cached := value.(*cachedResponse)
+			age := time.Since(cached.storedAt)
+			fresh := !swr || time.Now().Before(cached.freshUntil)
+			rc.hits.Add(1)
+			rc.savedTime.Add(int64(cached.handlerTime))
+			cacheSavedTime.Add(c.Request.Context(), telemetry.Milliseconds(cached.handlerTime), metric.WithAttributes(attrs.HTTPRoute.String(c.FullPath())))
+
+			span.SetAttributes(
+				attribute.Bool("http_cache.hit", true),
+				attribute.Bool("http_cache.fresh", fresh),
+				attribute.Int64("http_cache.age_ms", age.Milliseconds()),
+				attribute.Float64("http_cache.saved_ms", float64(cached.handlerTime.Microseconds())/1000),
+			)
+			c.Header("Age", strconv.Itoa(int(age.Seconds())))
+			if fresh {
+				c.Header("X-Cache", "HIT")
+			} else {
+				rc.stale.Add(1)
+				started := rc.revalidate(c, key)
+				span.SetAttributes(
+					attribute.Int64("http_cache.stale_ms", time.Since(cached.freshUntil).Milliseconds()),
+					attribute.Bool("http_cache.revalidation_started", started),
+				)
+				c.Header("X-Cache", "STALE")
+			}
+			if swr {
+				c.Header("Cache-Control", cacheControl(time.Until(cached.freshUntil), window))
+			}
+			c.Data(cached.status, cached.contentType, cached.body)
+			c.Abort()
+			return
+		}
+
+		if revalidation {
+			span.SetAttributes(attribute.Bool("http_cache.revalidation", true))
+			c.Header("X-Cache", "REVALIDATE")
+		} else {
+			rc.misses.Add(1)
+			span.SetAttributes(attribute.Bool("http_cache.hit", false))
+			c.Header("X-Cache", "MISS")
+		}
+		ttl := rc.cache.DefaultTTL()
+		if swr {
+			c.Header("Cache-Control", cacheControl(ttl, window))
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		start := time.Now()
+
+		c.Next()
+
+		elapsed := time.Since(start)
+		rc.handlerTime.Add(int64(elapsed))
+		cacheHandlerTime.Add(c.Request.Context(), telemetry.Milliseconds(elapsed), metric.WithAttributes(attrs.HTTPRoute.String(c.FullPath())))
+
+		if c.Writer.Status() != http.StatusOK || recorder.overflow {
+			return
+		}
+		now := time.Now()
+		err = rc.cache.Set(c.Request.Context(), key, &cachedResponse{
+			status:      http.StatusOK,
+			contentType: c.Writer.Header().Get("Content-Type"),
+			body:        recorder.body.Bytes(),
+			handlerTime: elapsed,
+			storedAt:    now,
+			freshUntil:  now.Add(ttl),
+		}, cache.WithTTL(ttl+window))
+		rc.breaker.Record(c.Request.Context(), err)
+	}
+}
+
+// revalidate queues a refresh of key that replays c's request, unless one
+// is already running or the pool is full. The refresh runs in the pool's
+// trace, linked to the request that served the stale response, so that
+// request's latency doesn't include it.
+func (rc *ResponseCache) revalidate(c *gin.Context, key string) bool {
+	if _, running := rc.revalidating.LoadOrStore(key, true); running {
+		return false
+	}
+
+	// The request is copied now: gin reuses c once the response is sent
+	route := c.FullPath()
+	req := c.Request.Clone(context.Background())
+	err := rc.refreshers.TrySubmit(c.Request.Context(), "http_cache.revalidate", func(ctx context.Context) error {
+		defer rc.revalidating.Delete(key)
+		ctx, span := rc.tracer.Start(ctx, "http_cache.revalidate", trace.WithAttributes(
+			attribute.String("cache.key", key),
+			attrs.HTTPRoute.String(route),
+		))
+		defer span.End()
+
+		response := httptest.NewRecorder()
+		rc.handler.ServeHTTP(response, req.WithContext(context.WithValue(ctx, revalidationKey{}, true)))
+		span.SetAttributes(attrs.HTTPStatusCode.Int(response.Code))
+		if response.Code != http.StatusOK {
+			rc.revalidateErrs.Add(1)
+			err := fmt.Errorf("revalidation returned %d", response.Code)
+			telemetry.FailSpan(span, err, "")
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		rc.revalidating.Delete(key)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("http_cache.revalidation_error", err.Error()))
+		return false
+	}
+	rc.revalidations.Add(1)
+	return true
+}
+
+// cacheKey is the request URI, suffixed with the tenant when there is one
+// so tenants never share entries. The tenant goes last so that invalidating
+// a URI prefix still drops every tenant's copy.
+func cacheKey(c *gin.Context) string {
+	key := c.Request.URL.RequestURI()
+	if id, ok := tenant.FromContext(c.Request.Context()); ok {
+		key += "#tenant=" + id
+	}
+	return key
+}
+
+// cacheControl tells clients how much longer a response is fresh and how
+// long they may keep using it while fetching a new one.
+func cacheControl(fresh, staleWhileRevalidate time.Duration) string {
+	if fresh < 0 {
+		fresh = 0
+	}
+	return fmt.Sprintf("max-age=%d, stale-while-revalidate=%d", int(fresh.Seconds()), int(staleWhileRevalidate.Seconds()))
+}
+
+// Invalidate drops cached responses whose request URI starts with prefix.
+// Write paths call it so readers don't see stale data for a whole TTL.
+func (rc *ResponseCache) Invalidate(ctx context.Context, prefix string) (int, error) {
+	return rc.cache.DeletePrefix(ctx, prefix)
+}
+
+// Purge drops every cached response.
+func (rc *ResponseCache) Purge() int {
+	return rc.cache.Clear()
+}
+
+// SetOutage starts or ends a simulated outage of the backing cache and
+// reports whether it changed.
+func (rc *ResponseCache) SetOutage(down bool) bool {
+	return rc.cache.SetOutage(down)
+}
+
+// Degradation reports the cache's breaker and whether an outage is being
+// simulated.
+func (rc *ResponseCache) Degradation() CacheDegradation {
+	return CacheDegradation{BreakerStatus: rc.breaker.Status(), SimulatedOutage: rc.cache.Outage()}
+}
+
+// SweepStats reports the backing cache's cleanup loop.
+func (rc *ResponseCache) SweepStats() cache.SweepStats {
+	return rc.cache.SweepStats()
+}
+
+func (rc *ResponseCache) Stats() CacheStats {
+	hits, misses := rc.hits.Load(), rc.misses.Load()
+
+	stats := CacheStats{
+		Entries:        rc.cache.Len(),
+		Hits:           hits,
+		Misses:         misses,
+		Bypassed:       rc.bypassed.Load(),
+		Degraded:       rc.degraded.Load(),
+		Stale:          rc.stale.Load(),
+		Revalidations:  rc.revalidations.Load(),
+		RevalidateErrs: rc.revalidateErrs.Load(),
+		HandlerTimeMs:  float64(time.Duration(rc.handlerTime.Load()).Microseconds()) / 1000,
+		SavedHandlerMs: float64(time.Duration(rc.savedTime.Load()).Microseconds()) / 1000,
+		TTLMs:          rc.cache.DefaultTTL().Milliseconds(),
+	}
+	if len(rc.staleFor) > 0 {
+		stats.StaleWhileRevalidate = make(map[string]int64, len(rc.staleFor))
+		for route, window := range rc.staleFor {
+			stats.StaleWhileRevalidate[route] = window.Milliseconds()
+		}
+	}
+	if hits+misses > 0 {
+		stats.HitRatio = float64(hits) / float64(hits+misses)
+	}
+	return stats
+}
+
+// bodyRecorder copies what the handler writes so it can be cached, giving
+// up once the body grows past maxCachedBodyBytes.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *bodyRecorder) Write(data []byte) (int, error) {
+	r.record(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.record([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *bodyRecorder) record(data []byte) {
+	if r.overflow {
+		return
+	}
+	if r.body.Len()+len(data) > maxCachedBodyBytes {
+		r.overflow = true
+		r.body.Reset()
+		return
+	}
+	r.body.Write(data)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+	"telemetry-demo/telemetry"
+)
+
+// Gin context keys set by ClientInfo, read back by AccessLog.
+const (
+	ClientCountryKey = "client.geo.country"
+	ClientCityKey    = "client.geo.city"
+)
+
+// ClientInfo resolves the real client IP (gin honours X-Forwarded-For only
+// from trusted proxies), optionally geolocates it, and stores the result in
+// the request context where telemetry.RequestAttributesProcessor picks it
+// up for spans. geo may be nil to skip geolocation.
+func ClientInfo(geo GeoLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := telemetry.ClientInfo{Address: c.ClientIP()}
+
+		if geo != nil {
+			if ip := net.ParseIP(info.Address); ip != nil {
+				if country, city, ok := geo.Lookup(ip); ok {
+					info.Country = country
+					info.City = city
+					c.Set(ClientCountryKey, country)
+					c.Set(ClientCityKey, city)
+				}
+			}
+		}
+
+		c.Request = c.Request.WithContext(telemetry.ContextWithClientInfo(c.Request.Context(), info))
+		c.Next()
+	}
+}
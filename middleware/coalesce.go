@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/i18n"
+	"telemetry-demo/tenant"
+)
+
+// statusClientClosedRequest is nginx's status for a client that hung up
+// before the response was ready.
+const statusClientClosedRequest = 499
+
+// CoalesceStats counts how identical concurrent GETs were shared.
+type CoalesceStats struct {
+	Leaders   int64 `json:"leaders"`
+	Followers int64 `json:"followers"`
+	// Fallbacks are followers that ran the handler themselves because the
+	// leader's response couldn't be shared (too large, or it panicked).
+	Fallbacks int64 `json:"fallbacks"`
+	InFlight  int   `json:"in_flight"`
+}
+
+// coalescedCall is one leader's execution that followers wait on.
+type coalescedCall struct {
+	done      chan struct{}
+	traceID   string
+	followers int
+	// response is nil when the leader's result can't be shared.
+	response *sharedResponse
+}
+
+type sharedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// RequestCoalescer runs identical concurrent GETs once. The first request
+// for a key is the leader and runs the handler; requests that arrive with
+// the same route, URI, and API key while it runs are followers and get a
+// copy of its response. It must run after otelgin so each request's server
+// span records its role, and after the response cache so hits never wait.
+type RequestCoalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+
+	leaders   atomic.Int64
+	followers atomic.Int64
+	fallbacks atomic.Int64
+}
+
+func NewRequestCoalescer() *RequestCoalescer {
+	return &RequestCoalescer{calls: make(map[string]*coalescedCall)}
+}
+
+func (rc *RequestCoalescer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		key := coalesceKey(c)
+		span := trace.SpanFromContext(c.Request.Context())
+
+		rc.mu.Lock()
+		if call, ok := rc.calls[key]; ok {
+			call.followers++
+			rc.mu.Unlock()
+			rc.follow(c, span, call)
+			return
+		}
+		call := &coalescedCall{
+			done:    make(chan struct{}),
+			traceID: span.SpanContext().TraceID().String(),
+		}
+		rc.calls[key] = call
+		rc.mu.Unlock()
+
+		rc.lead(c, span, key, call)
+	}
+}
+
+// lead runs the handler and publishes its response. The deferred release
+// also runs if the handler panics, so followers never wait forever.
+func (rc *RequestCoalescer) lead(c *gin.Context, span trace.Span, key string, call *coalescedCall) {
+	rc.leaders.Add(1)
+	span.SetAttributes(attribute.String("http_coalesce.role", "leader"))
+	c.Header("X-Coalesce", "LEADER")
+
+	recorder := &bodyRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	defer func() {
+		rc.mu.Lock()
+		delete(rc.calls, key)
+		followers := call.followers
+		rc.mu.Unlock()
+		close(call.done)
+		span.SetAttributes(attribute.Int("http_coalesce.followers", followers))
+	}()
+
+	c.Next()
+
+	if recorder.overflow {
+		return
+	}
+	call.response = &sharedResponse{
+		status: c.Writer.Status(),
+		header: c.Writer.Header().Clone(),
+		body:   recorder.body.Bytes(),
+	}
+}
+
+// follow waits for the leader and replays its response, or runs the
+// handler itself when there is nothing to replay.
+func (rc *RequestCoalescer) follow(c *gin.Context, span trace.Span, call *coalescedCall) {
+	rc.followers.Add(1)
+	span.SetAttributes(
+		attribute.String("http_coalesce.role", "follower"),
+		attribute.String("http_coalesce.leader_trace_id", call.traceID),
+	)
+	c.Header("X-Coalesce", "FOLLOWER")
+
+	start := time.Now()
+	select {
+	case <-call.done:
+	case <-c.Request.Context().Done():
+		span.SetAttributes(attribute.Bool("http_coalesce.canceled", true))
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	}
+	span.SetAttributes(attribute.Float64("http_coalesce.wait_ms", float64(time.Since(start).Microseconds())/1000))
+
+	shared := call.response
+	if shared == nil {
+		rc.fallbacks.Add(1)
+		span.SetAttributes(attribute.Bool("http_coalesce.shared", false))
+		c.Next()
+		return
+	}
+
+	span.SetAttributes(attribute.Bool("http_coalesce.shared", true))
+	header := c.Writer.Header()
+	for name, values := range shared.header {
+		if _, ok := header[name]; !ok {
+			header[name] = values
+		}
+	}
+	c.Data(shared.status, shared.header.Get("Content-Type"), shared.body)
+	c.Abort()
+}
+
+func (rc *RequestCoalescer) Stats() CoalesceStats {
+	rc.mu.Lock()
+	inFlight := len(rc.calls)
+	rc.mu.Unlock()
+
+	return CoalesceStats{
+		Leaders:   rc.leaders.Load(),
+		Followers: rc.followers.Load(),
+		Fallbacks: rc.fallbacks.Load(),
+		InFlight:  inFlight,
+	}
+}
+
+// coalesceKey identifies identical requests: the same route and URI from
+// the same API key and tenant, so callers never see another tenant's
+// response. The locale is part of the key because error bodies are
+// translated.
+func coalesceKey(c *gin.Context) string {
+	caller := anonymousKeyID
+	if key := strings.TrimSpace(c.GetHeader(APIKeyHeader)); key != "" {
+		caller = HashAPIKey(key)
+	}
+	tenantID, _ := tenant.FromContext(c.Request.Context())
+	locale := i18n.LocaleFromContext(c.Request.Context())
+	return caller + " " + tenantID + " " + locale + " " + c.FullPath() + " " + c.Request.URL.RequestURI()
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// DaprCallerHeader names the app that invoked this one through its Dapr
+// sidecar.
+const DaprCallerHeader = "dapr-caller-app-id"
+
+// DaprCaller tags the request's span with the calling Dapr app, so traces
+// crossing the sidecar show who made the call. It must run after the
+// middleware that starts the server span.
+func DaprCaller() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := trace.SpanFromContext(c.Request.Context())
+		span.SetAttributes(attribute.Bool("dapr.invoked", true))
+		if caller := strings.TrimSpace(c.GetHeader(DaprCallerHeader)); caller != "" {
+			span.SetAttributes(
+				attribute.String("dapr.caller_app_id", caller),
+				attrs.PeerService.String(caller),
+			)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// ExperimentVariantKey holds the request's variant in the gin context so the
+// access log can print it.
+const ExperimentVariantKey = "experiment.variant"
+
+// experimentVariantKey holds the bare variant for HTTP metrics.
+const experimentVariantKey = "experiment.variant.name"
+
+// ExperimentHeader echoes the assigned variant back to the caller.
+const ExperimentHeader = "X-Experiment-Variant"
+
+// VariantStats is the aggregated traffic for one experiment variant.
+type VariantStats struct {
+	Variant       string  `json:"variant"`
+	Requests      int64   `json:"requests"`
+	ClientErrors  int64   `json:"client_errors"`
+	ServerErrors  int64   `json:"server_errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+
+	totalLatency time.Duration
+}
+
+// ExperimentAssigner puts each caller in a variant of one experiment and
+// tags the request's telemetry with it. Assignment hashes the API key, or
+// the subscriber ID for keyless requests, so a caller sees the same variant
+// on every request. Requests with neither are bucketed by client IP.
+type ExperimentAssigner struct {
+	name     string
+	variants []string
+
+	mu    sync.Mutex
+	stats map[string]*VariantStats
+}
+
+func NewExperimentAssigner(name string, variants []string) *ExperimentAssigner {
+	stats := make(map[string]*VariantStats, len(variants))
+	for _, variant := range variants {
+		stats[variant] = &VariantStats{Variant: variant}
+	}
+
+	return &ExperimentAssigner{
+		name:     name,
+		variants: variants,
+		stats:    stats,
+	}
+}
+
+func (e *ExperimentAssigner) Name() string {
+	return e.name
+}
+
+// Assign returns the variant for a unit such as a hashed API key.
+func (e *ExperimentAssigner) Assign(unit string) string {
+	h := fnv.New32a()
+	h.Write([]byte(e.name + ":" + unit))
+	return e.variants[h.Sum32()%uint32(len(e.variants))]
+}
+
+// Middleware tags the root span with experiment.name and
+// experiment.variant, then records the outcome under that variant.
+func (e *ExperimentAssigner) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		variant := e.Assign(experimentUnit(c))
+		ctx := telemetry.ContextWithRootAttributes(c.Request.Context(),
+			attrs.ExperimentName.String(e.name),
+			attrs.ExperimentVariant.String(variant),
+		)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(ExperimentVariantKey, e.name+":"+variant)
+		c.Set(experimentVariantKey, variant)
+		c.Header(ExperimentHeader, variant)
+
+		c.Next()
+
+		e.record(variant, c.Writer.Status(), time.Since(start))
+	}
+}
+
+func experimentUnit(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader(APIKeyHeader)); key != "" {
+		return "key:" + HashAPIKey(key)
+	}
+	if id := c.Param("id"); id != "" && strings.Contains(c.FullPath(), "/subscribers/") {
+		return "subscriber:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func (e *ExperimentAssigner) record(variant string, status int, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := e.stats[variant]
+	stats.Requests++
+	switch {
+	case status >= 500:
+		stats.ServerErrors++
+	case status >= 400:
+		stats.ClientErrors++
+	}
+	stats.totalLatency += latency
+}
+
+// Report returns per-variant traffic in the configured variant order.
+func (e *ExperimentAssigner) Report() []VariantStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	report := make([]VariantStats, 0, len(e.variants))
+	for _, variant := range e.variants {
+		entry := *e.stats[variant]
+		if entry.Requests > 0 {
+			entry.ErrorRate = float64(entry.ClientErrors+entry.ServerErrors) / float64(entry.Requests)
+			entry.MeanLatencyMs = float64(entry.totalLatency.Microseconds()) / float64(entry.Requests) / 1000
+		}
+		report = append(report, entry)
+	}
+	return report
+}
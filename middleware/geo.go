@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// GeoLookup resolves an IP address to a location.
+type GeoLookup interface {
+	Lookup(ip net.IP) (country, city string, ok bool)
+}
+
+type geoRange struct {
+	network *net.IPNet
+	country string
+	city    string
+}
+
+// GeoIPTable is a small local GeoIP database: a list of CIDR ranges loaded
+// from a CSV file with rows of "cidr,country,city". The first matching
+// range wins, so list more specific ranges first.
+type GeoIPTable struct {
+	ranges []geoRange
+}
+
+func LoadGeoIPTable(path string) (*GeoIPTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading GeoIP table %s: %w", path, err)
+	}
+
+	table := &GeoIPTable{}
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("GeoIP table %s line %d: expected cidr,country[,city]", path, i+1)
+		}
+
+		_, network, err := net.ParseCIDR(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("GeoIP table %s line %d: %w", path, i+1, err)
+		}
+
+		entry := geoRange{network: network, country: strings.TrimSpace(record[1])}
+		if len(record) > 2 {
+			entry.city = strings.TrimSpace(record[2])
+		}
+		table.ranges = append(table.ranges, entry)
+	}
+
+	return table, nil
+}
+
+func (t *GeoIPTable) Lookup(ip net.IP) (string, string, bool) {
+	for _, entry := range t.ranges {
+		if entry.network.Contains(ip) {
+			return entry.country, entry.city, true
+		}
+	}
+	return "", "", false
+}
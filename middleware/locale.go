@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"telemetry-demo/i18n"
+	"telemetry-demo/telemetry"
+)
+
+// LocaleKey is the gin context key holding the request's resolved locale.
+const LocaleKey = "i18n.locale"
+
+// Locale resolves the caller's language from Accept-Language so error
+// messages can be translated, and tags the root span with the locale that
+// was picked. Unsupported languages fall back to i18n.DefaultLocale.
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(LocaleKey, locale)
+
+		ctx := i18n.ContextWithLocale(c.Request.Context(), locale)
+		ctx = telemetry.ContextWithRootAttributes(ctx, attribute.String("i18n.locale", locale))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
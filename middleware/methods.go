@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// MetadataRequestAttr marks HEAD and OPTIONS spans so latency dashboards can
+// exclude requests that never do real work.
+var MetadataRequestAttr = attrs.HTTPMetadataRequest.Bool(true)
+
+// MethodOverrideHeader lets clients that can only send GET and POST, such
+// as HTML forms, tunnel another method through a POST.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// DefaultOverridableMethods are the methods MethodOverride lets a POST
+// become. Safe methods are left out, so an override never turns a write
+// into a cacheable read.
+var DefaultOverridableMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+type routeEntry struct {
+	pattern  string
+	segments []string
+	params   int
+	methods  []string
+}
+
+// RouteTable records which methods are registered for each route pattern.
+// Create it before registering middleware and Load it once all routes exist.
+type RouteTable struct {
+	mu     sync.RWMutex
+	routes []routeEntry
+}
+
+func NewRouteTable() *RouteTable {
+	return &RouteTable{}
+}
+
+func (t *RouteTable) Load(routes gin.RoutesInfo) {
+	byPattern := make(map[string]*routeEntry)
+	var order []string
+	for _, route := range routes {
+		entry, ok := byPattern[route.Path]
+		if !ok {
+			entry = &routeEntry{pattern: route.Path, segments: splitPath(route.Path)}
+			for _, segment := range entry.segments {
+				if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+					entry.params++
+				}
+			}
+			byPattern[route.Path] = entry
+			order = append(order, route.Path)
+		}
+		entry.methods = append(entry.methods, route.Method)
+	}
+
+	entries := make([]routeEntry, 0, len(order))
+	for _, pattern := range order {
+		entries = append(entries, *byPattern[pattern])
+	}
+
+	t.mu.Lock()
+	t.routes = entries
+	t.mu.Unlock()
+}
+
+// Allowed returns the most specific pattern matching path and every method
+// that gin would accept for it, across all matching patterns.
+func (t *RouteTable) Allowed(path string) (string, []string, bool) {
+	segments := splitPath(path)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	best := -1
+	seen := make(map[string]bool)
+	var methods []string
+	for i, entry := range t.routes {
+		if !matchSegments(entry.segments, segments) {
+			continue
+		}
+		if best < 0 || entry.params < t.routes[best].params {
+			best = i
+		}
+		for _, method := range entry.methods {
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		}
+	}
+
+	if best < 0 {
+		return "", nil, false
+	}
+	sort.Strings(methods)
+	return t.routes[best].pattern, methods, true
+}
+
+// AllowHeader formats the Allow header value for path, including OPTIONS.
+func (t *RouteTable) AllowHeader(path string) string {
+	_, methods, ok := t.Allowed(path)
+	if !ok {
+		return ""
+	}
+	return strings.Join(append(methods, http.MethodOptions), ", ")
+}
+
+// MetadataMethods answers OPTIONS requests from the route table with an
+// Allow header, and tags HEAD and OPTIONS spans with MetadataRequestAttr.
+func MetadataMethods(table *RouteTable) gin.HandlerFunc {
+	tracer := otel.Tracer("telemetry-demo/router")
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodHead:
+			ctx := telemetry.ContextWithRootAttributes(c.Request.Context(), MetadataRequestAttr)
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+
+		case http.MethodOptions:
+			pattern, _, ok := table.Allowed(c.Request.URL.Path)
+			if !ok {
+				// Unknown path: let the not-found handler answer
+				c.Next()
+				return
+			}
+
+			_, span := tracer.Start(c.Request.Context(), pattern,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attrs.HTTPMethod.String(http.MethodOptions),
+					attrs.HTTPRoute.String(pattern),
+					attrs.HTTPStatusCode.Int(http.StatusNoContent),
+					MetadataRequestAttr,
+				),
+			)
+			defer span.End()
+
+			c.Header("Allow", table.AllowHeader(c.Request.URL.Path))
+			c.AbortWithStatus(http.StatusNoContent)
+
+		default:
+			c.Next()
+		}
+	}
+}
+
+// MethodOverride serves a POST carrying MethodOverrideHeader as the method
+// the header names, when that method is in allowed. Any other override is
+// ignored and the request stays a POST. It wraps the whole engine because
+// gin picks the route before middleware runs; every middleware, including
+// read-only mode, then sees the overridden method. The request's spans record
+// POST as http.request.method_original.
+func MethodOverride(next http.Handler, allowed ...string) http.Handler {
+	permitted := make(map[string]bool, len(allowed))
+	for _, method := range allowed {
+		permitted[strings.ToUpper(method)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			method := strings.ToUpper(strings.TrimSpace(r.Header.Get(MethodOverrideHeader)))
+			if permitted[method] {
+				ctx := telemetry.ContextWithRootAttributes(r.Context(), attrs.HTTPMethodOriginal.String(r.Method))
+				r = r.WithContext(ctx)
+				r.Method = method
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func matchSegments(pattern, path []string) bool {
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(segment, ":") && segment != path[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
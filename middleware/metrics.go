@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// httpDimensions are the only attributes HTTP metrics are recorded with.
+// Anything request-specific belongs on the span, not here.
+var httpDimensions = telemetry.Dimensions{
+	Allowed:   []attribute.Key{attrs.HTTPRoute, attrs.HTTPRequestMethod, attrs.HTTPStatusClass, attrs.TenantTier, attrs.Synthetic, attrs.ExperimentVariant, attrs.UserAgentDeviceType},
+	MaxValues: 100,
+}
+
+var (
+	httpMeter          = telemetry.Meter("telemetry-demo/http")
+	httpServerDuration = telemetry.GuardedFloat64Histogram(httpMeter, "http.server.duration", "ms", "Server request duration by method, route, and status class", httpDimensions)
+)
+
+// HTTPMetrics records each request's duration in the http.server.duration
+// histogram. It must run after the middleware that starts the server span:
+// the measurement is made with the request's context, so it carries the
+// span as an exemplar and a latency bucket links to one of its traces.
+func HTTPMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		dimensions := []attribute.KeyValue{
+			attrs.HTTPRequestMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(c.FullPath()),
+			attrs.HTTPStatusClass.String(statusClass(c.Writer.Status())),
+		}
+		// Tiers are configured, so there are only a few of them
+		if tier := c.GetString(TenantTierKey); tier != "" {
+			dimensions = append(dimensions, attrs.TenantTier.String(tier))
+		}
+		// Device type is one of a handful of values UserAgent derives
+		if device := c.GetString(DeviceTypeKey); device != "" {
+			dimensions = append(dimensions, attrs.UserAgentDeviceType.String(device))
+		}
+		// Variants are a short configured list, so they are cheap to slice by
+		if variant := c.GetString(experimentVariantKey); variant != "" {
+			dimensions = append(dimensions, attrs.ExperimentVariant.String(variant))
+		}
+		// Probe traffic is kept apart, so it doesn't skew real latency
+		if synthetic := baggage.FromContext(c.Request.Context()).Member(string(attrs.Synthetic)); synthetic.Key() != "" {
+			dimensions = append(dimensions, attrs.Synthetic.String(synthetic.Value()))
+		}
+		httpServerDuration.Record(c.Request.Context(), telemetry.Milliseconds(time.Since(start)), metric.WithAttributes(dimensions...))
+	}
+}
+
+// statusClass groups a status code as 2xx, 4xx, and so on.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
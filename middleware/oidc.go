@@ -0,0 +1,398 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/identity"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+const (
+	oidcSessionCookie = "demo_session"
+	oidcStateCookie   = "demo_oidc_state"
+	oidcSessionTTL    = 8 * time.Hour
+	oidcStateTTL      = 10 * time.Minute
+	oidcPeerService   = "oidc-provider"
+	maxOIDCBodyBytes  = 1 << 20
+
+	// maxOIDCStates caps the logins awaiting their callback. Login is
+	// unauthenticated, so without a cap anyone could grow the map.
+	maxOIDCStates = 1024
+)
+
+// OIDCConfig describes the client registered with the identity provider.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// LoginPath is where unauthenticated requests are pointed.
+	LoginPath string
+}
+
+type oidcSession struct {
+	subject string
+	expires time.Time
+}
+
+type oidcState struct {
+	returnTo string
+	expires  time.Time
+}
+
+// OIDCAuth protects routes with an OpenID Connect authorization code login.
+// Calls to the provider are client spans, and authenticated requests carry
+// enduser.id on their root span. Sessions live in memory like the rest of
+// the demo's state.
+type OIDCAuth struct {
+	cfg          OIDCConfig
+	authorizeURL string
+	tokenURL     string
+	userinfoURL  string
+	client       *http.Client
+	tracer       trace.Tracer
+
+	mu       sync.Mutex
+	states   map[string]oidcState
+	sessions map[string]oidcSession
+}
+
+// NewOIDCAuth discovers the provider's endpoints from its issuer.
+func NewOIDCAuth(ctx context.Context, cfg OIDCConfig) (*OIDCAuth, error) {
+	a := &OIDCAuth{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		tracer:   otel.Tracer("telemetry-demo/auth"),
+		states:   make(map[string]oidcState),
+		sessions: make(map[string]oidcSession),
+	}
+
+	discoveryURL := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequest(http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := a.call(ctx, "oidc.discovery", req, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.UserinfoEndpoint == "" {
+		return nil, errors.New("oidc discovery: provider is missing authorization, token, or userinfo endpoint")
+	}
+
+	a.authorizeURL = discovery.AuthorizationEndpoint
+	a.tokenURL = discovery.TokenEndpoint
+	a.userinfoURL = discovery.UserinfoEndpoint
+	return a, nil
+}
+
+// Login redirects to the provider. ?return_to= sets where to land after
+// the callback; only local paths are accepted. Once maxOIDCStates logins
+// are pending, the oldest is forgotten to make room.
+func (a *OIDCAuth) Login(c *gin.Context) {
+	state := randomToken()
+	returnTo := localReturnTo(c.Query("return_to"))
+
+	now := time.Now()
+	a.mu.Lock()
+	oldest := ""
+	for key, pending := range a.states {
+		if now.After(pending.expires) {
+			delete(a.states, key)
+			continue
+		}
+		if oldest == "" || pending.expires.Before(a.states[oldest].expires) {
+			oldest = key
+		}
+	}
+	if len(a.states) >= maxOIDCStates {
+		delete(a.states, oldest)
+	}
+	a.states[state] = oidcState{returnTo: returnTo, expires: now.Add(oidcStateTTL)}
+	a.mu.Unlock()
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(oidcStateTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.cfg.ClientID},
+		"redirect_uri":  {a.cfg.RedirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+	}
+	c.Redirect(http.StatusFound, a.authorizeURL+"?"+query.Encode())
+}
+
+// localReturnTo returns raw when it is a path on this server, and "" for
+// anything a browser could take off-site: a scheme, a host, a
+// scheme-relative //host, or a backslash, which browsers read as a slash.
+func localReturnTo(raw string) string {
+	if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.Contains(raw, `\`) {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return ""
+	}
+	return raw
+}
+
+// Callback completes the login: it checks state, exchanges the code for an
+// access token, and looks the user up before starting a session.
+func (a *OIDCAuth) Callback(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "oidc.callback",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(c.FullPath()),
+		),
+	)
+	defer span.End()
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		telemetry.ClassifyError(span, telemetry.ErrorUnauthenticated)
+		span.SetAttributes(attribute.String("oidc.error", providerErr))
+		a.reject(c, span, problem.Newf(problem.Unauthenticated, "The identity provider refused the login: %s", providerErr))
+		return
+	}
+
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	a.mu.Lock()
+	pending, known := a.states[state]
+	delete(a.states, state)
+	a.mu.Unlock()
+	c.SetCookie(oidcStateCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+
+	if state == "" || state != cookie || !known || time.Now().After(pending.expires) {
+		telemetry.ClassifyError(span, telemetry.ErrorValidation)
+		span.SetAttributes(attribute.String("oidc.error", "invalid_state"))
+		a.reject(c, span, problem.New(problem.ValidationFailed, "Invalid or expired login state"))
+		return
+	}
+
+	accessToken, err := a.exchange(ctx, c.Query("code"))
+	if err != nil {
+		telemetry.FailSpan(span, err, "token exchange failed")
+		a.reject(c, span, problem.New(problem.IdentityProviderError, "Token exchange failed"))
+		return
+	}
+
+	user, err := a.userinfo(ctx, accessToken)
+	if err != nil {
+		telemetry.FailSpan(span, err, "userinfo lookup failed")
+		a.reject(c, span, problem.New(problem.IdentityProviderError, "Userinfo lookup failed"))
+		return
+	}
+
+	sessionID := randomToken()
+	now := time.Now()
+	a.mu.Lock()
+	for key, session := range a.sessions {
+		if now.After(session.expires) {
+			delete(a.sessions, key)
+		}
+	}
+	a.sessions[sessionID] = oidcSession{subject: user.Subject, expires: now.Add(oidcSessionTTL)}
+	a.mu.Unlock()
+
+	span.SetAttributes(attrs.EnduserID.String(user.Subject))
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcSessionCookie, sessionID, int(oidcSessionTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+
+	if pending.returnTo != "" {
+		span.SetAttributes(attrs.HTTPStatusCode.Int(http.StatusFound))
+		c.Redirect(http.StatusFound, pending.returnTo)
+		return
+	}
+	a.respond(c, span, http.StatusOK, gin.H{"status": "logged_in", "enduser_id": user.Subject})
+}
+
+// Logout ends the caller's session.
+func (a *OIDCAuth) Logout(c *gin.Context) {
+	if sessionID, err := c.Cookie(oidcSessionCookie); err == nil {
+		a.mu.Lock()
+		delete(a.sessions, sessionID)
+		a.mu.Unlock()
+	}
+	c.SetCookie(oidcSessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// Middleware rejects requests without a live session and tags the rest
+// with enduser.id. The session's subject becomes the request's caller.
+func (a *OIDCAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := a.session(c)
+		if !ok {
+			span := trace.SpanFromContext(c.Request.Context())
+			telemetry.ClassifyError(span, telemetry.ErrorUnauthenticated)
+			span.SetAttributes(attrs.ErrorCode.String(string(problem.Unauthenticated)))
+			problem.Abort(c, span, problem.New(problem.Unauthenticated, "").
+				With("login", a.cfg.LoginPath+"?return_to="+url.QueryEscape(c.Request.URL.RequestURI())))
+			return
+		}
+
+		ctx := telemetry.ContextWithRootAttributes(c.Request.Context(), attrs.EnduserID.String(session.subject))
+		ctx = identity.ContextWithCaller(ctx, identity.Caller{Subject: session.subject, Source: identity.SourceOIDC})
+		trace.SpanFromContext(ctx).SetAttributes(attrs.EnduserID.String(session.subject))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func (a *OIDCAuth) session(c *gin.Context) (oidcSession, bool) {
+	sessionID, err := c.Cookie(oidcSessionCookie)
+	if err != nil {
+		return oidcSession{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[sessionID]
+	if !ok || time.Now().After(session.expires) {
+		delete(a.sessions, sessionID)
+		return oidcSession{}, false
+	}
+	return session, true
+}
+
+func (a *OIDCAuth) exchange(ctx context.Context, code string) (string, error) {
+	if code == "" {
+		return "", errors.New("callback has no code")
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {a.cfg.RedirectURL},
+	}
+	req, err := http.NewRequest(http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := a.call(ctx, "oidc.token_exchange", req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token response has no access_token")
+	}
+	return token.AccessToken, nil
+}
+
+type oidcUser struct {
+	Subject string `json:"sub"`
+}
+
+func (a *OIDCAuth) userinfo(ctx context.Context, accessToken string) (oidcUser, error) {
+	req, err := http.NewRequest(http.MethodGet, a.userinfoURL, nil)
+	if err != nil {
+		return oidcUser{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var user oidcUser
+	if err := a.call(ctx, "oidc.userinfo", req, &user); err != nil {
+		return oidcUser{}, err
+	}
+	if user.Subject == "" {
+		return oidcUser{}, errors.New("userinfo response has no sub")
+	}
+	return user, nil
+}
+
+// call sends req to the provider in a client span and decodes the JSON
+// response into out. The span's URL leaves out the query string, which can
+// carry codes.
+func (a *OIDCAuth) call(ctx context.Context, name string, req *http.Request, out any) error {
+	target := *req.URL
+	target.RawQuery = ""
+
+	ctx, span := a.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(req.Method),
+			attrs.HTTPURL.String(target.String()),
+			attrs.PeerService.String(oidcPeerService),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attrs.HTTPStatusCode.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := fmt.Errorf("%s returned %s", oidcPeerService, resp.Status)
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCBodyBytes)).Decode(out); err != nil {
+		err = fmt.Errorf("decode %s response: %w", name, err)
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+	return nil
+}
+
+func (a *OIDCAuth) respond(c *gin.Context, span trace.Span, status int, body gin.H) {
+	span.SetAttributes(attrs.HTTPStatusCode.Int(status))
+	c.JSON(status, body)
+}
+
+// reject fails the login with p, in the same problem+json format as
+// Middleware.
+func (a *OIDCAuth) reject(c *gin.Context, span trace.Span, p *problem.Details) {
+	span.SetAttributes(
+		attrs.HTTPStatusCode.Int(p.Status),
+		attrs.ErrorCode.String(string(p.Code)),
+	)
+	problem.Abort(c, span, p)
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
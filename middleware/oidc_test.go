@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLocalReturnTo(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"/v2/subscribers?sort=name", "/v2/subscribers?sort=name"},
+		{"/admin/", "/admin/"},
+		{"", ""},
+		{"v2/subscribers", ""},
+		{"https://evil.example/", ""},
+		{"javascript:alert(1)", ""},
+		{"//evil.example/", ""},
+		{"///evil.example/", ""},
+		{`/\evil.example/`, ""},
+		{`/admin\..\`, ""},
+		{"/%zz", ""},
+	}
+	for _, tt := range tests {
+		if got := localReturnTo(tt.raw); got != tt.want {
+			t.Errorf("localReturnTo(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLoginCapsPendingStates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := &OIDCAuth{
+		authorizeURL: "https://idp.example/authorize",
+		states:       make(map[string]oidcState),
+	}
+
+	for i := 0; i < maxOIDCStates+10; i++ {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/auth/login?return_to=//evil.example", nil)
+		a.Login(c)
+		if w.Code != http.StatusFound {
+			t.Fatalf("login %d answered %d", i, w.Code)
+		}
+	}
+
+	if len(a.states) != maxOIDCStates {
+		t.Errorf("%d pending states, want the cap of %d", len(a.states), maxOIDCStates)
+	}
+	for _, pending := range a.states {
+		if pending.returnTo != "" {
+			t.Fatalf("kept off-site return_to %q", pending.returnTo)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// ReadOnlyErrorType is the span's error.type for writes rejected in
+// read-only mode. Clients see problem.ReadOnlyMode as the body's code.
+const ReadOnlyErrorType = telemetry.ErrorReadOnly
+
+// ReadOnlyStatus reports the current mode and what it has rejected.
+type ReadOnlyStatus struct {
+	Enabled   bool       `json:"enabled"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+	Rejected  int64      `json:"rejected_total"`
+}
+
+// ReadOnlyGuard rejects writes with 503 while read-only mode is on, e.g.
+// during a migration or a demo that must not change data. Reads are
+// untouched, and requests under an exempt prefix (admin, auth) are always
+// let through so the mode can be switched back off.
+type ReadOnlyGuard struct {
+	enabled   atomic.Bool
+	changedAt atomic.Pointer[time.Time]
+	rejected  atomic.Int64
+	exempt    []string
+	tracer    trace.Tracer
+}
+
+func NewReadOnlyGuard(enabled bool, exemptPrefixes ...string) *ReadOnlyGuard {
+	g := &ReadOnlyGuard{
+		exempt: exemptPrefixes,
+		tracer: otel.Tracer("telemetry-demo/router"),
+	}
+	g.enabled.Store(enabled)
+	return g
+}
+
+// Enabled reports whether writes are currently rejected.
+func (g *ReadOnlyGuard) Enabled() bool {
+	return g.enabled.Load()
+}
+
+// Set turns read-only mode on or off and reports whether it changed.
+func (g *ReadOnlyGuard) Set(enabled bool) bool {
+	if g.enabled.Swap(enabled) == enabled {
+		return false
+	}
+	now := time.Now()
+	g.changedAt.Store(&now)
+	return true
+}
+
+func (g *ReadOnlyGuard) Status() ReadOnlyStatus {
+	return ReadOnlyStatus{
+		Enabled:   g.enabled.Load(),
+		ChangedAt: g.changedAt.Load(),
+		Rejected:  g.rejected.Load(),
+	}
+}
+
+func (g *ReadOnlyGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !g.enabled.Load() || !isWrite(c.Request.Method) || g.isExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		g.reject(c)
+	}
+}
+
+// reject records the refused write as its own server span, since the
+// request never reaches a handler or otelgin. It answers 503, so the span
+// is marked Error like any other 5xx.
+func (g *ReadOnlyGuard) reject(c *gin.Context) {
+	total := g.rejected.Add(1)
+
+	route := c.FullPath()
+	if route == "" {
+		route = notFoundRoute
+	}
+
+	_, span := g.tracer.Start(c.Request.Context(), route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(route),
+			attrs.HTTPStatusCode.Int(http.StatusServiceUnavailable),
+			attrs.ErrorType.String(string(ReadOnlyErrorType)),
+			attrs.ErrorCategory.String(string(ReadOnlyErrorType.Category())),
+			attrs.ErrorCode.String(string(problem.ReadOnlyMode)),
+			attribute.Bool("read_only", true),
+			attribute.Int64("read_only.rejected_total", total),
+		),
+	)
+	span.AddEvent("read_only_rejected")
+	span.SetStatus(codes.Error, "Write rejected in read-only mode")
+	span.End()
+
+	problem.Abort(c, span, problem.New(problem.ReadOnlyMode, "Writes are disabled until read-only mode is switched off"))
+}
+
+func (g *ReadOnlyGuard) isExempt(path string) bool {
+	for _, prefix := range g.exempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/problem"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+var shedRequests = telemetry.Int64Counter(httpMeter, "http.server.shed_requests", "{request}",
+	"Requests rejected with 503 under overload, by route")
+
+// LoadStats is a snapshot of the shedder's view of current load.
+type LoadStats struct {
+	InFlight    int64 `json:"in_flight"`
+	MaxInFlight int64 `json:"max_in_flight"`
+	Overloaded  bool  `json:"overloaded"`
+	Shed        int64 `json:"shed_total"`
+}
+
+// LoadShedder rejects low-priority requests with 503 + Retry-After once too
+// many are in flight. Requests under an exempt prefix (health, admin) are
+// neither counted nor shed, so operators can still see what's going on.
+type LoadShedder struct {
+	maxInFlight int64
+	retryAfter  time.Duration
+	exempt      []string
+	tracer      trace.Tracer
+
+	inFlight atomic.Int64
+	shed     atomic.Int64
+}
+
+// NewLoadShedder creates a shedder; maxInFlight of zero disables shedding.
+func NewLoadShedder(maxInFlight int, retryAfter time.Duration, exemptPrefixes ...string) *LoadShedder {
+	return &LoadShedder{
+		maxInFlight: int64(maxInFlight),
+		retryAfter:  retryAfter,
+		exempt:      exemptPrefixes,
+		tracer:      otel.Tracer("telemetry-demo/router"),
+	}
+}
+
+func (s *LoadShedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.maxInFlight == 0 || s.isExempt(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		inFlight := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		if inFlight > s.maxInFlight {
+			s.reject(c, inFlight)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Overloaded reports whether new low-priority requests would be shed.
+func (s *LoadShedder) Overloaded() bool {
+	return s.maxInFlight > 0 && s.inFlight.Load() >= s.maxInFlight
+}
+
+func (s *LoadShedder) Stats() LoadStats {
+	return LoadStats{
+		InFlight:    s.inFlight.Load(),
+		MaxInFlight: s.maxInFlight,
+		Overloaded:  s.Overloaded(),
+		Shed:        s.shed.Load(),
+	}
+}
+
+func (s *LoadShedder) reject(c *gin.Context, inFlight int64) {
+	total := s.shed.Add(1)
+
+	route := c.FullPath()
+	if route == "" {
+		route = notFoundRoute
+	}
+
+	// The request never reaches its handler, so record the shed as its own
+	// server span instead of leaving it invisible in traces
+	_, span := s.tracer.Start(c.Request.Context(), route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(c.Request.Method),
+			attrs.HTTPRoute.String(route),
+			attrs.HTTPStatusCode.Int(http.StatusServiceUnavailable),
+			attrs.ErrorCode.String(string(problem.ServiceOverloaded)),
+			attrs.ErrorType.String(string(telemetry.ErrorOverloaded)),
+			attrs.ErrorCategory.String(string(telemetry.ErrorOverloaded.Category())),
+			attribute.Bool("load_shed", true),
+			attribute.Int64("load_shed.in_flight", inFlight),
+			attribute.Int64("load_shed.max_in_flight", s.maxInFlight),
+			attribute.Int64("load_shed.total", total),
+		),
+	)
+	span.AddEvent("load_shed")
+	span.SetStatus(codes.Error, "Request shed under overload")
+	span.End()
+	shedRequests.Add(c.Request.Context(), 1, metric.WithAttributes(attrs.HTTPRoute.String(route)))
+
+	retryAfter := int(math.Ceil(s.retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	problem.Abort(c, span, problem.New(problem.ServiceOverloaded, "").With("retry_after", retryAfter))
+}
+
+func (s *LoadShedder) isExempt(path string) bool {
+	for _, prefix := range s.exempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// notFoundRoute mirrors the placeholder used for unmatched routes.
+const notFoundRoute = "<not_found>"
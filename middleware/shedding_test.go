@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParkedWatchersDontShedTheAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const maxInFlight = 2
+	shedder := NewLoadShedder(maxInFlight, time.Second, "/v1/subscribers/watch")
+
+	release := make(chan struct{})
+	var parked sync.WaitGroup
+	r := gin.New()
+	r.Use(shedder.Middleware())
+	r.GET("/v1/subscribers/watch", func(c *gin.Context) {
+		parked.Done()
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/v1/subscribers", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Park more watchers than the shedder admits
+	const watchers = 3 * maxInFlight
+	parked.Add(watchers)
+	var finished sync.WaitGroup
+	for i := 0; i < watchers; i++ {
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/subscribers/watch", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("watch answered %d, want 200", w.Code)
+			}
+		}()
+	}
+	parked.Wait()
+	defer func() {
+		close(release)
+		finished.Wait()
+	}()
+
+	if stats := shedder.Stats(); stats.InFlight != 0 || stats.Overloaded {
+		t.Errorf("stats with parked watchers = %+v, want nothing in flight", stats)
+	}
+	for i := 0; i < 2*maxInFlight; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/subscribers", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d with %d parked watchers answered %d, want 200", i, watchers, w.Code)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+	"telemetry-demo/tenant"
+)
+
+// TenantKey holds the request's tenant in the gin context so the access log
+// can print it.
+const TenantKey = "tenant.id"
+
+// TenantTierKey holds the request's tenant tier in the gin context for
+// HTTPMetrics.
+const TenantTierKey = "tenant.tier"
+
+// DefaultTenantTier is the tier of tenants with none configured.
+const DefaultTenantTier = "standard"
+
+// baggageHeader is the W3C baggage request header.
+const baggageHeader = "baggage"
+
+// Tenant sources, the tenant.source attribute.
+const (
+	TenantSourceHeader  = "header"
+	TenantSourceJWT     = "jwt"
+	TenantSourceBaggage = "baggage"
+	TenantSourceDefault = "default"
+)
+
+// TenantResolver attributes each request to a tenant and puts it in the
+// request's baggage as tenant.id, so the spans of every layer, V2 and
+// service log lines, and cache keys carry it.
+type TenantResolver struct {
+	header   string
+	claim    string
+	fallback string
+	tiers    map[string]string
+}
+
+// NewTenantResolver reads the tenant from header or from claim of a bearer
+// JWT. Requests naming neither belong to fallback, the tenant of a
+// dedicated deployment; empty leaves them unattributed. An empty header or
+// claim isn't consulted. tiers maps tenants to their tier; the rest are
+// DefaultTenantTier.
+func NewTenantResolver(header, claim, fallback string, tiers map[string]string) *TenantResolver {
+	return &TenantResolver{header: header, claim: claim, fallback: fallback, tiers: tiers}
+}
+
+// Middleware resolves the tenant from the first of these that names a
+// valid one:
+//
+//   - the tenant header, typically set by a gateway
+//   - the claim in a bearer JWT. Its signature isn't checked here; like the
+//     header, it is trusted as far as whatever sits in front of the server
+//     checked it
+//   - a tenant.id member the caller already sent in baggage
+//   - the fallback tenant
+//
+// The root span records which one won as tenant.source, the tenant's tier
+// as tenant.tier, and any source that named an invalid ID as
+// tenant.rejected. The resolved tenant is also written
+// back into the baggage header, because otelgin extracts baggage from it
+// again and would otherwise drop it.
+func (r *TenantResolver) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		incoming, _ := baggage.Parse(c.GetHeader(baggageHeader))
+
+		candidates := []struct{ source, id string }{
+			{TenantSourceHeader, r.headerTenant(c)},
+			{TenantSourceJWT, r.claimTenant(c)},
+			{TenantSourceBaggage, incoming.Member(TenantKey).Value()},
+			{TenantSourceDefault, r.fallback},
+		}
+		var rejected []string
+		for _, candidate := range candidates {
+			if candidate.id == "" {
+				continue
+			}
+			bag, err := tenant.WithBaggage(incoming, candidate.id)
+			if err != nil {
+				rejected = append(rejected, candidate.source)
+				continue
+			}
+
+			tier := r.tier(candidate.id)
+			ctx, _ := tenant.ContextWithTenant(c.Request.Context(), candidate.id)
+			ctx = telemetry.ContextWithRootAttributes(ctx,
+				attribute.String("tenant.source", candidate.source),
+				attrs.TenantTier.String(tier),
+			)
+			c.Request = c.Request.WithContext(ctx)
+			c.Request.Header.Set(baggageHeader, bag.String())
+			c.Set(TenantKey, candidate.id)
+			c.Set(TenantTierKey, tier)
+			break
+		}
+		if len(rejected) > 0 {
+			c.Request = c.Request.WithContext(telemetry.ContextWithRootAttributes(c.Request.Context(),
+				attribute.StringSlice("tenant.rejected", rejected),
+			))
+		}
+
+		c.Next()
+	}
+}
+
+func (r *TenantResolver) tier(id string) string {
+	if tier, ok := r.tiers[id]; ok {
+		return tier
+	}
+	return DefaultTenantTier
+}
+
+func (r *TenantResolver) headerTenant(c *gin.Context) string {
+	if r.header == "" {
+		return ""
+	}
+	return strings.TrimSpace(c.GetHeader(r.header))
+}
+
+// claimTenant returns the tenant claim of a bearer JWT, or "" when there is
+// no token or it doesn't decode.
+func (r *TenantResolver) claimTenant(c *gin.Context) string {
+	if r.claim == "" {
+		return ""
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	id, _ := claims[r.claim].(string)
+	return id
+}
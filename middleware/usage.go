@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"telemetry-demo/identity"
+	"telemetry-demo/telemetry"
+)
+
+// APIKeyHeader carries the caller's API key. Keys are optional in the demo;
+// requests without one are tracked as anonymous.
+const APIKeyHeader = "X-API-Key"
+
+const (
+	anonymousKeyID = "anonymous"
+	overflowKeyID  = "other"
+	// maxTrackedKeys caps the aggregation table so a flood of random keys
+	// can't grow it without bound; extra keys are folded into "other".
+	maxTrackedKeys = 1000
+)
+
+// KeyUsage is the aggregated traffic for one API key.
+type KeyUsage struct {
+	KeyID        string  `json:"key_id"`
+	Requests     int64   `json:"requests"`
+	ClientErrors int64   `json:"client_errors"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	BytesIn      int64   `json:"bytes_in"`
+	BytesOut     int64   `json:"bytes_out"`
+}
+
+// UsageTracker aggregates request counts, errors, and data volume per API
+// key. Keys are only ever stored and reported as hashed IDs.
+type UsageTracker struct {
+	mu   sync.Mutex
+	keys map[string]*KeyUsage
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{keys: make(map[string]*KeyUsage)}
+}
+
+// HashAPIKey returns a short, stable ID for an API key that is safe to put
+// on spans and in reports.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Middleware tags the request's root span with api_key.id, records the key
+// as the request's caller, and records the outcome once the rest of the
+// chain has run.
+func (t *UsageTracker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := anonymousKeyID
+		if key := strings.TrimSpace(c.GetHeader(APIKeyHeader)); key != "" {
+			keyID = HashAPIKey(key)
+			ctx := telemetry.ContextWithRootAttributes(c.Request.Context(), attribute.String("api_key.id", keyID))
+			// The key's ID is also who called, for downstream services
+			ctx = identity.ContextWithCaller(ctx, identity.Caller{Subject: keyID, Source: identity.SourceAPIKey})
+			c.Request = c.Request.WithContext(ctx)
+		}
+
+		c.Next()
+
+		var bytesIn int64
+		if c.Request.ContentLength > 0 {
+			bytesIn = c.Request.ContentLength
+		}
+		t.record(keyID, c.Writer.Status(), bytesIn, int64(c.Writer.Size()))
+	}
+}
+
+func (t *UsageTracker) record(keyID string, status int, bytesIn, bytesOut int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage, ok := t.keys[keyID]
+	if !ok {
+		if len(t.keys) >= maxTrackedKeys {
+			keyID = overflowKeyID
+			usage = t.keys[keyID]
+		}
+		if usage == nil {
+			usage = &KeyUsage{KeyID: keyID}
+			t.keys[keyID] = usage
+		}
+	}
+
+	usage.Requests++
+	switch {
+	case status >= 500:
+		usage.ServerErrors++
+	case status >= 400:
+		usage.ClientErrors++
+	}
+	usage.BytesIn += bytesIn
+	if bytesOut > 0 {
+		usage.BytesOut += bytesOut
+	}
+}
+
+// Report returns usage per key, busiest first.
+func (t *UsageTracker) Report() []KeyUsage {
+	t.mu.Lock()
+	report := make([]KeyUsage, 0, len(t.keys))
+	for _, usage := range t.keys {
+		entry := *usage
+		entry.ErrorRate = float64(entry.ClientErrors+entry.ServerErrors) / float64(entry.Requests)
+		report = append(report, entry)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Requests != report[j].Requests {
+			return report[i].Requests > report[j].Requests
+		}
+		return report[i].KeyID < report[j].KeyID
+	})
+	return report
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"telemetry-demo/telemetry"
+)
+
+// DeviceTypeKey is the gin context key holding the request's device type,
+// which HTTPMetrics records as a dimension.
+const DeviceTypeKey = "user_agent.device_type"
+
+// UserAgent parses the User-Agent header into normalized browser, OS, and
+// device type values and stores them in the request context for spans.
+func UserAgent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := ParseUserAgent(c.Request.UserAgent())
+		c.Set(DeviceTypeKey, info.DeviceType)
+		c.Request = c.Request.WithContext(telemetry.ContextWithUserAgent(c.Request.Context(), info))
+		c.Next()
+	}
+}
+
+// ParseUserAgent maps a User-Agent string onto a small fixed set of values.
+// Versions are deliberately dropped: "Chrome" is a useful dimension,
+// "Chrome 118.0.5993.88" is a cardinality explosion.
+func ParseUserAgent(ua string) telemetry.UserAgentInfo {
+	lower := strings.ToLower(ua)
+	return telemetry.UserAgentInfo{
+		Original:   ua,
+		Browser:    browserFamily(lower),
+		OS:         osFamily(lower),
+		DeviceType: deviceType(lower),
+	}
+}
+
+func browserFamily(ua string) string {
+	switch {
+	case ua == "":
+		return "unknown"
+	case isBot(ua):
+		return "bot"
+	case strings.HasPrefix(ua, "curl/"):
+		return "curl"
+	case strings.Contains(ua, "go-http-client"):
+		return "go-http-client"
+	case strings.Contains(ua, "postman"):
+		return "postman"
+	case strings.Contains(ua, "edg/"):
+		return "edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		return "opera"
+	case strings.Contains(ua, "firefox/"):
+		return "firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		return "chrome"
+	case strings.Contains(ua, "safari/"):
+		return "safari"
+	default:
+		return "other"
+	}
+}
+
+func osFamily(ua string) string {
+	switch {
+	case strings.Contains(ua, "android"):
+		return "android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		return "ios"
+	case strings.Contains(ua, "windows"):
+		return "windows"
+	case strings.Contains(ua, "mac os x"), strings.Contains(ua, "macintosh"):
+		return "macos"
+	case strings.Contains(ua, "linux"):
+		return "linux"
+	default:
+		return "other"
+	}
+}
+
+func deviceType(ua string) string {
+	switch {
+	case ua == "":
+		return "unknown"
+	case isBot(ua):
+		return "bot"
+	case strings.HasPrefix(ua, "curl/"), strings.Contains(ua, "go-http-client"), strings.Contains(ua, "postman"):
+		return "cli"
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi"), strings.Contains(ua, "iphone"), strings.Contains(ua, "android"):
+		return "mobile"
+	default:
+		return "desktop"
+	}
+}
+
+func isBot(ua string) bool {
+	return strings.Contains(ua, "bot") || strings.Contains(ua, "spider") || strings.Contains(ua, "crawl")
+}
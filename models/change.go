@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Subscriber change kinds recorded in the store's change feed.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// SubscriberChange is one write in the store's change feed. Seq increases
+// by one per change, so a client resumes the feed from the last Seq it saw.
+type SubscriberChange struct {
+	Seq          int64  `json:"seq"`
+	Kind         string `json:"kind"`
+	SubscriberID int    `json:"subscriber_id"`
+	// Subscriber is the subscriber as written, absent for deletes.
+	Subscriber *Subscriber `json:"subscriber,omitempty"`
+	At         time.Time   `json:"at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Activity event types accepted by the ingestion endpoint.
+const (
+	EventOpen  = "open"
+	EventClick = "click"
+)
+
+// ActivityEvent is a lightweight engagement signal such as an email open.
+type ActivityEvent struct {
+	SubscriberID int       `json:"subscriber_id"`
+	Type         string    `json:"type"`
+	Campaign     string    `json:"campaign,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Engagement rolls a subscriber's activity events up into a score.
+type Engagement struct {
+	Opens     int       `json:"opens"`
+	Clicks    int       `json:"clicks"`
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
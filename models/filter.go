@@ -0,0 +1,34 @@
+package models
+
+import "strings"
+
+// SubscriberFilter narrows the subscribers an operation such as a count
+// applies to. A nil filter, like the zero value, matches every subscriber.
+type SubscriberFilter struct {
+	// EmailDomain keeps subscribers whose email is at this domain, compared
+	// case-insensitively.
+	EmailDomain string
+}
+
+// Empty reports whether f matches every subscriber.
+func (f *SubscriberFilter) Empty() bool {
+	return f == nil || f.EmailDomain == ""
+}
+
+// Matches reports whether subscriber passes f.
+func (f *SubscriberFilter) Matches(subscriber *Subscriber) bool {
+	if f.Empty() {
+		return true
+	}
+	at := strings.LastIndex(subscriber.Email, "@")
+	return at >= 0 && strings.EqualFold(subscriber.Email[at+1:], f.EmailDomain)
+}
+
+// String is the filter the way spans and logs show it, e.g.
+// "email_domain=example.com", or "" when it is empty.
+func (f *SubscriberFilter) String() string {
+	if f.Empty() {
+		return ""
+	}
+	return "email_domain=" + f.EmailDomain
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Subscriber fields lists can be sorted by. SortByCreatedAt sorts by the
+// JSON's created member.
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByEmail     = "email"
+	SortByCreatedAt = "created_at"
+)
+
+// SortByCreated is the name ?sort= first took for SortByCreatedAt. It is
+// still accepted, matching the JSON member.
+const SortByCreated = "created"
+
+// SortFields lists every field a SortKey may name.
+var SortFields = []string{SortByID, SortByName, SortByEmail, SortByCreatedAt}
+
+// SortField returns the field name sorts by, resolving SortByCreated to
+// SortByCreatedAt, and false when no field goes by name.
+func SortField(name string) (string, bool) {
+	if name == SortByCreated {
+		return SortByCreatedAt, true
+	}
+	return name, slices.Contains(SortFields, name)
+}
+
+// SortKey orders subscribers by one field. A list sorted by several keys
+// compares by the first and falls back to the next on ties.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// String is the key as written in ?sort=, e.g. "-name" for descending.
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Field
+	}
+	return k.Field
+}
+
+// FormatSort writes keys the way ?sort= takes them, e.g. "created_at,-name".
+func FormatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.String()
+	}
+	return strings.Join(parts, ",")
+}
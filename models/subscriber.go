@@ -7,4 +7,7 @@ type Subscriber struct {
 	Name     string    `json:"name" binding:"required"`
 	Email    string    `json:"email" binding:"required,email"`
 	Created  time.Time `json:"created"`
+	// Engagement is filled in by the activity event aggregator once the
+	// subscriber has any events.
+	Engagement *Engagement `json:"engagement,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,204 @@
+// Package notify delivers subscriber writes to the notification service as
+// webhooks. Deliveries run on a bounded worker pool, each in a trace linked
+// to the write that caused it. They carry trace context and the caller's
+// identity, and are signed with webhooksig when a secret is configured.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/identity"
+	"telemetry-demo/models"
+	"telemetry-demo/pool"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+	"telemetry-demo/webhooksig"
+)
+
+// peerService is the peer.service of delivery spans.
+const peerService = "notification-service"
+
+// deliveryTimeout bounds one delivery, including the response.
+const deliveryTimeout = 5 * time.Second
+
+// Delivery pool sizing. A write whose delivery finds the queue full is
+// not delivered.
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 256
+)
+
+// Delivery outcomes, the notify.outcome attribute.
+const (
+	OutcomeDelivered = "delivered"
+	OutcomeRejected  = "rejected"
+	OutcomeFailed    = "failed"
+	// OutcomeDropped is a delivery never attempted because the pool was
+	// full or closed.
+	OutcomeDropped = "dropped"
+)
+
+var (
+	notifyMeter = telemetry.Meter("telemetry-demo/notify")
+	deliveries  = telemetry.Int64Counter(notifyMeter, "notify.deliveries", "{delivery}",
+		"Webhook deliveries to the notification service, by notify.event and notify.outcome")
+)
+
+// Notification is the webhook body.
+type Notification struct {
+	// Event is subscriber.created, subscriber.updated, or
+	// subscriber.deleted.
+	Event        string             `json:"event"`
+	SubscriberID int                `json:"subscriber_id"`
+	Subscriber   *models.Subscriber `json:"subscriber,omitempty"`
+	At           time.Time          `json:"at"`
+}
+
+// Config says where and how deliveries are sent.
+type Config struct {
+	URL string
+	// Secret signs deliveries with webhooksig. Empty sends them unsigned.
+	Secret []byte
+	// Identity hands the caller on. Nil sends every delivery anonymous.
+	Identity identity.Propagator
+}
+
+// Notifier delivers writes in the background on its own pool, so they
+// never slow the request that made them. It implements service.Notifier.
+type Notifier struct {
+	cfg    Config
+	pool   *pool.Pool
+	client *http.Client
+	tracer trace.Tracer
+}
+
+// New delivers on pool, which the Notifier drains on Close. The client's
+// otelhttp transport makes each request a client span that injects trace
+// context.
+func New(cfg Config, pool *pool.Pool) *Notifier {
+	return &Notifier{
+		cfg:  cfg,
+		pool: pool,
+		client: &http.Client{
+			Timeout: deliveryTimeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanOptions(trace.WithAttributes(attrs.PeerService.String(peerService))),
+			),
+		},
+		tracer: otel.Tracer("telemetry-demo/notify"),
+	}
+}
+
+// SubscriberChanged queues change for delivery without blocking. A full
+// queue drops it, counted as OutcomeDropped. The pool carries the
+// request's baggage over; the caller is carried here.
+func (n *Notifier) SubscriberChanged(ctx context.Context, change models.SubscriberChange) {
+	notification := Notification{
+		Event:        "subscriber." + change.Kind,
+		SubscriberID: change.SubscriberID,
+		Subscriber:   change.Subscriber,
+		At:           change.At,
+	}
+	caller, hasCaller := identity.CallerFromContext(ctx)
+
+	err := n.pool.TrySubmit(ctx, "notify.deliver", func(ctx context.Context) error {
+		if hasCaller {
+			ctx = identity.ContextWithCaller(ctx, caller)
+		}
+		return n.deliver(ctx, notification)
+	})
+	if err != nil {
+		trace.SpanFromContext(ctx).AddEvent("notify.dropped", trace.WithAttributes(
+			attribute.String("notify.event", notification.Event),
+			attribute.String("error.message", err.Error()),
+		))
+		deliveries.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("notify.event", notification.Event),
+			attribute.String("notify.outcome", OutcomeDropped),
+		))
+	}
+}
+
+// Close waits for queued deliveries. Each is bounded by its timeout, and
+// the whole drain by closeTimeout.
+func (n *Notifier) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := n.pool.Drain(ctx); err != nil {
+		log.Printf("Error draining notification queue: %v", err)
+	}
+}
+
+// closeTimeout bounds how long Close waits for queued deliveries.
+const closeTimeout = 2 * deliveryTimeout
+
+// deliver posts one notification in a notify.deliver span. The HTTP
+// request is a client span beneath it.
+func (n *Notifier) deliver(ctx context.Context, notification Notification) error {
+	ctx, span := n.tracer.Start(ctx, "notify.deliver",
+		trace.WithAttributes(
+			attrs.HTTPURL.String(n.cfg.URL),
+			attrs.PeerService.String(peerService),
+			attribute.String("notify.event", notification.Event),
+			attrs.SubscriberID(notification.SubscriberID),
+		),
+	)
+	defer span.End()
+
+	outcome, err := n.post(ctx, span, notification)
+	if err != nil {
+		telemetry.FailSpan(span, err, "Notification not delivered")
+	}
+	span.SetAttributes(attribute.String("notify.outcome", outcome))
+	deliveries.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("notify.event", notification.Event),
+		attribute.String("notify.outcome", outcome),
+	))
+	return err
+}
+
+func (n *Notifier) post(ctx context.Context, span trace.Span, notification Notification) (string, error) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.cfg.Secret) > 0 {
+		webhooksig.SignRequest(req, n.cfg.Secret, body)
+	}
+	span.SetAttributes(attribute.Bool("webhook.signed", len(n.cfg.Secret) > 0))
+	// A delivery that can't say who caused it is still worth sending
+	// anonymous, so the failure is recorded rather than returned
+	if err := identity.Propagate(ctx, req, n.cfg.Identity); err != nil {
+		span.SetAttributes(attribute.String("identity.error", err.Error()))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return OutcomeFailed, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	span.SetAttributes(attrs.HTTPStatusCode.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		return OutcomeRejected, fmt.Errorf("%s returned %s", peerService, resp.Status)
+	}
+	return OutcomeDelivered, nil
+}
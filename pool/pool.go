@@ -0,0 +1,273 @@
+// Package pool provides a bounded, instrumented worker pool for background
+// work so features don't each roll their own goroutines.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+)
+
+var (
+	ErrClosed    = errors.New("pool is closed")
+	ErrQueueFull = errors.New("pool queue is full")
+)
+
+// Pool instruments, exported through the global MeterProvider. Every
+// measurement carries pool.name.
+var (
+	poolMeter      = telemetry.Meter("telemetry-demo/pool")
+	poolQueueDepth = telemetry.Int64ObservableGauge(poolMeter, "pool.queue.depth", "{task}", "Tasks waiting for a worker")
+	poolBusy       = telemetry.Int64ObservableGauge(poolMeter, "pool.workers.busy", "{worker}", "Workers running a task")
+	poolWorkers    = telemetry.Int64ObservableGauge(poolMeter, "pool.workers", "{worker}", "Workers the pool runs; busy over this is its utilization")
+	poolRejected   = telemetry.Int64Counter(poolMeter, "pool.tasks.rejected", "{task}", "Tasks the pool refused, by pool.rejection_reason")
+)
+
+// Why a task was refused, the pool.rejection_reason attribute.
+const (
+	rejectedClosed    = "closed"
+	rejectedQueueFull = "queue_full"
+	rejectedCanceled  = "canceled"
+)
+
+// Task is a unit of work run by the pool. The context carries the task span
+// but is detached from the submitter's cancellation.
+type Task func(ctx context.Context) error
+
+// Stats is a point-in-time snapshot of pool activity.
+type Stats struct {
+	Name          string  `json:"name"`
+	Workers       int     `json:"workers"`
+	Busy          int64   `json:"busy"`
+	Utilization   float64 `json:"utilization"`
+	QueueDepth    int     `json:"queue_depth"`
+	QueueCapacity int     `json:"queue_capacity"`
+	Completed     int64   `json:"completed"`
+	Failed        int64   `json:"failed"`
+	Rejected      int64   `json:"rejected"`
+}
+
+type job struct {
+	name string
+	task Task
+	link trace.Link
+	// bag is the submitter's baggage, so the task's spans are attributed
+	// to the same tenant
+	bag      baggage.Baggage
+	enqueued time.Time
+}
+
+type Pool struct {
+	name    string
+	workers int
+	jobs    chan job
+	tracer  trace.Tracer
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	metrics metric.MeasurementOption
+	gauge   metric.Registration
+
+	busy      atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+	rejected  atomic.Int64
+}
+
+// New starts a pool with a fixed number of workers and a bounded queue.
+func New(name string, workers, queueSize int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{
+		name:    name,
+		workers: workers,
+		jobs:    make(chan job, queueSize),
+		tracer:  otel.Tracer("telemetry-demo/pool"),
+		metrics: metric.WithAttributes(attribute.String("pool.name", name)),
+	}
+
+	gauge, err := poolMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := p.Stats()
+		o.ObserveInt64(poolQueueDepth, int64(stats.QueueDepth), p.metrics)
+		o.ObserveInt64(poolBusy, stats.Busy, p.metrics)
+		o.ObserveInt64(poolWorkers, int64(stats.Workers), p.metrics)
+		return nil
+	}, poolQueueDepth, poolBusy, poolWorkers)
+	if err != nil {
+		log.Printf("Failed to observe pool %s: %v", name, err)
+	}
+	p.gauge = gauge
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		telemetry.Go(context.Background(), "pool."+p.name, func(context.Context) { p.work() })
+	}
+
+	return p
+}
+
+// Submit queues a task, blocking while the queue is full until ctx is done.
+func (p *Pool) Submit(ctx context.Context, name string, task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.reject(ctx, rejectedClosed)
+		return ErrClosed
+	}
+
+	select {
+	case p.jobs <- p.newJob(ctx, name, task):
+		return nil
+	case <-ctx.Done():
+		p.reject(ctx, rejectedCanceled)
+		return ctx.Err()
+	}
+}
+
+// TrySubmit queues a task without blocking, returning ErrQueueFull when the
+// pool can't accept more work.
+func (p *Pool) TrySubmit(ctx context.Context, name string, task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.reject(ctx, rejectedClosed)
+		return ErrClosed
+	}
+
+	select {
+	case p.jobs <- p.newJob(ctx, name, task):
+		return nil
+	default:
+		p.reject(ctx, rejectedQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// Drain stops accepting new tasks and waits for queued and running tasks to
+// finish, or for ctx to be done.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+		if p.gauge != nil {
+			p.gauge.Unregister()
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("draining pool %s: %w", p.name, ctx.Err())
+	}
+}
+
+// Stats reports the pool's current load; the pool.* gauges are observed
+// from it.
+func (p *Pool) Stats() Stats {
+	busy := p.busy.Load()
+	return Stats{
+		Name:          p.name,
+		Workers:       p.workers,
+		Busy:          busy,
+		Utilization:   float64(busy) / float64(p.workers),
+		QueueDepth:    len(p.jobs),
+		QueueCapacity: cap(p.jobs),
+		Completed:     p.completed.Load(),
+		Failed:        p.failed.Load(),
+		Rejected:      p.rejected.Load(),
+	}
+}
+
+func (p *Pool) reject(ctx context.Context, reason string) {
+	p.rejected.Add(1)
+	poolRejected.Add(ctx, 1, p.metrics, metric.WithAttributes(attribute.String("pool.rejection_reason", reason)))
+}
+
+func (p *Pool) newJob(ctx context.Context, name string, task Task) job {
+	return job{
+		name:     name,
+		task:     task,
+		link:     trace.LinkFromContext(ctx),
+		bag:      baggage.FromContext(ctx),
+		enqueued: time.Now(),
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for j := range p.jobs {
+		p.run(j)
+	}
+}
+
+// run executes a task in its own root span linked back to the submitter, so
+// background work doesn't stretch the originating request's trace. The
+// submitter's baggage carries over.
+func (p *Pool) run(j job) {
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+
+	ctx, span := p.tracer.Start(baggage.ContextWithBaggage(context.Background(), j.bag), "pool.task "+j.name,
+		trace.WithNewRoot(),
+		trace.WithLinks(j.link),
+		trace.WithAttributes(
+			attribute.String("pool.name", p.name),
+			attribute.String("pool.task", j.name),
+			attribute.Int64("pool.queue_wait_ms", time.Since(j.enqueued).Milliseconds()),
+			attribute.Int("pool.queue_depth", len(p.jobs)),
+			attribute.Int64("pool.busy_workers", p.busy.Load()),
+		),
+	)
+	defer span.End()
+
+	err := p.execute(ctx, j.task)
+	if err != nil {
+		p.failed.Add(1)
+		telemetry.FailSpan(span, err, "")
+		return
+	}
+
+	p.completed.Add(1)
+}
+
+// execute runs the task, converting a panic into an error so one bad task
+// doesn't take down a worker.
+func (p *Pool) execute(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+
+	return task(ctx)
+}
@@ -0,0 +1,362 @@
+// Package probe runs synthetic canary traffic against the server's own
+// API: every interval it creates, reads, updates, and deletes a subscriber
+// through V2, as a client would. Its requests carry synthetic=true baggage,
+// so their spans, logs, and HTTP metrics can be told apart from real
+// traffic, and the probe's own metrics are availability and latency SLIs
+// that don't depend on anyone using the API.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Probe steps, the probe.step attribute.
+const (
+	StepCreate = "create"
+	StepRead   = "read"
+	StepUpdate = "update"
+	StepDelete = "delete"
+	// StepCreatePoll polls the job of an async create. Its time counts
+	// toward the create step.
+	StepCreatePoll = "create.poll"
+)
+
+// Step outcomes, the probe.outcome attribute.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// userAgent identifies probe requests in access logs.
+const userAgent = "telemetry-demo-probe/1.0"
+
+// maxRetainedRuns bounds the runs Report returns and computes
+// availability over.
+const maxRetainedRuns = 50
+
+// Async writes are polled this often, this many times, before the create
+// step gives up.
+const (
+	jobPollInterval = 50 * time.Millisecond
+	jobPollAttempts = 40
+)
+
+var (
+	probeMeter  = telemetry.Meter("telemetry-demo/probe")
+	probeChecks = telemetry.Int64Counter(probeMeter, "probe.checks", "{check}",
+		"Synthetic probe steps run against the API, by probe.step and probe.outcome")
+	probeRuns = telemetry.Int64Counter(probeMeter, "probe.runs", "{run}",
+		"Synthetic probe runs, by probe.outcome; success when every step succeeded")
+	probeDuration = telemetry.Float64Histogram(probeMeter, "probe.duration", "ms",
+		"Synthetic probe step latency seen by the client, by probe.step")
+)
+
+// StepResult is how one step of a run went.
+type StepResult struct {
+	Step       string  `json:"step"`
+	Status     int     `json:"status,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Run is one pass through the steps. A failed step ends the run, except
+// that the subscriber it created is still deleted.
+type Run struct {
+	TraceID    string       `json:"trace_id"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMs float64      `json:"duration_ms"`
+	Success    bool         `json:"success"`
+	Steps      []StepResult `json:"steps"`
+}
+
+// Report summarizes the retained runs, newest first.
+type Report struct {
+	IntervalMs int64 `json:"interval_ms"`
+	// Availability is the share of retained runs that succeeded.
+	Availability float64 `json:"availability"`
+	Runs         []Run   `json:"runs"`
+}
+
+// Prober runs the canary steps on a schedule.
+type Prober struct {
+	baseURL  string
+	basePath string
+	interval time.Duration
+	client   *http.Client
+	tracer   trace.Tracer
+
+	mu   sync.Mutex
+	runs []Run
+	next int
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New probes the API at baseURL (e.g. http://localhost:8080) under
+// basePath every interval until Stop is called. The first run waits one
+// interval, so the server is listening by then.
+func New(baseURL, basePath string, interval time.Duration) *Prober {
+	p := &Prober{
+		baseURL:  baseURL,
+		basePath: basePath,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		tracer:   otel.Tracer("telemetry-demo/probe"),
+		stop:     make(chan struct{}),
+	}
+	telemetry.Go(context.Background(), "probe.loop", func(context.Context) { p.loop() })
+	return p
+}
+
+func (p *Prober) Stop() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+func (p *Prober) loop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.Run(context.Background())
+		}
+	}
+}
+
+// Report returns the retained runs and their availability.
+func (p *Prober) Report() Report {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	report := Report{IntervalMs: p.interval.Milliseconds(), Runs: make([]Run, 0, len(p.runs))}
+	succeeded := 0
+	for i := range p.runs {
+		// Walk back from the newest run
+		run := p.runs[(p.next-1-i+len(p.runs))%len(p.runs)]
+		if run.Success {
+			succeeded++
+		}
+		report.Runs = append(report.Runs, run)
+	}
+	if len(p.runs) > 0 {
+		report.Availability = float64(succeeded) / float64(len(p.runs))
+	}
+	return report
+}
+
+// Run probes the API once. Each run is one trace, marked synthetic, with a
+// client span per step whose context reaches the server.
+func (p *Prober) Run(ctx context.Context) Run {
+	if member, err := baggage.NewMember(string(attrs.Synthetic), "true"); err == nil {
+		if bag, err := baggage.FromContext(ctx).SetMember(member); err == nil {
+			ctx = baggage.ContextWithBaggage(ctx, bag)
+		}
+	}
+	start := time.Now()
+	ctx, span := p.tracer.Start(ctx, "probe.run", trace.WithNewRoot())
+	defer span.End()
+
+	run := Run{TraceID: span.SpanContext().TraceID().String(), StartedAt: start, Success: true}
+	record := func(result StepResult) bool {
+		run.Steps = append(run.Steps, result)
+		if result.Error != "" {
+			run.Success = false
+		}
+		return result.Error == ""
+	}
+
+	id, created := p.create(ctx)
+	if record(created) {
+		path := p.basePath + "/v2/subscribers/" + strconv.Itoa(id)
+		body := map[string]string{"name": "Probe Updated", "email": probeEmail(start)}
+		if record(p.step(ctx, StepRead, http.MethodGet, path, nil, http.StatusOK, nil)) {
+			record(p.step(ctx, StepUpdate, http.MethodPut, path, body, http.StatusOK, nil))
+		}
+		// Clean up whatever happened to the read and update
+		record(p.step(ctx, StepDelete, http.MethodDelete, path, nil, http.StatusNoContent, nil))
+	}
+
+	run.DurationMs = telemetry.Milliseconds(time.Since(start))
+	outcome := OutcomeSuccess
+	if !run.Success {
+		outcome = OutcomeFailure
+		span.SetStatus(codes.Error, "Probe failed")
+	}
+	span.SetAttributes(
+		attribute.String("probe.outcome", outcome),
+		attribute.Int("probe.steps", len(run.Steps)),
+	)
+	probeRuns.Add(ctx, 1, metric.WithAttributes(attribute.String("probe.outcome", outcome)))
+	for _, step := range run.Steps {
+		if step.Error != "" {
+			log.Printf("⚠️  Probe %s step failed: %s (trace %s)", step.Step, step.Error, run.TraceID)
+			break
+		}
+	}
+
+	p.mu.Lock()
+	if len(p.runs) < maxRetainedRuns {
+		p.runs = append(p.runs, run)
+	} else {
+		p.runs[p.next] = run
+	}
+	p.next = (p.next + 1) % maxRetainedRuns
+	p.mu.Unlock()
+	return run
+}
+
+// create stores a subscriber and returns its ID. In async write mode the
+// API answers 202, and the step includes polling the job until it is done.
+func (p *Prober) create(ctx context.Context) (int, StepResult) {
+	body := map[string]string{"name": "Probe", "email": probeEmail(time.Now())}
+	var created struct {
+		ID int `json:"id"`
+	}
+	var location string
+	start := time.Now()
+	result := p.step(ctx, StepCreate, http.MethodPost, p.basePath+"/v2/subscribers", body, http.StatusCreated, func(resp *http.Response, payload []byte) error {
+		if resp.StatusCode == http.StatusAccepted {
+			location = resp.Header.Get("Location")
+			return nil
+		}
+		return json.Unmarshal(payload, &created)
+	}, http.StatusAccepted)
+	if result.Error != "" || location == "" {
+		return created.ID, result
+	}
+
+	// The write was queued: wait for the job within the same step
+	for attempt := 0; attempt < jobPollAttempts; attempt++ {
+		var job struct {
+			Status       string `json:"status"`
+			SubscriberID int    `json:"subscriber_id"`
+			Error        string `json:"error"`
+		}
+		polled := p.step(ctx, StepCreatePoll, http.MethodGet, location, nil, http.StatusOK, func(_ *http.Response, payload []byte) error {
+			return json.Unmarshal(payload, &job)
+		})
+		if polled.Error != "" {
+			result.Error = polled.Error
+			break
+		}
+		if job.Status == "succeeded" {
+			created.ID = job.SubscriberID
+			break
+		}
+		if job.Status == "failed" {
+			result.Error = "job failed: " + job.Error
+			break
+		}
+		time.Sleep(jobPollInterval)
+		if attempt == jobPollAttempts-1 {
+			result.Error = "job still " + job.Status
+		}
+	}
+	result.DurationMs = telemetry.Milliseconds(time.Since(start))
+	return created.ID, result
+}
+
+// step sends one request in a client span and checks its status. decode,
+// when set, reads the response body of a successful step.
+func (p *Prober) step(ctx context.Context, name, method, path string, body any, want int, decode func(*http.Response, []byte) error, alsoOK ...int) StepResult {
+	url := p.baseURL + path
+	ctx, span := p.tracer.Start(ctx, "probe."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("probe.step", name),
+			attrs.HTTPMethod.String(method),
+			attrs.HTTPURL.String(url),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	result := StepResult{Step: name}
+	err := func() error {
+		var reader io.Reader
+		if body != nil {
+			encoded, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			reader = bytes.NewReader(encoded)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		result.Status = resp.StatusCode
+		span.SetAttributes(attrs.HTTPStatusCode.Int(resp.StatusCode))
+
+		payload, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		ok := resp.StatusCode == want
+		for _, status := range alsoOK {
+			ok = ok || resp.StatusCode == status
+		}
+		if !ok {
+			return fmt.Errorf("%s %s answered %d, want %d", method, path, resp.StatusCode, want)
+		}
+		if decode != nil {
+			return decode(resp, payload)
+		}
+		return nil
+	}()
+	elapsed := time.Since(start)
+	result.DurationMs = telemetry.Milliseconds(elapsed)
+
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeFailure
+		result.Error = err.Error()
+		telemetry.FailSpan(span, err, "Probe step failed")
+	}
+	span.SetAttributes(attribute.String("probe.outcome", outcome))
+	probeChecks.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("probe.step", name),
+		attribute.String("probe.outcome", outcome),
+	))
+	probeDuration.Record(ctx, telemetry.Milliseconds(elapsed), metric.WithAttributes(attribute.String("probe.step", name)))
+	return result
+}
+
+// probeEmail is unique per run, so probes never collide with each other.
+func probeEmail(at time.Time) string {
+	return fmt.Sprintf("probe-%d@probe.telemetry-demo.invalid", at.UnixNano())
+}
@@ -0,0 +1,209 @@
+// Package problem is the API's error code catalog. Every failure the V2
+// API, the admin routes and the shared middleware return carries a stable
+// code from the catalog, both in an RFC 7807 body (application/problem+json)
+// and as the error.code attribute on the request's span, so clients and
+// alerts can key off the code instead of parsing messages.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/i18n"
+)
+
+// ContentType is the media type of problem details bodies.
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine-readable error code.
+type Code string
+
+const (
+	SubscriberNotFound Code = "SUBSCRIBER_NOT_FOUND"
+	JobNotFound        Code = "JOB_NOT_FOUND"
+	TraceNotFound      Code = "TRACE_NOT_FOUND"
+	IncidentNotFound   Code = "INCIDENT_NOT_FOUND"
+	AnnotationNotFound Code = "ANNOTATION_NOT_FOUND"
+	// DuplicateEmail is reserved: the in-memory store does not enforce
+	// unique emails yet, so nothing returns it.
+	DuplicateEmail   Code = "DUPLICATE_EMAIL"
+	CacheDisabled    Code = "CACHE_DISABLED"
+	InvalidID        Code = "INVALID_ID"
+	ValidationFailed Code = "VALIDATION_FAILED"
+	UnknownField     Code = "UNKNOWN_FIELD"
+	Unauthenticated  Code = "UNAUTHENTICATED"
+	// RateLimited is reserved for a per-key rate limiter: the API sheds
+	// load with ServiceOverloaded but limits no caller yet, so nothing
+	// returns it.
+	RateLimited           Code = "RATE_LIMITED"
+	ServiceOverloaded     Code = "SERVICE_OVERLOADED"
+	ReadOnlyMode          Code = "READ_ONLY_MODE"
+	BudgetExhausted       Code = "LATENCY_BUDGET_EXHAUSTED"
+	IdentityProviderError Code = "IDENTITY_PROVIDER_ERROR"
+	InternalError         Code = "INTERNAL_ERROR"
+)
+
+// Entry describes one code: the status it is returned with and the title
+// every problem with that code shares.
+type Entry struct {
+	Code   Code   `json:"code"`
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+}
+
+var catalog = map[Code]Entry{
+	SubscriberNotFound:    entry(SubscriberNotFound, http.StatusNotFound, "Subscriber not found"),
+	JobNotFound:           entry(JobNotFound, http.StatusNotFound, "Job not found"),
+	TraceNotFound:         entry(TraceNotFound, http.StatusNotFound, "Trace not found"),
+	IncidentNotFound:      entry(IncidentNotFound, http.StatusNotFound, "Incident not found"),
+	AnnotationNotFound:    entry(AnnotationNotFound, http.StatusNotFound, "Annotation not found"),
+	DuplicateEmail:        entry(DuplicateEmail, http.StatusConflict, "Email is already subscribed"),
+	CacheDisabled:         entry(CacheDisabled, http.StatusConflict, "Response cache is disabled"),
+	InvalidID:             entry(InvalidID, http.StatusBadRequest, "Invalid subscriber ID"),
+	ValidationFailed:      entry(ValidationFailed, http.StatusBadRequest, "Request validation failed"),
+	UnknownField:          entry(UnknownField, http.StatusBadRequest, "Unknown field requested"),
+	Unauthenticated:       entry(Unauthenticated, http.StatusUnauthorized, "Login required"),
+	RateLimited:           entry(RateLimited, http.StatusTooManyRequests, "Too many requests"),
+	ServiceOverloaded:     entry(ServiceOverloaded, http.StatusServiceUnavailable, "Server overloaded, retry later"),
+	ReadOnlyMode:          entry(ReadOnlyMode, http.StatusServiceUnavailable, "API is in read-only mode"),
+	BudgetExhausted:       entry(BudgetExhausted, http.StatusGatewayTimeout, "Latency budget exhausted"),
+	IdentityProviderError: entry(IdentityProviderError, http.StatusBadGateway, "Identity provider request failed"),
+	InternalError:         entry(InternalError, http.StatusInternalServerError, "Internal server error"),
+}
+
+func entry(code Code, status int, title string) Entry {
+	return Entry{Code: code, Type: TypeURI(code), Status: status, Title: title}
+}
+
+// TypeURI is the problem type for code, e.g.
+// urn:telemetry-demo:problem:subscriber-not-found. A URN keeps the type
+// the same whatever base path or host the API is served from.
+func TypeURI(code Code) string {
+	return "urn:telemetry-demo:problem:" + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+}
+
+// Lookup returns the catalog entry for code. Unknown codes report
+// InternalError's entry.
+func Lookup(code Code) (Entry, bool) {
+	e, ok := catalog[code]
+	if !ok {
+		return catalog[InternalError], false
+	}
+	return e, true
+}
+
+// Catalog returns every entry, in code order.
+func Catalog() []Entry {
+	entries := make([]Entry, 0, len(catalog))
+	for _, e := range catalog {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// Details is an RFC 7807 problem details body. Extensions are extra
+// members written alongside the standard ones, e.g. retry_after.
+//
+// Title and Detail stay in English until Prepare translates them into the
+// request's locale.
+type Details struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       Code
+	TraceID    string
+	Extensions map[string]any
+
+	detailArgs []any
+}
+
+// New returns the problem for code with its catalog type, title and status.
+// detail explains this occurrence and may be empty.
+func New(code Code, detail string) *Details {
+	return Newf(code, detail)
+}
+
+// Newf is New with a formatted detail. format, not the formatted result,
+// is what gets translated, so keep variable parts in args.
+func Newf(code Code, format string, args ...any) *Details {
+	e, _ := Lookup(code)
+	return &Details{
+		Type:       e.Type,
+		Title:      e.Title,
+		Status:     e.Status,
+		Detail:     format,
+		Code:       e.Code,
+		detailArgs: args,
+	}
+}
+
+// With adds an extension member and returns p.
+func (p *Details) With(key string, value any) *Details {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Details) MarshalJSON() ([]byte, error) {
+	body := make(map[string]any, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		body[k] = v
+	}
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	if p.TraceID != "" {
+		body["trace_id"] = p.TraceID
+	}
+	return json.Marshal(body)
+}
+
+// Prepare translates the title and detail into the request's locale and
+// fills in the request path as the instance and span's trace ID, when they
+// are not already set.
+func (p *Details) Prepare(c *gin.Context, span trace.Span) *Details {
+	locale := i18n.LocaleFromContext(c.Request.Context())
+	p.Title = i18n.T(locale, p.Title)
+	if p.Detail != "" {
+		p.Detail = i18n.Sprintf(locale, p.Detail, p.detailArgs...)
+		p.detailArgs = nil
+	}
+	c.Header("Content-Language", locale)
+
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.TraceID == "" && span.SpanContext().HasTraceID() {
+		p.TraceID = span.SpanContext().TraceID().String()
+	}
+	return p
+}
+
+// Abort stops the request with p. span is the span that recorded the
+// failure, for the body's trace_id.
+func Abort(c *gin.Context, span trace.Span, p *Details) {
+	body, err := json.Marshal(p.Prepare(c, span))
+	if err != nil {
+		c.AbortWithStatus(p.Status)
+		return
+	}
+	c.Abort()
+	c.Data(p.Status, ContentType, body)
+}
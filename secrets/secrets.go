@@ -0,0 +1,185 @@
+// Package secrets resolves sensitive settings at startup from a Dapr secret
+// store, falling back to environment variables. Lookups are traced by
+// secret name and source only; values never reach spans or logs.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// ErrNotFound means no source has the secret.
+var ErrNotFound = errors.New("secret not found")
+
+// Source is one place secrets can come from.
+type Source interface {
+	// Name identifies the source in spans, e.g. "env" or "dapr:vault".
+	Name() string
+	// Lookup returns the secret's value, or ok=false when the source
+	// doesn't have it.
+	Lookup(ctx context.Context, name string) (value string, ok bool, err error)
+}
+
+// Env reads secrets from environment variables of the same name.
+type Env struct{}
+
+func (Env) Name() string { return "env" }
+
+func (Env) Lookup(_ context.Context, name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	return value, ok && value != "", nil
+}
+
+// maxSecretBytes bounds a secret store response.
+const maxSecretBytes = 64 << 10
+
+// daprPeerService is the peer.service of calls to the Dapr sidecar.
+const daprPeerService = "dapr-sidecar"
+
+// Dapr reads secrets from a secret store component through the local Dapr
+// sidecar's HTTP API.
+type Dapr struct {
+	store   string
+	baseURL string
+	client  *http.Client
+	tracer  trace.Tracer
+}
+
+// NewDapr reads from the secret store component named store through the
+// sidecar listening on port.
+func NewDapr(store string, port int) *Dapr {
+	return &Dapr{
+		store:   store,
+		baseURL: fmt.Sprintf("http://localhost:%d/v1.0/secrets/", port),
+		client:  &http.Client{Timeout: 5 * time.Second},
+		tracer:  otel.Tracer("telemetry-demo/secrets"),
+	}
+}
+
+func (d *Dapr) Name() string { return "dapr:" + d.store }
+
+// Lookup asks the sidecar for one secret in a client span. The sidecar
+// always takes W3C trace context, so it is injected whatever the global
+// propagator.
+func (d *Dapr) Lookup(ctx context.Context, name string) (string, bool, error) {
+	target := d.baseURL + url.PathEscape(d.store) + "/" + url.PathEscape(name)
+
+	ctx, span := d.tracer.Start(ctx, "dapr.get_secret",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attrs.HTTPMethod.String(http.MethodGet),
+			attrs.HTTPURL.String(target),
+			attrs.PeerService.String(daprPeerService),
+			attribute.String("secret.store", d.store),
+			attribute.String("secret.name", name),
+		),
+	)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return "", false, err
+	}
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attrs.HTTPStatusCode.Int(resp.StatusCode))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent:
+		return "", false, nil
+	case resp.StatusCode >= http.StatusBadRequest:
+		err := fmt.Errorf("%s returned %s", daprPeerService, resp.Status)
+		telemetry.FailSpan(span, err, "")
+		return "", false, err
+	}
+
+	// The sidecar answers with {name: value}
+	var values map[string]string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSecretBytes)).Decode(&values); err != nil {
+		telemetry.FailSpan(span, err, "Invalid secret store response")
+		return "", false, err
+	}
+	value, ok := values[name]
+	return value, ok && value != "", nil
+}
+
+// Resolver looks secrets up in its sources in order.
+type Resolver struct {
+	sources []Source
+	tracer  trace.Tracer
+}
+
+func NewResolver(sources ...Source) *Resolver {
+	return &Resolver{
+		sources: sources,
+		tracer:  otel.Tracer("telemetry-demo/secrets"),
+	}
+}
+
+// Get returns the first value any source has for name. A source that
+// fails is skipped, so a missing sidecar falls back to the environment;
+// the failure stays on the secrets.get span. The span records which source
+// answered, never the value.
+func (r *Resolver) Get(ctx context.Context, name string) (string, error) {
+	ctx, span := r.tracer.Start(ctx, "secrets.get", trace.WithAttributes(
+		attribute.String("secret.name", name),
+	))
+	defer span.End()
+
+	var errs []error
+	for _, source := range r.sources {
+		value, ok, err := source.Lookup(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			span.AddEvent("secret_source_failed", trace.WithAttributes(
+				attribute.String("secret.source", source.Name()),
+				attribute.String("error.message", err.Error()),
+			))
+			continue
+		}
+		if ok {
+			span.SetAttributes(
+				attribute.String("secret.source", source.Name()),
+				attribute.Bool("secret.found", true),
+			)
+			return value, nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("secret.found", false))
+	if len(errs) > 0 {
+		return "", fmt.Errorf("secret %s: %w", name, errors.Join(append([]error{ErrNotFound}, errs...)...))
+	}
+	return "", fmt.Errorf("secret %s: %w", name, ErrNotFound)
+}
+
+// Names lists the sources in lookup order, for logs.
+func (r *Resolver) Names() string {
+	names := make([]string, len(r.sources))
+	for i, source := range r.sources {
+		names[i] = source.Name()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,285 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// vaultPeerService is the peer.service of calls to Vault.
+const vaultPeerService = "vault"
+
+// minRenewRetry is the shortest wait between token renewal attempts, so a
+// token about to expire doesn't spin the renewal loop.
+const minRenewRetry = 5 * time.Second
+
+var (
+	secretsMeter  = telemetry.Meter("telemetry-demo/secrets")
+	vaultRenewals = telemetry.Int64Counter(secretsMeter, "vault.token.renewals", "{renewal}", "Vault token renewal attempts by outcome")
+	vaultTokenTTL = telemetry.Int64ObservableGauge(secretsMeter, "vault.token.ttl", "s", "Seconds until the Vault token expires")
+)
+
+// VaultConfig points the Vault source at a KV version 2 secret.
+type VaultConfig struct {
+	// Address is Vault's base URL, e.g. http://localhost:8200.
+	Address string
+	// Token authenticates every request and is renewed in the background
+	// while it is renewable.
+	Token string
+	// Mount is the KV v2 secrets engine mount, usually "secret".
+	Mount string
+	// Path is the secret under Mount whose keys are the secret names.
+	Path string
+}
+
+// VaultStatus describes the Vault token, for health checks.
+type VaultStatus struct {
+	Healthy     bool      `json:"healthy"`
+	Renewable   bool      `json:"renewable"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+	TTLSeconds  int64     `json:"ttl_seconds"`
+	Renewals    int       `json:"renewals"`
+	LastRenewal time.Time `json:"last_renewal,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Vault reads secrets from one KV v2 secret in HashiCorp Vault. A
+// background goroutine renews its token at half its TTL until Close. Only
+// the token has a lease: KV secrets are read once at startup and don't
+// expire.
+type Vault struct {
+	config VaultConfig
+	client *http.Client
+	tracer trace.Tracer
+	gauge  metric.Registration
+	stop   chan struct{}
+	once   sync.Once
+
+	mu     sync.RWMutex
+	status VaultStatus
+}
+
+// NewVault looks up the token's lease, failing if Vault rejects it, and
+// starts renewing it when it is renewable.
+func NewVault(ctx context.Context, config VaultConfig) (*Vault, error) {
+	v := &Vault{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Second},
+		tracer: otel.Tracer("telemetry-demo/secrets"),
+		stop:   make(chan struct{}),
+	}
+	v.config.Address = strings.TrimRight(config.Address, "/")
+
+	var lookup struct {
+		Data struct {
+			TTL       int64 `json:"ttl"`
+			Renewable bool  `json:"renewable"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, "vault.token_lookup", http.MethodGet, "/v1/auth/token/lookup-self", &lookup); err != nil {
+		return nil, fmt.Errorf("vault token lookup: %w", err)
+	}
+	v.setLease(lookup.Data.TTL, lookup.Data.Renewable)
+
+	gauge, err := secretsMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(vaultTokenTTL, v.Status().TTLSeconds)
+		return nil
+	}, vaultTokenTTL)
+	if err != nil {
+		log.Printf("Failed to observe Vault token TTL: %v", err)
+	}
+	v.gauge = gauge
+
+	if lookup.Data.Renewable {
+		telemetry.Go(context.Background(), "vault.token_renewal", func(context.Context) { v.renewLoop() })
+	}
+	return v, nil
+}
+
+func (v *Vault) Name() string { return "vault:" + v.config.Mount + "/" + v.config.Path }
+
+// Lookup reads the configured secret and returns its name key.
+func (v *Vault) Lookup(ctx context.Context, name string) (string, bool, error) {
+	var secret struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	path := "/v1/" + v.config.Mount + "/data/" + v.config.Path
+	if err := v.call(ctx, "vault.read_secret", http.MethodGet, path, &secret, attribute.String("secret.name", name)); err != nil {
+		if errors.Is(err, errVaultNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	value, ok := secret.Data.Data[name]
+	return value, ok && value != "", nil
+}
+
+// Status reports the token's lease and renewal history.
+func (v *Vault) Status() VaultStatus {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	status := v.status
+	if !status.ExpiresAt.IsZero() {
+		status.TTLSeconds = int64(time.Until(status.ExpiresAt).Seconds())
+		if status.TTLSeconds < 0 {
+			status.TTLSeconds = 0
+		}
+		status.Healthy = status.TTLSeconds > 0
+	} else {
+		// Tokens without a TTL, such as root tokens, never expire
+		status.TTLSeconds = 0
+		status.Healthy = true
+	}
+	return status
+}
+
+// Close stops renewing the token. The token is left to expire on its own.
+func (v *Vault) Close() {
+	v.once.Do(func() {
+		close(v.stop)
+		if v.gauge != nil {
+			v.gauge.Unregister()
+		}
+	})
+}
+
+func (v *Vault) setLease(ttlSeconds int64, renewable bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.status.Renewable = renewable
+	v.status.ExpiresAt = time.Time{}
+	if ttlSeconds > 0 {
+		v.status.ExpiresAt = time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	}
+}
+
+// renewLoop renews the token whenever half its remaining TTL has passed,
+// retrying failures until the token expires or is no longer renewable.
+func (v *Vault) renewLoop() {
+	for {
+		status := v.Status()
+		if !status.Renewable || status.ExpiresAt.IsZero() {
+			return
+		}
+		wait := time.Until(status.ExpiresAt) / 2
+		if wait < minRenewRetry {
+			wait = minRenewRetry
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-v.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := v.renew(); err != nil {
+			log.Printf("Vault token renewal failed: %v", err)
+			if v.Status().TTLSeconds == 0 {
+				log.Printf("Vault token expired; secrets can no longer be read from Vault")
+				return
+			}
+		}
+	}
+}
+
+// renew extends the token's lease in its own trace.
+func (v *Vault) renew() error {
+	var renewed struct {
+		Auth struct {
+			LeaseDuration int64 `json:"lease_duration"`
+			Renewable     bool  `json:"renewable"`
+		} `json:"auth"`
+	}
+	err := v.call(context.Background(), "vault.token_renewal", http.MethodPost, "/v1/auth/token/renew-self", &renewed)
+
+	outcome := "success"
+	v.mu.Lock()
+	if err != nil {
+		outcome = "failure"
+		v.status.LastError = err.Error()
+	} else {
+		v.status.Renewals++
+		v.status.LastRenewal = time.Now()
+		v.status.LastError = ""
+	}
+	v.mu.Unlock()
+	vaultRenewals.Add(context.Background(), 1, metric.WithAttributes(attribute.String("vault.renewal.outcome", outcome)))
+
+	if err != nil {
+		return err
+	}
+	v.setLease(renewed.Auth.LeaseDuration, renewed.Auth.Renewable)
+	return nil
+}
+
+var errVaultNotFound = errors.New("not found in vault")
+
+// call sends one request to Vault in a client span and decodes the JSON
+// response into out. The token is sent as a header and never recorded.
+func (v *Vault) call(ctx context.Context, spanName, method, path string, out any, extra ...attribute.KeyValue) error {
+	target := v.config.Address + path
+	ctx, span := v.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append([]attribute.KeyValue{
+			attrs.HTTPMethod.String(method),
+			attrs.HTTPURL.String(target),
+			attrs.PeerService.String(vaultPeerService),
+		}, extra...)...),
+	)
+	defer span.End()
+
+	var body io.Reader
+	if method == http.MethodPost {
+		body = bytes.NewReader([]byte("{}"))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.config.Token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(attrs.HTTPStatusCode.Int(resp.StatusCode))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errVaultNotFound
+	case resp.StatusCode >= http.StatusBadRequest:
+		err := fmt.Errorf("%s returned %s", vaultPeerService, resp.Status)
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSecretBytes)).Decode(out); err != nil {
+		telemetry.FailSpan(span, err, "Invalid Vault response")
+		return err
+	}
+	return nil
+}
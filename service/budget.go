@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+
+	"telemetry-demo/budget"
+	"telemetry-demo/models"
+)
+
+// Budgeted checks the request's latency budget before every call and
+// refuses the call with an error wrapping budget.ErrExhausted once it has
+// run out. Placed after Traced, it records the budget left on the business
+// span.
+func Budgeted() Decorator {
+	return func(next SubscriberService) SubscriberService {
+		return &budgeted{next: next}
+	}
+}
+
+type budgeted struct {
+	next SubscriberService
+}
+
+func (b *budgeted) Validate(ctx context.Context, name, email string) error {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return err
+	}
+	return b.next.Validate(ctx, name, email)
+}
+
+func (b *budgeted) Create(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, err
+	}
+	return b.next.Create(ctx, name, email)
+}
+
+func (b *budgeted) List(ctx context.Context, sort []models.SortKey) ([]*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, err
+	}
+	return b.next.List(ctx, sort)
+}
+
+func (b *budgeted) Get(ctx context.Context, id int) (*models.Subscriber, bool, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, false, err
+	}
+	return b.next.Get(ctx, id)
+}
+
+func (b *budgeted) GetMany(ctx context.Context, ids []int) (map[int]*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, err
+	}
+	return b.next.GetMany(ctx, ids)
+}
+
+func (b *budgeted) Count(ctx context.Context, filter *models.SubscriberFilter) (int, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return 0, err
+	}
+	return b.next.Count(ctx, filter)
+}
+
+func (b *budgeted) Exists(ctx context.Context, id int) (bool, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return false, err
+	}
+	return b.next.Exists(ctx, id)
+}
+
+func (b *budgeted) Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, false, err
+	}
+	return b.next.Update(ctx, id, name, email)
+}
+
+func (b *budgeted) Delete(ctx context.Context, id int) (bool, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return false, err
+	}
+	return b.next.Delete(ctx, id)
+}
+
+func (b *budgeted) Export(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return err
+	}
+	return b.next.Export(ctx, chunkSize, fn)
+}
+
+func (b *budgeted) History(ctx context.Context, id int) ([]models.SubscriberChange, error) {
+	if err := budget.Check(ctx, budget.LayerService); err != nil {
+		return nil, err
+	}
+	return b.next.History(ctx, id)
+}
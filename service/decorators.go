@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"telemetry-demo/logging"
+	"telemetry-demo/models"
+	"telemetry-demo/telemetry"
+)
+
+// Decorator wraps a SubscriberService with a cross-cutting concern.
+type Decorator func(SubscriberService) SubscriberService
+
+// Chain applies decorators to svc. The first decorator is the outermost, so
+// Chain(svc, Traced(), Logged(d)) logs inside the span Traced starts.
+func Chain(svc SubscriberService, decorators ...Decorator) SubscriberService {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		svc = decorators[i](svc)
+	}
+	return svc
+}
+
+// opExport names a whole export in metrics and logs; each chunk within it
+// is simulated as OpExportChunk.
+const opExport Operation = "export"
+
+// observer is called when an operation starts and returns a function to
+// call when it finishes. Metered and Logged are both observers.
+type observer func(ctx context.Context, op Operation) func()
+
+// observed runs every call through an observer.
+type observed struct {
+	next    SubscriberService
+	observe observer
+}
+
+func (o *observed) Validate(ctx context.Context, name, email string) error {
+	defer o.observe(ctx, OpValidate)()
+	return o.next.Validate(ctx, name, email)
+}
+
+func (o *observed) Create(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	defer o.observe(ctx, OpCreate)()
+	return o.next.Create(ctx, name, email)
+}
+
+func (o *observed) List(ctx context.Context, sort []models.SortKey) ([]*models.Subscriber, error) {
+	defer o.observe(ctx, OpList)()
+	return o.next.List(ctx, sort)
+}
+
+func (o *observed) Get(ctx context.Context, id int) (*models.Subscriber, bool, error) {
+	defer o.observe(ctx, OpGet)()
+	return o.next.Get(ctx, id)
+}
+
+func (o *observed) GetMany(ctx context.Context, ids []int) (map[int]*models.Subscriber, error) {
+	defer o.observe(ctx, OpGetMany)()
+	return o.next.GetMany(ctx, ids)
+}
+
+func (o *observed) Count(ctx context.Context, filter *models.SubscriberFilter) (int, error) {
+	defer o.observe(ctx, OpCount)()
+	return o.next.Count(ctx, filter)
+}
+
+func (o *observed) Exists(ctx context.Context, id int) (bool, error) {
+	defer o.observe(ctx, OpExists)()
+	return o.next.Exists(ctx, id)
+}
+
+func (o *observed) Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	defer o.observe(ctx, OpUpdate)()
+	return o.next.Update(ctx, id, name, email)
+}
+
+func (o *observed) Delete(ctx context.Context, id int) (bool, error) {
+	defer o.observe(ctx, OpDelete)()
+	return o.next.Delete(ctx, id)
+}
+
+func (o *observed) Export(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error {
+	defer o.observe(ctx, opExport)()
+	return o.next.Export(ctx, chunkSize, fn)
+}
+
+func (o *observed) History(ctx context.Context, id int) ([]models.SubscriberChange, error) {
+	defer o.observe(ctx, OpHistory)()
+	return o.next.History(ctx, id)
+}
+
+// OperationStats is the aggregated timing of one service operation as seen
+// from one API tier.
+type OperationStats struct {
+	Tier      string  `json:"tier"`
+	Operation string  `json:"operation"`
+	Calls     int64   `json:"calls"`
+	TotalMs   float64 `json:"total_ms"`
+	MeanMs    float64 `json:"mean_ms"`
+	MaxMs     float64 `json:"max_ms"`
+
+	total time.Duration
+	max   time.Duration
+}
+
+// Service call instruments, exported through the global MeterProvider.
+var (
+	serviceMeter    = telemetry.Meter("telemetry-demo/service")
+	serviceCalls    = telemetry.Int64Counter(serviceMeter, "subscriber_service.calls", "{call}", "Subscriber service calls by tier and operation")
+	serviceDuration = telemetry.Float64Histogram(serviceMeter, "subscriber_service.duration", "ms", "Subscriber service call duration by tier and operation")
+)
+
+// ServiceMetrics collects call counts and latency per tier and operation.
+// It records them as metric instruments and also keeps them in memory to
+// serve as a report.
+type ServiceMetrics struct {
+	mu    sync.Mutex
+	stats map[[2]string]*OperationStats
+}
+
+func NewServiceMetrics() *ServiceMetrics {
+	return &ServiceMetrics{stats: make(map[[2]string]*OperationStats)}
+}
+
+// Metered records every call's duration in metrics under tier.
+func Metered(metrics *ServiceMetrics, tier string) Decorator {
+	return func(next SubscriberService) SubscriberService {
+		return &observed{next: next, observe: func(ctx context.Context, op Operation) func() {
+			start := time.Now()
+			return func() { metrics.record(ctx, tier, op, time.Since(start)) }
+		}}
+	}
+}
+
+func (m *ServiceMetrics) record(ctx context.Context, tier string, op Operation, elapsed time.Duration) {
+	attrs := metric.WithAttributes(
+		attribute.String("tier", tier),
+		attribute.String("operation", string(op)),
+	)
+	serviceCalls.Add(ctx, 1, attrs)
+	serviceDuration.Record(ctx, telemetry.Milliseconds(elapsed), attrs)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{tier, string(op)}
+	stats, ok := m.stats[key]
+	if !ok {
+		stats = &OperationStats{Tier: tier, Operation: string(op)}
+		m.stats[key] = stats
+	}
+	stats.Calls++
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+}
+
+// Report returns every tier and operation seen, ordered by tier then
+// operation.
+func (m *ServiceMetrics) Report() []OperationStats {
+	m.mu.Lock()
+	report := make([]OperationStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		entry := *stats
+		entry.TotalMs = float64(entry.total.Microseconds()) / 1000
+		entry.MeanMs = entry.TotalMs / float64(entry.Calls)
+		entry.MaxMs = float64(entry.max.Microseconds()) / 1000
+		report = append(report, entry)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Tier != report[j].Tier {
+			return report[i].Tier < report[j].Tier
+		}
+		return report[i].Operation < report[j].Operation
+	})
+	return report
+}
+
+// DefaultSlowCallThreshold is the service call duration Logged warns about.
+const DefaultSlowCallThreshold = 250 * time.Millisecond
+
+// Logged warns about calls slower than threshold, with the trace ID and
+// baggage fields when the call runs inside a span. Fast calls are left to
+// the tiers' own logs.
+func Logged(tier string, threshold time.Duration, logger logging.ContextLogger) Decorator {
+	return func(next SubscriberService) SubscriberService {
+		return &observed{next: next, observe: func(ctx context.Context, op Operation) func() {
+			start := time.Now()
+			return func() {
+				elapsed := time.Since(start)
+				if elapsed < threshold {
+					return
+				}
+
+				logger.WithTracing(ctx).WithFields(logging.Fields{
+					"tier":      tier,
+					"operation": op,
+					"duration":  elapsed,
+					"threshold": threshold,
+				}).Warn("Slow service call")
+			}
+		}}
+	}
+}
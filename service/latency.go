@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation names a simulated backend call with its own base latency.
+type Operation string
+
+const (
+	OpValidate    Operation = "validate"
+	OpCreate      Operation = "create"
+	OpList        Operation = "list"
+	OpGet         Operation = "get"
+	OpGetMany     Operation = "get_many"
+	OpCount       Operation = "count"
+	OpExists      Operation = "exists"
+	OpUpdate      Operation = "update"
+	OpDelete      Operation = "delete"
+	OpExportChunk Operation = "export_chunk"
+	OpHistory     Operation = "history"
+)
+
+// baseLatencies are the "realistic" costs every profile scales from.
+var baseLatencies = map[Operation]time.Duration{
+	OpValidate:    20 * time.Millisecond,
+	OpCreate:      50 * time.Millisecond,
+	OpList:        30 * time.Millisecond,
+	OpGet:         20 * time.Millisecond,
+	OpGetMany:     20 * time.Millisecond,
+	OpCount:       5 * time.Millisecond,
+	OpExists:      5 * time.Millisecond,
+	OpUpdate:      40 * time.Millisecond,
+	OpDelete:      30 * time.Millisecond,
+	OpExportChunk: 10 * time.Millisecond,
+	OpHistory:     15 * time.Millisecond,
+}
+
+// LatencyProfile controls how long simulated backend calls take.
+type LatencyProfile struct {
+	Name string
+	// Scale multiplies every base latency; zero disables simulated latency.
+	Scale float64
+	// Jitter varies each call by up to ±Jitter of its scaled latency.
+	Jitter float64
+	// SpikeEvery makes every Nth call take SpikeFactor times longer.
+	SpikeEvery  int
+	SpikeFactor float64
+}
+
+var latencyProfiles = map[string]LatencyProfile{
+	"fast":      {Name: "fast", Scale: 0},
+	"realistic": {Name: "realistic", Scale: 1},
+	"slow":      {Name: "slow", Scale: 3},
+	"chaotic":   {Name: "chaotic", Scale: 1, Jitter: 0.8, SpikeEvery: 20, SpikeFactor: 10},
+}
+
+// latencySeed keeps chaotic runs reproducible: the same sequence of calls
+// always gets the same delays.
+const latencySeed = 42
+
+// LatencyProfileNames lists the available profiles in sorted order.
+func LatencyProfileNames() []string {
+	names := make([]string, 0, len(latencyProfiles))
+	for name := range latencyProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupLatencyProfile(name string) (LatencyProfile, error) {
+	profile, ok := latencyProfiles[name]
+	if !ok {
+		return LatencyProfile{}, fmt.Errorf("unknown latency profile %q: must be one of %s",
+			name, strings.Join(LatencyProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// latency applies a profile to simulated calls. Jitter and spikes come from
+// a fixed-seed generator so a given profile is deterministic across runs.
+type latency struct {
+	profile LatencyProfile
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	calls int
+}
+
+func newLatency(profile LatencyProfile) *latency {
+	return &latency{
+		profile: profile,
+		rng:     rand.New(rand.NewSource(latencySeed)),
+	}
+}
+
+// For returns how long op should take under the profile.
+func (l *latency) For(op Operation) time.Duration {
+	d := time.Duration(float64(baseLatencies[op]) * l.profile.Scale)
+	if d == 0 || (l.profile.Jitter == 0 && l.profile.SpikeEvery == 0) {
+		return d
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if l.profile.Jitter > 0 {
+		d += time.Duration(float64(d) * l.profile.Jitter * (2*l.rng.Float64() - 1))
+	}
+	if l.profile.SpikeEvery > 0 && l.calls%l.profile.SpikeEvery == 0 {
+		d = time.Duration(float64(d) * l.profile.SpikeFactor)
+	}
+	return d
+}
+
+func (l *latency) Wait(op Operation) {
+	if d := l.For(op); d > 0 {
+		time.Sleep(d)
+	}
+}
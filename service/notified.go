@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"telemetry-demo/models"
+)
+
+// Notifier is told about every successful write, e.g. to deliver it as a
+// webhook. It is called on the request path, so it must not block.
+type Notifier interface {
+	SubscriberChanged(ctx context.Context, change models.SubscriberChange)
+}
+
+// Notified tells n about every create, update, and delete that succeeded.
+// The change's Seq is left zero; only the store's change feed numbers
+// changes.
+func Notified(n Notifier) Decorator {
+	return func(next SubscriberService) SubscriberService {
+		return &notified{SubscriberService: next, notifier: n}
+	}
+}
+
+// notified passes every call through and reports the writes.
+type notified struct {
+	SubscriberService
+	notifier Notifier
+}
+
+func (n *notified) Create(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	subscriber, err := n.SubscriberService.Create(ctx, name, email)
+	if err == nil {
+		n.changed(ctx, models.ChangeCreated, subscriber.ID, subscriber)
+	}
+	return subscriber, err
+}
+
+func (n *notified) Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	subscriber, ok, err := n.SubscriberService.Update(ctx, id, name, email)
+	if ok {
+		n.changed(ctx, models.ChangeUpdated, id, subscriber)
+	}
+	return subscriber, ok, err
+}
+
+func (n *notified) Delete(ctx context.Context, id int) (bool, error) {
+	ok, err := n.SubscriberService.Delete(ctx, id)
+	if ok {
+		n.changed(ctx, models.ChangeDeleted, id, nil)
+	}
+	return ok, err
+}
+
+func (n *notified) changed(ctx context.Context, kind string, id int, subscriber *models.Subscriber) {
+	n.notifier.SubscriberChanged(ctx, models.SubscriberChange{
+		Kind:         kind,
+		SubscriberID: id,
+		Subscriber:   subscriber,
+		At:           time.Now(),
+	})
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/errgroup"
+	"telemetry-demo/telemetry"
+)
+
+// Branch is one independent sub-operation of a composite read. A failing
+// required branch fails the whole operation; optional branches only make the
+// result partial.
+type Branch struct {
+	Name     string
+	Required bool
+	Fetch    func(ctx context.Context) (any, error)
+}
+
+// PartialResult collects branch values by name along with the errors of any
+// optional branches that failed.
+type PartialResult struct {
+	Data    map[string]any    `json:"data"`
+	Errors  map[string]string `json:"errors,omitempty"`
+	Partial bool              `json:"partial"`
+}
+
+// RunParallel fetches all branches concurrently, each in its own child span
+// of a span named after the operation. The first required failure cancels the
+// remaining branches and is returned as the error.
+func RunParallel(ctx context.Context, operation string, branches ...Branch) (*PartialResult, error) {
+	tracer := otel.Tracer("telemetry-demo/service")
+
+	ctx, span := tracer.Start(ctx, operation)
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("parallel.branches", len(branches)))
+
+	result := &PartialResult{
+		Data:   make(map[string]any, len(branches)),
+		Errors: make(map[string]string),
+	}
+	var mu sync.Mutex
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, branch := range branches {
+		branch := branch
+		group.Go(func() error {
+			branchCtx, branchSpan := tracer.Start(groupCtx, operation+"."+branch.Name)
+			defer branchSpan.End()
+
+			start := time.Now()
+			value, err := branch.Fetch(branchCtx)
+
+			branchSpan.SetAttributes(
+				attribute.String("branch.name", branch.Name),
+				attribute.Bool("branch.required", branch.Required),
+				attribute.Int64("branch.duration_ms", time.Since(start).Milliseconds()),
+			)
+
+			if err != nil {
+				telemetry.FailSpan(branchSpan, err, "")
+
+				if branch.Required {
+					return err
+				}
+
+				mu.Lock()
+				result.Errors[branch.Name] = err.Error()
+				mu.Unlock()
+				return nil
+			}
+
+			mu.Lock()
+			result.Data[branch.Name] = value
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		telemetry.FailSpan(span, err, "Required branch failed")
+		return nil, err
+	}
+
+	result.Partial = len(result.Errors) > 0
+	span.SetAttributes(
+		attribute.Bool("parallel.partial", result.Partial),
+		attribute.Int("parallel.failed_branches", len(result.Errors)),
+	)
+
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+
+	"telemetry-demo/models"
+	"telemetry-demo/store"
+)
+
+// SubscriberService is the subscriber business logic shared by every API
+// tier. The core implementation is deliberately uninstrumented; tracing,
+// metrics, and logging are layered on with decorators (see Chain), so each
+// tier picks the instrumentation it demonstrates without touching the logic.
+type SubscriberService interface {
+	// Validate runs the demo's (simulated) business validation. Field-level
+	// checks such as a required email happen at request binding.
+	Validate(ctx context.Context, name, email string) error
+	Create(ctx context.Context, name, email string) (*models.Subscriber, error)
+	// List returns every subscriber, ordered by sort when it has keys.
+	List(ctx context.Context, sort []models.SortKey) ([]*models.Subscriber, error)
+	// Get reports a missing subscriber as false with a nil error.
+	Get(ctx context.Context, id int) (*models.Subscriber, bool, error)
+	// GetMany looks up several subscribers in one simulated round trip.
+	GetMany(ctx context.Context, ids []int) (map[int]*models.Subscriber, error)
+	// Count returns how many subscribers match filter; nil counts them all.
+	// Unfiltered, it is an index-only operation, much cheaper than a full
+	// List.
+	Count(ctx context.Context, filter *models.SubscriberFilter) (int, error)
+	// Exists checks for a subscriber without loading it.
+	Exists(ctx context.Context, id int) (bool, error)
+	Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error)
+	Delete(ctx context.Context, id int) (bool, error)
+	// Export streams subscribers to fn in chunks, simulating one page fetch
+	// per chunk. It stops at the first error from fn or ctx.
+	Export(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error
+	// History returns the writes to a subscriber still held in the store's
+	// change feed, oldest first.
+	History(ctx context.Context, id int) ([]models.SubscriberChange, error)
+}
+
+// subscriberService is the core SubscriberService backed by the store.
+// Simulated backend latency comes from the configured LatencyProfile.
+type subscriberService struct {
+	store   *store.MemoryStore
+	latency *latency
+}
+
+func NewSubscriberService(store *store.MemoryStore, profile LatencyProfile) SubscriberService {
+	return &subscriberService{
+		store:   store,
+		latency: newLatency(profile),
+	}
+}
+
+func (s *subscriberService) Validate(ctx context.Context, name, email string) error {
+	s.latency.Wait(OpValidate)
+	return nil
+}
+
+func (s *subscriberService) Create(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	s.latency.Wait(OpCreate)
+	return s.store.CreateSubscriber(ctx, name, email)
+}
+
+func (s *subscriberService) List(ctx context.Context, sort []models.SortKey) ([]*models.Subscriber, error) {
+	s.latency.Wait(OpList)
+	return s.store.GetAllSubscribers(ctx, sort)
+}
+
+func (s *subscriberService) Get(ctx context.Context, id int) (*models.Subscriber, bool, error) {
+	s.latency.Wait(OpGet)
+	return s.store.GetSubscriber(ctx, id)
+}
+
+func (s *subscriberService) GetMany(ctx context.Context, ids []int) (map[int]*models.Subscriber, error) {
+	s.latency.Wait(OpGetMany)
+	return s.store.GetSubscribersByIDs(ctx, ids)
+}
+
+func (s *subscriberService) Count(ctx context.Context, filter *models.SubscriberFilter) (int, error) {
+	s.latency.Wait(OpCount)
+	return s.store.CountSubscribers(ctx, filter)
+}
+
+func (s *subscriberService) Exists(ctx context.Context, id int) (bool, error) {
+	s.latency.Wait(OpExists)
+	return s.store.SubscriberExists(ctx, id)
+}
+
+func (s *subscriberService) Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	s.latency.Wait(OpUpdate)
+	return s.store.UpdateSubscriber(ctx, id, name, email)
+}
+
+func (s *subscriberService) Delete(ctx context.Context, id int) (bool, error) {
+	s.latency.Wait(OpDelete)
+	return s.store.DeleteSubscriber(ctx, id)
+}
+
+func (s *subscriberService) Export(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error {
+	return s.store.IterateSubscribers(ctx, chunkSize, func(chunk []*models.Subscriber) error {
+		s.latency.Wait(OpExportChunk)
+		return fn(chunk)
+	})
+}
+
+func (s *subscriberService) History(ctx context.Context, id int) ([]models.SubscriberChange, error) {
+	s.latency.Wait(OpHistory)
+	return s.store.SubscriberChanges(id), nil
+}
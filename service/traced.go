@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/models"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Traced starts a business-logic span around every call, so handlers get
+// spans such as store_subscriber and lookup_subscriber without writing
+// them by hand.
+func Traced() Decorator {
+	return func(next SubscriberService) SubscriberService {
+		return &traced{
+			next:   next,
+			tracer: otel.Tracer("telemetry-demo/business-logic"),
+		}
+	}
+}
+
+type traced struct {
+	next   SubscriberService
+	tracer trace.Tracer
+}
+
+func (t *traced) Validate(ctx context.Context, name, email string) error {
+	ctx, span := t.tracer.Start(ctx, "validate_subscriber_data")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("validation.name", name),
+		attribute.String("validation.email", email),
+	)
+
+	if err := t.next.Validate(ctx, name, email); err != nil {
+		telemetry.FailSpan(span, err, "")
+		return err
+	}
+	telemetry.Milestone(span, telemetry.EventValidationCompleted)
+	return nil
+}
+
+func (t *traced) Create(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	ctx, span := t.tracer.Start(ctx, "store_subscriber")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("create"),
+		attrs.DBSystemMemory,
+	)
+
+	subscriber, err := t.next.Create(ctx, name, email)
+	if err != nil {
+		// Refused, e.g. by Budgeted once the latency budget ran out
+		telemetry.FailSpan(span, err, "")
+		return nil, err
+	}
+	telemetry.RowWritten(span, subscriber.ID)
+
+	span.SetAttributes(
+		attrs.SubscriberID(subscriber.ID),
+		attribute.String("subscriber.name", subscriber.Name),
+		attribute.String("subscriber.email", subscriber.Email),
+	)
+	return subscriber, nil
+}
+
+func (t *traced) List(ctx context.Context, sort []models.SortKey) ([]*models.Subscriber, error) {
+	ctx, span := t.tracer.Start(ctx, "query_all_subscribers")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("read_all"),
+		attrs.DBSystemMemory,
+	)
+	if len(sort) > 0 {
+		span.SetAttributes(
+			attribute.String("sort.keys", models.FormatSort(sort)),
+			attribute.String("sort.implementation", "in_memory_comparator"),
+		)
+	}
+
+	subscribers, err := t.next.List(ctx, sort)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return nil, err
+	}
+	telemetry.RowsLoaded(span, len(subscribers))
+
+	span.SetAttributes(attribute.Int("result.count", len(subscribers)))
+	return subscribers, nil
+}
+
+func (t *traced) Get(ctx context.Context, id int) (*models.Subscriber, bool, error) {
+	ctx, span := t.tracer.Start(ctx, "lookup_subscriber")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("read_by_id"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+
+	subscriber, exists, err := t.next.Get(ctx, id)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return nil, false, err
+	}
+	telemetry.RowLoaded(span, id, exists)
+	if !exists {
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
+	}
+
+	if exists {
+		span.SetAttributes(
+			attribute.String("subscriber.name", subscriber.Name),
+			attribute.String("subscriber.email", subscriber.Email),
+		)
+	}
+	return subscriber, exists, nil
+}
+
+func (t *traced) GetMany(ctx context.Context, ids []int) (map[int]*models.Subscriber, error) {
+	ctx, span := t.tracer.Start(ctx, "batch_lookup_subscribers")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("read_by_ids"),
+		attrs.DBSystemMemory,
+		attribute.Int("batch.requested", len(ids)),
+	)
+
+	// A single round trip for the whole batch
+	subscribers, err := t.next.GetMany(ctx, ids)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return nil, err
+	}
+	telemetry.RowsLoaded(span, len(subscribers))
+
+	span.SetAttributes(
+		attribute.Int("batch.found", len(subscribers)),
+		attribute.Int("batch.missing", len(ids)-len(subscribers)),
+	)
+	return subscribers, nil
+}
+
+func (t *traced) Count(ctx context.Context, filter *models.SubscriberFilter) (int, error) {
+	ctx, span := t.tracer.Start(ctx, "count_subscribers")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("count"),
+		attrs.DBSystemMemory,
+	)
+	if !filter.Empty() {
+		span.SetAttributes(attribute.String("count.filter", filter.String()))
+	}
+
+	count, err := t.next.Count(ctx, filter)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return 0, err
+	}
+
+	span.SetAttributes(attribute.Int("result.count", count))
+	return count, nil
+}
+
+func (t *traced) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, span := t.tracer.Start(ctx, "check_subscriber_exists")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("exists"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+
+	exists, err := t.next.Exists(ctx, id)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return false, err
+	}
+
+	span.SetAttributes(attribute.Bool("subscriber.exists", exists))
+	return exists, nil
+}
+
+func (t *traced) Update(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	ctx, span := t.tracer.Start(ctx, "update_subscriber")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("update"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+
+	subscriber, exists, err := t.next.Update(ctx, id, name, email)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return nil, false, err
+	}
+	if exists {
+		telemetry.RowWritten(span, id)
+	} else {
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
+	}
+
+	span.SetAttributes(attribute.Bool("subscriber.found", exists))
+	if exists {
+		span.SetAttributes(
+			attribute.String("subscriber.name", subscriber.Name),
+			attribute.String("subscriber.email", subscriber.Email),
+		)
+	}
+	return subscriber, exists, nil
+}
+
+func (t *traced) Delete(ctx context.Context, id int) (bool, error) {
+	ctx, span := t.tracer.Start(ctx, "delete_subscriber")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("delete"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+
+	deleted, err := t.next.Delete(ctx, id)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return false, err
+	}
+	if deleted {
+		telemetry.RowDeleted(span, id)
+	} else {
+		telemetry.ClassifyError(span, telemetry.ErrorNotFound)
+	}
+
+	span.SetAttributes(attribute.Bool("subscriber.found", deleted))
+	return deleted, nil
+}
+
+// Export traces the whole export and each chunk handed to fn, so a slow
+// writer shows up on the chunk it stalled.
+func (t *traced) Export(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error {
+	ctx, span := t.tracer.Start(ctx, "export_subscribers")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("iterate"),
+		attrs.DBSystemMemory,
+		attribute.Int("export.chunk_size", chunkSize),
+	)
+
+	exported, chunks := 0, 0
+	err := t.next.Export(ctx, chunkSize, func(chunk []*models.Subscriber) error {
+		_, chunkSpan := t.tracer.Start(ctx, "export_subscribers_chunk")
+		defer chunkSpan.End()
+
+		chunkSpan.SetAttributes(
+			attribute.Int("chunk.index", chunks),
+			attribute.Int("chunk.size", len(chunk)),
+		)
+
+		if err := fn(chunk); err != nil {
+			telemetry.FailSpan(chunkSpan, err, "Failed to write chunk")
+			return err
+		}
+
+		exported += len(chunk)
+		chunks++
+		return nil
+	})
+
+	span.SetAttributes(
+		attribute.Int("export.subscribers", exported),
+		attribute.Int("export.chunks", chunks),
+	)
+	if err != nil {
+		telemetry.FailSpan(span, err, "Export aborted")
+	}
+	return err
+}
+
+func (t *traced) History(ctx context.Context, id int) ([]models.SubscriberChange, error) {
+	ctx, span := t.tracer.Start(ctx, "query_subscriber_history")
+	defer span.End()
+
+	span.SetAttributes(
+		attrs.DBOperation("read_changes"),
+		attrs.DBSystemMemory,
+		attrs.SubscriberID(id),
+	)
+
+	changes, err := t.next.History(ctx, id)
+	if err != nil {
+		telemetry.FailSpan(span, err, "")
+		return nil, err
+	}
+	telemetry.RowsLoaded(span, len(changes))
+
+	span.SetAttributes(attribute.Int("result.count", len(changes)))
+	return changes, nil
+}
@@ -0,0 +1,202 @@
+// Package storage reports how much each storage backend holds: the
+// subscriber store, the activity event store, the change feed, and the
+// caches. A scheduled job refreshes the report and the capacity gauges
+// built from it, so dashboards and /admin/storage never scan a backend on
+// the request path.
+package storage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/cache"
+	"telemetry-demo/store"
+	"telemetry-demo/telemetry"
+)
+
+// Backend kinds, the storage.kind attribute.
+const (
+	KindSubscribers = "subscribers"
+	KindEvents      = "events"
+	KindChangeFeed  = "change_feed"
+	KindCache       = "cache"
+)
+
+// Backend is what one backend held at the last refresh.
+type Backend struct {
+	Name           string `json:"name"`
+	Kind           string `json:"kind"`
+	Objects        int    `json:"objects"`
+	EstimatedBytes int64  `json:"estimated_bytes"`
+	// Expired counts cache entries past their TTL but not yet swept.
+	Expired int `json:"expired,omitempty"`
+}
+
+// Report is the latest refresh.
+type Report struct {
+	RefreshedAt       time.Time `json:"refreshed_at"`
+	RefreshDurationMs float64   `json:"refresh_duration_ms"`
+	IntervalMs        int64     `json:"interval_ms"`
+	Backends          []Backend `json:"backends"`
+	TotalObjects      int       `json:"total_objects"`
+	TotalBytes        int64     `json:"total_estimated_bytes"`
+}
+
+var (
+	storageMeter   = telemetry.Meter("telemetry-demo/storage")
+	storageObjects = telemetry.Int64ObservableGauge(storageMeter, "storage.objects", "{object}",
+		"Objects held by each storage backend at the last refresh, by storage.name and storage.kind")
+	storageBytes = telemetry.Int64ObservableGauge(storageMeter, "storage.bytes", "By",
+		"Estimated bytes held by each storage backend at the last refresh, by storage.name and storage.kind")
+)
+
+// Reporter periodically measures the store and caches.
+type Reporter struct {
+	store    *store.MemoryStore
+	caches   []*cache.InMemoryCache
+	interval time.Duration
+	tracer   trace.Tracer
+	gauge    metric.Registration
+
+	mu     sync.Mutex
+	report Report
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewReporter measures the store and caches once, then again every
+// interval until Stop is called. Nil caches are skipped.
+func NewReporter(s *store.MemoryStore, caches []*cache.InMemoryCache, interval time.Duration) *Reporter {
+	r := &Reporter{
+		store:    s,
+		interval: interval,
+		tracer:   otel.Tracer("telemetry-demo/storage"),
+		stop:     make(chan struct{}),
+	}
+	for _, c := range caches {
+		if c != nil {
+			r.caches = append(r.caches, c)
+		}
+	}
+
+	r.Refresh(context.Background())
+	gauge, err := storageMeter.RegisterCallback(r.observe, storageObjects, storageBytes)
+	if err != nil {
+		log.Printf("Failed to observe storage usage: %v", err)
+	}
+	r.gauge = gauge
+	telemetry.Go(context.Background(), "storage.reporter", func(context.Context) { r.loop() })
+	return r
+}
+
+func (r *Reporter) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+		if r.gauge != nil {
+			r.gauge.Unregister()
+		}
+	})
+}
+
+// Report returns the latest refresh.
+func (r *Reporter) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.report
+}
+
+func (r *Reporter) loop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Refresh(context.Background())
+		}
+	}
+}
+
+// Refresh measures every backend now. Each refresh is one trace with a
+// child span per backend, so a slow scan shows which backend caused it.
+func (r *Reporter) Refresh(ctx context.Context) Report {
+	start := time.Now()
+	ctx, span := r.tracer.Start(ctx, "storage.refresh", trace.WithNewRoot())
+	defer span.End()
+
+	var backends []Backend
+	r.measure(ctx, func() []Backend {
+		stats := r.store.Stats()
+		return []Backend{
+			{Name: stats.Backend, Kind: KindSubscribers, Objects: stats.Subscribers, EstimatedBytes: stats.SubscriberBytes},
+			{Name: stats.Backend, Kind: KindEvents, Objects: stats.Events, EstimatedBytes: stats.EventBytes},
+			{Name: stats.Backend, Kind: KindChangeFeed, Objects: stats.Changes, EstimatedBytes: stats.ChangeBytes},
+		}
+	}, &backends)
+	for _, c := range r.caches {
+		r.measure(ctx, func() []Backend {
+			stats := c.Stats()
+			return []Backend{{Name: stats.Cache, Kind: KindCache, Objects: stats.Entries, EstimatedBytes: stats.EstimatedBytes, Expired: stats.Expired}}
+		}, &backends)
+	}
+
+	report := Report{
+		RefreshedAt:       start,
+		RefreshDurationMs: telemetry.Milliseconds(time.Since(start)),
+		IntervalMs:        r.interval.Milliseconds(),
+		Backends:          backends,
+	}
+	for _, backend := range backends {
+		report.TotalObjects += backend.Objects
+		report.TotalBytes += backend.EstimatedBytes
+	}
+	span.SetAttributes(
+		attribute.Int("storage.backends", len(backends)),
+		attribute.Int("storage.objects", report.TotalObjects),
+		attribute.Int64("storage.bytes", report.TotalBytes),
+	)
+
+	r.mu.Lock()
+	r.report = report
+	r.mu.Unlock()
+	return report
+}
+
+func (r *Reporter) measure(ctx context.Context, stats func() []Backend, into *[]Backend) {
+	_, span := r.tracer.Start(ctx, "storage.measure")
+	defer span.End()
+
+	measured := stats()
+	for _, backend := range measured {
+		span.SetAttributes(
+			attribute.String("storage.name", backend.Name),
+			attribute.Int("storage."+backend.Kind+".objects", backend.Objects),
+			attribute.Int64("storage."+backend.Kind+".bytes", backend.EstimatedBytes),
+		)
+	}
+	*into = append(*into, measured...)
+}
+
+// observe reports the last refresh, so collecting metrics never scans a
+// backend.
+func (r *Reporter) observe(_ context.Context, o metric.Observer) error {
+	for _, backend := range r.Report().Backends {
+		backendAttrs := metric.WithAttributes(
+			attribute.String("storage.name", backend.Name),
+			attribute.String("storage.kind", backend.Kind),
+		)
+		o.ObserveInt64(storageObjects, int64(backend.Objects), backendAttrs)
+		o.ObserveInt64(storageBytes, backend.EstimatedBytes, backendAttrs)
+	}
+	return nil
+}
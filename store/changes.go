@@ -0,0 +1,100 @@
+package store
+
+import (
+	"sync"
+
+	"telemetry-demo/models"
+)
+
+// maxRetainedChanges bounds the change feed. Clients further behind than
+// this get a truncated batch and should reload the full list.
+const maxRetainedChanges = 1000
+
+// changeFeed is the sequenced log of subscriber writes that watchers read
+// from. Every append wakes whoever is waiting on the current signal by
+// closing it and starting a new one.
+type changeFeed struct {
+	mu      sync.Mutex
+	changes []models.SubscriberChange
+	head    int64
+	signal  chan struct{}
+}
+
+// ChangeBatch is the part of the change feed after a cursor.
+type ChangeBatch struct {
+	Changes []models.SubscriberChange
+	// Cursor is the Seq of the latest change, to pass as since next time.
+	Cursor int64
+	// Truncated is set when changes after since were already dropped from
+	// the feed, so Changes doesn't hold all of them.
+	Truncated bool
+}
+
+// recordChange appends a write to the change feed. Callers hold s.mu, so
+// changes are numbered in the order they were applied.
+func (s *MemoryStore) recordChange(kind string, id int, subscriber *models.Subscriber) {
+	f := &s.feed
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.head++
+	f.changes = append(f.changes, models.SubscriberChange{
+		Seq:          f.head,
+		Kind:         kind,
+		SubscriberID: id,
+		Subscriber:   subscriber,
+		At:           s.now(),
+	})
+	if len(f.changes) > maxRetainedChanges {
+		f.changes = append([]models.SubscriberChange(nil), f.changes[len(f.changes)-maxRetainedChanges:]...)
+	}
+	if f.signal != nil {
+		close(f.signal)
+		f.signal = nil
+	}
+}
+
+// ChangesSince returns the changes after since, and a channel closed at the
+// next change. Both come from one snapshot, so a change made between
+// reading the batch and waiting on the channel is never missed. A negative
+// since starts from the current end of the feed.
+func (s *MemoryStore) ChangesSince(since int64) (ChangeBatch, <-chan struct{}) {
+	f := &s.feed
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.signal == nil {
+		f.signal = make(chan struct{})
+	}
+	if since < 0 || since > f.head {
+		since = f.head
+	}
+
+	batch := ChangeBatch{Cursor: f.head}
+	if since == f.head {
+		return batch, f.signal
+	}
+	oldest := f.head - int64(len(f.changes)) + 1
+	if since+1 < oldest {
+		batch.Truncated = true
+		since = oldest - 1
+	}
+	batch.Changes = append([]models.SubscriberChange(nil), f.changes[since-oldest+1:]...)
+	return batch, f.signal
+}
+
+// SubscriberChanges returns the changes to one subscriber still retained in
+// the feed, oldest first.
+func (s *MemoryStore) SubscriberChanges(id int) []models.SubscriberChange {
+	f := &s.feed
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var changes []models.SubscriberChange
+	for _, change := range f.changes {
+		if change.SubscriberID == id {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"telemetry-demo/models"
+)
+
+func TestCountSubscribersFilter(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	for _, email := range []string{"ada@example.com", "bob@Example.COM", "cy@other.org"} {
+		if _, err := s.CreateSubscriber(ctx, "n", email); err != nil {
+			t.Fatalf("CreateSubscriber(%q): %v", email, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter *models.SubscriberFilter
+		want   int
+	}{
+		{nil, 3},
+		{&models.SubscriberFilter{}, 3},
+		{&models.SubscriberFilter{EmailDomain: "example.com"}, 2},
+		{&models.SubscriberFilter{EmailDomain: "nowhere.net"}, 0},
+	} {
+		got, err := s.CountSubscribers(ctx, tc.filter)
+		if err != nil || got != tc.want {
+			t.Errorf("CountSubscribers(%q) = %d, %v; want %d", tc.filter, got, err, tc.want)
+		}
+	}
+
+	if exists, err := s.SubscriberExists(ctx, 1); err != nil || !exists {
+		t.Errorf("SubscriberExists(1) = %v, %v; want true", exists, err)
+	}
+	if exists, err := s.SubscriberExists(ctx, 42); err != nil || exists {
+		t.Errorf("SubscriberExists(42) = %v, %v; want false", exists, err)
+	}
+}
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"testing"
+
+	"telemetry-demo/models"
+)
+
+func TestTrimEvents(t *testing.T) {
+	s := NewMemoryStore()
+	s.AppendEvents(make([]models.ActivityEvent, 3))
+
+	batch, cursor := s.EventsSince(0)
+	if len(batch) != 3 || cursor != 3 {
+		t.Fatalf("EventsSince(0) = %d events, cursor %d; want 3, 3", len(batch), cursor)
+	}
+	s.TrimEvents(cursor)
+	s.AppendEvents(make([]models.ActivityEvent, 2))
+
+	if got := s.EventCount(); got != 5 {
+		t.Errorf("EventCount = %d, want 5 including trimmed events", got)
+	}
+	if got := len(s.events); got != 2 {
+		t.Errorf("store holds %d events, want the 2 pending", got)
+	}
+	batch, cursor = s.EventsSince(cursor)
+	if len(batch) != 2 || cursor != 5 {
+		t.Errorf("EventsSince(3) = %d events, cursor %d; want 2, 5", len(batch), cursor)
+	}
+}
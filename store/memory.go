@@ -1,8 +1,11 @@
 package store
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"time"
+	"telemetry-demo/budget"
 	"telemetry-demo/models"
 )
 
@@ -10,41 +13,201 @@ type MemoryStore struct {
 	subscribers map[int]*models.Subscriber
 	nextID      int
 	mu          sync.RWMutex
+	changeHooks []func()
+	now         func() time.Time
+	
+	// feed records every subscriber write for watchers
+	feed changeFeed
+	
+	// Activity events use their own lock so the high-volume write path
+	// never contends with subscriber reads. Events already aggregated are
+	// trimmed; trimmed counts them, so cursors keep counting from the first
+	// event ever persisted.
+	events   []models.ActivityEvent
+	trimmed  int
+	eventsMu sync.RWMutex
 }
 
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{
+// Option configures a MemoryStore.
+type Option func(*MemoryStore)
+
+// WithClock replaces time.Now for subscriber timestamps, so demos and tests
+// can produce stable output.
+func WithClock(now func() time.Time) Option {
+	return func(s *MemoryStore) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	s := &MemoryStore{
 		subscribers: make(map[int]*models.Subscriber),
 		nextID:      1,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-func (s *MemoryStore) CreateSubscriber(name, email string) *models.Subscriber {
+// OnChange registers a hook run after every write, outside the store lock.
+// Caches use it to drop data that is no longer current.
+func (s *MemoryStore) OnChange(hook func()) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
+	s.changeHooks = append(s.changeHooks, hook)
+}
+
+// CreateSubscriber stores a new subscriber. Like every store call that
+// takes a context, it fails with an error wrapping budget.ErrExhausted once
+// the request's latency budget has run out.
+func (s *MemoryStore) CreateSubscriber(ctx context.Context, name, email string) (*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return nil, err
+	}
+	defer s.observe(ctx, opCreate, time.Now())
+	
+	s.mu.Lock()
+	
 	subscriber := &models.Subscriber{
 		ID:      s.nextID,
 		Name:    name,
 		Email:   email,
-		Created: time.Now(),
+		Created: s.now(),
 	}
 	
 	s.subscribers[s.nextID] = subscriber
 	s.nextID++
+	s.recordChange(models.ChangeCreated, subscriber.ID, subscriber)
+	hooks := s.changeHooks
+	s.mu.Unlock()
 	
-	return subscriber
+	for _, hook := range hooks {
+		hook()
+	}
+	
+	return subscriber, nil
 }
 
-func (s *MemoryStore) GetSubscriber(id int) (*models.Subscriber, bool) {
+func (s *MemoryStore) GetSubscriber(ctx context.Context, id int) (*models.Subscriber, bool, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return nil, false, err
+	}
+	defer s.observe(ctx, opRead, time.Now())
+	
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	subscriber, exists := s.subscribers[id]
-	return subscriber, exists
+	return subscriber, exists, nil
 }
 
-func (s *MemoryStore) GetAllSubscribers() []*models.Subscriber {
+// UpdateSubscriber replaces a subscriber's name and email, keeping its ID,
+// creation time, and engagement. The stored subscriber is swapped for a
+// copy because handlers read subscribers without holding the lock.
+func (s *MemoryStore) UpdateSubscriber(ctx context.Context, id int, name, email string) (*models.Subscriber, bool, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return nil, false, err
+	}
+	defer s.observe(ctx, opUpdate, time.Now())
+	
+	s.mu.Lock()
+	existing, exists := s.subscribers[id]
+	if !exists {
+		s.mu.Unlock()
+		return nil, false, nil
+	}
+	
+	updated := *existing
+	updated.Name = name
+	updated.Email = email
+	s.subscribers[id] = &updated
+	s.recordChange(models.ChangeUpdated, id, &updated)
+	hooks := s.changeHooks
+	s.mu.Unlock()
+	
+	for _, hook := range hooks {
+		hook()
+	}
+	
+	return &updated, true, nil
+}
+
+// DeleteSubscriber removes a subscriber, reporting whether it existed.
+func (s *MemoryStore) DeleteSubscriber(ctx context.Context, id int) (bool, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return false, err
+	}
+	defer s.observe(ctx, opDelete, time.Now())
+	
+	s.mu.Lock()
+	_, exists := s.subscribers[id]
+	delete(s.subscribers, id)
+	if exists {
+		s.recordChange(models.ChangeDeleted, id, nil)
+	}
+	hooks := s.changeHooks
+	s.mu.Unlock()
+	
+	if exists {
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+	
+	return exists, nil
+}
+
+// SubscriberExists checks for a subscriber without returning it.
+func (s *MemoryStore) SubscriberExists(ctx context.Context, id int) (bool, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return false, err
+	}
+	defer s.observe(ctx, opExists, time.Now())
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	_, exists := s.subscribers[id]
+	return exists, nil
+}
+
+// CountSubscribers returns the number of subscribers matching filter
+// without copying them. A nil filter reads the count straight off the
+// index.
+func (s *MemoryStore) CountSubscribers(ctx context.Context, filter *models.SubscriberFilter) (int, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return 0, err
+	}
+	defer s.observe(ctx, opCount, time.Now())
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	if filter.Empty() {
+		return len(s.subscribers), nil
+	}
+	count := 0
+	for _, subscriber := range s.subscribers {
+		if filter.Matches(subscriber) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetAllSubscribers returns every subscriber ordered by keys, or in no
+// particular order without any.
+func (s *MemoryStore) GetAllSubscribers(ctx context.Context, keys []models.SortKey) ([]*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return nil, err
+	}
+	defer s.observe(ctx, opList, time.Now())
+	
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
@@ -52,6 +215,154 @@ func (s *MemoryStore) GetAllSubscribers() []*models.Subscriber {
 	for _, subscriber := range s.subscribers {
 		subscribers = append(subscribers, subscriber)
 	}
+	if len(keys) > 0 {
+		SortSubscribers(subscribers, keys)
+	}
+	
+	return subscribers, nil
+}
+
+// GetSubscribersByIDs looks up many subscribers under a single lock.
+// IDs that don't exist are simply absent from the result.
+func (s *MemoryStore) GetSubscribersByIDs(ctx context.Context, ids []int) (map[int]*models.Subscriber, error) {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return nil, err
+	}
+	defer s.observe(ctx, opBatchRead, time.Now())
+	
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	subscribers := make(map[int]*models.Subscriber, len(ids))
+	for _, id := range ids {
+		if subscriber, exists := s.subscribers[id]; exists {
+			subscribers[id] = subscriber
+		}
+	}
+	
+	return subscribers, nil
+}
+
+// IterateSubscribers streams subscribers in ID order, chunkSize at a time.
+// The lock is only held while copying each chunk, so a slow consumer never
+// blocks writers, and fn runs synchronously so it naturally applies
+// backpressure. Iteration stops at the first error from fn or ctx.
+func (s *MemoryStore) IterateSubscribers(ctx context.Context, chunkSize int, fn func(chunk []*models.Subscriber) error) error {
+	if err := budget.Check(ctx, budget.LayerStore); err != nil {
+		return err
+	}
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	
+	s.mu.RLock()
+	ids := make([]int, 0, len(s.subscribers))
+	for id := range s.subscribers {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+	sort.Ints(ids)
 	
-	return subscribers
-}
\ No newline at end of file
+	for offset := 0; offset < len(ids); offset += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
+		end := offset + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		
+		// Subscribers deleted since the ID snapshot are skipped
+		chunk := make([]*models.Subscriber, 0, end-offset)
+		s.mu.RLock()
+		for _, id := range ids[offset:end] {
+			if subscriber, exists := s.subscribers[id]; exists {
+				chunk = append(chunk, subscriber)
+			}
+		}
+		s.mu.RUnlock()
+		
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// AppendEvents persists a batch of activity events in one lock acquisition.
+func (s *MemoryStore) AppendEvents(events []models.ActivityEvent) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	
+	s.events = append(s.events, events...)
+}
+
+// EventCount returns how many activity events have been persisted.
+func (s *MemoryStore) EventCount() int {
+	s.eventsMu.RLock()
+	defer s.eventsMu.RUnlock()
+	
+	return s.trimmed + len(s.events)
+}
+
+// EventsSince returns the events persisted after cursor along with the
+// cursor to pass next time. Events before a trimmed cursor are gone.
+func (s *MemoryStore) EventsSince(cursor int) ([]models.ActivityEvent, int) {
+	s.eventsMu.RLock()
+	defer s.eventsMu.RUnlock()
+	
+	total := s.trimmed + len(s.events)
+	if cursor >= total {
+		return nil, cursor
+	}
+	offset := max(cursor-s.trimmed, 0)
+	events := make([]models.ActivityEvent, len(s.events)-offset)
+	copy(events, s.events[offset:])
+	return events, total
+}
+
+// TrimEvents forgets the events before cursor, once they have been
+// aggregated, so the event log only holds what is still pending.
+func (s *MemoryStore) TrimEvents(cursor int) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	
+	n := min(cursor-s.trimmed, len(s.events))
+	if n <= 0 {
+		return
+	}
+	// Copy what is left, so the trimmed events' backing array can be freed
+	s.events = append([]models.ActivityEvent(nil), s.events[n:]...)
+	s.trimmed += n
+}
+
+// UpdateEngagement replaces the engagement of each listed subscriber.
+// Subscribers are copied rather than mutated because handlers read them
+// without holding the lock. Returns how many subscribers were updated.
+func (s *MemoryStore) UpdateEngagement(engagement map[int]models.Engagement) int {
+	s.mu.Lock()
+	updated := 0
+	for id, value := range engagement {
+		subscriber, exists := s.subscribers[id]
+		if !exists {
+			continue
+		}
+		value := value
+		copied := *subscriber
+		copied.Engagement = &value
+		s.subscribers[id] = &copied
+		updated++
+	}
+	hooks := s.changeHooks
+	s.mu.Unlock()
+	
+	if updated > 0 {
+		for _, hook := range hooks {
+			hook()
+		}
+	}
+	
+	return updated
+}
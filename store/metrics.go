@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"telemetry-demo/telemetry"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Store operations, the store.operation attribute on duration metrics.
+const (
+	opCreate    = "create"
+	opRead      = "read"
+	opBatchRead = "batch_read"
+	opList      = "list"
+	opCount     = "count"
+	opExists    = "exists"
+	opUpdate    = "update"
+	opDelete    = "delete"
+)
+
+// backendMemory is the db.system attribute of MemoryStore, so other
+// backends can report into the same histogram and be compared with it.
+const backendMemory = "memory"
+
+var (
+	storeMeter       = telemetry.Meter("telemetry-demo/store")
+	storeDuration    = telemetry.Float64Histogram(storeMeter, "store.operation.duration", "ms", "Subscriber store operation duration by backend and operation")
+	subscribersTotal = telemetry.Int64ObservableGauge(storeMeter, "subscribers.total", "{subscriber}", "Subscribers currently in the store")
+)
+
+// observe records how long op took since start. Writes include the change
+// hooks, since callers wait for them too. ctx carries the caller's span, so
+// the measurement can become an exemplar pointing at its trace.
+func (s *MemoryStore) observe(ctx context.Context, op string, start time.Time) {
+	storeDuration.Record(ctx, telemetry.Milliseconds(time.Since(start)), metric.WithAttributes(
+		attrs.DBSystem(backendMemory),
+		attrs.DBOperation(op),
+	))
+}
+
+// ObserveSubscribers reports the subscriber count as the subscribers.total
+// gauge on every metric collection until the returned func is called. The
+// count is read directly rather than through CountSubscribers, so
+// collections don't show up as store operations.
+func (s *MemoryStore) ObserveSubscribers() func() {
+	backend := metric.WithAttributes(attrs.DBSystem(backendMemory))
+	registration, err := storeMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s.mu.RLock()
+		count := len(s.subscribers)
+		s.mu.RUnlock()
+
+		o.ObserveInt64(subscribersTotal, int64(count), backend)
+		return nil
+	}, subscribersTotal)
+	if err != nil {
+		log.Printf("Failed to observe subscriber count: %v", err)
+		return func() {}
+	}
+	return func() {
+		if err := registration.Unregister(); err != nil {
+			log.Printf("Failed to stop observing subscriber count: %v", err)
+		}
+	}
+}
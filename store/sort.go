@@ -0,0 +1,56 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"telemetry-demo/models"
+)
+
+// SortSubscribers orders subscribers by keys in place, breaking remaining
+// ties by ID so every order is deterministic. It is the in-memory fallback
+// for backends that can't sort natively: a SQL backend would turn keys into
+// an ORDER BY instead, and MemoryStore, which has no indexes, always uses
+// it. models.SortByCreated sorts like models.SortByCreatedAt, and keys
+// naming unknown fields are ignored.
+func SortSubscribers(subscribers []*models.Subscriber, keys []models.SortKey) {
+	sort.SliceStable(subscribers, func(i, j int) bool {
+		a, b := subscribers[i], subscribers[j]
+		for _, key := range keys {
+			c := compareField(a, b, key.Field)
+			if c == 0 {
+				continue
+			}
+			if key.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+}
+
+// compareField compares a and b by one field, returning -1, 0, or 1.
+func compareField(a, b *models.Subscriber, field string) int {
+	switch field {
+	case models.SortByID:
+		return compareInts(a.ID, b.ID)
+	case models.SortByName:
+		return strings.Compare(a.Name, b.Name)
+	case models.SortByEmail:
+		return strings.Compare(a.Email, b.Email)
+	case models.SortByCreatedAt, models.SortByCreated:
+		return a.Created.Compare(b.Created)
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"telemetry-demo/models"
+)
+
+func TestSortSubscribers(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fixture := func() []*models.Subscriber {
+		return []*models.Subscriber{
+			{ID: 3, Name: "bea", Email: "b@x.io", Created: base.Add(2 * time.Hour)},
+			{ID: 1, Name: "ann", Email: "a@x.io", Created: base.Add(time.Hour)},
+			{ID: 4, Name: "bea", Email: "c@x.io", Created: base.Add(time.Hour)},
+			{ID: 2, Name: "ann", Email: "d@x.io", Created: base.Add(2 * time.Hour)},
+		}
+	}
+
+	tests := []struct {
+		name string
+		keys []models.SortKey
+		want []int
+	}{
+		{
+			name: "no keys orders by ID",
+			want: []int{1, 2, 3, 4},
+		},
+		{
+			name: "single key",
+			keys: []models.SortKey{{Field: models.SortByEmail, Desc: true}},
+			want: []int{2, 4, 3, 1},
+		},
+		{
+			name: "created_at then name descending",
+			keys: []models.SortKey{{Field: models.SortByCreatedAt}, {Field: models.SortByName, Desc: true}},
+			want: []int{4, 1, 3, 2},
+		},
+		{
+			name: "created sorts like created_at",
+			keys: []models.SortKey{{Field: models.SortByCreated}, {Field: models.SortByName, Desc: true}},
+			want: []int{4, 1, 3, 2},
+		},
+		{
+			name: "name then created_at descending",
+			keys: []models.SortKey{{Field: models.SortByName}, {Field: models.SortByCreatedAt, Desc: true}},
+			want: []int{2, 1, 3, 4},
+		},
+		{
+			name: "ties left by every key fall back to ID",
+			keys: []models.SortKey{{Field: models.SortByName}},
+			want: []int{1, 2, 3, 4},
+		},
+		{
+			name: "descending keys still break ties by ascending ID",
+			keys: []models.SortKey{{Field: models.SortByName, Desc: true}},
+			want: []int{3, 4, 1, 2},
+		},
+		{
+			name: "unknown fields are ignored",
+			keys: []models.SortKey{{Field: "shoe_size"}, {Field: models.SortByEmail}},
+			want: []int{1, 3, 4, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every starting order must give the same result
+			for _, reversed := range []bool{false, true} {
+				subscribers := fixture()
+				if reversed {
+					for i, j := 0, len(subscribers)-1; i < j; i, j = i+1, j-1 {
+						subscribers[i], subscribers[j] = subscribers[j], subscribers[i]
+					}
+				}
+
+				SortSubscribers(subscribers, tt.keys)
+
+				got := make([]int, len(subscribers))
+				for i, s := range subscribers {
+					got[i] = s.ID
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("reversed=%v: got IDs %v, want %v", reversed, got, tt.want)
+				}
+			}
+		})
+	}
+}
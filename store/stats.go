@@ -0,0 +1,68 @@
+package store
+
+import (
+	"unsafe"
+
+	"telemetry-demo/models"
+)
+
+// Stats is a point-in-time measure of what the store holds. Byte counts
+// are estimates: each record's struct size plus its string contents, not
+// map or slice overhead.
+type Stats struct {
+	Backend         string `json:"backend"`
+	Subscribers     int    `json:"subscribers"`
+	SubscriberBytes int64  `json:"subscriber_bytes"`
+	Events          int    `json:"events"`
+	EventBytes      int64  `json:"event_bytes"`
+	Changes         int    `json:"changes"`
+	ChangeBytes     int64  `json:"change_bytes"`
+}
+
+var (
+	subscriberSize = int64(unsafe.Sizeof(models.Subscriber{}))
+	engagementSize = int64(unsafe.Sizeof(models.Engagement{}))
+	eventSize      = int64(unsafe.Sizeof(models.ActivityEvent{}))
+	changeSize     = int64(unsafe.Sizeof(models.SubscriberChange{}))
+)
+
+// Stats counts and sizes subscribers, activity events, and the change
+// feed. Each is read under its own lock, so the three may be from slightly
+// different moments. It isn't a store operation and records no metrics.
+func (s *MemoryStore) Stats() Stats {
+	stats := Stats{Backend: backendMemory}
+
+	s.mu.RLock()
+	stats.Subscribers = len(s.subscribers)
+	for _, subscriber := range s.subscribers {
+		stats.SubscriberBytes += subscriberBytes(subscriber)
+	}
+	s.mu.RUnlock()
+
+	s.eventsMu.RLock()
+	stats.Events = len(s.events)
+	for _, event := range s.events {
+		stats.EventBytes += eventSize + int64(len(event.Type)+len(event.Campaign))
+	}
+	s.eventsMu.RUnlock()
+
+	s.feed.mu.Lock()
+	stats.Changes = len(s.feed.changes)
+	for _, change := range s.feed.changes {
+		stats.ChangeBytes += changeSize + int64(len(change.Kind))
+		if change.Subscriber != nil {
+			stats.ChangeBytes += subscriberBytes(change.Subscriber)
+		}
+	}
+	s.feed.mu.Unlock()
+
+	return stats
+}
+
+func subscriberBytes(subscriber *models.Subscriber) int64 {
+	size := subscriberSize + int64(len(subscriber.Name)+len(subscriber.Email))
+	if subscriber.Engagement != nil {
+		size += engagementSize
+	}
+	return size
+}
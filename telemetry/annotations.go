@@ -0,0 +1,228 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Annotation metrics, so dashboards can overlay operator events on the
+// series they affect.
+var (
+	annotationMeter  = Meter("telemetry-demo/annotations")
+	annotationEvents = Int64Counter(annotationMeter, "annotation.events", "{event}",
+		"Annotations started and ended, by annotation.kind and annotation.event")
+	annotationsActive = Int64ObservableGauge(annotationMeter, "annotation.active", "{annotation}",
+		"Annotations currently active, by annotation.kind")
+)
+
+// Annotation is an operator marker such as "deploy started" or "chaos
+// enabled". While it is active every root span carries its ID and kind, so
+// traces recorded during the event can be found and compared with the rest.
+type Annotation struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end,omitempty"`
+	Active    bool       `json:"active"`
+	Annotated int64      `json:"annotated_spans"`
+}
+
+type annotation struct {
+	Annotation
+	// until is when the annotation lapses on its own; zero means it stays
+	// active until it is ended.
+	until time.Time
+}
+
+func (a *annotation) activeAt(now time.Time) bool {
+	if a.End != nil {
+		return false
+	}
+	return a.until.IsZero() || now.Before(a.until)
+}
+
+// Annotations records operator annotations and is a SpanProcessor that
+// stamps the active ones on every root span as it starts.
+type Annotations struct {
+	now func() time.Time
+
+	mu          sync.Mutex
+	annotations []*annotation
+	nextID      int
+	max         int
+}
+
+// NewAnnotations reports its active annotations in the annotation.active
+// gauge for as long as the process runs.
+func NewAnnotations() *Annotations {
+	a := &Annotations{now: time.Now, max: 100}
+	_, err := annotationMeter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for kind, active := range a.activeByKind() {
+			o.ObserveInt64(annotationsActive, active, metric.WithAttributes(attribute.String("annotation.kind", kind)))
+		}
+		return nil
+	}, annotationsActive)
+	if err != nil {
+		log.Printf("Failed to observe active annotations: %v", err)
+	}
+	return a
+}
+
+// activeByKind counts the active annotations of each kind that has any
+// annotation kept, so a kind whose annotations ended reports zero.
+func (a *Annotations) activeByKind() map[string]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	counts := make(map[string]int64)
+	for _, entry := range a.annotations {
+		if entry.activeAt(now) {
+			counts[entry.Kind]++
+		} else if _, ok := counts[entry.Kind]; !ok {
+			counts[entry.Kind] = 0
+		}
+	}
+	return counts
+}
+
+// Annotate starts an annotation. A zero duration keeps it active until End.
+// Starting and ending each emit an "annotation" span so the marker shows up
+// in the trace UIs on its own.
+func (a *Annotations) Annotate(ctx context.Context, kind, message string, duration time.Duration) Annotation {
+	a.mu.Lock()
+	a.nextID++
+	start := a.now()
+	entry := &annotation{Annotation: Annotation{
+		ID:      fmt.Sprintf("ann-%04d", a.nextID),
+		Kind:    kind,
+		Message: message,
+		Start:   start,
+	}}
+	if duration > 0 {
+		entry.until = start.Add(duration)
+	}
+	a.annotations = append(a.annotations, entry)
+	if excess := len(a.annotations) - a.max; excess > 0 {
+		a.annotations = a.annotations[excess:]
+	}
+	snapshot := a.snapshotLocked(entry, start)
+	a.mu.Unlock()
+
+	a.mark(ctx, snapshot, "annotation_started")
+	log.Printf("📌 Annotation %s [%s]: %s", snapshot.ID, kind, message)
+	return snapshot
+}
+
+// End stops an active annotation early. It reports false if there is no
+// annotation with that ID.
+func (a *Annotations) End(ctx context.Context, id string) (Annotation, bool) {
+	a.mu.Lock()
+	var entry *annotation
+	for _, candidate := range a.annotations {
+		if candidate.ID == id {
+			entry = candidate
+			break
+		}
+	}
+	if entry == nil {
+		a.mu.Unlock()
+		return Annotation{}, false
+	}
+
+	now := a.now()
+	wasActive := entry.activeAt(now)
+	if wasActive {
+		entry.End = &now
+	}
+	snapshot := a.snapshotLocked(entry, now)
+	a.mu.Unlock()
+
+	if wasActive {
+		a.mark(ctx, snapshot, "annotation_ended")
+		log.Printf("📌 Annotation %s ended", snapshot.ID)
+	}
+	return snapshot, true
+}
+
+// List returns every annotation kept, newest first.
+func (a *Annotations) List() []Annotation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	report := make([]Annotation, 0, len(a.annotations))
+	for i := len(a.annotations) - 1; i >= 0; i-- {
+		report = append(report, a.snapshotLocked(a.annotations[i], now))
+	}
+	return report
+}
+
+// snapshotLocked copies entry, filling in when a timed annotation lapsed.
+func (a *Annotations) snapshotLocked(entry *annotation, now time.Time) Annotation {
+	snapshot := entry.Annotation
+	snapshot.Active = entry.activeAt(now)
+	if !snapshot.Active && snapshot.End == nil {
+		until := entry.until
+		snapshot.End = &until
+	}
+	return snapshot
+}
+
+func (a *Annotations) mark(ctx context.Context, entry Annotation, event string) {
+	_, span := otel.Tracer("telemetry-demo/annotations").Start(ctx, "annotation")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("annotation.id", entry.ID),
+		attribute.String("annotation.kind", entry.Kind),
+		attribute.String("annotation.message", entry.Message),
+	)
+	span.AddEvent(event)
+	annotationEvents.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("annotation.kind", entry.Kind),
+		attribute.String("annotation.event", event),
+	))
+}
+
+func (a *Annotations) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	// Child spans inherit the context of their root
+	if s.Parent().IsValid() && !s.Parent().IsRemote() {
+		return
+	}
+
+	a.mu.Lock()
+	now := a.now()
+	var ids, kinds []string
+	for _, entry := range a.annotations {
+		if entry.activeAt(now) {
+			ids = append(ids, entry.ID)
+			kinds = append(kinds, entry.Kind)
+			entry.Annotated++
+		}
+	}
+	a.mu.Unlock()
+
+	if len(ids) == 0 {
+		return
+	}
+	s.SetAttributes(
+		attribute.StringSlice("annotation.ids", ids),
+		attribute.StringSlice("annotation.kinds", kinds),
+	)
+}
+
+func (a *Annotations) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (a *Annotations) Shutdown(ctx context.Context) error { return nil }
+
+func (a *Annotations) ForceFlush(ctx context.Context) error { return nil }
@@ -0,0 +1,286 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AnomalyDetectorConfig struct {
+	// Window is how long a route's request and error counts accumulate
+	// before they reset.
+	Window time.Duration
+	// MinRequests is how many requests a window needs before its error rate
+	// counts, so one failure on a quiet route isn't a spike.
+	MinRequests int
+	// ErrorRateThreshold is the error ratio within a window that opens an
+	// incident.
+	ErrorRateThreshold float64
+	// Capture is how many failing traces an incident collects.
+	Capture int
+	// CaptureTimeout closes an incident that hasn't collected Capture
+	// traces in time, so a spike that subsides stops forcing samples.
+	CaptureTimeout time.Duration
+	// MaxIncidents is how many incident records are kept, newest first.
+	MaxIncidents int
+}
+
+func DefaultAnomalyDetectorConfig() AnomalyDetectorConfig {
+	return AnomalyDetectorConfig{
+		Window:             30 * time.Second,
+		MinRequests:        10,
+		ErrorRateThreshold: 0.2,
+		Capture:            5,
+		CaptureTimeout:     2 * time.Minute,
+		MaxIncidents:       50,
+	}
+}
+
+// Incident states.
+const (
+	IncidentCapturing = "capturing"
+	IncidentCaptured  = "captured"
+	IncidentExpired   = "expired"
+)
+
+// Incident is the evidence captured for one error rate spike on a route:
+// the window that tripped the detector and the trace IDs of failing
+// requests sampled afterwards.
+type Incident struct {
+	ID            string     `json:"id"`
+	Route         string     `json:"route"`
+	Status        string     `json:"status"`
+	OpenedAt      time.Time  `json:"opened_at"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+	Requests      int        `json:"window_requests"`
+	Errors        int        `json:"window_errors"`
+	ErrorRate     float64    `json:"window_error_rate"`
+	Threshold     float64    `json:"threshold"`
+	Capture       int        `json:"capture"`
+	TraceIDs      []string   `json:"trace_ids"`
+	ForcedSamples int64      `json:"forced_samples"`
+}
+
+type anomalyWindow struct {
+	start    time.Time
+	requests int
+	errors   int
+}
+
+// AnomalyDetector watches root span error rates per route. When a route's
+// error rate spikes it opens an incident, samples every request on that
+// route until the incident has collected Capture failing traces, and keeps
+// their trace IDs in the incident record.
+//
+// It is a SpanProcessor to see outcomes and wraps the root sampler to force
+// sampling, so register it in both places.
+type AnomalyDetector struct {
+	config AnomalyDetectorConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	windows   map[string]*anomalyWindow
+	open      map[string]*Incident
+	incidents []*Incident
+	nextID    int
+}
+
+func NewAnomalyDetector(config AnomalyDetectorConfig) *AnomalyDetector {
+	return &AnomalyDetector{
+		config:  config,
+		now:     time.Now,
+		windows: make(map[string]*anomalyWindow),
+		open:    make(map[string]*Incident),
+	}
+}
+
+// Sampler wraps next so requests on a route with an open incident are
+// always sampled and tagged with the incident ID. next still sees every
+// request, so its own statistics stay complete.
+func (d *AnomalyDetector) Sampler(next sdktrace.Sampler) sdktrace.Sampler {
+	return &incidentSampler{detector: d, next: next}
+}
+
+type incidentSampler struct {
+	detector *AnomalyDetector
+	next     sdktrace.Sampler
+}
+
+func (s *incidentSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.next.ShouldSample(p)
+
+	incident := s.detector.capturing(p.Name)
+	if incident == "" {
+		return result
+	}
+	result.Decision = sdktrace.RecordAndSample
+	result.Attributes = append(result.Attributes, attribute.String("incident.id", incident))
+	return result
+}
+
+func (s *incidentSampler) Description() string {
+	return fmt.Sprintf("IncidentCapture{%s}", s.next.Description())
+}
+
+// capturing returns the ID of route's open incident, or "" if there is none.
+func (d *AnomalyDetector) capturing(route string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	incident := d.openLocked(route)
+	if incident == nil {
+		return ""
+	}
+	incident.ForcedSamples++
+	return incident.ID
+}
+
+func (d *AnomalyDetector) OnStart(parent context.Context, span sdktrace.ReadWriteSpan) {}
+
+func (d *AnomalyDetector) OnEnd(span sdktrace.ReadOnlySpan) {
+	// Like the adaptive sampler, only root spans describe a request
+	if span.Parent().IsValid() && !span.Parent().IsRemote() {
+		return
+	}
+
+	route := span.Name()
+	failed := span.Status().Code == codes.Error
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if incident := d.openLocked(route); incident != nil {
+		if failed && span.SpanContext().IsSampled() {
+			d.captureLocked(incident, span.SpanContext().TraceID())
+		}
+		return
+	}
+
+	window := d.windowLocked(route)
+	window.requests++
+	if failed {
+		window.errors++
+	}
+
+	if window.requests < d.config.MinRequests {
+		return
+	}
+	rate := errorRate(window.requests, window.errors)
+	if rate < d.config.ErrorRateThreshold {
+		return
+	}
+
+	incident := d.openIncidentLocked(route, window, rate)
+	delete(d.windows, route)
+
+	// The request that tripped the detector is evidence too, if it was kept
+	if failed && span.SpanContext().IsSampled() {
+		d.captureLocked(incident, span.SpanContext().TraceID())
+	}
+}
+
+func (d *AnomalyDetector) Shutdown(ctx context.Context) error { return nil }
+
+func (d *AnomalyDetector) ForceFlush(ctx context.Context) error { return nil }
+
+// Incidents returns every incident record kept, newest first.
+func (d *AnomalyDetector) Incidents() []Incident {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for route := range d.open {
+		d.openLocked(route)
+	}
+
+	report := make([]Incident, 0, len(d.incidents))
+	for i := len(d.incidents) - 1; i >= 0; i-- {
+		entry := *d.incidents[i]
+		entry.TraceIDs = append([]string{}, entry.TraceIDs...)
+		report = append(report, entry)
+	}
+	return report
+}
+
+// Incident returns one incident record by ID.
+func (d *AnomalyDetector) Incident(id string) (Incident, bool) {
+	for _, incident := range d.Incidents() {
+		if incident.ID == id {
+			return incident, true
+		}
+	}
+	return Incident{}, false
+}
+
+// openLocked returns route's incident if it is still capturing, expiring it
+// first if it has run out of time.
+func (d *AnomalyDetector) openLocked(route string) *Incident {
+	incident, ok := d.open[route]
+	if !ok {
+		return nil
+	}
+
+	now := d.now()
+	if now.Sub(incident.OpenedAt) < d.config.CaptureTimeout {
+		return incident
+	}
+	d.closeLocked(incident, IncidentExpired, now)
+	return nil
+}
+
+func (d *AnomalyDetector) windowLocked(route string) *anomalyWindow {
+	now := d.now()
+	window, ok := d.windows[route]
+	if !ok || now.Sub(window.start) >= d.config.Window {
+		window = &anomalyWindow{start: now}
+		d.windows[route] = window
+	}
+	return window
+}
+
+func (d *AnomalyDetector) openIncidentLocked(route string, window *anomalyWindow, rate float64) *Incident {
+	d.nextID++
+	incident := &Incident{
+		ID:        fmt.Sprintf("inc-%04d", d.nextID),
+		Route:     route,
+		Status:    IncidentCapturing,
+		OpenedAt:  d.now(),
+		Requests:  window.requests,
+		Errors:    window.errors,
+		ErrorRate: rate,
+		Threshold: d.config.ErrorRateThreshold,
+		Capture:   d.config.Capture,
+		TraceIDs:  []string{},
+	}
+	d.open[route] = incident
+
+	d.incidents = append(d.incidents, incident)
+	if excess := len(d.incidents) - d.config.MaxIncidents; excess > 0 {
+		d.incidents = d.incidents[excess:]
+	}
+
+	log.Printf("🚨 Incident %s: %s error rate %.0f%% (%d/%d) - capturing next %d failing traces",
+		incident.ID, route, rate*100, window.errors, window.requests, d.config.Capture)
+	return incident
+}
+
+func (d *AnomalyDetector) captureLocked(incident *Incident, traceID trace.TraceID) {
+	incident.TraceIDs = append(incident.TraceIDs, traceID.String())
+	if len(incident.TraceIDs) >= incident.Capture {
+		d.closeLocked(incident, IncidentCaptured, d.now())
+	}
+}
+
+func (d *AnomalyDetector) closeLocked(incident *Incident, status string, at time.Time) {
+	incident.Status = status
+	incident.ClosedAt = &at
+	delete(d.open, incident.Route)
+
+	log.Printf("🚨 Incident %s %s with %d trace(s)", incident.ID, status, len(incident.TraceIDs))
+}
@@ -0,0 +1,122 @@
+// Package attrs is the single place the demo imports OpenTelemetry semantic
+// conventions from. Everything else uses these names, so moving to a newer
+// semconv version (and absorbing its renames) only touches this package.
+package attrs
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// SchemaURL is the schema URL of the semconv version re-exported here.
+const SchemaURL = semconv.SchemaURL
+
+// Resource attributes.
+var (
+	ServiceName           = semconv.ServiceName
+	ServiceVersion        = semconv.ServiceVersion
+	DeploymentEnvironment = semconv.DeploymentEnvironment
+)
+
+// Keys taken from the current semconv version.
+const (
+	HTTPRoute            = semconv.HTTPRouteKey
+	HTTPRequestMethod    = semconv.HTTPRequestMethodKey
+	HTTPMethodOriginal   = semconv.HTTPRequestMethodOriginalKey
+	ClientAddress        = semconv.ClientAddressKey
+	UserAgentOriginal    = semconv.UserAgentOriginalKey
+	PeerService          = semconv.PeerServiceKey
+	EnduserID            = semconv.EnduserIDKey
+	HTTPResponseBodySize = semconv.HTTPResponseBodySizeKey
+	MessagingSystem      = semconv.MessagingSystemKey
+	MessagingOperation   = semconv.MessagingOperationKey
+	MessagingDestination = semconv.MessagingDestinationNameKey
+	MessagingMessageID   = semconv.MessagingMessageIDKey
+	MessagingBatchCount  = semconv.MessagingBatchMessageCountKey
+)
+
+// Legacy HTTP keys. otelgin still emits the pre-1.21 names, so spans created
+// by hand use them too to stay queryable alongside V2's middleware spans.
+// Switch these when otelgin moves to the new names.
+const (
+	HTTPMethod     = attribute.Key("http.method")
+	HTTPStatusCode = attribute.Key("http.status_code")
+	HTTPTarget     = attribute.Key("http.target")
+	HTTPURL        = attribute.Key("http.url")
+)
+
+// Keys with no semconv equivalent in this version.
+const (
+	CodeStacktrace      = attribute.Key("code.stacktrace")
+	ErrorCode           = attribute.Key("error.code")
+	ErrorType           = attribute.Key("error.type")
+	ErrorCategory       = attribute.Key("error.category")
+	ClientGeoCountry    = attribute.Key("client.geo.country")
+	ClientGeoCity       = attribute.Key("client.geo.city")
+	UserAgentBrowser    = attribute.Key("user_agent.browser")
+	UserAgentOS         = attribute.Key("user_agent.os")
+	UserAgentDeviceType = attribute.Key("user_agent.device_type")
+	HTTPMetadataRequest = attribute.Key("http.metadata_request")
+	HTTPStatusClass     = attribute.Key("http.response.status_class")
+	TenantID            = attribute.Key("tenant.id")
+	TenantTier          = attribute.Key("tenant.tier")
+	ExperimentName      = attribute.Key("experiment.name")
+	ExperimentVariant   = attribute.Key("experiment.variant")
+	// Synthetic is "true" on the spans, logs, and HTTP metrics of probe
+	// traffic, from the baggage member of the same name.
+	Synthetic = attribute.Key("synthetic")
+)
+
+// Attributes set by more than one layer. Handlers, the service, the
+// store, and the caches build them with these constructors, so a key never
+// drifts between layers and dashboards can rely on it.
+const (
+	DBSystemKey     = semconv.DBSystemKey
+	DBOperationKey  = semconv.DBOperationKey
+	SubscriberIDKey = attribute.Key("subscriber.id")
+	CacheNameKey    = attribute.Key("cache.name")
+	CacheResultKey  = attribute.Key("cache.result")
+)
+
+// Cache lookup results, the values of CacheResult.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// DBSystemMemory is the db.system of the in-memory store.
+var DBSystemMemory = DBSystem("memory")
+
+// DBSystem names the storage backend behind a store span or metric.
+func DBSystem(system string) attribute.KeyValue {
+	return DBSystemKey.String(system)
+}
+
+// DBOperation names the store operation, e.g. create or read_by_id.
+func DBOperation(op string) attribute.KeyValue {
+	return DBOperationKey.String(op)
+}
+
+// SubscriberID is the subscriber a span reads or writes.
+func SubscriberID(id int) attribute.KeyValue {
+	return SubscriberIDKey.Int(id)
+}
+
+// CacheName names the cache behind a cache span or metric.
+func CacheName(name string) attribute.KeyValue {
+	return CacheNameKey.String(name)
+}
+
+// CacheResult is whether a cache lookup hit.
+func CacheResult(hit bool) attribute.KeyValue {
+	if hit {
+		return CacheResultKey.String(CacheHit)
+	}
+	return CacheResultKey.String(CacheMiss)
+}
+
+// IsHTTPMethod reports whether key names the request method under either
+// the legacy or the current convention.
+func IsHTTPMethod(key attribute.Key) bool {
+	return key == HTTPMethod || key == HTTPRequestMethod
+}
@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BaggageProcessor copies selected baggage members onto every span as it
+// starts, as attributes of the same name. Baggage travels with the context,
+// so a tenant.id sent by the caller lands on handler, service, and store
+// spans alike, and on the spans of services called downstream.
+type BaggageProcessor struct {
+	keys []string
+}
+
+func NewBaggageProcessor(keys []string) BaggageProcessor {
+	return BaggageProcessor{keys: keys}
+}
+
+func (p BaggageProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	bag := baggage.FromContext(parent)
+	if bag.Len() == 0 {
+		return
+	}
+	for _, key := range p.keys {
+		if member := bag.Member(key); member.Key() != "" {
+			s.SetAttributes(attribute.String(key, member.Value()))
+		}
+	}
+}
+
+func (BaggageProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (BaggageProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (BaggageProcessor) ForceFlush(ctx context.Context) error { return nil }
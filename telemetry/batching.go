@@ -0,0 +1,247 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchConfig tunes the batch processor in front of every span exporter.
+// Zero values keep the SDK's defaults, which the OTEL_BSP_* variables can
+// change: a 2048 span queue, 512 span batches, a batch every 5s, and 30s
+// per export.
+type BatchConfig struct {
+	// MaxQueueSize is how many finished spans can wait for export. Once it
+	// is full, new spans are dropped unless BlockOnQueueFull is set.
+	MaxQueueSize int
+	// MaxExportBatchSize is the most spans sent in one export.
+	MaxExportBatchSize int
+	// BatchTimeout is the longest a span waits before a partial batch is
+	// sent.
+	BatchTimeout time.Duration
+	// ExportTimeout bounds one export, including its retries.
+	ExportTimeout time.Duration
+	// BlockOnQueueFull makes ending a span wait for room in a full queue
+	// instead of dropping it. No span is lost, but a slow backend then
+	// slows down requests.
+	BlockOnQueueFull bool
+	Retry            RetryConfig
+}
+
+// RetryConfig retries failed exports with exponential backoff. A retrying
+// export holds up the batches behind it, so long retries fill the queue.
+type RetryConfig struct {
+	// MaxElapsedTime is how long one batch is retried before it is given
+	// up. Zero disables retries.
+	MaxElapsedTime time.Duration
+	// InitialInterval is the wait before the first retry, doubling up to
+	// MaxInterval. Zero values mean 500ms and 5s.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+const (
+	defaultRetryInitialInterval = 500 * time.Millisecond
+	defaultRetryMaxInterval     = 5 * time.Second
+)
+
+// otlpRetry has the layout of the OTLP exporters' RetryConfig, so it
+// converts to either one.
+type otlpRetry struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// otlp returns c as OTLP exporter retry settings. The OTLP exporters retry
+// only what the collector reports as retryable, such as 503 or
+// UNAVAILABLE, and honor its requested backoff, so they do their own
+// retrying instead of going through retryingExporter.
+func (c RetryConfig) otlp() otlpRetry {
+	retry := otlpRetry{
+		Enabled:         c.MaxElapsedTime > 0,
+		InitialInterval: c.InitialInterval,
+		MaxInterval:     c.MaxInterval,
+		MaxElapsedTime:  c.MaxElapsedTime,
+	}
+	if retry.InitialInterval <= 0 {
+		retry.InitialInterval = defaultRetryInitialInterval
+	}
+	if retry.MaxInterval <= 0 {
+		retry.MaxInterval = defaultRetryMaxInterval
+	}
+	return retry
+}
+
+func (c BatchConfig) options() []sdktrace.BatchSpanProcessorOption {
+	var options []sdktrace.BatchSpanProcessorOption
+	if c.MaxQueueSize > 0 {
+		options = append(options, sdktrace.WithMaxQueueSize(c.MaxQueueSize))
+	}
+	if c.MaxExportBatchSize > 0 {
+		options = append(options, sdktrace.WithMaxExportBatchSize(c.MaxExportBatchSize))
+	}
+	if c.BatchTimeout > 0 {
+		options = append(options, sdktrace.WithBatchTimeout(c.BatchTimeout))
+	}
+	if c.ExportTimeout > 0 {
+		options = append(options, sdktrace.WithExportTimeout(c.ExportTimeout))
+	}
+	if c.BlockOnQueueFull {
+		options = append(options, sdktrace.WithBlocking())
+	}
+	return options
+}
+
+// BatchSettings reports the batch tuning Init applied. Zero values are the
+// SDK's defaults.
+type BatchSettings struct {
+	MaxQueueSize       int   `json:"max_queue_size,omitempty"`
+	MaxExportBatchSize int   `json:"max_export_batch_size,omitempty"`
+	BatchTimeoutMs     int64 `json:"batch_timeout_ms,omitempty"`
+	ExportTimeoutMs    int64 `json:"export_timeout_ms,omitempty"`
+	BlockOnQueueFull   bool  `json:"block_on_queue_full"`
+	RetryMaxElapsedMs  int64 `json:"retry_max_elapsed_ms"`
+}
+
+func (c BatchConfig) settings() BatchSettings {
+	return BatchSettings{
+		MaxQueueSize:       c.MaxQueueSize,
+		MaxExportBatchSize: c.MaxExportBatchSize,
+		BatchTimeoutMs:     c.BatchTimeout.Milliseconds(),
+		ExportTimeoutMs:    c.ExportTimeout.Milliseconds(),
+		BlockOnQueueFull:   c.BlockOnQueueFull,
+		RetryMaxElapsedMs:  c.Retry.MaxElapsedTime.Milliseconds(),
+	}
+}
+
+// Export outcomes, the outcome attribute of span.export.spans.
+const (
+	exportOutcomeExported = "exported"
+	exportOutcomeFailed   = "failed"
+)
+
+var (
+	exportMeter    = Meter("telemetry-demo/export")
+	exportEnqueued = Int64Counter(exportMeter, "span.export.enqueued", "{span}",
+		"Sampled spans handed to an exporter's batch processor, by exporter")
+	exportSpans = Int64Counter(exportMeter, "span.export.spans", "{span}",
+		"Spans an exporter sent or gave up on, by exporter and outcome")
+	exportRetries = Int64Counter(exportMeter, "span.export.retries", "{retry}",
+		"Export attempts repeated after a failure, by exporter")
+)
+
+// ExportStats counts the spans handed to one exporter and what became of
+// them.
+type ExportStats struct {
+	Exporter ExporterKind `json:"exporter"`
+	Enqueued int64        `json:"enqueued"`
+	Exported int64        `json:"exported"`
+	Failed   int64        `json:"failed"`
+	// Unaccounted spans were neither exported nor given up on: the queue
+	// dropped them, or they are still waiting. After a flush they were
+	// dropped.
+	Unaccounted int64 `json:"unaccounted"`
+}
+
+// exportCounts backs ExportStats for one exporter, alongside the
+// span.export.* counters.
+type exportCounts struct {
+	kind     ExporterKind
+	enqueued atomic.Int64
+	exported atomic.Int64
+	failed   atomic.Int64
+}
+
+func (c *exportCounts) stats() ExportStats {
+	// Read outcomes before enqueued, so a span finishing in between can't
+	// make unaccounted negative
+	exported, failed := c.exported.Load(), c.failed.Load()
+	enqueued := c.enqueued.Load()
+	return ExportStats{
+		Exporter:    c.kind,
+		Enqueued:    enqueued,
+		Exported:    exported,
+		Failed:      failed,
+		Unaccounted: enqueued - exported - failed,
+	}
+}
+
+// newBatcher is the batch processor feeding exporter, with its failed
+// exports retried as config says. Comparing span.export.enqueued with
+// span.export.spans shows spans the queue dropped.
+func newBatcher(counts *exportCounts, exporter sdktrace.SpanExporter, config BatchConfig) sdktrace.SpanProcessor {
+	return sdktrace.NewBatchSpanProcessor(newRetryingExporter(counts, exporter, config.Retry), config.options()...)
+}
+
+// retryingExporter retries a failed export with exponential backoff until
+// RetryConfig.MaxElapsedTime passes or the export's context is done, and
+// counts what each export achieved.
+type retryingExporter struct {
+	next   sdktrace.SpanExporter
+	config RetryConfig
+	counts *exportCounts
+	attrs  attribute.Set
+}
+
+func newRetryingExporter(counts *exportCounts, next sdktrace.SpanExporter, config RetryConfig) *retryingExporter {
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = defaultRetryInitialInterval
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = defaultRetryMaxInterval
+	}
+	return &retryingExporter{
+		next:   next,
+		config: config,
+		counts: counts,
+		attrs:  attribute.NewSet(attribute.String("exporter", string(counts.kind))),
+	}
+}
+
+func (e *retryingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	wait := e.config.InitialInterval
+	for {
+		err := e.next.ExportSpans(ctx, spans)
+		if err == nil {
+			e.count(ctx, exportOutcomeExported, len(spans))
+			return nil
+		}
+		if time.Since(start)+wait > e.config.MaxElapsedTime {
+			e.count(ctx, exportOutcomeFailed, len(spans))
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			e.count(ctx, exportOutcomeFailed, len(spans))
+			return err
+		}
+		exportRetries.Add(ctx, 1, metric.WithAttributeSet(e.attrs))
+		wait = min(2*wait, e.config.MaxInterval)
+	}
+}
+
+func (e *retryingExporter) count(ctx context.Context, outcome string, spans int) {
+	if outcome == exportOutcomeExported {
+		e.counts.exported.Add(int64(spans))
+	} else {
+		e.counts.failed.Add(int64(spans))
+	}
+	exportSpans.Add(ctx, int64(spans), metric.WithAttributes(
+		append(e.attrs.ToSlice(), attribute.String("outcome", outcome))...,
+	))
+}
+
+func (e *retryingExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
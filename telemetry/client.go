@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// ClientInfo describes who sent the current request.
+type ClientInfo struct {
+	Address string `json:"address"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// UserAgentInfo is a normalized, low-cardinality view of a User-Agent header.
+type UserAgentInfo struct {
+	Original   string `json:"original"`
+	Browser    string `json:"browser"`
+	OS         string `json:"os"`
+	DeviceType string `json:"device_type"`
+}
+
+type clientInfoKey struct{}
+
+type userAgentKey struct{}
+
+func ContextWithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+func ContextWithUserAgent(ctx context.Context, info UserAgentInfo) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, info)
+}
+
+func UserAgentFromContext(ctx context.Context) (UserAgentInfo, bool) {
+	info, ok := ctx.Value(userAgentKey{}).(UserAgentInfo)
+	return info, ok
+}
+
+func (i ClientInfo) Attributes() []attribute.KeyValue {
+	kvs := []attribute.KeyValue{attrs.ClientAddress.String(i.Address)}
+	if i.Country != "" {
+		kvs = append(kvs, attrs.ClientGeoCountry.String(i.Country))
+	}
+	if i.City != "" {
+		kvs = append(kvs, attrs.ClientGeoCity.String(i.City))
+	}
+	return kvs
+}
+
+func (i UserAgentInfo) Attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attrs.UserAgentOriginal.String(i.Original),
+		attrs.UserAgentBrowser.String(i.Browser),
+		attrs.UserAgentOS.String(i.OS),
+		attrs.UserAgentDeviceType.String(i.DeviceType),
+	}
+}
@@ -0,0 +1,239 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// spanOverheadBytes approximates the fixed size of a span on the wire
+// (IDs, timestamps, name, status) before any attributes are added.
+const spanOverheadBytes = 64
+
+// maxPendingTraces bounds the number of traces tracked while waiting for
+// their root span to end, so orphaned traces can't grow memory forever.
+const maxPendingTraces = 10000
+
+// pendingTraceTTL is how long a trace is tracked without a span ending
+// before it is given up on, e.g. a background span that ended after its
+// root.
+const pendingTraceTTL = time.Minute
+
+var (
+	costMeter         = Meter("telemetry-demo/telemetry")
+	costDroppedTraces = Int64Counter(costMeter, "telemetry.cost.dropped_traces", "{trace}",
+		"Traces left out of the cost report, by reason: expired waiting for their root span, or full when too many were pending")
+)
+
+// EndpointCost is the accumulated telemetry cost for one endpoint.
+type EndpointCost struct {
+	Endpoint         string  `json:"endpoint"`
+	Traces           int     `json:"traces"`
+	Spans            int     `json:"spans"`
+	EstimatedBytes   int     `json:"estimated_bytes"`
+	AvgSpansPerTrace float64 `json:"avg_spans_per_trace"`
+	AvgBytesPerTrace float64 `json:"avg_bytes_per_trace"`
+}
+
+type traceCost struct {
+	spans   int
+	bytes   int
+	updated time.Time
+}
+
+// CostProcessor estimates how much telemetry each trace produces
+// (span count × attribute bytes) and rolls it up per endpoint.
+type CostProcessor struct {
+	mu        sync.Mutex
+	pending   map[trace.TraceID]*traceCost
+	endpoints map[string]*EndpointCost
+	lastSweep time.Time
+	dropped   int64
+	now       func() time.Time
+}
+
+func NewCostProcessor() *CostProcessor {
+	return &CostProcessor{
+		pending:   make(map[trace.TraceID]*traceCost),
+		endpoints: make(map[string]*EndpointCost),
+		now:       time.Now,
+	}
+}
+
+func (p *CostProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *CostProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	// Recorded-but-unsampled spans are never exported, so they cost nothing
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+
+	traceID := s.SpanContext().TraceID()
+	size := estimateSpanBytes(s)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if now.Sub(p.lastSweep) >= pendingTraceTTL || len(p.pending) >= maxPendingTraces {
+		p.expireLocked(now)
+	}
+	tc, ok := p.pending[traceID]
+	if !ok {
+		if len(p.pending) >= maxPendingTraces {
+			p.dropped++
+			costDroppedTraces.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", "full")))
+			return
+		}
+		tc = &traceCost{}
+		p.pending[traceID] = tc
+	}
+	tc.spans++
+	tc.bytes += size
+	tc.updated = now
+
+	// Children end before their local root, so the root closes the trace
+	if s.Parent().IsValid() && !s.Parent().IsRemote() {
+		return
+	}
+	delete(p.pending, traceID)
+
+	endpoint := endpointName(s)
+	ec, ok := p.endpoints[endpoint]
+	if !ok {
+		ec = &EndpointCost{Endpoint: endpoint}
+		p.endpoints[endpoint] = ec
+	}
+	ec.Traces++
+	ec.Spans += tc.spans
+	ec.EstimatedBytes += tc.bytes
+}
+
+// expireLocked forgets pending traces that haven't had a span end within
+// pendingTraceTTL.
+func (p *CostProcessor) expireLocked(now time.Time) {
+	p.lastSweep = now
+	var expired int64
+	for traceID, tc := range p.pending {
+		if now.Sub(tc.updated) >= pendingTraceTTL {
+			delete(p.pending, traceID)
+			expired++
+		}
+	}
+	if expired > 0 {
+		p.dropped += expired
+		costDroppedTraces.Add(context.Background(), expired, metric.WithAttributes(attribute.String("reason", "expired")))
+	}
+}
+
+// DroppedTraces is how many traces were left out of the report because
+// their root span never ended here or too many traces were pending.
+func (p *CostProcessor) DroppedTraces() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.dropped
+}
+
+func (p *CostProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (p *CostProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+// TopEndpoints returns the n most expensive endpoints by estimated bytes.
+// A non-positive n returns every endpoint.
+func (p *CostProcessor) TopEndpoints(n int) []EndpointCost {
+	p.mu.Lock()
+	report := make([]EndpointCost, 0, len(p.endpoints))
+	for _, ec := range p.endpoints {
+		entry := *ec
+		entry.AvgSpansPerTrace = float64(ec.Spans) / float64(ec.Traces)
+		entry.AvgBytesPerTrace = float64(ec.EstimatedBytes) / float64(ec.Traces)
+		report = append(report, entry)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].EstimatedBytes != report[j].EstimatedBytes {
+			return report[i].EstimatedBytes > report[j].EstimatedBytes
+		}
+		return report[i].Endpoint < report[j].Endpoint
+	})
+
+	if n > 0 && len(report) > n {
+		report = report[:n]
+	}
+	return report
+}
+
+// endpointName prefers the HTTP route recorded on the root span and falls
+// back to the span name for non-HTTP roots.
+func endpointName(s sdktrace.ReadOnlySpan) string {
+	var method, route string
+	for _, kv := range s.Attributes() {
+		switch {
+		case attrs.IsHTTPMethod(kv.Key):
+			method = kv.Value.AsString()
+		case kv.Key == attrs.HTTPRoute:
+			route = kv.Value.AsString()
+		}
+	}
+	if route == "" {
+		return s.Name()
+	}
+	if method == "" {
+		return route
+	}
+	return method + " " + route
+}
+
+func estimateSpanBytes(s sdktrace.ReadOnlySpan) int {
+	size := spanOverheadBytes + len(s.Name())
+	size += attributesBytes(s.Attributes())
+	for _, event := range s.Events() {
+		size += len(event.Name) + attributesBytes(event.Attributes)
+	}
+	for _, link := range s.Links() {
+		size += attributesBytes(link.Attributes)
+	}
+	return size
+}
+
+func attributesBytes(attrs []attribute.KeyValue) int {
+	size := 0
+	for _, kv := range attrs {
+		size += len(kv.Key) + valueBytes(kv.Value)
+	}
+	return size
+}
+
+func valueBytes(v attribute.Value) int {
+	switch v.Type() {
+	case attribute.BOOL:
+		return 1
+	case attribute.INT64, attribute.FLOAT64:
+		return 8
+	case attribute.STRING:
+		return len(v.AsString())
+	case attribute.BOOLSLICE:
+		return len(v.AsBoolSlice())
+	case attribute.INT64SLICE:
+		return 8 * len(v.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		return 8 * len(v.AsFloat64Slice())
+	case attribute.STRINGSLICE:
+		size := 0
+		for _, str := range v.AsStringSlice() {
+			size += len(str)
+		}
+		return size
+	default:
+		return 0
+	}
+}
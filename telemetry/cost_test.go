@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCostProcessorExpiresPendingTraces(t *testing.T) {
+	costs := NewCostProcessor()
+	now := time.Now()
+	costs.now = func() time.Time { return now }
+	tracer, _ := recordSpans(t, costs)
+
+	// A child that ends after its root leaves a trace that never closes
+	ctx, root := tracer.Start(context.Background(), "GET /late")
+	_, child := tracer.Start(ctx, "background")
+	root.End()
+	child.End()
+	if got := len(costs.pending); got != 1 {
+		t.Fatalf("pending = %d, want the orphaned trace", got)
+	}
+
+	now = now.Add(pendingTraceTTL)
+	_, next := tracer.Start(context.Background(), "GET /next")
+	next.End()
+
+	if got := len(costs.pending); got != 0 {
+		t.Errorf("pending = %d, want the orphan expired", got)
+	}
+	if got := costs.DroppedTraces(); got != 1 {
+		t.Errorf("DroppedTraces = %d, want 1", got)
+	}
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OverflowValue replaces attribute values beyond a Dimensions value cap.
+const OverflowValue = "_other"
+
+// Dimensions limits the attributes a metric is recorded with, so a new
+// middleware or handler attaching request-scoped attributes can't explode
+// its cardinality.
+type Dimensions struct {
+	// Allowed lists the only attributes kept. Others are dropped, which
+	// aggregates their measurements into the remaining series.
+	Allowed []attribute.Key
+	// MaxValues caps the distinct values kept per attribute. Later values
+	// are recorded as OverflowValue. Zero means no cap.
+	MaxValues int
+}
+
+var (
+	guardMeter        = Meter("telemetry-demo/telemetry")
+	droppedAttributes = Int64Counter(guardMeter, "telemetry.metric.dropped_attributes", "{attribute}", "Metric attributes dropped for not being allow-listed, by metric and attribute")
+	overflowedValues  = Int64Counter(guardMeter, "telemetry.metric.overflowed_values", "{value}", "Metric attribute values recorded as _other past the distinct value cap, by metric and attribute")
+)
+
+// dimensionGuard enforces Dimensions for one instrument.
+type dimensionGuard struct {
+	instrument string
+	allowed    map[attribute.Key]bool
+	maxValues  int
+
+	mu      sync.Mutex
+	values  map[attribute.Key]map[string]bool
+	dropped map[attribute.Key]bool
+}
+
+func newDimensionGuard(instrument string, dimensions Dimensions) *dimensionGuard {
+	g := &dimensionGuard{
+		instrument: instrument,
+		allowed:    make(map[attribute.Key]bool, len(dimensions.Allowed)),
+		maxValues:  dimensions.MaxValues,
+		values:     make(map[attribute.Key]map[string]bool),
+		dropped:    make(map[attribute.Key]bool),
+	}
+	for _, key := range dimensions.Allowed {
+		g.allowed[key] = true
+	}
+	return g
+}
+
+// filter returns set without disallowed attributes and with values past
+// the cap replaced, counting both. The first time an attribute is dropped
+// it is logged, pointing whoever added it at the allow-list.
+func (g *dimensionGuard) filter(ctx context.Context, set attribute.Set) attribute.Set {
+	kept := make([]attribute.KeyValue, 0, set.Len())
+	g.mu.Lock()
+	for _, kv := range set.ToSlice() {
+		if !g.allowed[kv.Key] {
+			if !g.dropped[kv.Key] {
+				g.dropped[kv.Key] = true
+				log.Printf("Metric %s dropped attribute %s: not in its allowed dimensions", g.instrument, kv.Key)
+			}
+			droppedAttributes.Add(ctx, 1, g.measurement(kv.Key))
+			continue
+		}
+		if g.maxValues > 0 {
+			seen := g.values[kv.Key]
+			if seen == nil {
+				seen = make(map[string]bool)
+				g.values[kv.Key] = seen
+			}
+			value := kv.Value.Emit()
+			if !seen[value] {
+				if len(seen) >= g.maxValues {
+					overflowedValues.Add(ctx, 1, g.measurement(kv.Key))
+					kv = kv.Key.String(OverflowValue)
+				} else {
+					seen[value] = true
+				}
+			}
+		}
+		kept = append(kept, kv)
+	}
+	g.mu.Unlock()
+	return attribute.NewSet(kept...)
+}
+
+func (g *dimensionGuard) measurement(key attribute.Key) metric.MeasurementOption {
+	return metric.WithAttributes(
+		attribute.String("metric.name", g.instrument),
+		attribute.String("metric.attribute", string(key)),
+	)
+}
+
+// guardedHistogram records through a dimensionGuard.
+type guardedHistogram struct {
+	metric.Float64Histogram
+	guard *dimensionGuard
+}
+
+func (h guardedHistogram) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	set := h.guard.filter(ctx, metric.NewRecordConfig(options).Attributes())
+	h.Float64Histogram.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+// GuardedFloat64Histogram creates a histogram like Float64Histogram whose
+// measurements only keep the attributes dimensions allows.
+func GuardedFloat64Histogram(meter metric.Meter, name, unit, description string, dimensions Dimensions) metric.Float64Histogram {
+	return guardedHistogram{
+		Float64Histogram: Float64Histogram(meter, name, unit, description),
+		guard:            newDimensionGuard(name, dimensions),
+	}
+}
@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// ErrorCategory is the broad class of a failure, recorded as
+// error.category. Dashboards and alerts group by category without having
+// to know every error.type.
+type ErrorCategory string
+
+const (
+	// CategoryValidation is a request the client got wrong.
+	CategoryValidation ErrorCategory = "validation"
+	// CategoryNotFound is a request for something that doesn't exist.
+	CategoryNotFound ErrorCategory = "not_found"
+	// CategoryConflict is a request that clashes with current state.
+	CategoryConflict ErrorCategory = "conflict"
+	// CategoryBackendUnavailable is a dependency or the server itself
+	// refusing work for now; retrying later may succeed.
+	CategoryBackendUnavailable ErrorCategory = "backend_unavailable"
+	// CategoryInternal is a bug or an unexpected failure.
+	CategoryInternal ErrorCategory = "internal"
+)
+
+// ErrorType is a specific failure, recorded as error.type. Every type
+// belongs to one category.
+type ErrorType string
+
+const (
+	ErrorParsing         ErrorType = "parsing_error"
+	ErrorValidation      ErrorType = "validation_error"
+	ErrorUnauthenticated ErrorType = "unauthenticated"
+	ErrorNotFound        ErrorType = "not_found"
+	ErrorConflict        ErrorType = "conflict"
+	ErrorReadOnly        ErrorType = "read_only_mode"
+	ErrorQueueFull       ErrorType = "queue_full"
+	ErrorOverloaded      ErrorType = "overloaded"
+	ErrorBudgetExhausted ErrorType = "budget_exhausted"
+	ErrorTimeout         ErrorType = "timeout"
+	ErrorUnavailable     ErrorType = "unavailable"
+	ErrorInternal        ErrorType = "internal_error"
+)
+
+var errorCategories = map[ErrorType]ErrorCategory{
+	ErrorParsing:         CategoryValidation,
+	ErrorValidation:      CategoryValidation,
+	ErrorUnauthenticated: CategoryValidation,
+	ErrorNotFound:        CategoryNotFound,
+	ErrorConflict:        CategoryConflict,
+	ErrorReadOnly:        CategoryBackendUnavailable,
+	ErrorQueueFull:       CategoryBackendUnavailable,
+	ErrorOverloaded:      CategoryBackendUnavailable,
+	ErrorBudgetExhausted: CategoryBackendUnavailable,
+	ErrorTimeout:         CategoryBackendUnavailable,
+	ErrorUnavailable:     CategoryBackendUnavailable,
+	ErrorInternal:        CategoryInternal,
+}
+
+// Category returns the category t belongs to. Unknown types are internal.
+func (t ErrorType) Category() ErrorCategory {
+	if category, ok := errorCategories[t]; ok {
+		return category
+	}
+	return CategoryInternal
+}
+
+// ClassifyError records t as error.type and its category as
+// error.category on span. It leaves the status alone: client errors keep
+// an Unset status, and FailSpan marks server errors.
+func ClassifyError(span trace.Span, t ErrorType) {
+	span.SetAttributes(
+		attrs.ErrorType.String(string(t)),
+		attrs.ErrorCategory.String(string(t.Category())),
+	)
+}
+
+// ErrorFields returns t and its category as log fields, so log lines
+// classify a failure the same way its span does. The map can be passed as
+// logging.Fields or logrus.Fields.
+func ErrorFields(t ErrorType) map[string]any {
+	return map[string]any{
+		"error_type":     string(t),
+		"error_category": string(t.Category()),
+	}
+}
+
+// classifiedError is an error marked with its ErrorType.
+type classifiedError struct {
+	error
+	errorType ErrorType
+}
+
+func (e classifiedError) Unwrap() error { return e.error }
+
+// WithErrorType marks err as t, so FailSpan and ErrorTypeOf classify it
+// wherever it ends up. errors.Is and errors.As still see err.
+func WithErrorType(err error, t ErrorType) error {
+	if err == nil {
+		return nil
+	}
+	return classifiedError{error: err, errorType: t}
+}
+
+// ErrorTypeOf returns the type err was marked with by WithErrorType.
+// Unmarked errors are timeouts when they wrap context.DeadlineExceeded, and
+// internal otherwise.
+func ErrorTypeOf(err error) ErrorType {
+	var classified classifiedError
+	switch {
+	case errors.As(err, &classified):
+		return classified.errorType
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	default:
+		return ErrorInternal
+	}
+}
@@ -0,0 +1,234 @@
+package telemetry
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultBucketBounds are the SDK's default explicit histogram buckets.
+var defaultBucketBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// Histograms created with Float64Histogram remember, for every series, the
+// latest measurement in each bucket that was made inside a sampled span.
+// Exporters attach them to histogram data points as exemplars, so a latency
+// bucket links straight to a trace that landed in it.
+var exemplars = &exemplarStore{bounds: defaultBucketBounds, series: make(map[exemplarKey]*exemplarSeries)}
+
+type exemplarKey struct {
+	instrument string
+	attributes attribute.Distinct
+}
+
+type exemplarSeries struct {
+	instrument string
+	attributes attribute.Set
+	// buckets is indexed by the store's bounds
+	buckets map[int]metricdata.Exemplar[float64]
+}
+
+type exemplarStore struct {
+	mu sync.Mutex
+	// bounds file exemplars as they are recorded: the latency histogram
+	// buckets, so each exported bucket has its own exemplar
+	bounds []float64
+	series map[exemplarKey]*exemplarSeries
+}
+
+// setExemplarBounds files exemplars by the configured latency buckets.
+func setExemplarBounds(bounds []float64) {
+	exemplars.mu.Lock()
+	defer exemplars.mu.Unlock()
+
+	exemplars.bounds = bounds
+	exemplars.series = make(map[exemplarKey]*exemplarSeries)
+}
+
+func (s *exemplarStore) offer(instrument string, attributes attribute.Set, value float64, span trace.SpanContext) {
+	traceID, spanID := span.TraceID(), span.SpanID()
+	sample := metricdata.Exemplar[float64]{
+		Time:    time.Now(),
+		Value:   value,
+		TraceID: traceID[:],
+		SpanID:  spanID[:],
+	}
+
+	key := exemplarKey{instrument: instrument, attributes: attributes.Equivalent()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.series[key]
+	if !ok {
+		series = &exemplarSeries{instrument: instrument, attributes: attributes, buckets: make(map[int]metricdata.Exemplar[float64])}
+		s.series[key] = series
+	}
+	series.buckets[sort.SearchFloat64s(s.bounds, value)] = sample
+}
+
+// forDataPoint returns the latest exemplar in each of a data point's
+// buckets, which may be bounded differently than the buckets they were
+// filed under. Nil bounds keeps the buckets they were filed under, for
+// exponential histograms.
+func (s *exemplarStore) forDataPoint(instrument string, attributes attribute.Set, bounds []float64) []metricdata.Exemplar[float64] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bounds == nil {
+		bounds = s.bounds
+	}
+	series, ok := s.series[exemplarKey{instrument: instrument, attributes: attributes.Equivalent()}]
+	if !ok {
+		return nil
+	}
+	latest := make(map[int]metricdata.Exemplar[float64], len(series.buckets))
+	for _, sample := range series.buckets {
+		bucket := sort.SearchFloat64s(bounds, sample.Value)
+		if current, ok := latest[bucket]; !ok || sample.Time.After(current.Time) {
+			latest[bucket] = sample
+		}
+	}
+	buckets := make([]int, 0, len(latest))
+	for bucket := range latest {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	out := make([]metricdata.Exemplar[float64], len(buckets))
+	for i, bucket := range buckets {
+		out[i] = latest[bucket]
+	}
+	return out
+}
+
+// exemplarHistogram files sampled measurements as exemplars.
+type exemplarHistogram struct {
+	metric.Float64Histogram
+	name string
+}
+
+func (h exemplarHistogram) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	h.Float64Histogram.Record(ctx, value, options...)
+
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsSampled() {
+		return
+	}
+	exemplars.offer(h.name, metric.NewRecordConfig(options).Attributes(), value, span)
+}
+
+// exemplarExporter attaches exemplars to float64 histogram data points on
+// their way to the wrapped exporter. Each point gets the latest exemplar
+// per bucket, so a rarely hit slow bucket keeps linking to its trace.
+type exemplarExporter struct {
+	sdkmetric.Exporter
+}
+
+func (e exemplarExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for i := range rm.ScopeMetrics {
+		for _, m := range rm.ScopeMetrics[i].Metrics {
+			switch histogram := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				for j := range histogram.DataPoints {
+					point := &histogram.DataPoints[j]
+					point.Exemplars = exemplars.forDataPoint(m.Name, point.Attributes, point.Bounds)
+				}
+			case metricdata.ExponentialHistogram[float64]:
+				for j := range histogram.DataPoints {
+					point := &histogram.DataPoints[j]
+					point.Exemplars = exemplars.forDataPoint(m.Name, point.Attributes, nil)
+				}
+			}
+		}
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+// ExemplarBucket is the latest sampled measurement in one histogram bucket.
+type ExemplarBucket struct {
+	// LessOrEqual is the bucket's upper bound, "+Inf" for the last one.
+	LessOrEqual string    `json:"le"`
+	Value       float64   `json:"value"`
+	TraceID     string    `json:"trace_id"`
+	SpanID      string    `json:"span_id"`
+	Time        time.Time `json:"time"`
+}
+
+// ExemplarSeries lists the exemplars of one histogram series.
+type ExemplarSeries struct {
+	Instrument string            `json:"instrument"`
+	Attributes map[string]string `json:"attributes"`
+	Buckets    []ExemplarBucket  `json:"buckets"`
+}
+
+// TraceExemplars lists the histogram series with a current exemplar from
+// traceID, keeping only those exemplars.
+func TraceExemplars(traceID trace.TraceID) []ExemplarSeries {
+	id := traceID.String()
+	var matched []ExemplarSeries
+	for _, series := range ExemplarReport("") {
+		var buckets []ExemplarBucket
+		for _, bucket := range series.Buckets {
+			if bucket.TraceID == id {
+				buckets = append(buckets, bucket)
+			}
+		}
+		if len(buckets) > 0 {
+			series.Buckets = buckets
+			matched = append(matched, series)
+		}
+	}
+	return matched
+}
+
+// ExemplarReport lists the current exemplars of every histogram series,
+// or only those of instrument when it isn't empty.
+func ExemplarReport(instrument string) []ExemplarSeries {
+	exemplars.mu.Lock()
+	bounds := exemplars.bounds
+	all := make([]*exemplarSeries, 0, len(exemplars.series))
+	for _, series := range exemplars.series {
+		if instrument == "" || series.instrument == instrument {
+			all = append(all, series)
+		}
+	}
+	exemplars.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].instrument != all[j].instrument {
+			return all[i].instrument < all[j].instrument
+		}
+		return all[i].attributes.Encoded(attribute.DefaultEncoder()) < all[j].attributes.Encoded(attribute.DefaultEncoder())
+	})
+
+	report := make([]ExemplarSeries, 0, len(all))
+	for _, series := range all {
+		entry := ExemplarSeries{Instrument: series.instrument, Attributes: make(map[string]string)}
+		for _, kv := range series.attributes.ToSlice() {
+			entry.Attributes[string(kv.Key)] = kv.Value.Emit()
+		}
+		for _, sample := range exemplars.forDataPoint(series.instrument, series.attributes, bounds) {
+			upper := math.Inf(1)
+			if bucket := sort.SearchFloat64s(bounds, sample.Value); bucket < len(bounds) {
+				upper = bounds[bucket]
+			}
+			entry.Buckets = append(entry.Buckets, ExemplarBucket{
+				LessOrEqual: strconv.FormatFloat(upper, 'g', -1, 64),
+				Value:       sample.Value,
+				TraceID:     trace.TraceID(sample.TraceID).String(),
+				SpanID:      trace.SpanID(sample.SpanID).String(),
+				Time:        sample.Time,
+			})
+		}
+		report = append(report, entry)
+	}
+	return report
+}
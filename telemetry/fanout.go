@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanFilter chooses which finished spans an exporter receives.
+type SpanFilter string
+
+const (
+	// FilterAll sends every span.
+	FilterAll SpanFilter = "all"
+	// FilterErrors sends only spans whose status is Error.
+	FilterErrors SpanFilter = "errors"
+	// FilterRoots sends only the first span of each trace in this process,
+	// usually the request's server span.
+	FilterRoots SpanFilter = "roots"
+)
+
+func (f SpanFilter) keep(s sdktrace.ReadOnlySpan) bool {
+	switch f {
+	case FilterErrors:
+		return s.Status().Code == codes.Error
+	case FilterRoots:
+		return !s.Parent().IsValid() || s.Parent().IsRemote()
+	default:
+		return true
+	}
+}
+
+var fanOutFiltered = Int64Counter(Meter("telemetry-demo/fanout"), "span.fanout.filtered", "{span}",
+	"Finished spans an exporter's filter kept from it, by exporter and filter")
+
+// fanOutRoute is one exporter's processor and the spans it accepts.
+type fanOutRoute struct {
+	exporter ExporterKind
+	filter   SpanFilter
+	next     sdktrace.SpanProcessor
+	// filtered and enqueued are the pre-built attribute sets for
+	// fanOutFiltered and exportEnqueued
+	filtered attribute.Set
+	enqueued attribute.Set
+	counts   *exportCounts
+}
+
+// fanOutProcessor hands each finished span to every exporter whose filter
+// keeps it, so one backend can get only errors while another gets
+// everything. Init places it in front of the exporters' processors, after
+// redaction and tail sampling have had their say.
+type fanOutProcessor struct {
+	routes []fanOutRoute
+}
+
+func newFanOutProcessor(routes []fanOutRoute) *fanOutProcessor {
+	for i := range routes {
+		routes[i].filtered = attribute.NewSet(
+			attribute.String("exporter", string(routes[i].exporter)),
+			attribute.String("filter", string(routes[i].filter)),
+		)
+		routes[i].enqueued = attribute.NewSet(attribute.String("exporter", string(routes[i].exporter)))
+	}
+	return &fanOutProcessor{routes: routes}
+}
+
+func (p *fanOutProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, route := range p.routes {
+		route.next.OnStart(parent, s)
+	}
+}
+
+func (p *fanOutProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, route := range p.routes {
+		if !route.filter.keep(s) {
+			fanOutFiltered.Add(context.Background(), 1, metric.WithAttributeSet(route.filtered))
+			continue
+		}
+		// Batch processors ignore unsampled spans
+		if s.SpanContext().IsSampled() {
+			route.counts.enqueued.Add(1)
+			exportEnqueued.Add(context.Background(), 1, metric.WithAttributeSet(route.enqueued))
+		}
+		route.next.OnEnd(s)
+	}
+}
+
+func (p *fanOutProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, route := range p.routes {
+		errs = append(errs, route.next.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *fanOutProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, route := range p.routes {
+		errs = append(errs, route.next.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
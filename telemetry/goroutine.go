@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// GoroutineStats counts the goroutines started with Go under one name.
+type GoroutineStats struct {
+	Name     string `json:"name"`
+	Running  int64  `json:"running"`
+	Started  int64  `json:"started"`
+	Panicked int64  `json:"panicked"`
+}
+
+var goroutines = struct {
+	mu    sync.Mutex
+	stats map[string]*GoroutineStats
+}{stats: make(map[string]*GoroutineStats)}
+
+// Go runs fn in a new goroutine with ctx, so spans fn starts stay in ctx's
+// trace. A panic in fn is recovered instead of crashing the server: it is
+// recorded as an exception on ctx's span, with the stack, and logged. name
+// groups goroutines in GoroutineReport, e.g. "cache.cleanup".
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	goroutines.mu.Lock()
+	stats, ok := goroutines.stats[name]
+	if !ok {
+		stats = &GoroutineStats{Name: name}
+		goroutines.stats[name] = stats
+	}
+	stats.Started++
+	stats.Running++
+	goroutines.mu.Unlock()
+
+	go func() {
+		defer func() {
+			recovered := recover()
+
+			goroutines.mu.Lock()
+			stats.Running--
+			if recovered != nil {
+				stats.Panicked++
+			}
+			goroutines.mu.Unlock()
+
+			if recovered == nil {
+				return
+			}
+			err := fmt.Errorf("goroutine %s panicked: %v", name, recovered)
+			stack := string(debug.Stack())
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err, trace.WithAttributes(attrs.CodeStacktrace.String(stack)))
+			span.SetStatus(codes.Error, err.Error())
+			log.Printf("%v\n%s", err, stack)
+		}()
+
+		fn(ctx)
+	}()
+}
+
+// GoroutineReport returns the counts for every name passed to Go, in name
+// order.
+func GoroutineReport() []GoroutineStats {
+	goroutines.mu.Lock()
+	report := make([]GoroutineStats, 0, len(goroutines.stats))
+	for _, stats := range goroutines.stats {
+		report = append(report, *stats)
+	}
+	goroutines.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
@@ -0,0 +1,269 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricExporterKind selects where metrics are sent.
+type MetricExporterKind string
+
+const (
+	MetricExporterStdout   MetricExporterKind = "stdout"
+	MetricExporterOTLPHTTP MetricExporterKind = "otlphttp"
+)
+
+// DefaultMetricInterval is how often metrics are collected and exported.
+const DefaultMetricInterval = time.Minute
+
+// MetricsConfig selects the metric backends InitMeter configures.
+type MetricsConfig struct {
+	// Exporters lists the backends metrics are sent to. Empty means the
+	// ones named by OTEL_METRICS_EXPORTER, then OTLP/HTTP when
+	// OTEL_EXPORTER_OTLP_ENDPOINT is set. With none, instruments still
+	// work but nothing is exported.
+	Exporters []MetricExporterKind
+	// OTLPEndpoint is the collector base URL for MetricExporterOTLPHTTP.
+	// Empty means OTEL_EXPORTER_OTLP_ENDPOINT, then DefaultOTLPEndpoint.
+	OTLPEndpoint string
+	// OTLPHeaders are sent with every OTLP request, e.g. an API key.
+	OTLPHeaders map[string]string
+	// Interval is how often metrics are exported. Zero means
+	// OTEL_METRIC_EXPORT_INTERVAL, then DefaultMetricInterval.
+	Interval time.Duration
+	// ServiceName reports metrics under this name instead of
+	// OTEL_SERVICE_NAME or the default telemetry-demo.
+	ServiceName string
+	// Resource adds attributes describing this process to every metric.
+	Resource []attribute.KeyValue
+	// Histograms aggregates latency histograms, those recorded in ms.
+	// Empty means OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION,
+	// then explicit buckets.
+	Histograms HistogramAggregation
+	// LatencyBuckets are the explicit bucket bounds of latency histograms.
+	// Empty means the SDK's defaults.
+	LatencyBuckets []float64
+}
+
+// HistogramAggregation selects how histograms bucket their measurements,
+// named as in the OTLP exporter specification.
+type HistogramAggregation string
+
+const (
+	// HistogramExplicit counts measurements in fixed buckets. p99 can only
+	// be estimated as well as the buckets around it are placed.
+	HistogramExplicit HistogramAggregation = "explicit_bucket_histogram"
+	// HistogramExponential sizes buckets to the measurements, keeping the
+	// relative error of every percentile bounded whatever their range.
+	HistogramExponential HistogramAggregation = "base2_exponential_bucket_histogram"
+)
+
+// exponentialMaxSize is the bucket budget of exponential histograms, the
+// SDK's default.
+const exponentialMaxSize = 160
+
+// InitMeter installs the global MeterProvider alongside the tracer's. The
+// returned function flushes and stops it.
+func InitMeter(config MetricsConfig) func() {
+	envEndpoint := otlpEndpointFromEnv()
+	kinds := config.Exporters
+	if len(kinds) == 0 {
+		kinds = metricExportersFromEnv()
+	}
+	// Metrics are only exported over OTLP/HTTP, so a gRPC endpoint alone
+	// doesn't turn them on
+	if len(kinds) == 0 && envEndpoint != "" && otlpExporterFromEnv() == ExporterOTLPHTTP {
+		kinds = []MetricExporterKind{MetricExporterOTLPHTTP}
+	}
+
+	interval := config.Interval
+	if interval <= 0 {
+		interval = metricIntervalFromEnv()
+	}
+
+	name := config.ServiceName
+	if name == "" {
+		name = serviceNameFromEnv()
+	}
+	res, err := serviceResource(context.Background(), name, config.Resource...)
+	if err != nil {
+		log.Printf("Failed to create metrics resource: %v", err)
+		return func() {}
+	}
+	options := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	configured := MetricSettings{Exporters: []MetricExporterKind{}}
+
+	// Latency histograms are the ones recorded in ms
+	histograms := config.Histograms
+	if histograms == "" {
+		histograms = histogramAggregationFromEnv()
+	}
+	if histograms == "" {
+		histograms = HistogramExplicit
+	}
+	latency := sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram, Unit: "ms"}
+	switch {
+	case histograms == HistogramExponential:
+		options = append(options, sdkmetric.WithView(sdkmetric.NewView(latency, sdkmetric.Stream{
+			Aggregation: sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: exponentialMaxSize, MaxScale: 20},
+		})))
+		if len(config.LatencyBuckets) > 0 {
+			log.Printf("Latency buckets ignored: exponential histograms size their own")
+		}
+		log.Printf("🪣 Latency histograms use exponential buckets")
+	case len(config.LatencyBuckets) > 0:
+		options = append(options, sdkmetric.WithView(sdkmetric.NewView(latency, sdkmetric.Stream{
+			Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: config.LatencyBuckets},
+		})))
+		setExemplarBounds(config.LatencyBuckets)
+		configured.LatencyBuckets = config.LatencyBuckets
+		log.Printf("🪣 Latency histogram buckets: %v ms", config.LatencyBuckets)
+	}
+	configured.Histograms = histograms
+
+	for _, kind := range kinds {
+		var exporter sdkmetric.Exporter
+		switch kind {
+		case MetricExporterStdout:
+			exporter, err = stdoutmetric.New(stdoutmetric.WithWriter(os.Stdout))
+			if err != nil {
+				log.Printf("Failed to create stdout metric exporter: %v", err)
+				continue
+			}
+			log.Printf("📈 Stdout metric exporter configured - every %s", interval)
+
+		case MetricExporterOTLPHTTP:
+			endpoint := config.OTLPEndpoint
+			if endpoint == "" {
+				endpoint = envEndpoint
+			}
+			if endpoint == "" {
+				endpoint = DefaultOTLPEndpoint
+			}
+			exporter, err = newOTLPMetricExporter(endpoint, config.OTLPHeaders)
+			if err != nil {
+				log.Printf("Failed to create OTLP/HTTP metric exporter: %v", err)
+				continue
+			}
+			log.Printf("📈 OTLP/HTTP metric exporter configured - metrics sent to %s every %s", endpoint, interval)
+			configured.OTLPEndpoint = endpoint
+			configured.OTLPHeaderNames = headerNames(config.OTLPHeaders)
+
+		default:
+			log.Printf("Unknown metric exporter %q ignored", kind)
+			continue
+		}
+		exporter = exemplarExporter{Exporter: exporter}
+		options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))))
+		configured.Exporters = append(configured.Exporters, kind)
+	}
+	if len(configured.Exporters) > 0 {
+		configured.IntervalMs = interval.Milliseconds()
+	}
+	if len(options) == 1 {
+		log.Println("📈 No metric exporters configured - metrics are recorded but not exported")
+	}
+
+	mp := sdkmetric.NewMeterProvider(options...)
+	otel.SetMeterProvider(mp)
+	updateSettings(func(s *Settings) { s.Metrics = configured })
+
+	return func() {
+		if err := mp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down meter provider: %v", err)
+		}
+	}
+}
+
+// newOTLPMetricExporter posts metrics to endpoint + /v1/metrics, the same
+// collector base URL the OTLP trace exporter uses.
+func newOTLPMetricExporter(endpoint string, headers map[string]string) (sdkmetric.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(path, "/v1/metrics") {
+		path += "/v1/metrics"
+	}
+	options := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(u.Host),
+		otlpmetrichttp.WithURLPath(path),
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlpmetrichttp.WithInsecure())
+	}
+	if len(headers) > 0 {
+		options = append(options, otlpmetrichttp.WithHeaders(headers))
+	}
+	return otlpmetrichttp.New(context.Background(), options...)
+}
+
+// Meter returns a meter from the global MeterProvider. Instruments created
+// before InitMeter runs start recording once it does.
+func Meter(name string) metric.Meter {
+	return otel.Meter(name)
+}
+
+// Int64Counter creates a counter, logging and falling back to a no-op
+// counter on error so callers can create instruments in package variables.
+func Int64Counter(meter metric.Meter, name, unit, description string) metric.Int64Counter {
+	counter, err := meter.Int64Counter(name, metric.WithUnit(unit), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create counter %s: %v", name, err)
+		return noop.Int64Counter{}
+	}
+	return counter
+}
+
+// Float64Counter creates a counter of fractional amounts such as
+// milliseconds, logging and falling back to a no-op counter on error.
+func Float64Counter(meter metric.Meter, name, unit, description string) metric.Float64Counter {
+	counter, err := meter.Float64Counter(name, metric.WithUnit(unit), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create counter %s: %v", name, err)
+		return noop.Float64Counter{}
+	}
+	return counter
+}
+
+// Float64Histogram creates a histogram, logging and falling back to a
+// no-op histogram on error. Measurements recorded inside a sampled span are
+// kept as exemplars linking the histogram to traces.
+func Float64Histogram(meter metric.Meter, name, unit, description string) metric.Float64Histogram {
+	histogram, err := meter.Float64Histogram(name, metric.WithUnit(unit), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create histogram %s: %v", name, err)
+		return noop.Float64Histogram{}
+	}
+	return exemplarHistogram{Float64Histogram: histogram, name: name}
+}
+
+// Int64ObservableGauge creates a gauge read through callbacks registered on
+// meter, logging and falling back to a no-op gauge on error.
+func Int64ObservableGauge(meter metric.Meter, name, unit, description string) metric.Int64ObservableGauge {
+	gauge, err := meter.Int64ObservableGauge(name, metric.WithUnit(unit), metric.WithDescription(description))
+	if err != nil {
+		log.Printf("Failed to create gauge %s: %v", name, err)
+		return noop.Int64ObservableGauge{}
+	}
+	return gauge
+}
+
+// Milliseconds converts d for histograms recorded in ms.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// Milestone span events. Recorded inside a span, they show where in its
+// duration something happened, e.g. how much of a lookup passed before the
+// row was loaded.
+const (
+	EventCacheHit            = "cache.hit"
+	EventCacheMiss           = "cache.miss"
+	EventCacheStored         = "cache.stored"
+	EventRowLoaded           = "db.row_loaded"
+	EventRowNotFound         = "db.row_not_found"
+	EventRowsLoaded          = "db.rows_loaded"
+	EventRowWritten          = "db.row_written"
+	EventRowDeleted          = "db.row_deleted"
+	EventValidationCompleted = "validation.completed"
+)
+
+// Milestone records a span event named name, stamped now. The helpers below
+// cover the events with attributes of their own.
+func Milestone(span trace.Span, name string, attributes ...attribute.KeyValue) {
+	span.AddEvent(name, trace.WithTimestamp(time.Now()), trace.WithAttributes(attributes...))
+}
+
+// CacheHit records that cache had key.
+func CacheHit(span trace.Span, cache, key string) {
+	Milestone(span, EventCacheHit, attrs.CacheName(cache), attribute.String("cache.key", key))
+}
+
+// CacheMiss records that cache didn't have key, and why: absent or
+// expired.
+func CacheMiss(span trace.Span, cache, key, reason string) {
+	Milestone(span, EventCacheMiss, attrs.CacheName(cache), attribute.String("cache.key", key), attribute.String("cache.miss_reason", reason))
+}
+
+// CacheStored records that cache now holds key for ttl.
+func CacheStored(span trace.Span, cache, key string, ttl time.Duration) {
+	Milestone(span, EventCacheStored, attrs.CacheName(cache), attribute.String("cache.key", key), attribute.Int64("cache.ttl_ms", ttl.Milliseconds()))
+}
+
+// RowLoaded records that the subscriber with id was read, or that there
+// is none.
+func RowLoaded(span trace.Span, id int, found bool) {
+	if !found {
+		Milestone(span, EventRowNotFound, attrs.SubscriberID(id))
+		return
+	}
+	Milestone(span, EventRowLoaded, attrs.SubscriberID(id))
+}
+
+// RowsLoaded records that a query returned count rows.
+func RowsLoaded(span trace.Span, count int) {
+	Milestone(span, EventRowsLoaded, attribute.Int("db.rows", count))
+}
+
+// RowWritten records that the subscriber with id was stored.
+func RowWritten(span trace.Span, id int) {
+	Milestone(span, EventRowWritten, attrs.SubscriberID(id))
+}
+
+// RowDeleted records that the subscriber with id was removed.
+func RowDeleted(span trace.Span, id int) {
+	Milestone(span, EventRowDeleted, attrs.SubscriberID(id))
+}
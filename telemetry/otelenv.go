@@ -0,0 +1,164 @@
+package telemetry
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Standard OpenTelemetry SDK environment variables. They let the same binary
+// be pointed at another backend, renamed, or sampled differently without
+// code changes. Invalid values are logged and ignored, like
+// TRACE_SLOW_SPAN_THRESHOLD.
+const (
+	otlpEndpointEnv  = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpProtocolEnv  = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	serviceNameEnv   = "OTEL_SERVICE_NAME"
+	tracesSamplerEnv = "OTEL_TRACES_SAMPLER"
+	samplerArgEnv    = "OTEL_TRACES_SAMPLER_ARG"
+	metricsExportEnv = "OTEL_METRICS_EXPORTER"
+	metricPeriodEnv  = "OTEL_METRIC_EXPORT_INTERVAL"
+	histogramEnv     = "OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION"
+	propagatorsEnv   = "OTEL_PROPAGATORS"
+)
+
+// otlpEndpointFromEnv returns the collector URL from the environment, or ""
+// when it is unset or not an absolute http(s) URL.
+func otlpEndpointFromEnv() string {
+	value := strings.TrimSpace(os.Getenv(otlpEndpointEnv))
+	if value == "" {
+		return ""
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Printf("Ignoring invalid %s=%q: expected an http or https URL", otlpEndpointEnv, value)
+		return ""
+	}
+	return value
+}
+
+// otlpExporterFromEnv picks the OTLP exporter for
+// OTEL_EXPORTER_OTLP_PROTOCOL: gRPC for grpc, HTTP for the http/
+// protocols or when it is unset.
+func otlpExporterFromEnv() ExporterKind {
+	switch value := strings.ToLower(strings.TrimSpace(os.Getenv(otlpProtocolEnv))); value {
+	case "grpc":
+		return ExporterOTLPGRPC
+	case "", "http/protobuf", "http/json":
+		return ExporterOTLPHTTP
+	default:
+		log.Printf("Ignoring unknown %s=%q: expected grpc or http/protobuf", otlpProtocolEnv, value)
+		return ExporterOTLPHTTP
+	}
+}
+
+// serviceNameFromEnv returns the service name spans are reported under.
+func serviceNameFromEnv() string {
+	if value := strings.TrimSpace(os.Getenv(serviceNameEnv)); value != "" {
+		return value
+	}
+	return serviceName
+}
+
+// samplingFromEnv reads the strategy named by OTEL_TRACES_SAMPLER. ok is
+// false when the variable is unset or unknown, leaving the configured
+// strategy in place. Only the parentbased_ variants follow the parent's
+// decision, as the specification defines.
+func samplingFromEnv() (config SamplingConfig, ok bool) {
+	name := SamplingStrategy(strings.ToLower(strings.TrimSpace(os.Getenv(tracesSamplerEnv))))
+	switch name {
+	case "":
+		return SamplingConfig{}, false
+	case SamplingAlwaysOn, SamplingAlwaysOff, SamplingParentBasedAlwaysOn, SamplingParentBasedAlwaysOff:
+		return SamplingConfig{Strategy: name}, true
+	case SamplingRatio, SamplingParentBasedRatio:
+		return SamplingConfig{Strategy: name, Ratio: samplerRatioFromEnv()}, true
+	default:
+		log.Printf("Ignoring unknown %s=%q", tracesSamplerEnv, name)
+		return SamplingConfig{}, false
+	}
+}
+
+// samplerRatioFromEnv reads the traceidratio probability, defaulting to 1
+// as the specification requires.
+func samplerRatioFromEnv() float64 {
+	value := strings.TrimSpace(os.Getenv(samplerArgEnv))
+	if value == "" {
+		return 1
+	}
+
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		log.Printf("Ignoring invalid %s=%q: expected a number from 0 to 1", samplerArgEnv, value)
+		return 1
+	}
+	return ratio
+}
+
+// metricExportersFromEnv maps OTEL_METRICS_EXPORTER's otlp, console, and
+// none onto metric exporters. Unknown names are logged and skipped.
+func metricExportersFromEnv() []MetricExporterKind {
+	var kinds []MetricExporterKind
+	for _, name := range strings.Split(os.Getenv(metricsExportEnv), ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "", "none":
+		case "otlp":
+			kinds = append(kinds, MetricExporterOTLPHTTP)
+		case "console":
+			kinds = append(kinds, MetricExporterStdout)
+		default:
+			log.Printf("Ignoring unknown %s entry %q: expected otlp, console, or none", metricsExportEnv, name)
+		}
+	}
+	return kinds
+}
+
+// metricIntervalFromEnv reads the export interval in milliseconds, as the
+// specification defines it.
+func metricIntervalFromEnv() time.Duration {
+	value := strings.TrimSpace(os.Getenv(metricPeriodEnv))
+	if value == "" {
+		return DefaultMetricInterval
+	}
+
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		log.Printf("Ignoring invalid %s=%q: expected a positive number of milliseconds", metricPeriodEnv, value)
+		return DefaultMetricInterval
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// histogramAggregationFromEnv reads the histogram aggregation, "" when it
+// is unset or invalid.
+func histogramAggregationFromEnv() HistogramAggregation {
+	value := HistogramAggregation(strings.ToLower(strings.TrimSpace(os.Getenv(histogramEnv))))
+	switch value {
+	case "", HistogramExplicit, HistogramExponential:
+		return value
+	}
+	log.Printf("Ignoring unknown %s=%q: expected %s or %s", histogramEnv, value, HistogramExplicit, HistogramExponential)
+	return ""
+}
+
+// propagatorsFromEnv reads the comma-separated propagator names. ok is false
+// when the variable is unset, leaving the defaults in place; "none" turns
+// propagation off.
+func propagatorsFromEnv() (kinds []PropagatorKind, ok bool) {
+	value := strings.TrimSpace(os.Getenv(propagatorsEnv))
+	if value == "" {
+		return nil, false
+	}
+	for _, name := range strings.Split(value, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "", "none":
+		default:
+			kinds = append(kinds, PropagatorKind(name))
+		}
+	}
+	return kinds, true
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultOTLPGRPCEndpoint is the OpenTelemetry Collector's OTLP/gRPC port.
+const DefaultOTLPGRPCEndpoint = "http://localhost:4317"
+
+// OTLPGRPCConfig configures ExporterOTLPGRPC. Anything left unset falls
+// back to the exporter's own OTEL_EXPORTER_OTLP_* variables, such as
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_EXPORTER_OTLP_CERTIFICATE.
+type OTLPGRPCConfig struct {
+	// Endpoint is the collector URL, e.g. https://collector:4317. An http
+	// URL sends spans without TLS. Empty means OTEL_EXPORTER_OTLP_ENDPOINT,
+	// then DefaultOTLPGRPCEndpoint.
+	Endpoint string
+	// Insecure sends spans without TLS whatever Endpoint's scheme, e.g. to
+	// a collector sidecar on localhost.
+	Insecure bool
+	// CACertFile verifies the collector against the PEM certificates in
+	// this file instead of the system roots.
+	CACertFile string
+	// Headers are sent as gRPC metadata with every export, e.g. an API key.
+	Headers map[string]string
+}
+
+// newOTLPGRPCExporter dials the collector lazily, so a collector that is
+// down at startup doesn't stop the server; exports fail and are counted
+// until it is reachable. Spans are gzip-compressed, and only failures the
+// collector reports as retryable are retried, under retry.
+func newOTLPGRPCExporter(ctx context.Context, config OTLPGRPCConfig, retry RetryConfig) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP/gRPC endpoint %q: expected a URL such as %s", config.Endpoint, DefaultOTLPGRPCEndpoint)
+	}
+
+	options := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(u.Host),
+		otlptracegrpc.WithCompressor("gzip"),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(retry.otlp())),
+	}
+	switch {
+	case config.Insecure || u.Scheme == "http":
+		options = append(options, otlptracegrpc.WithInsecure())
+	case config.CACertFile != "":
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading OTLP CA certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", config.CACertFile)
+		}
+		options = append(options, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots})))
+	}
+	if len(config.Headers) > 0 {
+		options = append(options, otlptracegrpc.WithHeaders(config.Headers))
+	}
+	return otlptracegrpc.New(ctx, options...)
+}
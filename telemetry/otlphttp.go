@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// newOTLPHTTPExporter sends spans to an OpenTelemetry Collector with OTLP
+// over HTTP, where plain HTTPS egress is allowed but gRPC is not, e.g.
+// behind corporate proxies. endpoint is the collector's base URL such as
+// http://localhost:4318; spans are posted gzip-compressed to its
+// /v1/traces. Only failures the collector reports as retryable (429, 502,
+// 503, 504) are retried, under retry, honouring its Retry-After.
+func newOTLPHTTPExporter(ctx context.Context, endpoint string, headers map[string]string, retry RetryConfig) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP/HTTP endpoint %q: expected a URL such as %s", endpoint, DefaultOTLPEndpoint)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(path, "/v1/traces") {
+		path += "/v1/traces"
+	}
+
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(path),
+		otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig(retry.otlp())),
+	}
+	if u.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if len(headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+	return otlptracehttp.New(ctx, options...)
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PropagatorKind names a header format trace context is read from and
+// written to, using OTEL_PROPAGATORS' names.
+type PropagatorKind string
+
+const (
+	PropagatorTraceContext PropagatorKind = "tracecontext"
+	PropagatorBaggage      PropagatorKind = "baggage"
+	// PropagatorB3 reads both B3 encodings and writes the single b3 header
+	PropagatorB3 PropagatorKind = "b3"
+	// PropagatorB3Multi reads both B3 encodings and writes X-B3-* headers
+	PropagatorB3Multi PropagatorKind = "b3multi"
+	PropagatorJaeger  PropagatorKind = "jaeger"
+)
+
+// DefaultPropagators are W3C trace context and baggage.
+var DefaultPropagators = []PropagatorKind{PropagatorTraceContext, PropagatorBaggage}
+
+// newPropagator combines kinds into one propagator. Every format is written
+// on outgoing requests. On incoming ones each is read in order, so when a
+// caller sends several the last one listed wins. Unknown kinds are logged
+// and skipped.
+func newPropagator(kinds []PropagatorKind) (propagation.TextMapPropagator, []PropagatorKind) {
+	var propagators []propagation.TextMapPropagator
+	var configured []PropagatorKind
+	for _, kind := range kinds {
+		switch kind {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		default:
+			log.Printf("Unknown propagator %q ignored", kind)
+			continue
+		}
+		configured = append(configured, kind)
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), configured
+}
+
+// Traceparent formats sc as a W3C traceparent value whatever
+// OTEL_PROPAGATORS says, for carriers other than request headers, such as
+// an HTML meta tag. It is empty when sc is invalid.
+func Traceparent(sc trace.SpanContext) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	return carrier.Get("traceparent")
+}
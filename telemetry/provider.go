@@ -0,0 +1,172 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the tracing pipeline Init installs.
+type Option func(*initConfig)
+
+type initConfig struct {
+	serviceName string
+	export      exportConfig
+	adaptive    trace.Sampler
+	wrap        func(trace.Sampler) trace.Sampler
+	processors  []trace.SpanProcessor
+}
+
+// WithServiceName reports spans under name instead of OTEL_SERVICE_NAME or
+// the default telemetry-demo.
+func WithServiceName(name string) Option {
+	return func(c *initConfig) { c.serviceName = name }
+}
+
+// WithExporter sends spans to the given backends. Without it they go over
+// OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set, using the protocol
+// OTEL_EXPORTER_OTLP_PROTOCOL names, and to Zipkin and Jaeger otherwise.
+func WithExporter(kinds ...ExporterKind) Option {
+	return func(c *initConfig) { c.export.Exporters = append(c.export.Exporters, kinds...) }
+}
+
+// WithExporterFilter sends kind only the spans filter keeps, such as
+// FilterErrors. Exporters without a filter receive every span.
+func WithExporterFilter(kind ExporterKind, filter SpanFilter) Option {
+	return func(c *initConfig) {
+		if c.export.Filters == nil {
+			c.export.Filters = make(map[ExporterKind]SpanFilter)
+		}
+		c.export.Filters[kind] = filter
+	}
+}
+
+// WithOTLP sets the collector base URL for ExporterOTLPHTTP and headers
+// sent with every request, e.g. an API key. An empty endpoint means
+// OTEL_EXPORTER_OTLP_ENDPOINT, then DefaultOTLPEndpoint.
+func WithOTLP(endpoint string, headers map[string]string) Option {
+	return func(c *initConfig) {
+		c.export.OTLPEndpoint = endpoint
+		c.export.OTLPHeaders = headers
+	}
+}
+
+// WithOTLPGRPC configures ExporterOTLPGRPC: the collector endpoint, TLS,
+// and headers sent as gRPC metadata.
+func WithOTLPGRPC(config OTLPGRPCConfig) Option {
+	return func(c *initConfig) { c.export.OTLPGRPC = config }
+}
+
+// WithSampler chooses which traces are exported. An unset strategy means
+// OTEL_TRACES_SAMPLER, then adaptive.
+func WithSampler(sampling SamplingConfig) Option {
+	return func(c *initConfig) { c.export.Sampling = sampling }
+}
+
+// WithAdaptiveSampler is the sampler SamplingAdaptive uses. Without it Init
+// creates an AdaptiveSampler with the default configuration and registers
+// it as a span processor too, so it sees errors. Callers passing their own
+// register it with WithSpanProcessors if it needs to.
+func WithAdaptiveSampler(sampler trace.Sampler) Option {
+	return func(c *initConfig) { c.adaptive = sampler }
+}
+
+// WithSamplerWrapper wraps whichever sampler the sampling strategy chose,
+// e.g. with AnomalyDetector.Sampler so open incidents are captured under
+// any strategy.
+func WithSamplerWrapper(wrap func(trace.Sampler) trace.Sampler) Option {
+	return func(c *initConfig) { c.wrap = wrap }
+}
+
+// WithPropagators reads and writes trace context and baggage in the given
+// header formats instead of OTEL_PROPAGATORS or DefaultPropagators.
+func WithPropagators(kinds ...PropagatorKind) Option {
+	return func(c *initConfig) { c.export.Propagators = append(c.export.Propagators, kinds...) }
+}
+
+// WithResource adds attributes describing this process to every span.
+func WithResource(attributes ...attribute.KeyValue) Option {
+	return func(c *initConfig) { c.export.Resource = append(c.export.Resource, attributes...) }
+}
+
+// WithRedaction rewrites PII attributes before spans reach the exporters.
+// Without it DefaultRedactedAttributes are hashed.
+func WithRedaction(redaction RedactionConfig) Option {
+	return func(c *initConfig) { c.export.Redaction = redaction }
+}
+
+// WithBatching tunes the batch processors feeding the exporters: queue
+// and batch sizes, timeouts, what happens when the queue is full, and how
+// failed exports are retried.
+func WithBatching(batch BatchConfig) Option {
+	return func(c *initConfig) { c.export.Batch = batch }
+}
+
+// WithTailSampler buffers each trace and only passes traces with errors or
+// slow spans on to the exporters.
+func WithTailSampler(sampler *TailSampler) Option {
+	return func(c *initConfig) { c.export.TailSampler = sampler }
+}
+
+// WithSpanProcessors registers in-process processors, such as cost
+// estimation, that see every recorded span alongside the exporters.
+func WithSpanProcessors(processors ...trace.SpanProcessor) Option {
+	return func(c *initConfig) { c.processors = append(c.processors, processors...) }
+}
+
+// Provider is the tracing pipeline Init installed.
+type Provider struct {
+	tp      *trace.TracerProvider
+	exports []*exportCounts
+}
+
+// ForceFlush exports every finished span still waiting in a batch.
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	return p.tp.ForceFlush(ctx)
+}
+
+// Shutdown flushes and stops every exporter. Spans ending afterwards are
+// dropped.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tp.Shutdown(ctx)
+}
+
+// ExportStats reports, per exporter, the spans handed to it and how many
+// were exported, failed, or are unaccounted for.
+func (p *Provider) ExportStats() []ExportStats {
+	stats := make([]ExportStats, len(p.exports))
+	for i, counts := range p.exports {
+		stats[i] = counts.stats()
+	}
+	return stats
+}
+
+// FlushAndShutdown is the graceful end of tracing: it exports every span
+// still queued within timeout, logs per exporter how many spans were
+// exported and how many were dropped since startup, then shuts down. A
+// failed flush is logged rather than returned.
+// Shutdown gets a fresh timeout of its own, so a flush that ran out of
+// time doesn't keep the exporters from being stopped.
+func (p *Provider) FlushAndShutdown(timeout time.Duration) error {
+	flushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	flushErr := p.ForceFlush(flushCtx)
+	cancel()
+	if flushErr != nil {
+		log.Printf("⚠️  Flushing spans: %v", flushErr)
+	}
+
+	for _, stats := range p.ExportStats() {
+		if stats.Unaccounted > 0 || stats.Failed > 0 {
+			log.Printf("⚠️  %s: %d spans exported, %d failed, %d dropped", stats.Exporter, stats.Exported, stats.Failed, stats.Unaccounted)
+			continue
+		}
+		log.Printf("📤 %s: all %d spans exported", stats.Exporter, stats.Exported)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Shutdown(shutdownCtx)
+}
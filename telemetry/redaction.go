@@ -0,0 +1,179 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// RedactionMode is how PII attribute values are rewritten before export.
+type RedactionMode string
+
+const (
+	// RedactHash replaces a value with a short SHA-256 prefix, so spans
+	// about the same person can still be matched up.
+	RedactHash RedactionMode = "hash"
+	// RedactMask keeps only the first character, and an email's domain.
+	RedactMask RedactionMode = "mask"
+	// RedactNone exports values as recorded.
+	RedactNone RedactionMode = "none"
+)
+
+// DefaultRedactedAttributes are the span attributes that carry subscriber
+// email addresses, or a request body that may hold one.
+var DefaultRedactedAttributes = []string{"user.email", "subscriber.email", "validation.email", "request.body"}
+
+// RedactionConfig selects the span attributes treated as PII.
+type RedactionConfig struct {
+	// Attributes lists the keys to redact. Empty means
+	// DefaultRedactedAttributes.
+	Attributes []string
+	// Mode is how their values are rewritten. Empty means RedactHash.
+	Mode RedactionMode
+}
+
+// redactingProcessor rewrites PII attributes of finished spans, and of their
+// events, before handing them to next, the processor feeding an exporter.
+// In-process processors such as the cost estimator still see the original
+// values, but nothing leaves the process with them. The SDK doesn't let a
+// processor change attributes once a span has started, so this wraps the
+// exporters' processors instead of running alongside them.
+type redactingProcessor struct {
+	next sdktrace.SpanProcessor
+	keys map[attribute.Key]bool
+	mode RedactionMode
+}
+
+func newRedactingProcessor(next sdktrace.SpanProcessor, config RedactionConfig) sdktrace.SpanProcessor {
+	p := &redactingProcessor{next: next, keys: make(map[attribute.Key]bool, len(config.Attributes)), mode: config.Mode}
+	for _, key := range config.Attributes {
+		p.keys[attribute.Key(key)] = true
+	}
+	return p
+}
+
+func (p *redactingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *redactingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	attributes, changed := p.redact(s.Attributes())
+	events := s.Events()
+	var redactedEvents []sdktrace.Event
+	for i, event := range events {
+		eventAttributes, eventChanged := p.redact(event.Attributes)
+		if !eventChanged {
+			continue
+		}
+		if redactedEvents == nil {
+			redactedEvents = append([]sdktrace.Event(nil), events...)
+		}
+		redactedEvents[i].Attributes = eventAttributes
+	}
+	if !changed && redactedEvents == nil {
+		p.next.OnEnd(s)
+		return
+	}
+	if redactedEvents == nil {
+		redactedEvents = events
+	}
+	p.next.OnEnd(redactedSpan{ReadOnlySpan: s, attributes: attributes, events: redactedEvents})
+}
+
+func (p *redactingProcessor) Shutdown(ctx context.Context) error { return p.next.Shutdown(ctx) }
+
+func (p *redactingProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
+
+// redact returns attributes with PII values rewritten, and whether any were.
+func (p *redactingProcessor) redact(attributes []attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	var out []attribute.KeyValue
+	for i, kv := range attributes {
+		if !p.keys[kv.Key] {
+			continue
+		}
+		if out == nil {
+			out = append([]attribute.KeyValue(nil), attributes...)
+		}
+		out[i] = kv.Key.String(redactValue(kv.Value.Emit(), p.mode))
+	}
+	if out == nil {
+		return attributes, false
+	}
+	return out, true
+}
+
+// FieldRedactor returns a func rewriting the log fields config covers, for
+// log lines kept in process such as logging.TraceLogs, or nil for
+// RedactNone. A field is covered when its name is a redacted attribute key
+// or that key's last segment, so subscriber.email covers an email field.
+func (c RedactionConfig) FieldRedactor() func(map[string]any) map[string]any {
+	if c.Mode == RedactNone {
+		return nil
+	}
+	mode := c.Mode
+	if mode == "" {
+		mode = RedactHash
+	}
+	attributes := c.Attributes
+	if len(attributes) == 0 {
+		attributes = DefaultRedactedAttributes
+	}
+	names := make(map[string]bool, 2*len(attributes))
+	for _, key := range attributes {
+		names[key] = true
+		names[key[strings.LastIndex(key, ".")+1:]] = true
+	}
+
+	return func(fields map[string]any) map[string]any {
+		var out map[string]any
+		for name, value := range fields {
+			if !names[name] {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]any, len(fields))
+				for k, v := range fields {
+					out[k] = v
+				}
+			}
+			out[name] = redactValue(fmt.Sprint(value), mode)
+		}
+		if out == nil {
+			return fields
+		}
+		return out
+	}
+}
+
+func redactValue(value string, mode RedactionMode) string {
+	if value == "" {
+		return value
+	}
+	if mode == RedactMask {
+		first, _ := utf8.DecodeRuneInString(value)
+		masked := string(first) + "***"
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			masked += value[at:]
+		}
+		return masked
+	}
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactedSpan is a finished span with some attributes replaced.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue { return s.attributes }
+
+func (s redactedSpan) Events() []sdktrace.Event { return s.events }
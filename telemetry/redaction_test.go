@@ -0,0 +1,22 @@
+package telemetry
+
+import "testing"
+
+func TestFieldRedactor(t *testing.T) {
+	fields := map[string]any{"email": "ada@example.com", "name": "Ada", "subscriber_id": 1}
+	redact := RedactionConfig{Mode: RedactMask}.FieldRedactor()
+	got := redact(fields)
+
+	if got["email"] != "a***@example.com" {
+		t.Errorf("email = %v, want it masked", got["email"])
+	}
+	if got["name"] != "Ada" || got["subscriber_id"] != 1 {
+		t.Errorf("fields = %v, want only email rewritten", got)
+	}
+	if fields["email"] != "ada@example.com" {
+		t.Error("FieldRedactor changed the caller's fields")
+	}
+	if (RedactionConfig{Mode: RedactNone}).FieldRedactor() != nil {
+		t.Error("RedactNone should not redact fields")
+	}
+}
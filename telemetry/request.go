@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type rootAttributesKey struct{}
+
+// ContextWithRootAttributes adds attributes that RequestAttributesProcessor
+// will stamp on the next local root span started from ctx. Middleware uses
+// it to tag a request before the span for that request exists.
+func ContextWithRootAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing, _ := ctx.Value(rootAttributesKey{}).([]attribute.KeyValue)
+	combined := make([]attribute.KeyValue, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, rootAttributesKey{}, combined)
+}
+
+// RequestAttributesProcessor stamps request-scoped attributes (client info,
+// user agent, and anything added with ContextWithRootAttributes) onto local
+// root spans at start. Working at span start means both manually created
+// (V1) and middleware created (V2) spans get them without handler changes.
+type RequestAttributesProcessor struct{}
+
+func (RequestAttributesProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if psc := trace.SpanContextFromContext(parent); psc.IsValid() && !psc.IsRemote() {
+		return
+	}
+	if info, ok := ClientInfoFromContext(parent); ok {
+		s.SetAttributes(info.Attributes()...)
+	}
+	if ua, ok := UserAgentFromContext(parent); ok {
+		s.SetAttributes(ua.Attributes()...)
+	}
+	if attrs, ok := parent.Value(rootAttributesKey{}).([]attribute.KeyValue); ok {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (RequestAttributesProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (RequestAttributesProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (RequestAttributesProcessor) ForceFlush(ctx context.Context) error { return nil }
@@ -0,0 +1,218 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AdaptiveSamplerConfig struct {
+	// Window is how long request and error counts accumulate before the
+	// sampling probability for a route is recalculated.
+	Window time.Duration
+	// HighVolumeRPS is the per-route request rate above which healthy
+	// routes are sampled down to roughly this many traces per second.
+	HighVolumeRPS float64
+	// MinProbability is the floor for healthy high-volume routes.
+	MinProbability float64
+	// ErrorRateThreshold is the error ratio at which a route is considered
+	// unhealthy and sampled at 100%.
+	ErrorRateThreshold float64
+}
+
+func DefaultAdaptiveSamplerConfig() AdaptiveSamplerConfig {
+	return AdaptiveSamplerConfig{
+		Window:             10 * time.Second,
+		HighVolumeRPS:      5,
+		MinProbability:     0.01,
+		ErrorRateThreshold: 0.05,
+	}
+}
+
+// RouteSampling describes the current sampling decision for a route.
+type RouteSampling struct {
+	Route       string  `json:"route"`
+	Probability float64 `json:"probability"`
+	Reason      string  `json:"reason"`
+	RequestRate float64 `json:"request_rate"`
+	ErrorRate   float64 `json:"error_rate"`
+	Sampled     int64   `json:"sampled"`
+	Dropped     int64   `json:"dropped"`
+}
+
+type routeStats struct {
+	windowStart  time.Time
+	requests     int
+	errors       int
+	lastRequests int
+	lastErrors   int
+	probability  float64
+	reason       string
+	sampled      int64
+	dropped      int64
+}
+
+// AdaptiveSampler samples root spans per route, raising the probability for
+// routes with elevated error rates and lowering it for healthy high-volume
+// routes. It is also a SpanProcessor: register it with the TracerProvider so
+// it can observe request outcomes.
+//
+// Unsampled root spans are recorded but not exported so their outcome still
+// reaches OnEnd; otherwise a route that stopped being sampled could never
+// be noticed failing.
+type AdaptiveSampler struct {
+	config AdaptiveSamplerConfig
+	now    func() time.Time
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+func NewAdaptiveSampler(config AdaptiveSamplerConfig) *AdaptiveSampler {
+	return &AdaptiveSampler{
+		config: config,
+		now:    time.Now,
+		routes: make(map[string]*routeStats),
+	}
+}
+
+func (s *AdaptiveSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+
+	s.mu.Lock()
+	stats := s.routeLocked(p.Name)
+	probability := stats.probability
+	sampled := traceIDBelow(p.TraceID, probability)
+	if sampled {
+		stats.sampled++
+	} else {
+		stats.dropped++
+	}
+	s.mu.Unlock()
+
+	decision := sdktrace.RecordOnly
+	if sampled {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: psc.TraceState(),
+	}
+}
+
+func (s *AdaptiveSampler) Description() string {
+	return fmt.Sprintf("AdaptiveSampler{errorRateThreshold:%g,highVolumeRPS:%g}",
+		s.config.ErrorRateThreshold, s.config.HighVolumeRPS)
+}
+
+func (s *AdaptiveSampler) OnStart(parent context.Context, span sdktrace.ReadWriteSpan) {}
+
+func (s *AdaptiveSampler) OnEnd(span sdktrace.ReadOnlySpan) {
+	// Only root spans carry a sampling decision of their own
+	if span.Parent().IsValid() && !span.Parent().IsRemote() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.routeLocked(span.Name())
+	stats.requests++
+	if span.Status().Code == codes.Error {
+		stats.errors++
+	}
+}
+
+func (s *AdaptiveSampler) Shutdown(ctx context.Context) error { return nil }
+
+func (s *AdaptiveSampler) ForceFlush(ctx context.Context) error { return nil }
+
+// Report returns the current sampling decision for every route seen so far.
+func (s *AdaptiveSampler) Report() []RouteSampling {
+	s.mu.Lock()
+	report := make([]RouteSampling, 0, len(s.routes))
+	for route := range s.routes {
+		stats := s.routeLocked(route)
+		report = append(report, RouteSampling{
+			Route:       route,
+			Probability: stats.probability,
+			Reason:      stats.reason,
+			RequestRate: float64(stats.lastRequests) / s.config.Window.Seconds(),
+			ErrorRate:   errorRate(stats.lastRequests, stats.lastErrors),
+			Sampled:     stats.sampled,
+			Dropped:     stats.dropped,
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Route < report[j].Route })
+	return report
+}
+
+// routeLocked returns the stats for a route, rolling its window over and
+// recalculating the probability when the window has elapsed.
+func (s *AdaptiveSampler) routeLocked(route string) *routeStats {
+	now := s.now()
+	stats, ok := s.routes[route]
+	if !ok {
+		stats = &routeStats{windowStart: now, probability: 1, reason: "warming_up"}
+		s.routes[route] = stats
+		return stats
+	}
+
+	elapsed := now.Sub(stats.windowStart)
+	if elapsed < s.config.Window {
+		return stats
+	}
+
+	// An idle gap longer than a full window means the last window saw nothing
+	if elapsed >= 2*s.config.Window {
+		stats.requests, stats.errors = 0, 0
+	}
+	stats.lastRequests, stats.lastErrors = stats.requests, stats.errors
+	stats.requests, stats.errors = 0, 0
+	stats.windowStart = now
+	stats.probability, stats.reason = s.probability(stats.lastRequests, stats.lastErrors)
+
+	return stats
+}
+
+func (s *AdaptiveSampler) probability(requests, errors int) (float64, string) {
+	if errorRate(requests, errors) >= s.config.ErrorRateThreshold && errors > 0 {
+		return 1, "elevated_error_rate"
+	}
+
+	rps := float64(requests) / s.config.Window.Seconds()
+	if rps <= s.config.HighVolumeRPS {
+		return 1, "low_volume"
+	}
+
+	probability := s.config.HighVolumeRPS / rps
+	if probability < s.config.MinProbability {
+		probability = s.config.MinProbability
+	}
+	return probability, "healthy_high_volume"
+}
+
+func errorRate(requests, errors int) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// traceIDBelow makes the same deterministic decision as TraceIDRatioBased
+// so every service sampling at the same probability agrees on a trace.
+func traceIDBelow(traceID trace.TraceID, probability float64) bool {
+	if probability >= 1 {
+		return true
+	}
+	bound := uint64(probability * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:16])>>1 < bound
+}
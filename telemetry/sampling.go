@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplingStrategy selects how root spans are sampled. The names match the
+// OTEL_TRACES_SAMPLER values, plus adaptive for this service's own sampler.
+type SamplingStrategy string
+
+const (
+	SamplingAdaptive             SamplingStrategy = "adaptive"
+	SamplingAlwaysOn             SamplingStrategy = "always_on"
+	SamplingAlwaysOff            SamplingStrategy = "always_off"
+	SamplingRatio                SamplingStrategy = "traceidratio"
+	SamplingParentBasedAlwaysOn  SamplingStrategy = "parentbased_always_on"
+	SamplingParentBasedAlwaysOff SamplingStrategy = "parentbased_always_off"
+	SamplingParentBasedRatio     SamplingStrategy = "parentbased_traceidratio"
+)
+
+// SamplingConfig chooses a sampling strategy. The zero value is adaptive.
+type SamplingConfig struct {
+	Strategy SamplingStrategy
+	// Ratio is the fraction of traces kept by the ratio strategies, from 0
+	// to 1.
+	Ratio float64
+}
+
+// Sampler builds the TracerProvider's sampler. adaptive is used, behind
+// ParentBased, for SamplingAdaptive.
+//
+// Traces the ratio strategies drop are still recorded, like the adaptive
+// sampler's, so cost estimation, the status audit, and the anomaly detector
+// keep seeing every request. Only export volume goes down. always_off
+// records nothing, for turning tracing off.
+func (c SamplingConfig) Sampler(adaptive sdktrace.Sampler) (sdktrace.Sampler, error) {
+	if c.Strategy == SamplingRatio || c.Strategy == SamplingParentBasedRatio {
+		if c.Ratio < 0 || c.Ratio > 1 {
+			return nil, fmt.Errorf("sampling ratio %g: must be from 0 to 1", c.Ratio)
+		}
+	}
+
+	switch c.Strategy {
+	case "", SamplingAdaptive:
+		if adaptive == nil {
+			return nil, fmt.Errorf("adaptive sampling: no adaptive sampler")
+		}
+		return sdktrace.ParentBased(adaptive), nil
+	case SamplingAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case SamplingAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case SamplingRatio:
+		return recordDropped{sdktrace.TraceIDRatioBased(c.Ratio)}, nil
+	case SamplingParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case SamplingParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case SamplingParentBasedRatio:
+		return sdktrace.ParentBased(recordDropped{sdktrace.TraceIDRatioBased(c.Ratio)}), nil
+	default:
+		return nil, fmt.Errorf("unknown sampling strategy %q", c.Strategy)
+	}
+}
+
+// recordDropped turns a Drop decision into RecordOnly, so the span reaches
+// in-process processors but is never exported.
+type recordDropped struct {
+	sdktrace.Sampler
+}
+
+func (r recordDropped) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := r.Sampler.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
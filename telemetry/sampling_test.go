@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplingConfigSampler(t *testing.T) {
+	tests := []struct {
+		strategy SamplingStrategy
+		ratio    float64
+		want     sdktrace.SamplingDecision
+	}{
+		{strategy: SamplingAlwaysOn, want: sdktrace.RecordAndSample},
+		{strategy: SamplingAlwaysOff, want: sdktrace.Drop},
+		{strategy: SamplingParentBasedAlwaysOff, want: sdktrace.Drop},
+		{strategy: SamplingRatio, ratio: 0, want: sdktrace.RecordOnly},
+		{strategy: SamplingParentBasedRatio, ratio: 0, want: sdktrace.RecordOnly},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			sampler, err := SamplingConfig{Strategy: tt.strategy, Ratio: tt.ratio}.Sampler(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := sampler.ShouldSample(sdktrace.SamplingParameters{TraceID: trace.TraceID{1}, Name: "GET /"})
+			if result.Decision != tt.want {
+				t.Errorf("decision = %v, want %v", result.Decision, tt.want)
+			}
+		})
+	}
+}
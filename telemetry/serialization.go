@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// serializationBuckets are the upper bounds, in milliseconds, of the
+// serialization time histogram.
+var serializationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// The same measurements, exported per route through the MeterProvider.
+var (
+	serializationMeter    = Meter("telemetry-demo/http")
+	serializationDuration = Float64Histogram(serializationMeter, "http.response.serialization.duration", "ms",
+		"Time spent encoding response bodies, by route")
+	serializationBodySize = Float64Histogram(serializationMeter, "http.response.body.size", "By",
+		"Encoded response body size, by route")
+)
+
+// HistogramBucket counts observations at or below LessOrEqualMs. The last
+// bucket of a report has LessOrEqualMs of -1 and counts the overflow.
+type HistogramBucket struct {
+	LessOrEqualMs float64 `json:"le_ms"`
+	Count         int64   `json:"count"`
+}
+
+// RouteSerialization summarizes response serialization for one route.
+type RouteSerialization struct {
+	Route         string            `json:"route"`
+	Responses     int64             `json:"responses"`
+	TotalBytes    int64             `json:"total_bytes"`
+	MeanBytes     float64           `json:"mean_bytes"`
+	MaxBytes      int               `json:"max_bytes"`
+	TotalMs       float64           `json:"total_ms"`
+	MeanMs        float64           `json:"mean_ms"`
+	MaxMs         float64           `json:"max_ms"`
+	DurationHisto []HistogramBucket `json:"duration_histogram"`
+}
+
+type routeSerialization struct {
+	responses  int64
+	totalBytes int64
+	maxBytes   int
+	total      time.Duration
+	max        time.Duration
+	buckets    []int64
+}
+
+// SerializationRecorder aggregates how long each route spends encoding its
+// response body and how large the bodies are. It keeps histograms in memory
+// as well as exporting them, so the cost is visible without a metrics
+// backend.
+type SerializationRecorder struct {
+	mu     sync.Mutex
+	routes map[string]*routeSerialization
+}
+
+func NewSerializationRecorder() *SerializationRecorder {
+	return &SerializationRecorder{routes: make(map[string]*routeSerialization)}
+}
+
+// Record adds one serialized response for route, and records it in the
+// serialization histograms with ctx, which links them to its trace.
+func (r *SerializationRecorder) Record(ctx context.Context, route string, elapsed time.Duration, bytes int) {
+	if route == "" {
+		route = "unmatched"
+	}
+	dimensions := metric.WithAttributes(attrs.HTTPRoute.String(route))
+	serializationDuration.Record(ctx, durationMs(elapsed), dimensions)
+	serializationBodySize.Record(ctx, float64(bytes), dimensions)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.routes[route]
+	if !ok {
+		stats = &routeSerialization{buckets: make([]int64, len(serializationBuckets)+1)}
+		r.routes[route] = stats
+	}
+
+	stats.responses++
+	stats.totalBytes += int64(bytes)
+	if bytes > stats.maxBytes {
+		stats.maxBytes = bytes
+	}
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+
+	ms := durationMs(elapsed)
+	bucket := sort.SearchFloat64s(serializationBuckets, ms)
+	stats.buckets[bucket]++
+}
+
+// Report returns every route, most total serialization time first.
+func (r *SerializationRecorder) Report() []RouteSerialization {
+	r.mu.Lock()
+	report := make([]RouteSerialization, 0, len(r.routes))
+	for route, stats := range r.routes {
+		histo := make([]HistogramBucket, len(stats.buckets))
+		for i, count := range stats.buckets {
+			le := -1.0
+			if i < len(serializationBuckets) {
+				le = serializationBuckets[i]
+			}
+			histo[i] = HistogramBucket{LessOrEqualMs: le, Count: count}
+		}
+
+		report = append(report, RouteSerialization{
+			Route:         route,
+			Responses:     stats.responses,
+			TotalBytes:    stats.totalBytes,
+			MeanBytes:     float64(stats.totalBytes) / float64(stats.responses),
+			MaxBytes:      stats.maxBytes,
+			TotalMs:       durationMs(stats.total),
+			MeanMs:        durationMs(stats.total) / float64(stats.responses),
+			MaxMs:         durationMs(stats.max),
+			DurationHisto: histo,
+		})
+	}
+	r.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].TotalMs != report[j].TotalMs {
+			return report[i].TotalMs > report[j].TotalMs
+		}
+		return report[i].Route < report[j].Route
+	})
+	return report
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"sort"
+	"sync"
+)
+
+// Settings describes the telemetry pipeline Init and InitMeter
+// installed, after environment variables and defaults were applied. OTLP
+// header values are never included, only their names.
+type Settings struct {
+	Traces  TraceSettings  `json:"traces"`
+	Metrics MetricSettings `json:"metrics"`
+}
+
+type TraceSettings struct {
+	ServiceName        string                      `json:"service_name"`
+	Exporters          []ExporterKind              `json:"exporters"`
+	ExporterFilters    map[ExporterKind]SpanFilter `json:"exporter_filters,omitempty"`
+	OTLPEndpoint       string                      `json:"otlp_endpoint,omitempty"`
+	OTLPHeaderNames    []string                    `json:"otlp_header_names,omitempty"`
+	Sampler            string                      `json:"sampler"`
+	SamplingStrategy   SamplingStrategy            `json:"sampling_strategy"`
+	Propagators        []PropagatorKind            `json:"propagators"`
+	Redaction          RedactionMode               `json:"pii_redaction"`
+	RedactedAttributes []string                    `json:"pii_attributes,omitempty"`
+	Batching           BatchSettings               `json:"batching"`
+	TailSamplingMs     int64                       `json:"tail_sampling_latency_ms,omitempty"`
+	SlowSpanStackMs    int64                       `json:"slow_span_stack_threshold_ms,omitempty"`
+}
+
+type MetricSettings struct {
+	Exporters       []MetricExporterKind `json:"exporters"`
+	OTLPEndpoint    string               `json:"otlp_endpoint,omitempty"`
+	OTLPHeaderNames []string             `json:"otlp_header_names,omitempty"`
+	IntervalMs      int64                `json:"interval_ms,omitempty"`
+	Histograms      HistogramAggregation `json:"histogram_aggregation"`
+	LatencyBuckets  []float64            `json:"latency_buckets_ms,omitempty"`
+}
+
+var settings struct {
+	mu      sync.Mutex
+	current Settings
+}
+
+// CurrentSettings returns what the telemetry pipeline is running with.
+func CurrentSettings() Settings {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	return settings.current
+}
+
+func updateSettings(update func(s *Settings)) {
+	settings.mu.Lock()
+	defer settings.mu.Unlock()
+
+	update(&settings.current)
+}
+
+// headerNames lists the keys of headers, which usually carry credentials,
+// so settings can show which are sent without their values.
+func headerNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
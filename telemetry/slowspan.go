@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// slowSpanThresholdEnv enables stack capture for spans slower than the given
+// duration (e.g. "100ms"). Capturing stacks is expensive, so it is off by default.
+const slowSpanThresholdEnv = "TRACE_SLOW_SPAN_THRESHOLD"
+
+// maxStackBytes caps the size of a captured stack trace attribute.
+const maxStackBytes = 8192
+
+// slowSpanThreshold reads the slow span threshold from the environment,
+// returning zero when the feature is disabled.
+func slowSpanThreshold() time.Duration {
+	value := os.Getenv(slowSpanThresholdEnv)
+	if value == "" {
+		return 0
+	}
+
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold <= 0 {
+		log.Printf("Ignoring invalid %s=%q: expected a positive duration", slowSpanThresholdEnv, value)
+		return 0
+	}
+	return threshold
+}
+
+// NewSlowSpanTracerProvider wraps a TracerProvider so that any span lasting
+// longer than threshold gets a "slow_span" event carrying the stack trace of
+// the goroutine that ended it.
+func NewSlowSpanTracerProvider(tp trace.TracerProvider, threshold time.Duration) trace.TracerProvider {
+	return &slowSpanTracerProvider{TracerProvider: tp, threshold: threshold}
+}
+
+type slowSpanTracerProvider struct {
+	trace.TracerProvider
+	threshold time.Duration
+}
+
+func (p *slowSpanTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &slowSpanTracer{
+		Tracer:    p.TracerProvider.Tracer(name, opts...),
+		threshold: p.threshold,
+	}
+}
+
+type slowSpanTracer struct {
+	trace.Tracer
+	threshold time.Duration
+}
+
+func (t *slowSpanTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	ctx, span := t.Tracer.Start(ctx, name, opts...)
+	if !span.IsRecording() {
+		return ctx, span
+	}
+
+	config := trace.NewSpanStartConfig(opts...)
+	start := config.Timestamp()
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	// Store the wrapper in the context so handlers ending the span via
+	// trace.SpanFromContext still get the slow span check
+	slow := &slowSpan{Span: span, start: start, threshold: t.threshold}
+	return trace.ContextWithSpan(ctx, slow), slow
+}
+
+type slowSpan struct {
+	trace.Span
+	start     time.Time
+	threshold time.Duration
+}
+
+func (s *slowSpan) End(options ...trace.SpanEndOption) {
+	config := trace.NewSpanEndConfig(options...)
+	end := config.Timestamp()
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	if duration := end.Sub(s.start); duration >= s.threshold {
+		stack := make([]byte, maxStackBytes)
+		stack = stack[:runtime.Stack(stack, false)]
+
+		s.Span.AddEvent("slow_span", trace.WithAttributes(
+			attribute.Int64("slow_span.duration_ms", duration.Milliseconds()),
+			attribute.Int64("slow_span.threshold_ms", s.threshold.Milliseconds()),
+			attrs.CodeStacktrace.String(string(stack)),
+		))
+	}
+
+	s.Span.End(options...)
+}
@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanBufferSize is how many finished spans the process remembers for
+// TraceSpans. The oldest are forgotten first.
+const spanBufferSize = 2048
+
+// recentSpans keeps the latest finished spans of every recorded trace, as
+// exported: Init installs it behind the same redaction as the exporters.
+var recentSpans = &spanBuffer{spans: make([]sdktrace.ReadOnlySpan, spanBufferSize)}
+
+// spanBuffer is a ring of finished spans.
+type spanBuffer struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+	next  int
+}
+
+func (b *spanBuffer) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (b *spanBuffer) OnEnd(s sdktrace.ReadOnlySpan) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.spans[b.next] = s
+	b.next = (b.next + 1) % len(b.spans)
+}
+
+func (b *spanBuffer) Shutdown(context.Context) error { return nil }
+
+func (b *spanBuffer) ForceFlush(context.Context) error { return nil }
+
+// RecordedSpan is a finished span as TraceSpans reports it.
+type RecordedSpan struct {
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	Scope        string            `json:"scope"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	DurationMs   float64           `json:"duration_ms"`
+	Status       string            `json:"status"`
+	StatusDesc   string            `json:"status_description,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Events       []RecordedEvent   `json:"events,omitempty"`
+	Links        []string          `json:"links,omitempty"`
+}
+
+// RecordedEvent is a span event of a RecordedSpan.
+type RecordedEvent struct {
+	Name       string            `json:"name"`
+	Time       time.Time         `json:"time"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// TraceSpans returns the remembered spans of traceID, earliest start
+// first. Spans of a long or busy trace may already have been forgotten.
+func TraceSpans(traceID trace.TraceID) []RecordedSpan {
+	recentSpans.mu.Lock()
+	var matched []sdktrace.ReadOnlySpan
+	for _, s := range recentSpans.spans {
+		if s != nil && s.SpanContext().TraceID() == traceID {
+			matched = append(matched, s)
+		}
+	}
+	recentSpans.mu.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].StartTime().Before(matched[j].StartTime()) })
+	out := make([]RecordedSpan, len(matched))
+	for i, s := range matched {
+		out[i] = recordSpan(s)
+	}
+	return out
+}
+
+func recordSpan(s sdktrace.ReadOnlySpan) RecordedSpan {
+	recorded := RecordedSpan{
+		TraceID:    s.SpanContext().TraceID().String(),
+		SpanID:     s.SpanContext().SpanID().String(),
+		Name:       s.Name(),
+		Kind:       s.SpanKind().String(),
+		Scope:      s.InstrumentationScope().Name,
+		Start:      s.StartTime(),
+		End:        s.EndTime(),
+		DurationMs: Milliseconds(s.EndTime().Sub(s.StartTime())),
+		Status:     s.Status().Code.String(),
+		StatusDesc: s.Status().Description,
+		Attributes: make(map[string]string, len(s.Attributes())),
+	}
+	if s.Parent().IsValid() {
+		recorded.ParentSpanID = s.Parent().SpanID().String()
+	}
+	for _, kv := range s.Attributes() {
+		recorded.Attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+	for _, event := range s.Events() {
+		recordedEvent := RecordedEvent{Name: event.Name, Time: event.Time}
+		if len(event.Attributes) > 0 {
+			recordedEvent.Attributes = make(map[string]string, len(event.Attributes))
+			for _, kv := range event.Attributes {
+				recordedEvent.Attributes[string(kv.Key)] = kv.Value.Emit()
+			}
+		}
+		recorded.Events = append(recorded.Events, recordedEvent)
+	}
+	for _, link := range s.Links() {
+		recorded.Links = append(recorded.Links, link.SpanContext.TraceID().String()+"/"+link.SpanContext.SpanID().String())
+	}
+	return recorded
+}
@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span metric attributes. Span names come from route templates and code,
+// not request data, but a cap still keeps a naming mistake from exploding
+// the series count.
+const (
+	spanNameKey   = attribute.Key("span.name")
+	spanKindKey   = attribute.Key("span.kind")
+	spanStatusKey = attribute.Key("status.code")
+)
+
+var spanMetricDimensions = Dimensions{
+	Allowed:   []attribute.Key{spanNameKey, spanKindKey, spanStatusKey},
+	MaxValues: 200,
+}
+
+var (
+	spanMeter    = Meter("telemetry-demo/spanmetrics")
+	spanCalls    = Int64Counter(spanMeter, "span.calls", "{span}", "Finished sampled spans, by span name, kind, and status code")
+	spanDuration = Float64Histogram(spanMeter, "span.duration", "ms", "Duration of sampled spans, by span name, kind, and status code")
+)
+
+// SpanMetricsProcessor derives rate, error, and duration metrics from spans
+// as they end: every sampled span counts once in span.calls and records its
+// duration in span.duration, both by span name, kind, and status code. A
+// service that is only instrumented for tracing gets RED metrics for every
+// operation, and the error rate of one is its ERROR calls over all calls.
+//
+// It only sees spans the sampler kept, so head sampling lowers the counts
+// by the sampling ratio. It runs before tail sampling, which therefore
+// doesn't skew them.
+type SpanMetricsProcessor struct {
+	guard *dimensionGuard
+}
+
+func NewSpanMetricsProcessor() *SpanMetricsProcessor {
+	return &SpanMetricsProcessor{guard: newDimensionGuard("span.duration", spanMetricDimensions)}
+}
+
+func (p *SpanMetricsProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *SpanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+
+	// The span's own context makes the duration an exemplar linking back
+	// to it
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	set := p.guard.filter(ctx, attribute.NewSet(
+		spanNameKey.String(s.Name()),
+		spanKindKey.String(s.SpanKind().String()),
+		spanStatusKey.String(s.Status().Code.String()),
+	))
+
+	spanCalls.Add(ctx, 1, metric.WithAttributeSet(set))
+	spanDuration.Record(ctx, Milliseconds(s.EndTime().Sub(s.StartTime())), metric.WithAttributeSet(set))
+}
+
+func (p *SpanMetricsProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (p *SpanMetricsProcessor) ForceFlush(ctx context.Context) error { return nil }
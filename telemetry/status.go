@@ -0,0 +1,152 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// FailSpan records err on span and marks it as an error in one step, so the
+// two can't drift apart. description defaults to the error message. The
+// span is classified with ErrorTypeOf(err), so mark errors WithErrorType
+// where they are created.
+func FailSpan(span trace.Span, err error, description string) {
+	if description == "" {
+		description = err.Error()
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, description)
+	ClassifyError(span, ErrorTypeOf(err))
+}
+
+// EndOk marks span as explicitly successful and ends it. Use it only where
+// an operation's success is a meaningful assertion; otherwise just End the
+// span and leave its status Unset.
+func EndOk(span trace.Span, description string) {
+	span.SetStatus(codes.Ok, description)
+	span.End()
+}
+
+// StatusViolation is a finished span whose status contradicts what else it
+// recorded.
+type StatusViolation struct {
+	Span   string `json:"span"`
+	Rule   string `json:"rule"`
+	Status string `json:"status"`
+}
+
+// Status audit rules.
+const (
+	RuleErrorNotFailed  = "recorded_error_without_error_status"
+	RuleServerErrorOk   = "5xx_without_error_status"
+	RuleClientErrorFail = "4xx_server_span_marked_error"
+)
+
+// AuditSpanStatus checks a finished span against the repo's status rules:
+// a span that recorded an error must have status Error, and so must a
+// server span answering 5xx. A server span answering 4xx must not, since a
+// bad request is the client's problem rather than the server's.
+func AuditSpanStatus(s sdktrace.ReadOnlySpan) []StatusViolation {
+	status := s.Status().Code
+	var violations []StatusViolation
+	report := func(rule string) {
+		violations = append(violations, StatusViolation{Span: s.Name(), Rule: rule, Status: status.String()})
+	}
+
+	for _, event := range s.Events() {
+		if event.Name == "exception" && status != codes.Error {
+			report(RuleErrorNotFailed)
+			break
+		}
+	}
+
+	if s.SpanKind() == trace.SpanKindServer {
+		for _, kv := range s.Attributes() {
+			if kv.Key != attrs.HTTPStatusCode {
+				continue
+			}
+			code := kv.Value.AsInt64()
+			if code >= 500 && status != codes.Error {
+				report(RuleServerErrorOk)
+			}
+			if code >= 400 && code < 500 && status == codes.Error {
+				report(RuleClientErrorFail)
+			}
+		}
+	}
+
+	return violations
+}
+
+// StatusAuditReport counts violations of one rule on one span name.
+type StatusAuditReport struct {
+	Span   string `json:"span"`
+	Rule   string `json:"rule"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// StatusAuditProcessor runs AuditSpanStatus on every finished span, logging
+// the first violation of each rule per span name and counting the rest.
+type StatusAuditProcessor struct {
+	mu     sync.Mutex
+	counts map[StatusViolation]int64
+}
+
+func NewStatusAuditProcessor() *StatusAuditProcessor {
+	return &StatusAuditProcessor{counts: make(map[StatusViolation]int64)}
+}
+
+func (p *StatusAuditProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *StatusAuditProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	violations := AuditSpanStatus(s)
+	if len(violations) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, v := range violations {
+		if p.counts[v] == 0 {
+			log.Printf("⚠️  Span status audit: %s", v)
+		}
+		p.counts[v]++
+	}
+}
+
+func (p *StatusAuditProcessor) Report() []StatusAuditReport {
+	p.mu.Lock()
+	report := make([]StatusAuditReport, 0, len(p.counts))
+	for v, count := range p.counts {
+		report = append(report, StatusAuditReport{Span: v.Span, Rule: v.Rule, Status: v.Status, Count: count})
+	}
+	p.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		if report[i].Span != report[j].Span {
+			return report[i].Span < report[j].Span
+		}
+		return report[i].Rule < report[j].Rule
+	})
+	return report
+}
+
+func (p *StatusAuditProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (p *StatusAuditProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+func (v StatusViolation) String() string {
+	return fmt.Sprintf("span %q has status %s (%s)", v.Span, v.Status, v.Rule)
+}
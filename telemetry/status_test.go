@@ -0,0 +1,228 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// recordSpans returns a tracer whose ended spans are kept by the returned
+// recorder.
+func recordSpans(t *testing.T, processors ...sdktrace.SpanProcessor) (trace.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	options := []sdktrace.TracerProviderOption{sdktrace.WithSpanProcessor(recorder)}
+	for _, p := range processors {
+		options = append(options, sdktrace.WithSpanProcessor(p))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider.Tracer("status-test"), recorder
+}
+
+// onlySpan returns the single span recorder has seen end.
+func onlySpan(t *testing.T, recorder *tracetest.SpanRecorder) sdktrace.ReadOnlySpan {
+	t.Helper()
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d ended spans, want 1", len(ended))
+	}
+	return ended[0]
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestFailSpan(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		description string
+		wantStatus  string
+		wantType    ErrorType
+	}{
+		{
+			name:       "description defaults to the error",
+			err:        errors.New("boom"),
+			wantStatus: "boom",
+			wantType:   ErrorInternal,
+		},
+		{
+			name:        "explicit description",
+			err:         errors.New("boom"),
+			description: "Lookup failed",
+			wantStatus:  "Lookup failed",
+			wantType:    ErrorInternal,
+		},
+		{
+			name:       "marked error keeps its type",
+			err:        WithErrorType(errors.New("down"), ErrorUnavailable),
+			wantStatus: "down",
+			wantType:   ErrorUnavailable,
+		},
+		{
+			name:       "deadline is a timeout",
+			err:        context.DeadlineExceeded,
+			wantStatus: context.DeadlineExceeded.Error(),
+			wantType:   ErrorTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := recordSpans(t)
+			_, span := tracer.Start(context.Background(), "op")
+			FailSpan(span, tt.err, tt.description)
+			span.End()
+
+			ended := onlySpan(t, recorder)
+			if got := ended.Status(); got.Code != codes.Error || got.Description != tt.wantStatus {
+				t.Errorf("status = %v %q, want Error %q", got.Code, got.Description, tt.wantStatus)
+			}
+			if events := ended.Events(); len(events) != 1 || events[0].Name != "exception" {
+				t.Errorf("events = %v, want one exception", events)
+			}
+			if got, _ := attributeOf(ended, attrs.ErrorType); got.AsString() != string(tt.wantType) {
+				t.Errorf("error.type = %q, want %q", got.AsString(), tt.wantType)
+			}
+			if violations := AuditSpanStatus(ended); len(violations) != 0 {
+				t.Errorf("FailSpan left violations %v", violations)
+			}
+		})
+	}
+}
+
+func TestEndOk(t *testing.T) {
+	tracer, recorder := recordSpans(t)
+	_, span := tracer.Start(context.Background(), "op")
+	EndOk(span, "Stored")
+
+	ended := onlySpan(t, recorder)
+	if got := ended.Status(); got.Code != codes.Ok {
+		t.Errorf("status = %v, want Ok", got.Code)
+	}
+	if ended.EndTime().IsZero() {
+		t.Error("EndOk didn't end the span")
+	}
+}
+
+func TestAuditSpanStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  trace.SpanKind
+		build func(span trace.Span)
+		want  []string
+	}{
+		{
+			name:  "untouched span",
+			kind:  trace.SpanKindInternal,
+			build: func(trace.Span) {},
+		},
+		{
+			name:  "recorded error left unset",
+			kind:  trace.SpanKindInternal,
+			build: func(span trace.Span) { span.RecordError(errors.New("boom")) },
+			want:  []string{RuleErrorNotFailed},
+		},
+		{
+			name: "recorded error marked ok",
+			kind: trace.SpanKindInternal,
+			build: func(span trace.Span) {
+				span.RecordError(errors.New("boom"))
+				span.SetStatus(codes.Ok, "")
+			},
+			want: []string{RuleErrorNotFailed},
+		},
+		{
+			name:  "server 5xx left unset",
+			kind:  trace.SpanKindServer,
+			build: func(span trace.Span) { span.SetAttributes(attrs.HTTPStatusCode.Int(503)) },
+			want:  []string{RuleServerErrorOk},
+		},
+		{
+			name: "server 5xx failed",
+			kind: trace.SpanKindServer,
+			build: func(span trace.Span) {
+				span.SetAttributes(attrs.HTTPStatusCode.Int(500))
+				FailSpan(span, errors.New("boom"), "")
+			},
+		},
+		{
+			name: "server 4xx failed",
+			kind: trace.SpanKindServer,
+			build: func(span trace.Span) {
+				span.SetAttributes(attrs.HTTPStatusCode.Int(404))
+				span.SetStatus(codes.Error, "not found")
+			},
+			want: []string{RuleClientErrorFail},
+		},
+		{
+			name:  "server 4xx left unset",
+			kind:  trace.SpanKindServer,
+			build: func(span trace.Span) { span.SetAttributes(attrs.HTTPStatusCode.Int(404)) },
+		},
+		{
+			name: "client span may fail on 4xx",
+			kind: trace.SpanKindClient,
+			build: func(span trace.Span) {
+				span.SetAttributes(attrs.HTTPStatusCode.Int(404))
+				span.SetStatus(codes.Error, "not found")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := recordSpans(t)
+			_, span := tracer.Start(context.Background(), "op", trace.WithSpanKind(tt.kind))
+			tt.build(span)
+			span.End()
+
+			var got []string
+			for _, v := range AuditSpanStatus(onlySpan(t, recorder)) {
+				got = append(got, v.Rule)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("violations = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusAuditProcessorCounts(t *testing.T) {
+	audit := NewStatusAuditProcessor()
+	tracer, _ := recordSpans(t, audit)
+
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "unset")
+		span.RecordError(errors.New("boom"))
+		span.End()
+	}
+	_, span := tracer.Start(context.Background(), "failed")
+	FailSpan(span, errors.New("boom"), "")
+	span.End()
+
+	report := audit.Report()
+	if len(report) != 1 {
+		t.Fatalf("report = %+v, want one entry", report)
+	}
+	if got := report[0]; got.Span != "unset" || got.Rule != RuleErrorNotFailed || got.Count != 3 {
+		t.Errorf("report[0] = %+v, want 3 %s on unset", got, RuleErrorNotFailed)
+	}
+}
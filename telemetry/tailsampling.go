@@ -0,0 +1,271 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type TailSamplerConfig struct {
+	// LatencyThreshold keeps traces with a span at least this slow.
+	LatencyThreshold time.Duration
+	// DecisionWait is how long a trace is buffered waiting for its local
+	// root span before it is decided on the spans seen so far.
+	DecisionWait time.Duration
+	// MaxTraces bounds the buffered traces. When full, the oldest trace is
+	// decided early.
+	MaxTraces int
+}
+
+func DefaultTailSamplerConfig() TailSamplerConfig {
+	return TailSamplerConfig{
+		LatencyThreshold: 500 * time.Millisecond,
+		DecisionWait:     30 * time.Second,
+		MaxTraces:        maxPendingTraces,
+	}
+}
+
+// Tail sampling decisions.
+const (
+	TailKeptError = "error"
+	TailKeptSlow  = "slow"
+	TailDropped   = "dropped"
+)
+
+// TailSamplingStats counts tail sampling decisions.
+type TailSamplingStats struct {
+	LatencyThresholdMs int64 `json:"latency_threshold_ms"`
+	KeptError          int64 `json:"kept_error"`
+	KeptSlow           int64 `json:"kept_slow"`
+	Dropped            int64 `json:"dropped"`
+	SpansKept          int64 `json:"spans_kept"`
+	SpansDropped       int64 `json:"spans_dropped"`
+	// DecidedEarly counts traces decided before their root ended, because
+	// the buffer was full or the root took longer than DecisionWait.
+	DecidedEarly int64 `json:"decided_early"`
+	Buffered     int   `json:"buffered"`
+}
+
+type tailTrace struct {
+	spans   []sdktrace.ReadOnlySpan
+	first   time.Time
+	failed  bool
+	slowest time.Duration
+}
+
+// TailSampler buffers the spans of each trace until its local root span
+// ends, then exports the whole trace only if a span failed or was slower
+// than the latency threshold. Head sampling has to keep a trace before the
+// tail sampler can see it, so pair it with the always_on strategy to judge
+// every trace.
+//
+// Init places it in front of the exporters' batch processors.
+type TailSampler struct {
+	config TailSamplerConfig
+	now    func() time.Time
+	next   []sdktrace.SpanProcessor
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*tailTrace
+	// decided remembers recent outcomes so spans that end after their
+	// root follow the trace's decision.
+	decided      map[trace.TraceID]bool
+	decidedOrder []trace.TraceID
+	lastSweep    time.Time
+	stats        TailSamplingStats
+}
+
+func NewTailSampler(config TailSamplerConfig) *TailSampler {
+	if config.MaxTraces <= 0 {
+		config.MaxTraces = maxPendingTraces
+	}
+	return &TailSampler{
+		config:  config,
+		now:     time.Now,
+		pending: make(map[trace.TraceID]*tailTrace),
+		decided: make(map[trace.TraceID]bool),
+	}
+}
+
+// setNext sets the processors kept traces are handed to.
+func (t *TailSampler) setNext(next ...sdktrace.SpanProcessor) {
+	t.next = next
+}
+
+func (t *TailSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, p := range t.next {
+		p.OnStart(parent, s)
+	}
+}
+
+func (t *TailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	// Unsampled spans would be dropped by the exporters anyway
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+
+	traceID := s.SpanContext().TraceID()
+	now := t.now()
+
+	t.mu.Lock()
+	if keep, ok := t.decided[traceID]; ok {
+		t.countSpanLocked(keep)
+		t.mu.Unlock()
+		if keep {
+			t.forward(s)
+		}
+		return
+	}
+
+	tt, ok := t.pending[traceID]
+	if !ok {
+		tt = &tailTrace{first: now}
+		t.pending[traceID] = tt
+	}
+	tt.spans = append(tt.spans, s)
+	if s.Status().Code == codes.Error {
+		tt.failed = true
+	}
+	if d := s.EndTime().Sub(s.StartTime()); d > tt.slowest {
+		tt.slowest = d
+	}
+
+	var kept []sdktrace.ReadOnlySpan
+	// Children end before their local root, so the root closes the trace
+	if !s.Parent().IsValid() || s.Parent().IsRemote() {
+		kept = t.decideLocked(traceID, tt, false)
+	}
+	kept = append(kept, t.sweepLocked(now)...)
+	t.mu.Unlock()
+
+	t.forward(kept...)
+}
+
+// sweepLocked decides traces that have waited too long for their root, and
+// the oldest traces while the buffer is over its limit.
+func (t *TailSampler) sweepLocked(now time.Time) []sdktrace.ReadOnlySpan {
+	overfull := len(t.pending) > t.config.MaxTraces
+	if !overfull && now.Sub(t.lastSweep) < t.config.DecisionWait/2 {
+		return nil
+	}
+	t.lastSweep = now
+
+	var kept []sdktrace.ReadOnlySpan
+	for traceID, tt := range t.pending {
+		if now.Sub(tt.first) >= t.config.DecisionWait {
+			kept = append(kept, t.decideLocked(traceID, tt, true)...)
+		}
+	}
+	for len(t.pending) > t.config.MaxTraces {
+		var oldestID trace.TraceID
+		var oldest *tailTrace
+		for traceID, tt := range t.pending {
+			if oldest == nil || tt.first.Before(oldest.first) {
+				oldestID, oldest = traceID, tt
+			}
+		}
+		kept = append(kept, t.decideLocked(oldestID, oldest, true)...)
+	}
+	return kept
+}
+
+// decideLocked removes a trace from the buffer and returns its spans if
+// the trace is kept.
+func (t *TailSampler) decideLocked(traceID trace.TraceID, tt *tailTrace, early bool) []sdktrace.ReadOnlySpan {
+	delete(t.pending, traceID)
+	if early {
+		t.stats.DecidedEarly++
+	}
+
+	keep := true
+	switch {
+	case tt.failed:
+		t.stats.KeptError++
+	case t.config.LatencyThreshold > 0 && tt.slowest >= t.config.LatencyThreshold:
+		t.stats.KeptSlow++
+	default:
+		keep = false
+		t.stats.Dropped++
+	}
+	for range tt.spans {
+		t.countSpanLocked(keep)
+	}
+	t.rememberLocked(traceID, keep)
+
+	if !keep {
+		return nil
+	}
+	return tt.spans
+}
+
+func (t *TailSampler) countSpanLocked(keep bool) {
+	if keep {
+		t.stats.SpansKept++
+	} else {
+		t.stats.SpansDropped++
+	}
+}
+
+// rememberLocked records a decision, forgetting the oldest once as many
+// decisions are remembered as traces can be buffered.
+func (t *TailSampler) rememberLocked(traceID trace.TraceID, keep bool) {
+	if len(t.decidedOrder) >= t.config.MaxTraces {
+		delete(t.decided, t.decidedOrder[0])
+		t.decidedOrder = t.decidedOrder[1:]
+	}
+	t.decided[traceID] = keep
+	t.decidedOrder = append(t.decidedOrder, traceID)
+}
+
+func (t *TailSampler) forward(spans ...sdktrace.ReadOnlySpan) {
+	for _, s := range spans {
+		for _, p := range t.next {
+			p.OnEnd(s)
+		}
+	}
+}
+
+// flush decides every buffered trace on the spans seen so far.
+func (t *TailSampler) flush() {
+	t.mu.Lock()
+	var kept []sdktrace.ReadOnlySpan
+	for traceID, tt := range t.pending {
+		kept = append(kept, t.decideLocked(traceID, tt, true)...)
+	}
+	t.mu.Unlock()
+
+	t.forward(kept...)
+}
+
+func (t *TailSampler) Shutdown(ctx context.Context) error {
+	t.flush()
+	var errs []error
+	for _, p := range t.next {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (t *TailSampler) ForceFlush(ctx context.Context) error {
+	t.flush()
+	var errs []error
+	for _, p := range t.next {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (t *TailSampler) Stats() TailSamplingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.stats
+	stats.LatencyThresholdMs = t.config.LatencyThreshold.Milliseconds()
+	stats.Buffered = len(t.pending)
+	return stats
+}
@@ -2,70 +2,323 @@ package telemetry
 
 import (
 	"context"
+	"fmt"
 	"log"
 	
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"telemetry-demo/telemetry/attrs"
 )
 
 const serviceName = "telemetry-demo"
 
-func InitTracer() func() {
-	// Create Zipkin exporter
-	zipkinExporter, err := zipkin.New("http://localhost:9411/api/v2/spans")
-	if err != nil {
-		log.Printf("Failed to create Zipkin exporter: %v", err)
+// ExporterKind selects where finished spans are sent.
+type ExporterKind string
+
+const (
+	ExporterZipkin   ExporterKind = "zipkin"
+	ExporterJaeger   ExporterKind = "jaeger"
+	ExporterOTLPHTTP ExporterKind = "otlphttp"
+	ExporterOTLPGRPC ExporterKind = "otlpgrpc"
+)
+
+// DefaultOTLPEndpoint is the OpenTelemetry Collector's OTLP/HTTP port.
+const DefaultOTLPEndpoint = "http://localhost:4318"
+
+// exportConfig selects the span backends Init configures and how much is
+// sent to them. Init's options fill it in.
+type exportConfig struct {
+	// Exporters lists the backends spans are sent to. Empty means OTLP
+	// when OTEL_EXPORTER_OTLP_ENDPOINT is set, over gRPC if
+	// OTEL_EXPORTER_OTLP_PROTOCOL is grpc and HTTP otherwise, and Zipkin
+	// and Jaeger when it isn't.
+	Exporters []ExporterKind
+	// Filters narrows the spans an exporter receives. Exporters without
+	// one get FilterAll.
+	Filters map[ExporterKind]SpanFilter
+	// OTLPEndpoint is the collector base URL for ExporterOTLPHTTP. Empty
+	// means OTEL_EXPORTER_OTLP_ENDPOINT, then DefaultOTLPEndpoint.
+	OTLPEndpoint string
+	// OTLPHeaders are sent with every OTLP request, e.g. an API key.
+	OTLPHeaders map[string]string
+	// OTLPGRPC configures ExporterOTLPGRPC.
+	OTLPGRPC OTLPGRPCConfig
+	// Resource adds attributes describing this process to every span.
+	Resource []attribute.KeyValue
+	// Sampling chooses which traces are exported. An unset strategy means
+	// OTEL_TRACES_SAMPLER, then adaptive.
+	Sampling SamplingConfig
+	// Propagators lists the header formats trace context and baggage are
+	// read from and written to. Empty means OTEL_PROPAGATORS, then
+	// DefaultPropagators.
+	Propagators []PropagatorKind
+	// Redaction rewrites PII attributes before spans reach the exporters.
+	Redaction RedactionConfig
+	// Batch tunes the batch processors in front of the exporters and
+	// retries their failed exports.
+	Batch BatchConfig
+	// TailSampler, when set, buffers each trace and only passes traces
+	// with errors or slow spans on to the exporters.
+	TailSampler *TailSampler
+}
+
+// Init installs the global TracerProvider and TextMapPropagator configured
+// by opts and returns the Provider to flush and stop them with. The
+// standard OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME,
+// OTEL_TRACES_SAMPLER, and OTEL_PROPAGATORS variables fill in what opts
+// leave unset, so Init() alone is a working setup.
+func Init(ctx context.Context, opts ...Option) (*Provider, error) {
+	var config initConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	export := config.export
+	processors := config.processors
+	adaptive := config.adaptive
+	if adaptive == nil {
+		sampler := NewAdaptiveSampler(DefaultAdaptiveSamplerConfig())
+		adaptive = sampler
+		processors = append(processors, sampler)
+	}
+	name := config.serviceName
+	if name == "" {
+		name = serviceNameFromEnv()
 	}
 	
-	// Create Jaeger exporter
-	jaegerExporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint("http://localhost:14268/api/traces")))
-	if err != nil {
-		log.Printf("Failed to create Jaeger exporter: %v", err)
+	envEndpoint := otlpEndpointFromEnv()
+	kinds := export.Exporters
+	if len(kinds) == 0 {
+		if envEndpoint != "" {
+			kinds = []ExporterKind{otlpExporterFromEnv()}
+		} else {
+			kinds = []ExporterKind{ExporterZipkin, ExporterJaeger}
+		}
 	}
 	
 	// Create resource with service information
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("v1.0.0"),
-		),
-	)
+	res, err := serviceResource(ctx, name, export.Resource...)
 	if err != nil {
-		log.Printf("Failed to create resource: %v", err)
-		return func() {}
+		return nil, fmt.Errorf("creating trace resource: %w", err)
 	}
 	
 	// Create trace provider with multiple exporters
 	var options []trace.TracerProviderOption
 	options = append(options, trace.WithResource(res))
 	
-	if zipkinExporter != nil {
-		options = append(options, trace.WithBatcher(zipkinExporter))
-		log.Println("📡 Zipkin exporter configured - traces at http://localhost:9411")
+	// Sample with the configured strategy, falling back to adaptive
+	// sampling if it can't be built
+	sampling := export.Sampling
+	if sampling.Strategy == "" {
+		if fromEnv, ok := samplingFromEnv(); ok {
+			sampling = fromEnv
+		}
+	}
+	sampler, err := sampling.Sampler(adaptive)
+	if err != nil {
+		log.Printf("Falling back to adaptive sampling: %v", err)
+		sampling = SamplingConfig{}
+		sampler, err = sampling.Sampler(adaptive)
+	}
+	if err == nil && config.wrap != nil {
+		sampler = config.wrap(sampler)
+	}
+	if err == nil {
+		options = append(options, trace.WithSampler(sampler))
+		log.Printf("🎲 Sampling with %s", sampler.Description())
 	}
 	
-	if jaegerExporter != nil {
-		options = append(options, trace.WithBatcher(jaegerExporter))
-		log.Println("📡 Jaeger exporter configured - traces at http://localhost:16686")
+	var routes []fanOutRoute
+	var counts []*exportCounts
+	configured := TraceSettings{ServiceName: name, SamplingStrategy: sampling.Strategy, Batching: export.Batch.settings()}
+	if configured.SamplingStrategy == "" {
+		configured.SamplingStrategy = SamplingAdaptive
+	}
+	if sampler != nil {
+		configured.Sampler = sampler.Description()
+	}
+	for _, kind := range kinds {
+		exported := &exportCounts{kind: kind}
+		switch kind {
+		case ExporterZipkin:
+			zipkinExporter, err := zipkin.New("http://localhost:9411/api/v2/spans")
+			if err != nil {
+				log.Printf("Failed to create Zipkin exporter: %v", err)
+				continue
+			}
+			routes = append(routes, fanOutRoute{exporter: kind, counts: exported, next: newBatcher(exported, zipkinExporter, export.Batch)})
+			counts = append(counts, exported)
+			configured.Exporters = append(configured.Exporters, kind)
+			log.Println("📡 Zipkin exporter configured - traces at http://localhost:9411")
+		
+		case ExporterJaeger:
+			jaegerExporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint("http://localhost:14268/api/traces")))
+			if err != nil {
+				log.Printf("Failed to create Jaeger exporter: %v", err)
+				continue
+			}
+			routes = append(routes, fanOutRoute{exporter: kind, counts: exported, next: newBatcher(exported, jaegerExporter, export.Batch)})
+			counts = append(counts, exported)
+			configured.Exporters = append(configured.Exporters, kind)
+			log.Println("📡 Jaeger exporter configured - traces at http://localhost:16686")
+		
+		case ExporterOTLPHTTP:
+			endpoint := export.OTLPEndpoint
+			if endpoint == "" {
+				endpoint = envEndpoint
+			}
+			if endpoint == "" {
+				endpoint = DefaultOTLPEndpoint
+			}
+			httpExporter, err := newOTLPHTTPExporter(ctx, endpoint, export.OTLPHeaders, export.Batch.Retry)
+			if err != nil {
+				log.Printf("Failed to create OTLP/HTTP exporter: %v", err)
+				continue
+			}
+			// The exporter retries by itself, only what is retryable
+			batch := export.Batch
+			batch.Retry = RetryConfig{}
+			routes = append(routes, fanOutRoute{exporter: kind, counts: exported, next: newBatcher(exported, httpExporter, batch)})
+			counts = append(counts, exported)
+			configured.Exporters = append(configured.Exporters, kind)
+			configured.OTLPEndpoint = endpoint
+			configured.OTLPHeaderNames = headerNames(export.OTLPHeaders)
+			log.Printf("📡 OTLP/HTTP exporter configured - traces sent to %s", endpoint)
+		
+		case ExporterOTLPGRPC:
+			grpcConfig := export.OTLPGRPC
+			if grpcConfig.Endpoint == "" {
+				grpcConfig.Endpoint = envEndpoint
+			}
+			if grpcConfig.Endpoint == "" {
+				grpcConfig.Endpoint = DefaultOTLPGRPCEndpoint
+			}
+			grpcExporter, err := newOTLPGRPCExporter(ctx, grpcConfig, export.Batch.Retry)
+			if err != nil {
+				log.Printf("Failed to create OTLP/gRPC exporter: %v", err)
+				continue
+			}
+			// The exporter retries by itself, only what is retryable
+			batch := export.Batch
+			batch.Retry = RetryConfig{}
+			routes = append(routes, fanOutRoute{exporter: kind, counts: exported, next: newBatcher(exported, grpcExporter, batch)})
+			counts = append(counts, exported)
+			configured.Exporters = append(configured.Exporters, kind)
+			configured.OTLPEndpoint = grpcConfig.Endpoint
+			configured.OTLPHeaderNames = headerNames(grpcConfig.Headers)
+			log.Printf("📡 OTLP/gRPC exporter configured - traces sent to %s", grpcConfig.Endpoint)
+		
+		default:
+			log.Printf("Unknown exporter %q ignored", kind)
+		}
 	}
 	
-	tp := trace.NewTracerProvider(options...)
+	// PII never reaches an exporter, with or without tail sampling
+	redaction := export.Redaction
+	if redaction.Mode == "" {
+		redaction.Mode = RedactHash
+	}
+	if len(redaction.Attributes) == 0 {
+		redaction.Attributes = DefaultRedactedAttributes
+	}
+	configured.Redaction = redaction.Mode
+	if redaction.Mode != RedactNone {
+		configured.RedactedAttributes = redaction.Attributes
+		for i := range routes {
+			routes[i].next = newRedactingProcessor(routes[i].next, redaction)
+		}
+		log.Printf("🙈 Redacting %v on exported spans (%s)", redaction.Attributes, redaction.Mode)
+	}
+	
+	// Debug bundles see the latest spans as the exporters do, whatever the
+	// tail sampler and filters make of them
+	var buffered trace.SpanProcessor = recentSpans
+	if redaction.Mode != RedactNone {
+		buffered = newRedactingProcessor(recentSpans, redaction)
+	}
+	options = append(options, trace.WithSpanProcessor(buffered))
+	
+	// Each exporter gets only the spans its filter keeps, e.g. errors to
+	// one backend and everything to another
+	for i := range routes {
+		routes[i].filter = FilterAll
+		if filter, ok := export.Filters[routes[i].exporter]; ok && filter != "" {
+			routes[i].filter = filter
+		}
+		if routes[i].filter != FilterAll {
+			if configured.ExporterFilters == nil {
+				configured.ExporterFilters = make(map[ExporterKind]SpanFilter)
+			}
+			configured.ExporterFilters[routes[i].exporter] = routes[i].filter
+			log.Printf("🔀 Sending %s spans only to %s", routes[i].filter, routes[i].exporter)
+		}
+	}
+	fanOut := newFanOutProcessor(routes)
+	
+	// With tail sampling, exporters only receive the traces it keeps
+	if export.TailSampler != nil {
+		export.TailSampler.setNext(fanOut)
+		configured.TailSamplingMs = export.TailSampler.config.LatencyThreshold.Milliseconds()
+		options = append(options, trace.WithSpanProcessor(export.TailSampler))
+		log.Printf("🧺 Tail sampling: exporting traces with errors or spans slower than %s", export.TailSampler.config.LatencyThreshold)
+	} else {
+		options = append(options, trace.WithSpanProcessor(fanOut))
+	}
+	
+	// Register additional in-process span processors (cost estimation, etc.)
+	for _, processor := range processors {
+		options = append(options, trace.WithSpanProcessor(processor))
+	}
 	
-	// Set global trace provider
-	otel.SetTracerProvider(tp)
+	tp := trace.NewTracerProvider(options...)
 	
-	log.Println("🚀 Dual tracing enabled - same traces visible in both UIs!")
+	// Set global trace provider, optionally capturing stacks on slow spans
+	if threshold := slowSpanThreshold(); threshold > 0 {
+		configured.SlowSpanStackMs = threshold.Milliseconds()
+		otel.SetTracerProvider(NewSlowSpanTracerProvider(tp, threshold))
+		log.Printf("🐢 Capturing stack traces for spans slower than %s", threshold)
+	} else {
+		otel.SetTracerProvider(tp)
+	}
 	
-	// Return cleanup function
-	return func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down tracer: %v", err)
+	// Requests carry trace context and baggage in and out in every
+	// configured format
+	propagatorKinds := export.Propagators
+	if len(propagatorKinds) == 0 {
+		propagatorKinds = DefaultPropagators
+		if fromEnv, ok := propagatorsFromEnv(); ok {
+			propagatorKinds = fromEnv
 		}
 	}
-}
\ No newline at end of file
+	propagator, propagatorKinds := newPropagator(propagatorKinds)
+	otel.SetTextMapPropagator(propagator)
+	configured.Propagators = propagatorKinds
+	log.Printf("🔗 Propagating trace context as %v", propagatorKinds)
+	
+	if len(kinds) > 1 {
+		log.Println("🚀 Multi-backend tracing enabled - same traces visible in every backend!")
+	}
+	updateSettings(func(s *Settings) { s.Traces = configured })
+	
+	return &Provider{tp: tp, exports: counts}, nil
+}
+
+// serviceResource describes this service, plus extra attributes, for both
+// traces and metrics.
+func serviceResource(ctx context.Context, name string, extra ...attribute.KeyValue) (*resource.Resource, error) {
+	serviceAttrs := []attribute.KeyValue{
+		attrs.ServiceName(name),
+		attrs.ServiceVersion("v1.0.0"),
+	}
+	service, err := resource.New(ctx,
+		resource.WithSchemaURL(attrs.SchemaURL),
+		resource.WithAttributes(append(serviceAttrs, extra...)...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Merge(resource.Default(), service)
+}
@@ -0,0 +1,64 @@
+// Package tenant attributes requests to the tenant they were made for. The
+// tenant travels as the tenant.id baggage member, so it reaches every span
+// and log line the way other baggage does, and is handed on to downstream
+// services with trace context.
+package tenant
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/baggage"
+	"telemetry-demo/telemetry/attrs"
+)
+
+// maxIDLength bounds a tenant ID. It ends up in every span, log line, and
+// cache key of the tenant's requests.
+const maxIDLength = 64
+
+var ErrInvalidID = errors.New("tenant: ID must be 1 to 64 letters, digits, '.', '_', or '-'")
+
+// ValidID reports whether id can name a tenant.
+func ValidID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithBaggage returns bag with id as its tenant.id member, replacing any
+// tenant already in it.
+func WithBaggage(bag baggage.Baggage, id string) (baggage.Baggage, error) {
+	if !ValidID(id) {
+		return bag, ErrInvalidID
+	}
+	member, err := baggage.NewMember(string(attrs.TenantID), id)
+	if err != nil {
+		return bag, err
+	}
+	return bag.SetMember(member)
+}
+
+// ContextWithTenant returns ctx with id as the tenant.id member of its
+// baggage, keeping the other members.
+func ContextWithTenant(ctx context.Context, id string) (context.Context, error) {
+	bag, err := WithBaggage(baggage.FromContext(ctx), id)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// FromContext returns the tenant in ctx's baggage, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id := baggage.FromContext(ctx).Member(string(attrs.TenantID)).Value()
+	return id, id != ""
+}